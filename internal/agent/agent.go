@@ -69,6 +69,9 @@ type SessionAgent interface {
 	QueuedPrompts(sessionID string) int
 	QueuedPromptsList(sessionID string) []string
 	ClearQueue(sessionID string)
+	// QueueNotice saves a notice for the agent in the session, with the next
+	// step if it is running or right away otherwise. It never starts a run.
+	QueueNotice(sessionID, text string)
 	Summarize(context.Context, string, fantasy.ProviderOptions) error
 	Model() Model
 }
@@ -93,6 +96,7 @@ type sessionAgent struct {
 
 	messageQueue   *csync.Map[string, []SessionAgentCall]
 	activeRequests *csync.Map[string, context.CancelFunc]
+	notices        *noticeQueue
 }
 
 type SessionAgentOptions struct {
@@ -124,6 +128,7 @@ func NewSessionAgent(
 		isYolo:               opts.IsYolo,
 		messageQueue:         csync.NewMap[string, []SessionAgentCall](),
 		activeRequests:       csync.NewMap[string, context.CancelFunc](),
+		notices:              &noticeQueue{notices: make(map[string][]string)},
 	}
 }
 
@@ -189,6 +194,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	genCtx, cancel := context.WithCancel(ctx)
 	a.activeRequests.Set(call.SessionID, cancel)
 
+	// Notices that come in after the last step are saved once the session
+	// is no longer busy, so the next prompt picks them up from history.
+	defer a.saveNotices(call.SessionID)
 	defer cancel()
 	defer a.activeRequests.Del(call.SessionID)
 
@@ -199,6 +207,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 
 	var currentAssistant *message.Message
 	var shouldSummarize bool
+	var notices []sentNotice
 	result, err := agent.Stream(genCtx, fantasy.AgentStreamCall{
 		Prompt:           message.PromptWithTextAttachments(call.Prompt, call.Attachments),
 		Files:            files,
@@ -216,6 +225,18 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				prepared.Messages[i].ProviderOptions = nil
 			}
 
+			// Fantasy only keeps the response messages between steps, so
+			// notices are sent again with every later step, in the place
+			// they were first sent.
+			if pending := a.notices.take(call.SessionID); len(pending) > 0 {
+				noticeMsg, createErr := a.createNoticeMessage(callContext, call.SessionID, pending)
+				if createErr != nil {
+					return callContext, prepared, createErr
+				}
+				notices = append(notices, sentNotice{at: len(options.Messages), messages: noticeMsg.ToAIMessage()})
+			}
+			prepared.Messages = withNotices(prepared.Messages, notices)
+
 			queuedCalls, _ := a.messageQueue.Get(call.SessionID)
 			a.messageQueue.Del(call.SessionID)
 			for _, queued := range queuedCalls {
@@ -927,6 +948,88 @@ func (a *sessionAgent) QueuedPromptsList(sessionID string) []string {
 	return prompts
 }
 
+func (a *sessionAgent) QueueNotice(sessionID, text string) {
+	a.notices.push(sessionID, text)
+	if !a.IsSessionBusy(sessionID) {
+		a.saveNotices(sessionID)
+	}
+}
+
+// saveNotices saves the queued notices of the session as a message, so the
+// next prompt sends them with the rest of the history.
+func (a *sessionAgent) saveNotices(sessionID string) {
+	notices := a.notices.take(sessionID)
+	if len(notices) == 0 {
+		return
+	}
+	if _, err := a.createNoticeMessage(context.Background(), sessionID, notices); err != nil {
+		slog.Error("Failed to save notices", "session_id", sessionID, "error", err)
+	}
+}
+
+func (a *sessionAgent) createNoticeMessage(ctx context.Context, sessionID string, notices []string) (message.Message, error) {
+	return a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: noticeText(notices)}},
+	})
+}
+
+// noticeQueue holds the notices of every session until they are saved.
+type noticeQueue struct {
+	mu      sync.Mutex
+	notices map[string][]string
+}
+
+func (q *noticeQueue) push(sessionID, text string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.notices[sessionID] = append(q.notices[sessionID], text)
+}
+
+// take removes and returns the notices of the session.
+func (q *noticeQueue) take(sessionID string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	notices := q.notices[sessionID]
+	delete(q.notices, sessionID)
+	return notices
+}
+
+// noticeText wraps the notices so the model doesn't take them for something
+// the user said.
+func noticeText(notices []string) string {
+	var sb strings.Builder
+	sb.WriteString("<system_notice>\n")
+	for _, notice := range notices {
+		sb.WriteString(notice)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</system_notice>")
+	return sb.String()
+}
+
+// sentNotice is a notice message saved during a run, with the number of step
+// messages it followed.
+type sentNotice struct {
+	at       int
+	messages []fantasy.Message
+}
+
+// withNotices inserts the notices into the step messages.
+func withNotices(messages []fantasy.Message, notices []sentNotice) []fantasy.Message {
+	if len(notices) == 0 {
+		return messages
+	}
+	result := make([]fantasy.Message, 0, len(messages)+len(notices))
+	prev := 0
+	for _, notice := range notices {
+		result = append(result, messages[prev:notice.at]...)
+		result = append(result, notice.messages...)
+		prev = notice.at
+	}
+	return append(result, messages[prev:]...)
+}
+
 func (a *sessionAgent) SetModels(large Model, small Model) {
 	a.largeModel = large
 	a.smallModel = small
@@ -1047,10 +1150,14 @@ func (a *sessionAgent) workaroundProviderMediaLimitations(messages []fantasy.Mes
 					Filename:  fmt.Sprintf("tool-result-%s", toolResult.ToolCallID),
 				})
 
+				text := "[Image/media content loaded - see attached file]"
+				if media.Text != "" {
+					text = media.Text + "\n\n" + text
+				}
 				textParts = append(textParts, fantasy.ToolResultPart{
 					ToolCallID: toolResult.ToolCallID,
 					Output: fantasy.ToolResultOutputContentText{
-						Text: "[Image/media content loaded - see attached file]",
+						Text: text,
 					},
 					ProviderOptions: toolResult.ProviderOptions,
 				})
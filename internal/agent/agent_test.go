@@ -1,15 +1,20 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"charm.land/fantasy"
 	"charm.land/x/vcr"
 	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -619,3 +624,113 @@ func TestCoderAgent(t *testing.T) {
 		})
 	}
 }
+
+// scriptedModel streams one scripted step per call and records the prompts
+// it was sent.
+type scriptedModel struct {
+	mu      sync.Mutex
+	steps   [][]fantasy.StreamPart
+	prompts []fantasy.Prompt
+}
+
+func (m *scriptedModel) Stream(_ context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = append(m.prompts, call.Prompt)
+	parts := []fantasy.StreamPart{{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonStop}}
+	if len(m.steps) > 0 {
+		parts, m.steps = m.steps[0], m.steps[1:]
+	}
+	return slices.Values(parts), nil
+}
+
+func (m *scriptedModel) Generate(context.Context, fantasy.Call) (*fantasy.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *scriptedModel) GenerateObject(context.Context, fantasy.ObjectCall) (*fantasy.ObjectResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *scriptedModel) StreamObject(context.Context, fantasy.ObjectCall) (fantasy.ObjectStreamResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *scriptedModel) Provider() string { return "scripted" }
+func (m *scriptedModel) Model() string    { return "scripted" }
+
+func toolCallStep(id string) []fantasy.StreamPart {
+	return []fantasy.StreamPart{
+		{Type: fantasy.StreamPartTypeToolCall, ID: id, ToolCallName: "notify", ToolCallInput: "{}"},
+		{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonToolCalls},
+	}
+}
+
+func promptText(prompt fantasy.Prompt) string {
+	var sb strings.Builder
+	for _, msg := range prompt {
+		for _, part := range msg.Content {
+			if text, ok := part.(fantasy.TextPart); ok {
+				sb.WriteString(text.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestSessionAgent_Notices(t *testing.T) {
+	t.Parallel()
+
+	env := testEnv(t)
+	_, err := config.Init(env.workingDir, "", false)
+	require.NoError(t, err)
+	sess, err := env.sessions.Create(t.Context(), "New Session")
+	require.NoError(t, err)
+
+	large := &scriptedModel{steps: [][]fantasy.StreamPart{toolCallStep("call-1"), toolCallStep("call-2")}}
+	var agent SessionAgent
+	notify := fantasy.NewAgentTool("notify", "Queues a notice.", func(ctx context.Context, _ struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		if call.ID == "call-1" {
+			agent.QueueNotice(sess.ID, "job 1 finished")
+		}
+		return fantasy.NewTextResponse("ok"), nil
+	})
+	agent = testSessionAgent(env, large, &scriptedModel{}, "system", notify)
+
+	_, err = agent.Run(t.Context(), SessionAgentCall{SessionID: sess.ID, Prompt: "hello", MaxOutputTokens: 100})
+	require.NoError(t, err)
+
+	require.Len(t, large.prompts, 3)
+	require.NotContains(t, promptText(large.prompts[0]), "job 1 finished")
+	require.Contains(t, promptText(large.prompts[1]), "<system_notice>\njob 1 finished\n</system_notice>")
+	require.Contains(t, promptText(large.prompts[2]), "job 1 finished", "notices are kept for the next steps")
+
+	// Notices for a session that isn't running are saved right away.
+	agent.QueueNotice(sess.ID, "job 2 finished")
+
+	msgs, err := env.messages.List(t.Context(), sess.ID)
+	require.NoError(t, err)
+	var notices []string
+	for _, msg := range msgs {
+		if msg.Role == message.User && strings.HasPrefix(msg.Content().Text, "<system_notice>") {
+			notices = append(notices, msg.Content().Text)
+		}
+	}
+	require.Equal(t, []string{
+		"<system_notice>\njob 1 finished\n</system_notice>",
+		"<system_notice>\njob 2 finished\n</system_notice>",
+	}, notices)
+}
+
+func TestWithNotices(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := fantasy.NewUserMessage("a"), fantasy.NewUserMessage("b"), fantasy.NewUserMessage("c")
+	n1, n2 := fantasy.NewUserMessage("n1"), fantasy.NewUserMessage("n2")
+
+	require.Equal(t, []fantasy.Message{a, b}, withNotices([]fantasy.Message{a, b}, nil))
+	require.Equal(t, []fantasy.Message{a, n1, b, c, n2}, withNotices([]fantasy.Message{a, b, c}, []sentNotice{
+		{at: 1, messages: []fantasy.Message{n1}},
+		{at: 3, messages: []fantasy.Message{n2}},
+	}))
+}
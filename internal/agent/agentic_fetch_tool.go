@@ -166,7 +166,7 @@ func (c *coordinator) agenticFetchTool(_ context.Context, client *http.Client) (
 				tools.NewGlobTool(tmpDir),
 				tools.NewGrepTool(tmpDir),
 				tools.NewSourcegraphTool(client),
-				tools.NewViewTool(c.lspClients, c.permissions, tmpDir),
+				tools.NewViewTool(c.lspClients, c.touchLSPs, c.permissions, c.fileTracker, tmpDir),
 			}
 
 			agent := NewSessionAgent(SessionAgentOptions{
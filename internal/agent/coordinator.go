@@ -22,6 +22,7 @@ import (
 	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/lsp"
@@ -52,6 +53,9 @@ type Coordinator interface {
 	QueuedPrompts(sessionID string) int
 	QueuedPromptsList(sessionID string) []string
 	ClearQueue(sessionID string)
+	// QueueNotice queues a notice for the agent, sent with the next step of
+	// the session, see [SessionAgent.QueueNotice].
+	QueueNotice(sessionID, text string)
 	Summarize(context.Context, string) error
 	Model() Model
 	UpdateModels(ctx context.Context) error
@@ -63,7 +67,9 @@ type coordinator struct {
 	messages    message.Service
 	permissions permission.Service
 	history     history.Service
+	fileTracker filetracker.Service
 	lspClients  *csync.Map[string, *lsp.Client]
+	touchLSPs   tools.LSPToucher
 
 	currentAgent SessionAgent
 	agents       map[string]SessionAgent
@@ -78,7 +84,9 @@ func NewCoordinator(
 	messages message.Service,
 	permissions permission.Service,
 	history history.Service,
+	fileTracker filetracker.Service,
 	lspClients *csync.Map[string, *lsp.Client],
+	touchLSPs tools.LSPToucher,
 ) (Coordinator, error) {
 	c := &coordinator{
 		cfg:         cfg,
@@ -86,7 +94,9 @@ func NewCoordinator(
 		messages:    messages,
 		permissions: permissions,
 		history:     history,
+		fileTracker: fileTracker,
 		lspClients:  lspClients,
+		touchLSPs:   touchLSPs,
 		agents:      make(map[string]SessionAgent),
 	}
 
@@ -377,25 +387,45 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 		}
 	}
 
+	formatter := tools.NewFormatter(c.cfg.Format, c.lspClients, c.cfg.WorkingDir())
 	allTools = append(allTools,
-		tools.NewBashTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Options.Attribution, modelName),
+		tools.NewBashTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Options.Attribution, modelName, c.cfg.Tools.Bash),
 		tools.NewJobOutputTool(),
 		tools.NewJobKillTool(),
 		tools.NewDownloadTool(c.permissions, c.cfg.WorkingDir(), nil),
-		tools.NewEditTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
-		tools.NewMultiEditTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
+		tools.NewEditTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, formatter, c.cfg.WorkingDir()),
+		tools.NewMultiEditTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, formatter, c.cfg.WorkingDir()),
+		tools.NewEditLinesTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, formatter, c.cfg.WorkingDir()),
+		tools.NewNotebookEditTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
 		tools.NewFetchTool(c.permissions, c.cfg.WorkingDir(), nil),
 		tools.NewGlobTool(c.cfg.WorkingDir()),
 		tools.NewGrepTool(c.cfg.WorkingDir()),
 		tools.NewLsTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Tools.Ls),
 		tools.NewSourcegraphTool(nil),
 		tools.NewTodosTool(c.sessions),
-		tools.NewViewTool(c.lspClients, c.permissions, c.cfg.WorkingDir()),
-		tools.NewWriteTool(c.lspClients, c.permissions, c.history, c.cfg.WorkingDir()),
+		tools.NewViewTool(c.lspClients, c.touchLSPs, c.permissions, c.fileTracker, c.cfg.WorkingDir()),
+		tools.NewWriteTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, formatter, c.cfg.WorkingDir()),
+		tools.NewApplyPatchTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, formatter, c.cfg.WorkingDir()),
+		tools.NewMoveTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
+		tools.NewCopyTool(c.lspClients, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
+		tools.NewDeleteTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
 	)
 
 	if len(c.cfg.LSP) > 0 {
-		allTools = append(allTools, tools.NewDiagnosticsTool(c.lspClients), tools.NewReferencesTool(c.lspClients))
+		allTools = append(allTools,
+			tools.NewDiagnosticsTool(c.lspClients, c.touchLSPs),
+			tools.NewReferencesTool(c.lspClients, c.touchLSPs),
+			tools.NewDefinitionTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewTypeDefinitionTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewImplementationTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewHoverTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewDocumentSymbolsTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewWorkspaceSymbolsTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewRenameTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
+			tools.NewCodeActionTool(c.lspClients, c.touchLSPs, c.permissions, c.history, c.fileTracker, c.cfg.WorkingDir()),
+			tools.NewCallHierarchyTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+			tools.NewTypeHierarchyTool(c.lspClients, c.touchLSPs, c.cfg.WorkingDir()),
+		)
 	}
 
 	var filteredTools []fantasy.AgentTool
@@ -817,6 +847,10 @@ func (c *coordinator) QueuedPromptsList(sessionID string) []string {
 	return c.currentAgent.QueuedPromptsList(sessionID)
 }
 
+func (c *coordinator) QueueNotice(sessionID, text string) {
+	c.currentAgent.QueueNotice(sessionID, text)
+}
+
 func (c *coordinator) Summarize(ctx context.Context, sessionID string) error {
 	providerCfg, ok := c.cfg.Providers.Get(c.currentAgent.Model().ModelCfg.Provider)
 	if !ok {
@@ -0,0 +1,372 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type ApplyPatchParams struct {
+	Patch string `json:"patch" description:"The patch to apply, as a unified diff or in the *** Begin Patch format"`
+}
+
+type ApplyPatchPermissionsParams struct {
+	Files []WorkspaceFileChange `json:"files"`
+}
+
+type ApplyPatchResponseMetadata struct {
+	Files     []string `json:"files"`
+	Additions int      `json:"additions"`
+	Removals  int      `json:"removals"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
+}
+
+const ApplyPatchToolName = "apply_patch"
+
+//go:embed apply_patch.md
+var applyPatchDescription []byte
+
+// patchChange is the change a patch makes to a file, ready to be written.
+// Its contents use LF line endings, the ones of the file are restored when
+// writing it.
+type patchChange struct {
+	WorkspaceFileChange
+	action patchAction
+	mode   os.FileMode
+	format fsext.TextFormat
+	crlf   bool
+	// note tells the model about the changes made on disk since the file
+	// was read, which were merged with the patch.
+	note string
+}
+
+func NewApplyPatchTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, formatter *Formatter, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		ApplyPatchToolName,
+		string(applyPatchDescription),
+		func(ctx context.Context, params ApplyPatchParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if strings.TrimSpace(params.Patch) == "" {
+				return fantasy.NewTextErrorResponse("patch is required"), nil
+			}
+
+			patches, err := parsePatch(params.Patch)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid patch: %s", err)), nil
+			}
+			changes, err := planPatch(ctx, tracker, patches, workingDir)
+			var conflict *mergeConflict
+			if errors.As(err, &conflict) {
+				return conflict.response(fmt.Sprintf("the patch was not applied: %s", err)), nil
+			}
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("the patch was not applied: %s", err)), nil
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for applying patches")
+			}
+
+			fileChanges := make([]WorkspaceFileChange, len(changes))
+			paths := make([]string, 0, len(changes))
+			for i, change := range changes {
+				fileChanges[i] = change.WorkspaceFileChange
+				paths = append(paths, change.finalPath())
+			}
+			defer touchLSPs.use(ctx, paths...)()
+			snapshot := snapshotDiagnostics(ctx, lspClients, paths...)
+
+			p := permissions.Request(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    ApplyPatchToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Apply patch to %d file(s)", len(changes)),
+				Params: ApplyPatchPermissionsParams{
+					Files: fileChanges,
+				},
+			})
+			if !p {
+				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+			}
+
+			for _, change := range changes {
+				if change.action != patchDelete {
+					defer tracker.Writing(change.finalPath())()
+				}
+			}
+			if err := writePatchChanges(changes); err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("the patch was not applied: %s", err)), nil
+			}
+
+			var notes strings.Builder
+			var historyChanges []history.FileChange
+			var changedPaths []string
+			for i := range changes {
+				change := &changes[i]
+				path := change.finalPath()
+				if change.action != patchDelete {
+					content, note := formatter.formatChange(ctx, path, change.OldContent, change.NewContent, change.format, change.crlf, nil, &change.Additions, &change.Removals)
+					change.NewContent = content
+					notes.WriteString(change.note + note)
+					recordFileWrite(ctx, tracker, path)
+					changedPaths = append(changedPaths, path)
+				}
+				historyChanges = append(historyChanges, change.historyChanges()...)
+			}
+			if _, err := files.RecordChanges(ctx, sessionID, historyChanges); err != nil {
+				slog.Error("Error recording file history", "error", err)
+			}
+
+			notifyLSPsOfFiles(ctx, lspClients, changedPaths)
+
+			var totalAdditions, totalRemovals int
+			relPaths := make([]string, len(changes))
+			for i, change := range changes {
+				fileChanges[i] = change.WorkspaceFileChange
+				totalAdditions += change.Additions
+				totalRemovals += change.Removals
+				relPaths[i] = relativePath(workingDir, change.finalPath())
+			}
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "<result>\nApplied patch to %d file(s):\n", len(changes))
+			output.WriteString(formatWorkspaceChanges(fileChanges, workingDir))
+			output.WriteString(strings.TrimPrefix(notes.String(), "\n"))
+			output.WriteString("</result>\n")
+			diagnostics, delta := snapshot.compare(lspClients)
+			output.WriteString(diagnostics)
+
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse(output.String()),
+				ApplyPatchResponseMetadata{
+					Files:       relPaths,
+					Additions:   totalAdditions,
+					Removals:    totalRemovals,
+					Diagnostics: delta,
+				},
+			), nil
+		})
+}
+
+// planPatch checks every file operation and hunk of the patch against the
+// files on disk and returns the resulting changes, without writing anything.
+func planPatch(ctx context.Context, tracker filetracker.Service, patches []filePatch, workingDir string) ([]patchChange, error) {
+	seen := make(map[string]bool)
+	claim := func(path string) error {
+		if !fsext.HasPrefix(path, workingDir) {
+			return fmt.Errorf("%s is outside of the working directory", path)
+		}
+		if seen[path] {
+			return fmt.Errorf("%s is changed more than once, combine its changes into a single file section", path)
+		}
+		seen[path] = true
+		return nil
+	}
+
+	changes := make([]patchChange, 0, len(patches))
+	for _, patch := range patches {
+		if patch.path == "" {
+			return nil, fmt.Errorf("a file operation has no path")
+		}
+		path := filepathext.SmartJoin(workingDir, patch.path)
+		if err := claim(path); err != nil {
+			return nil, err
+		}
+		change := patchChange{
+			WorkspaceFileChange: WorkspaceFileChange{FilePath: path},
+			action:              patch.action,
+			mode:                0o644,
+		}
+
+		switch patch.action {
+		case patchAdd:
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("cannot add %s, it already exists", path)
+			}
+			change.NewContent = patch.content
+		case patchUpdate, patchDelete:
+			content, base, format, mode, err := readPatchTarget(ctx, tracker, path)
+			if err != nil {
+				return nil, err
+			}
+			content, change.crlf = fsext.ToUnixLineEndings(content)
+			base, _ = fsext.ToUnixLineEndings(base)
+			change.OldContent, change.format, change.mode = content, format, mode
+			if patch.action == patchDelete {
+				if base != content {
+					return nil, fmt.Errorf("%s has been modified since it was last read, read it again before deleting it", path)
+				}
+				break
+			}
+
+			newContent, err := applyHunks(base, patch.hunks)
+			if err != nil {
+				return nil, fmt.Errorf("cannot update %s: %w", path, err)
+			}
+			if base != content {
+				merged, note, conflict := mergeDiskChanges(path, base, newContent, content)
+				if conflict != nil {
+					return nil, conflict
+				}
+				newContent, change.note = merged, note
+			}
+			change.NewContent = newContent
+
+			if patch.movePath != "" {
+				newPath := filepathext.SmartJoin(workingDir, patch.movePath)
+				if err := claim(newPath); err != nil {
+					return nil, err
+				}
+				if _, err := os.Stat(newPath); err == nil {
+					return nil, fmt.Errorf("cannot move %s to %s, it already exists", path, newPath)
+				}
+				change.NewPath = newPath
+			} else if newContent == content {
+				return nil, fmt.Errorf("the patch doesn't change %s", path)
+			}
+		}
+
+		_, change.Additions, change.Removals = diff.GenerateDiff(change.OldContent, change.NewContent, relativePath(workingDir, change.finalPath()))
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// readPatchTarget reads a file the patch updates or deletes, which must have
+// been read. It returns the current content and the content the patch
+// applies to, which is the content last read when the file changed since,
+// and the format to write the file back in.
+func readPatchTarget(ctx context.Context, tracker filetracker.Service, path string) (content, base string, format fsext.TextFormat, mode os.FileMode, err error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", "", format, 0, fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return "", "", format, 0, err
+	}
+	if info.IsDir() {
+		return "", "", format, 0, fmt.Errorf("%s is a directory, not a file", path)
+	}
+	status, err := fileStatus(ctx, tracker, path)
+	if err != nil {
+		return "", "", format, 0, err
+	}
+	if status == filetracker.StatusUnread {
+		return "", "", format, 0, fmt.Errorf("you must read %s before changing it. Use the View tool first", path)
+	}
+	content, format, message, err := readText(path)
+	if err != nil {
+		return "", "", format, 0, err
+	}
+	if message != "" {
+		return "", "", format, 0, errors.New(message)
+	}
+	base = content
+	if status == filetracker.StatusChanged {
+		var ok bool
+		base, ok, err = tracker.Base(ctx, GetSessionFromContext(ctx), path)
+		if err != nil {
+			return "", "", format, 0, fmt.Errorf("error reading file history: %w", err)
+		}
+		if !ok {
+			return "", "", format, 0, fmt.Errorf("%s has been modified since it was last read, read it again before changing it", path)
+		}
+	}
+	return content, base, format, info.Mode().Perm(), nil
+}
+
+// historyChanges returns the history entries of the change. A moved file is
+// also recorded as emptied at its old path.
+func (c patchChange) historyChanges() []history.FileChange {
+	var changes []history.FileChange
+	if c.NewPath != "" {
+		changes = append(changes, history.FileChange{Path: c.FilePath, OldContent: c.OldContent})
+	}
+	return append(changes, history.FileChange{Path: c.finalPath(), OldContent: c.OldContent, NewContent: c.NewContent})
+}
+
+// writePatchChanges writes all changes, undoing the ones already written if
+// one of them fails.
+func writePatchChanges(changes []patchChange) error {
+	for i, change := range changes {
+		if err := writePatchChange(change); err != nil {
+			for j := i; j >= 0; j-- {
+				if undoErr := undoPatchChange(changes[j]); undoErr != nil {
+					slog.Error("Error undoing patch change", "file", changes[j].FilePath, "error", undoErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func writePatchChange(change patchChange) error {
+	if change.action == patchDelete {
+		return os.Remove(change.FilePath)
+	}
+	path := change.finalPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := writePatchText(path, change.NewContent, change); err != nil {
+		return err
+	}
+	if change.NewPath != "" {
+		if err := os.Chmod(path, change.mode); err != nil {
+			return err
+		}
+		return os.Remove(change.FilePath)
+	}
+	return nil
+}
+
+// writePatchText writes content to a file of the change in the format and
+// with the line endings the file was read in.
+func writePatchText(path, content string, change patchChange) error {
+	message, err := writeText(path, withLineEndings(content, change.crlf), change.format)
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+func undoPatchChange(change patchChange) error {
+	if change.action == patchAdd {
+		if err := os.Remove(change.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if change.NewPath != "" {
+		if err := os.Remove(change.NewPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := writePatchText(change.FilePath, change.OldContent, change); err != nil {
+		return err
+	}
+	// Deleted and moved files are created again.
+	return os.Chmod(change.FilePath, change.mode)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ type BashParams struct {
 	Command         string `json:"command" description:"The command to execute"`
 	WorkingDir      string `json:"working_dir,omitempty" description:"The working directory to execute the command in (defaults to current directory)"`
 	RunInBackground bool   `json:"run_in_background,omitempty" description:"Set to true (boolean) to run this command in the background. Use job_output to read the output later."`
+	ResetShell      bool   `json:"reset_shell,omitempty" description:"Set to true (boolean) to reset the persistent shell's working directory, environment variables and functions before running the command."`
 }
 
 type BashPermissionsParams struct {
@@ -31,6 +33,10 @@ type BashPermissionsParams struct {
 	Command         string `json:"command"`
 	WorkingDir      string `json:"working_dir"`
 	RunInBackground bool   `json:"run_in_background"`
+	ResetShell      bool   `json:"reset_shell"`
+	// Sandbox describes the restrictions the command runs with, it is empty
+	// when the sandbox is disabled.
+	Sandbox string `json:"sandbox,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -60,93 +66,25 @@ var bashDescriptionTpl = template.Must(
 )
 
 type bashDescriptionData struct {
-	BannedCommands  string
-	MaxOutputLength int
-	Attribution     config.Attribution
-	ModelName       string
+	BannedCommands   string
+	BlockedArguments string
+	MaxOutputLength  int
+	Attribution      config.Attribution
+	ModelName        string
+	Sandbox          *shell.Sandbox
+	PersistentShell  bool
 }
 
-var bannedCommands = []string{
-	// Network/Download tools
-	"alias",
-	"aria2c",
-	"axel",
-	"chrome",
-	"curl",
-	"curlie",
-	"firefox",
-	"http-prompt",
-	"httpie",
-	"links",
-	"lynx",
-	"nc",
-	"safari",
-	"scp",
-	"ssh",
-	"telnet",
-	"w3m",
-	"wget",
-	"xh",
-
-	// System administration
-	"doas",
-	"su",
-	"sudo",
-
-	// Package managers
-	"apk",
-	"apt",
-	"apt-cache",
-	"apt-get",
-	"dnf",
-	"dpkg",
-	"emerge",
-	"home-manager",
-	"makepkg",
-	"opkg",
-	"pacman",
-	"paru",
-	"pkg",
-	"pkg_add",
-	"pkg_delete",
-	"portage",
-	"rpm",
-	"yay",
-	"yum",
-	"zypper",
-
-	// System modification
-	"at",
-	"batch",
-	"chkconfig",
-	"crontab",
-	"fdisk",
-	"mkfs",
-	"mount",
-	"parted",
-	"service",
-	"systemctl",
-	"umount",
-
-	// Network configuration
-	"firewall-cmd",
-	"ifconfig",
-	"ip",
-	"iptables",
-	"netstat",
-	"pfctl",
-	"route",
-	"ufw",
-}
-
-func bashDescription(attribution *config.Attribution, modelName string) string {
-	bannedCommandsStr := strings.Join(bannedCommands, ", ")
+func bashDescription(attribution *config.Attribution, modelName string, bashConfig config.ToolBash, sandbox *shell.Sandbox) string {
 	var out bytes.Buffer
 	if err := bashDescriptionTpl.Execute(&out, bashDescriptionData{
-		BannedCommands:  bannedCommandsStr,
-		MaxOutputLength: MaxOutputLength,
-		Attribution:     *attribution,
-		ModelName:       modelName,
+		BannedCommands:   strings.Join(bashConfig.EffectiveBannedCommands(), ", "),
+		BlockedArguments: strings.Join(bashConfig.EffectiveBlockedArguments(), ", "),
+		MaxOutputLength:  MaxOutputLength,
+		Attribution:      *attribution,
+		ModelName:        modelName,
+		Sandbox:          sandbox,
+		PersistentShell:  bashConfig.PersistentShell,
 	}); err != nil {
 		// this should never happen.
 		panic("failed to execute bash description template: " + err.Error())
@@ -154,49 +92,33 @@ func bashDescription(attribution *config.Attribution, modelName string) string {
 	return out.String()
 }
 
-func blockFuncs() []shell.BlockFunc {
-	return []shell.BlockFunc{
-		shell.CommandsBlocker(bannedCommands),
-
-		// System package managers
-		shell.ArgumentsBlocker("apk", []string{"add"}, nil),
-		shell.ArgumentsBlocker("apt", []string{"install"}, nil),
-		shell.ArgumentsBlocker("apt-get", []string{"install"}, nil),
-		shell.ArgumentsBlocker("dnf", []string{"install"}, nil),
-		shell.ArgumentsBlocker("pacman", nil, []string{"-S"}),
-		shell.ArgumentsBlocker("pkg", []string{"install"}, nil),
-		shell.ArgumentsBlocker("yum", []string{"install"}, nil),
-		shell.ArgumentsBlocker("zypper", []string{"install"}, nil),
-
-		// Language-specific package managers
-		shell.ArgumentsBlocker("brew", []string{"install"}, nil),
-		shell.ArgumentsBlocker("cargo", []string{"install"}, nil),
-		shell.ArgumentsBlocker("gem", []string{"install"}, nil),
-		shell.ArgumentsBlocker("go", []string{"install"}, nil),
-		shell.ArgumentsBlocker("npm", []string{"install"}, []string{"--global"}),
-		shell.ArgumentsBlocker("npm", []string{"install"}, []string{"-g"}),
-		shell.ArgumentsBlocker("pip", []string{"install"}, []string{"--user"}),
-		shell.ArgumentsBlocker("pip3", []string{"install"}, []string{"--user"}),
-		shell.ArgumentsBlocker("pnpm", []string{"add"}, []string{"--global"}),
-		shell.ArgumentsBlocker("pnpm", []string{"add"}, []string{"-g"}),
-		shell.ArgumentsBlocker("yarn", []string{"global", "add"}, nil),
-
-		// `go test -exec` can run arbitrary commands
-		shell.ArgumentsBlocker("go", []string{"test"}, []string{"-exec"}),
+func blockFuncs(bashConfig config.ToolBash) []shell.BlockFunc {
+	funcs := []shell.BlockFunc{
+		shell.CommandsBlocker(bashConfig.EffectiveBannedCommands()),
 	}
+	for _, pattern := range bashConfig.EffectiveBlockedArguments() {
+		funcs = append(funcs, shell.PatternBlocker(pattern))
+	}
+	return funcs
 }
 
-func NewBashTool(permissions permission.Service, workingDir string, attribution *config.Attribution, modelName string) fantasy.AgentTool {
-	return fantasy.NewAgentTool(
+func NewBashTool(permissions permission.Service, workingDir string, attribution *config.Attribution, modelName string, bashConfig config.ToolBash) fantasy.AgentTool {
+	sandbox := bashConfig.ShellSandbox(workingDir)
+	blockers := blockFuncs(bashConfig)
+	tool := fantasy.NewAgentTool(
 		BashToolName,
-		string(bashDescription(attribution, modelName)),
+		string(bashDescription(attribution, modelName, bashConfig, sandbox)),
 		func(ctx context.Context, params BashParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
 			if params.Command == "" {
 				return fantasy.NewTextErrorResponse("missing command"), nil
 			}
-
-			// Determine working directory
-			execWorkingDir := cmp.Or(params.WorkingDir, workingDir)
+			if sandbox != nil {
+				// Refuse upfront rather than ask for a command that can't run
+				// with the configured restrictions.
+				if err := sandbox.Check(); err != nil {
+					return fantasy.NewTextErrorResponse(err.Error()), nil
+				}
+			}
 
 			isSafeReadOnly := false
 			cmdLower := strings.ToLower(params.Command)
@@ -214,6 +136,31 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 			if sessionID == "" {
 				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for executing shell command")
 			}
+
+			sessionShells := shell.GetSessionShellManager()
+			if params.ResetShell {
+				sessionShells.Reset(sessionID)
+			}
+			shellOpts := &shell.Options{
+				WorkingDir: cmp.Or(params.WorkingDir, workingDir),
+				BlockFuncs: blockers,
+				Sandbox:    sandbox,
+			}
+			sh := shell.NewShell(shellOpts)
+			if bashConfig.PersistentShell {
+				sh = sessionShells.Checkout(sessionID, shellOpts)
+				if params.WorkingDir != "" {
+					if err := sh.SetWorkingDir(params.WorkingDir); err != nil {
+						return fantasy.NewTextErrorResponse(err.Error()), nil
+					}
+				}
+			}
+			if isSafeReadOnly && sh.Customized() {
+				// A function or PATH of the persistent shell can make a safe
+				// command name run anything.
+				isSafeReadOnly = false
+			}
+			execWorkingDir := sh.GetWorkingDir()
 			if !isSafeReadOnly {
 				p := permissions.Request(
 					permission.CreatePermissionRequest{
@@ -223,7 +170,14 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 						ToolName:    BashToolName,
 						Action:      "execute",
 						Description: fmt.Sprintf("Execute command: %s", params.Command),
-						Params:      BashPermissionsParams(params),
+						Params: BashPermissionsParams{
+							Description:     params.Description,
+							Command:         params.Command,
+							WorkingDir:      params.WorkingDir,
+							RunInBackground: params.RunInBackground,
+							ResetShell:      params.ResetShell,
+							Sandbox:         sandboxSummary(sandbox),
+						},
 					},
 				)
 				if !p {
@@ -237,7 +191,7 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 				bgManager := shell.GetBackgroundShellManager()
 				bgManager.Cleanup()
 				// Use background context so it continues after tool returns
-				bgShell, err := bgManager.Start(context.Background(), execWorkingDir, blockFuncs(), params.Command, params.Description)
+				bgShell, err := bgManager.StartShell(context.Background(), sessionID, sh, params.Command, params.Description)
 				if err != nil {
 					return fantasy.ToolResponse{}, fmt.Errorf("error starting background shell: %w", err)
 				}
@@ -257,6 +211,7 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 					}
 
 					stdout = formatOutput(stdout, stderr, execErr)
+					stdout = withSandboxNote(stdout, sandbox, execErr)
 
 					metadata := BashResponseMetadata{
 						StartTime:        startTime.UnixMilli(),
@@ -274,6 +229,7 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 				}
 
 				// Still running after fast-failure check - return as background job
+				bgShell.Detach()
 				metadata := BashResponseMetadata{
 					StartTime:        startTime.UnixMilli(),
 					EndTime:          time.Now().UnixMilli(),
@@ -292,7 +248,7 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 			// Start with detached context so it can survive if moved to background
 			bgManager := shell.GetBackgroundShellManager()
 			bgManager.Cleanup()
-			bgShell, err := bgManager.Start(context.Background(), execWorkingDir, blockFuncs(), params.Command, params.Description)
+			bgShell, err := bgManager.StartShell(context.Background(), sessionID, sh, params.Command, params.Description)
 			if err != nil {
 				return fantasy.ToolResponse{}, fmt.Errorf("error starting shell: %w", err)
 			}
@@ -337,7 +293,16 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 					return fantasy.ToolResponse{}, fmt.Errorf("[Job %s] error executing command: %w", bgShell.ID, execErr)
 				}
 
+				// Keep the state the command left the shell in for the next
+				// command of the session.
+				cwd := bgShell.WorkingDir
+				if bashConfig.PersistentShell && !interrupted {
+					sessionShells.Save(sessionID, bgShell.Shell)
+					cwd = bgShell.Shell.GetWorkingDir()
+				}
+
 				stdout = formatOutput(stdout, stderr, execErr)
+				stdout = withSandboxNote(stdout, sandbox, execErr)
 
 				metadata := BashResponseMetadata{
 					StartTime:        startTime.UnixMilli(),
@@ -345,16 +310,17 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 					Output:           stdout,
 					Description:      params.Description,
 					Background:       params.RunInBackground,
-					WorkingDirectory: bgShell.WorkingDir,
+					WorkingDirectory: cwd,
 				}
 				if stdout == "" {
 					return fantasy.WithResponseMetadata(fantasy.NewTextResponse(BashNoOutput), metadata), nil
 				}
-				stdout += fmt.Sprintf("\n\n<cwd>%s</cwd>", normalizeWorkingDir(bgShell.WorkingDir))
+				stdout += fmt.Sprintf("\n\n<cwd>%s</cwd>", normalizeWorkingDir(cwd))
 				return fantasy.WithResponseMetadata(fantasy.NewTextResponse(stdout), metadata), nil
 			}
 
 			// Still running - keep as background job
+			bgShell.Detach()
 			metadata := BashResponseMetadata{
 				StartTime:        startTime.UnixMilli(),
 				EndTime:          time.Now().UnixMilli(),
@@ -366,6 +332,40 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 			response := fmt.Sprintf("Command is taking longer than expected and has been moved to background.\n\nBackground shell ID: %s\n\nUse job_output tool to view output or job_kill to terminate.", bgShell.ID)
 			return fantasy.WithResponseMetadata(fantasy.NewTextResponse(response), metadata), nil
 		})
+	if !bashConfig.PersistentShell {
+		// Without a persistent shell there is nothing to reset.
+		return hiddenParamTool{AgentTool: tool, param: "reset_shell"}
+	}
+	return tool
+}
+
+// hiddenParamTool leaves a parameter out of the schema of a tool, for
+// parameters that don't apply to the tool's configuration.
+type hiddenParamTool struct {
+	fantasy.AgentTool
+	param string
+}
+
+func (t hiddenParamTool) Info() fantasy.ToolInfo {
+	info := t.AgentTool.Info()
+	delete(info.Parameters, t.param)
+	info.Required = slices.DeleteFunc(slices.Clone(info.Required), func(name string) bool {
+		return name == t.param
+	})
+	return info
+}
+
+// withSandboxNote explains failures of sandboxed commands, which otherwise
+// only show up as generic permission errors.
+func withSandboxNote(output string, sandbox *shell.Sandbox, execErr error) string {
+	if sandbox == nil || shell.ExitCode(execErr) == 0 || shell.IsInterrupt(execErr) {
+		return output
+	}
+	note := fmt.Sprintf("Note: the command ran in a sandbox and %s. Permission errors outside these paths are caused by the sandbox.", sandboxSummary(sandbox))
+	if output == "" {
+		return note
+	}
+	return output + "\n" + note
 }
 
 // formatOutput formats the output of a completed command with error handling
@@ -437,3 +437,15 @@ func normalizeWorkingDir(path string) string {
 
 	return filepath.ToSlash(path)
 }
+
+// sandboxSummary describes what commands run in sandbox can do.
+func sandboxSummary(sandbox *shell.Sandbox) string {
+	if sandbox == nil {
+		return ""
+	}
+	summary := "can only write to " + strings.Join(sandbox.WritablePaths, ", ")
+	if !sandbox.AllowNetwork {
+		summary += ", without network access"
+	}
+	return summary
+}
@@ -8,19 +8,33 @@ Common shell builtins and core utils available on Windows.
 
 <execution_steps>
 1. Directory Verification: If creating directories/files, use LS tool to verify parent exists
-2. Security Check: Banned commands ({{ .BannedCommands }}) return error - explain to user. Safe read-only commands execute without prompts
+2. Security Check: Banned commands ({{ .BannedCommands }}) and blocked argument patterns ({{ .BlockedArguments }}) return error - explain to user. Safe read-only commands execute without prompts
 3. Command Execution: Execute with proper quoting, capture output
 4. Auto-Background: Commands exceeding 1 minute automatically move to background and return shell ID
 5. Output Processing: Truncate if exceeds {{ .MaxOutputLength }} characters
 6. Return Result: Include errors, metadata with <cwd></cwd> tags
 </execution_steps>
 
-<usage_notes>
+{{ if .Sandbox }}<sandbox>
+Commands run in a sandbox enforced by the kernel:
+- Writes are only allowed in: {{ range $i, $p := .Sandbox.WritablePaths }}{{ if $i }}, {{ end }}{{ $p }}{{ end }}
+{{- if not .Sandbox.AllowNetwork }}
+- Network access is blocked
+{{- end }}
+- "Permission denied" errors outside these limits come from the sandbox. Don't try to work around it, tell the user instead
+</sandbox>
+
+{{ end }}<usage_notes>
 - Command required, working_dir optional (defaults to current directory)
 - IMPORTANT: Use Grep/Glob/Agent tools instead of 'find'/'grep'. Use View/LS tools instead of 'cat'/'head'/'tail'/'ls'
 - Chain with ';' or '&&', avoid newlines except in quoted strings
+{{- if .PersistentShell }}
+- Commands of this session share a persistent shell: the working directory, environment variables and shell functions carry over between calls. Set reset_shell=true to start from a clean shell
+- The current working directory is reported in <cwd></cwd> tags after each command
+{{- else }}
 - Each command runs in independent shell (no state persistence between calls)
 - Prefer absolute paths over 'cd' (use 'cd' only if user explicitly requests)
+{{- end }}
 </usage_notes>
 
 <background_execution>
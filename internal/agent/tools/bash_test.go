@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+type countingPermissionService struct {
+	mockPermissionService
+	requests atomic.Int32
+}
+
+func (c *countingPermissionService) Request(req permission.CreatePermissionRequest) bool {
+	c.requests.Add(1)
+	return true
+}
+
+func TestBashTool_ResetShellParam(t *testing.T) {
+	t.Parallel()
+
+	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+	tool := NewBashTool(permissions, t.TempDir(), &config.Attribution{}, "", config.ToolBash{})
+	require.NotContains(t, tool.Info().Parameters, "reset_shell")
+
+	tool = NewBashTool(permissions, t.TempDir(), &config.Attribution{}, "", config.ToolBash{PersistentShell: true})
+	require.Contains(t, tool.Info().Parameters, "reset_shell")
+}
+
+func TestBashTool_PersistentShellSafeCommands(t *testing.T) {
+	t.Parallel()
+
+	permissions := &countingPermissionService{mockPermissionService: mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}}
+	tool := NewBashTool(permissions, t.TempDir(), &config.Attribution{}, "", config.ToolBash{PersistentShell: true})
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, t.Name())
+
+	run := func(command string) {
+		input, err := json.Marshal(BashParams{Command: command})
+		require.NoError(t, err)
+		resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: BashToolName, Input: string(input)})
+		require.NoError(t, err)
+		require.False(t, resp.IsError, resp.Content)
+	}
+
+	run("pwd")
+	require.Zero(t, permissions.requests.Load())
+
+	// Once the shell has functions, a safe name may run anything.
+	run("pwd() { echo hijacked; }")
+	require.EqualValues(t, 1, permissions.requests.Load())
+	run("pwd")
+	require.EqualValues(t, 2, permissions.requests.Load())
+}
@@ -0,0 +1,336 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+type CodeActionParams struct {
+	FilePath   string `json:"file_path" description:"The path to the file"`
+	Line       int    `json:"line,omitempty" description:"The first line of the range (1-based)"`
+	EndLine    int    `json:"end_line,omitempty" description:"The last line of the range (1-based), defaults to line"`
+	Diagnostic string `json:"diagnostic,omitempty" description:"Part of the message of a diagnostic to fix, used to find the range when line is not given"`
+	Kind       string `json:"kind,omitempty" description:"Only list actions of this kind or its sub-kinds, e.g. quickfix, refactor, source.organizeImports"`
+	Apply      int    `json:"apply,omitempty" description:"The number of the action to apply, as listed by a previous call with the same parameters. Leave empty to list the actions"`
+}
+
+type CodeActionPermissionsParams struct {
+	Title   string                `json:"title"`
+	Kind    string                `json:"kind,omitempty"`
+	Command string                `json:"command,omitempty"`
+	Files   []WorkspaceFileChange `json:"files,omitempty"`
+}
+
+type CodeActionResponseMetadata struct {
+	Title     string   `json:"title,omitempty"`
+	Files     []string `json:"files,omitempty"`
+	Additions int      `json:"additions"`
+	Removals  int      `json:"removals"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
+}
+
+const CodeActionToolName = "lsp_code_action"
+
+//go:embed code_action.md
+var codeActionDescription []byte
+
+// codeActionChoice is a code action and the LSP client that offered it.
+type codeActionChoice struct {
+	client *lsp.Client
+	action protocol.CodeAction
+}
+
+func NewCodeActionTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		CodeActionToolName,
+		string(codeActionDescription),
+		func(ctx context.Context, params CodeActionParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.FilePath == "" {
+				return fantasy.NewTextErrorResponse("file_path is required"), nil
+			}
+			if params.Line <= 0 && params.Diagnostic == "" {
+				return fantasy.NewTextErrorResponse("either line or diagnostic is required"), nil
+			}
+			path := params.FilePath
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workingDir, path)
+			}
+			relPath := relativePath(workingDir, path)
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to read file: %s", err)), nil
+			}
+
+			defer touchLSPs.use(ctx, path)()
+			var choices []codeActionChoice
+			var errs error
+			var where string
+			for client := range lspClients.Seq() {
+				if !client.HandlesFile(path) {
+					continue
+				}
+				rng, diagnostics, err := codeActionRange(string(content), client.GetFileDiagnostics(protocol.URIFromPath(path)), params)
+				if err != nil {
+					return fantasy.NewTextErrorResponse(err.Error()), nil
+				}
+				where = fmt.Sprintf("%s:%d-%d", relPath, rng.Start.Line+1, rng.End.Line+1)
+
+				var only []protocol.CodeActionKind
+				if params.Kind != "" {
+					only = []protocol.CodeActionKind{protocol.CodeActionKind(params.Kind)}
+				}
+				actions, err := client.CodeActions(ctx, path, rng, diagnostics, only)
+				if err != nil {
+					if !errors.Is(err, lsp.ErrCodeActionsNotSupported) {
+						errs = errors.Join(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+					}
+					continue
+				}
+				for _, action := range actions {
+					choices = append(choices, codeActionChoice{client: client, action: action})
+				}
+			}
+			if where == "" {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("no LSP client available for %s", params.FilePath)), nil
+			}
+			if len(choices) == 0 {
+				if errs != nil {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get code actions: %s", errs)), nil
+				}
+				return fantasy.NewTextResponse(fmt.Sprintf("No code actions available for %s", where)), nil
+			}
+
+			if params.Apply == 0 {
+				var output strings.Builder
+				fmt.Fprintf(&output, "Code actions for %s:\n\n", where)
+				output.WriteString(formatCodeActions(choices))
+				output.WriteString("\nCall this tool again with the same parameters and apply set to the number of an action to apply it.\n")
+				return fantasy.NewTextResponse(output.String()), nil
+			}
+
+			if params.Apply < 0 || params.Apply > len(choices) {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid action %d, there are %d actions for %s", params.Apply, len(choices), where)), nil
+			}
+			choice := choices[params.Apply-1]
+			if choice.action.Disabled != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("action %q is disabled: %s", choice.action.Title, choice.action.Disabled.Reason)), nil
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for applying code actions")
+			}
+			return applyCodeAction(ctx, choice, codeActionContext{
+				lspClients:  lspClients,
+				permissions: permissions,
+				files:       files,
+				tracker:     tracker,
+				workingDir:  workingDir,
+				sessionID:   sessionID,
+				call:        call,
+				path:        path,
+			})
+		})
+}
+
+type codeActionContext struct {
+	lspClients  *csync.Map[string, *lsp.Client]
+	permissions permission.Service
+	files       history.Service
+	tracker     filetracker.Service
+	workingDir  string
+	sessionID   string
+	call        fantasy.ToolCall
+	path        string
+}
+
+// applyCodeAction applies the edit of the action, then runs its command,
+// after a single permission prompt.
+func applyCodeAction(ctx context.Context, choice codeActionChoice, c codeActionContext) (fantasy.ToolResponse, error) {
+	action, err := choice.client.ResolveCodeAction(ctx, choice.action)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to resolve action %q: %s", choice.action.Title, err)), nil
+	}
+	if action.Edit == nil && action.Command == nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("action %q has nothing to apply", action.Title)), nil
+	}
+
+	var fileChanges []WorkspaceFileChange
+	if action.Edit != nil {
+		fileChanges, err = previewWorkspaceEdit(*action.Edit, c.workingDir)
+		if err != nil {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to apply action %q: %s", action.Title, err)), nil
+		}
+	}
+	var command string
+	if action.Command != nil {
+		command = action.Command.Command
+	}
+
+	description := fmt.Sprintf("Apply code action %q", action.Title)
+	if len(fileChanges) > 0 {
+		description += fmt.Sprintf(" to %d file(s)", len(fileChanges))
+	}
+	if command != "" {
+		description += fmt.Sprintf(" and run command %s", command)
+	}
+	paths := []string{c.path}
+	for _, change := range fileChanges {
+		paths = append(paths, change.FilePath)
+	}
+	snapshot := snapshotDiagnostics(ctx, c.lspClients, paths...)
+
+	p := c.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   c.sessionID,
+		Path:        c.workingDir,
+		ToolCallID:  c.call.ID,
+		ToolName:    CodeActionToolName,
+		Action:      "write",
+		Description: description,
+		Params: CodeActionPermissionsParams{
+			Title:   action.Title,
+			Kind:    string(action.Kind),
+			Command: command,
+			Files:   fileChanges,
+		},
+	})
+	if !p {
+		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	var changedPaths []string
+	if action.Edit != nil && len(fileChanges) > 0 {
+		changedPaths, err = applyWorkspaceEdit(ctx, c.files, c.tracker, c.sessionID, fileChanges)
+		if err != nil {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to apply action %q: %s", action.Title, err)), nil
+		}
+		notifyLSPsOfFiles(ctx, c.lspClients, changedPaths)
+	}
+
+	if action.Command != nil {
+		// The command may ask us to apply more edits, which the user already
+		// allowed by allowing the action.
+		var mu sync.Mutex
+		_, err := choice.client.ExecuteCommand(ctx, *action.Command, func(edit protocol.WorkspaceEdit) error {
+			changes, err := previewWorkspaceEdit(edit, c.workingDir)
+			if err != nil {
+				return err
+			}
+			paths, err := applyWorkspaceEdit(ctx, c.files, c.tracker, c.sessionID, changes)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			fileChanges = append(fileChanges, changes...)
+			changedPaths = append(changedPaths, paths...)
+			return nil
+		})
+		if err != nil {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to run command %s of action %q: %s", command, action.Title, err)), nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		notifyLSPsOfFiles(ctx, c.lspClients, changedPaths)
+	}
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "<result>\nApplied code action %q", action.Title)
+	if len(fileChanges) == 0 {
+		output.WriteString(", no files were changed.\n")
+	} else {
+		fmt.Fprintf(&output, " to %d file(s):\n", len(fileChanges))
+		output.WriteString(formatWorkspaceChanges(fileChanges, c.workingDir))
+	}
+	output.WriteString("</result>\n")
+	diagnostics, delta := snapshot.compare(c.lspClients)
+	output.WriteString(diagnostics)
+
+	meta := CodeActionResponseMetadata{Title: action.Title, Diagnostics: delta}
+	for _, change := range fileChanges {
+		meta.Files = append(meta.Files, relativePath(c.workingDir, change.finalPath()))
+		meta.Additions += change.Additions
+		meta.Removals += change.Removals
+	}
+	return fantasy.WithResponseMetadata(fantasy.NewTextResponse(output.String()), meta), nil
+}
+
+// codeActionRange returns the range of whole lines to get actions for and
+// the diagnostics in it. Without a line, the range of the first diagnostic
+// matching the message is used.
+func codeActionRange(content string, diagnostics []protocol.Diagnostic, params CodeActionParams) (protocol.Range, []protocol.Diagnostic, error) {
+	lines := strings.Split(content, "\n")
+	var rng protocol.Range
+	if params.Line > 0 {
+		endLine := max(params.EndLine, params.Line)
+		if params.Line > len(lines) || endLine > len(lines) {
+			return rng, nil, fmt.Errorf("line %d is out of range, the file has %d lines", endLine, len(lines))
+		}
+		rng = protocol.Range{
+			Start: protocol.Position{Line: uint32(params.Line - 1)},
+			End:   protocol.Position{Line: uint32(endLine - 1), Character: uint32(len(lines[endLine-1]))},
+		}
+	} else {
+		found := false
+		for _, d := range diagnostics {
+			if strings.Contains(d.Message, params.Diagnostic) {
+				rng, found = d.Range, true
+				break
+			}
+		}
+		if !found {
+			return rng, nil, fmt.Errorf("no diagnostic matching %q in the file", params.Diagnostic)
+		}
+	}
+
+	var matching []protocol.Diagnostic
+	for _, d := range diagnostics {
+		if params.Diagnostic != "" && !strings.Contains(d.Message, params.Diagnostic) {
+			continue
+		}
+		if d.Range.Start.Line <= rng.End.Line && d.Range.End.Line >= rng.Start.Line {
+			matching = append(matching, d)
+		}
+	}
+	return rng, matching, nil
+}
+
+// formatCodeActions lists the actions, numbered from 1.
+func formatCodeActions(choices []codeActionChoice) string {
+	var output strings.Builder
+	for i, choice := range choices {
+		action := choice.action
+		fmt.Fprintf(&output, "%d. ", i+1)
+		if action.Kind != "" {
+			fmt.Fprintf(&output, "[%s] ", action.Kind)
+		}
+		output.WriteString(action.Title)
+		if action.IsPreferred {
+			output.WriteString(" (preferred)")
+		}
+		if action.Disabled != nil {
+			fmt.Fprintf(&output, " (disabled: %s)", action.Disabled.Reason)
+		}
+		output.WriteString("\n")
+		for _, d := range action.Diagnostics {
+			fmt.Fprintf(&output, "   fixes: %s\n", strings.ReplaceAll(d.Message, "\n", " "))
+		}
+	}
+	return output.String()
+}
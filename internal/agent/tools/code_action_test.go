@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeActionRange(t *testing.T) {
+	t.Parallel()
+
+	content := "package main\n\nimport \"os\"\n\nfunc main() {\n\tfmt.Println()\n}\n"
+	diagnostics := []protocol.Diagnostic{
+		{
+			Message: "\"os\" imported and not used",
+			Range:   protocol.Range{Start: protocol.Position{Line: 2, Character: 7}, End: protocol.Position{Line: 2, Character: 11}},
+		},
+		{
+			Message: "undefined: fmt",
+			Range:   protocol.Range{Start: protocol.Position{Line: 5, Character: 1}, End: protocol.Position{Line: 5, Character: 4}},
+		},
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		t.Parallel()
+		rng, diags, err := codeActionRange(content, diagnostics, CodeActionParams{Line: 5, EndLine: 6})
+		require.NoError(t, err)
+		require.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 4},
+			End:   protocol.Position{Line: 5, Character: 14},
+		}, rng)
+		require.Len(t, diags, 1)
+		require.Equal(t, "undefined: fmt", diags[0].Message)
+	})
+
+	t.Run("diagnostic", func(t *testing.T) {
+		t.Parallel()
+		rng, diags, err := codeActionRange(content, diagnostics, CodeActionParams{Diagnostic: "not used"})
+		require.NoError(t, err)
+		require.Equal(t, diagnostics[0].Range, rng)
+		require.Equal(t, diagnostics[:1], diags)
+	})
+
+	t.Run("unknown diagnostic", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := codeActionRange(content, diagnostics, CodeActionParams{Diagnostic: "missing return"})
+		require.Error(t, err)
+	})
+
+	t.Run("line out of range", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := codeActionRange(content, diagnostics, CodeActionParams{Line: 100})
+		require.Error(t, err)
+	})
+}
+
+func TestFormatCodeActions(t *testing.T) {
+	t.Parallel()
+
+	output := formatCodeActions([]codeActionChoice{
+		{action: protocol.CodeAction{
+			Title:       "Add import: \"fmt\"",
+			Kind:        "quickfix",
+			IsPreferred: true,
+			Diagnostics: []protocol.Diagnostic{{Message: "undefined: fmt"}},
+		}},
+		{action: protocol.CodeAction{Title: "Organize Imports"}},
+		{action: protocol.CodeAction{
+			Title:    "Extract function",
+			Kind:     "refactor.extract",
+			Disabled: &protocol.CodeActionDisabled{Reason: "no selection"},
+		}},
+	})
+	require.Equal(t, "1. [quickfix] Add import: \"fmt\" (preferred)\n"+
+		"   fixes: undefined: fmt\n"+
+		"2. Organize Imports\n"+
+		"3. [refactor.extract] Extract function (disabled: no selection)\n", output)
+}
+
+func TestPreviewWorkspaceEditOutsideWorkingDir(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	workingDir := filepath.Join(root, "project")
+	outside := filepath.Join(root, "other.go")
+	inside := filepath.Join(workingDir, "main.go")
+	require.NoError(t, os.MkdirAll(workingDir, 0o755))
+	require.NoError(t, os.WriteFile(outside, []byte("foo\n"), 0o644))
+	require.NoError(t, os.WriteFile(inside, []byte("foo\n"), 0o644))
+
+	edit := func(path string) protocol.WorkspaceEdit {
+		return protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				protocol.URIFromPath(path): {{
+					Range:   protocol.Range{End: protocol.Position{Character: 3}},
+					NewText: "bar",
+				}},
+			},
+		}
+	}
+
+	changes, err := previewWorkspaceEdit(edit(inside), workingDir)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "foo\n", changes[0].OldContent)
+	require.Equal(t, "bar\n", changes[0].NewContent)
+	require.Equal(t, 1, changes[0].Additions)
+	require.Equal(t, 1, changes[0].Removals)
+
+	_, err = previewWorkspaceEdit(edit(outside), workingDir)
+	require.Error(t, err)
+}
+
+func TestApplyWorkspaceEditRollback(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	changed := filepath.Join(dir, "main.go")
+	blocker := filepath.Join(dir, "blocker")
+	require.NoError(t, os.WriteFile(changed, []byte("foo\n"), 0o644))
+	require.NoError(t, os.WriteFile(blocker, []byte("file\n"), 0o644))
+
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	files := &mockHistoryService{Broker: pubsub.NewBroker[history.File]()}
+	changes := []WorkspaceFileChange{
+		{FilePath: changed, OldContent: "foo\n", NewContent: "bar\n"},
+		// The parent of the new file is a file, so writing it fails.
+		{FilePath: filepath.Join(blocker, "new.go"), NewContent: "package a\n", created: true},
+	}
+	_, err := applyWorkspaceEdit(ctx, files, &mockFileTracker{}, "session", changes)
+	require.Error(t, err)
+
+	content, err := os.ReadFile(changed)
+	require.NoError(t, err)
+	require.Equal(t, "foo\n", string(content))
+
+	// Files changed since the preview are not overwritten.
+	require.NoError(t, os.WriteFile(changed, []byte("baz\n"), 0o644))
+	_, err = applyWorkspaceEdit(ctx, files, &mockFileTracker{}, "session", changes[:1])
+	require.ErrorContains(t, err, "modified since")
+
+	require.NoError(t, os.WriteFile(changed, []byte("foo\n"), 0o644))
+	paths, err := applyWorkspaceEdit(ctx, files, &mockFileTracker{}, "session", changes[:1])
+	require.NoError(t, err)
+	require.Equal(t, []string{changed}, paths)
+	content, err = os.ReadFile(changed)
+	require.NoError(t, err)
+	require.Equal(t, "bar\n", string(content))
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type CopyParams struct {
+	SourcePath      string `json:"source_path" description:"The path of the file or directory to copy"`
+	DestinationPath string `json:"destination_path" description:"The path of the copy, which must not exist"`
+}
+
+type CopyPermissionsParams struct {
+	SourcePath      string `json:"source_path"`
+	DestinationPath string `json:"destination_path"`
+}
+
+type CopyResponseMetadata struct {
+	SourcePath      string `json:"source_path"`
+	DestinationPath string `json:"destination_path"`
+}
+
+const CopyToolName = "copy"
+
+//go:embed copy.md
+var copyDescription []byte
+
+func NewCopyTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		CopyToolName,
+		string(copyDescription),
+		func(ctx context.Context, params CopyParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			src, err := checkFileOperationPath(workingDir, params.SourcePath, "source_path")
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			dst, err := checkFileOperationPath(workingDir, params.DestinationPath, "destination_path")
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			info, err := os.Lstat(src)
+			if os.IsNotExist(err) {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("%s does not exist", src)), nil
+			}
+			if err != nil {
+				return fantasy.ToolResponse{}, fmt.Errorf("error checking source: %w", err)
+			}
+			if _, err := os.Lstat(dst); err == nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("%s already exists, delete it first to replace it", dst)), nil
+			}
+			if info.IsDir() {
+				if _, inside := fsext.UnderPath(src, dst); inside {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("cannot copy %s into itself", src)), nil
+				}
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for copying files")
+			}
+
+			p := permissions.Request(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    CopyToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Copy %s to %s", relativePath(workingDir, src), relativePath(workingDir, dst)),
+				Params: CopyPermissionsParams{
+					SourcePath:      src,
+					DestinationPath: dst,
+				},
+			})
+			if !p {
+				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+			}
+
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return fantasy.ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
+			}
+			if err := copyPath(src, dst); err != nil {
+				if removeErr := os.RemoveAll(dst); removeErr != nil {
+					slog.Error("Error removing partial copy", "path", dst, "error", removeErr)
+				}
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to copy %s: %s", src, err)), nil
+			}
+
+			contents, err := textFiles(dst)
+			if err != nil {
+				slog.Warn("Failed to read copied files for history", "error", err)
+			}
+			var historyChanges []history.FileChange
+			for _, path := range slices.Sorted(maps.Keys(contents)) {
+				historyChanges = append(historyChanges, history.FileChange{Path: path, NewContent: contents[path]})
+				recordFileWrite(ctx, tracker, path)
+			}
+			if _, err := files.RecordChanges(ctx, sessionID, historyChanges); err != nil {
+				slog.Error("Error recording file history", "error", err)
+			}
+
+			for name, client := range lspClients.Seq2() {
+				if err := client.DidCreateFile(ctx, dst); err != nil {
+					slog.Warn("Failed to notify LSP of copied file", "lsp", name, "error", err)
+				}
+			}
+
+			result := fmt.Sprintf("<result>\nCopied %s to %s\n</result>", relativePath(workingDir, src), relativePath(workingDir, dst))
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse(result),
+				CopyResponseMetadata{
+					SourcePath:      relativePath(workingDir, src),
+					DestinationPath: relativePath(workingDir, dst),
+				},
+			), nil
+		})
+}
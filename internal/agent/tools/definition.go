@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// LSPPositionParams locates a symbol in a file, either by position or by
+// name.
+type LSPPositionParams struct {
+	FilePath string `json:"file_path" description:"The path to the file containing the symbol"`
+	Line     int    `json:"line,omitempty" description:"The line of the symbol (1-based)"`
+	Column   int    `json:"column,omitempty" description:"The column of the symbol (1-based)"`
+	Symbol   string `json:"symbol,omitempty" description:"The symbol name, used to find the position when line or column are not given"`
+}
+
+const (
+	DefinitionToolName     = "lsp_definition"
+	TypeDefinitionToolName = "lsp_type_definition"
+	ImplementationToolName = "lsp_implementation"
+
+	// snippetLines is how many lines are shown from each location.
+	snippetLines = 3
+	// maxLocations is how many locations are shown at most.
+	maxLocations = 50
+)
+
+//go:embed definition.md
+var definitionDescription []byte
+
+//go:embed type_definition.md
+var typeDefinitionDescription []byte
+
+//go:embed implementation.md
+var implementationDescription []byte
+
+type locationsFunc func(client *lsp.Client, ctx context.Context, path string, line, column int) ([]protocol.Location, error)
+
+func NewDefinitionTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return newLocationsTool(DefinitionToolName, string(definitionDescription), "definition", (*lsp.Client).Definition, lspClients, touchLSPs, workingDir)
+}
+
+func NewTypeDefinitionTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return newLocationsTool(TypeDefinitionToolName, string(typeDefinitionDescription), "type definition", (*lsp.Client).TypeDefinition, lspClients, touchLSPs, workingDir)
+}
+
+func NewImplementationTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return newLocationsTool(ImplementationToolName, string(implementationDescription), "implementation", (*lsp.Client).Implementation, lspClients, touchLSPs, workingDir)
+}
+
+func newLocationsTool(name, description, what string, locate locationsFunc, lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		name,
+		description,
+		func(ctx context.Context, params LSPPositionParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			client, pos, done, err := resolveLSPPosition(ctx, lspClients, touchLSPs, workingDir, params)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			defer done()
+
+			locations, err := locate(client, ctx, pos.path, pos.line, pos.column)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to find %s: %s", what, err)), nil
+			}
+			if len(locations) == 0 {
+				return fantasy.NewTextResponse(fmt.Sprintf("No %s found for %s", what, pos)), nil
+			}
+
+			locations = cleanupLocations(locations)
+			var output strings.Builder
+			fmt.Fprintf(&output, "Found %d %s(s) for %s:\n", len(locations), what, pos)
+			output.WriteString(formatLocationSnippets(locations, workingDir))
+			return fantasy.NewTextResponse(output.String()), nil
+		})
+}
+
+// lspPosition is a resolved, 1-based position in a file.
+type lspPosition struct {
+	path         string
+	relPath      string
+	line, column int
+	symbol       string
+}
+
+func (p lspPosition) String() string {
+	if p.symbol != "" {
+		return fmt.Sprintf("%s at %s:%d:%d", p.symbol, p.relPath, p.line, p.column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.relPath, p.line, p.column)
+}
+
+// resolveLSPPosition finds the LSP client handling the file and the position
+// of the symbol the parameters point to. Unless it fails, the servers handling
+// the file are kept in use until the returned function is called.
+func resolveLSPPosition(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string, params LSPPositionParams) (*lsp.Client, lspPosition, func(), error) {
+	if params.FilePath == "" {
+		return nil, lspPosition{}, nil, errors.New("file_path is required")
+	}
+	if params.Line <= 0 && params.Symbol == "" {
+		return nil, lspPosition{}, nil, errors.New("either line or symbol is required")
+	}
+
+	path := params.FilePath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	done := touchLSPs.use(ctx, path)
+	client := lspClientForFile(lspClients, path)
+	if client == nil {
+		done()
+		return nil, lspPosition{}, nil, fmt.Errorf("no LSP client available for %s", params.FilePath)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		done()
+		return nil, lspPosition{}, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	line, column, err := findSymbolPosition(string(content), params.Line, params.Column, params.Symbol)
+	if err != nil {
+		done()
+		return nil, lspPosition{}, nil, err
+	}
+	return client, lspPosition{
+		path:    path,
+		relPath: relativePath(workingDir, path),
+		line:    line,
+		column:  column,
+		symbol:  params.Symbol,
+	}, done, nil
+}
+
+// lspClientForFile returns the first LSP client handling the file, if any.
+func lspClientForFile(lspClients *csync.Map[string, *lsp.Client], path string) *lsp.Client {
+	for client := range lspClients.Seq() {
+		if client.HandlesFile(path) {
+			return client
+		}
+	}
+	return nil
+}
+
+// findSymbolPosition returns the 1-based position of the symbol. A given
+// line and column are used as is; otherwise the symbol is searched for on
+// the line, or in the whole file when no line is given. Without a symbol the
+// first non-blank character of the line is used.
+func findSymbolPosition(content string, line, column int, symbol string) (int, int, error) {
+	lines := strings.Split(content, "\n")
+	if line > len(lines) {
+		return 0, 0, fmt.Errorf("line %d is out of range, the file has %d lines", line, len(lines))
+	}
+	if line > 0 && column > 0 {
+		return line, column, nil
+	}
+	if symbol == "" {
+		text := lines[line-1]
+		return line, len(text) - len(strings.TrimLeft(text, " \t")) + 1, nil
+	}
+
+	name := symbol[getSymbolOffset(symbol):]
+	re, err := regexp.Compile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid symbol: %w", err)
+	}
+	search := func(i int) (int, int, bool) {
+		if loc := re.FindStringIndex(lines[i]); loc != nil {
+			return i + 1, loc[0] + 1, true
+		}
+		return 0, 0, false
+	}
+	if line > 0 {
+		if l, c, ok := search(line - 1); ok {
+			return l, c, nil
+		}
+		return 0, 0, fmt.Errorf("symbol %q not found on line %d", symbol, line)
+	}
+	for i := range lines {
+		if l, c, ok := search(i); ok {
+			return l, c, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("symbol %q not found in file", symbol)
+}
+
+// formatLocationSnippets renders each location as path:line:column followed
+// by the first lines of its range.
+func formatLocationSnippets(locations []protocol.Location, workingDir string) string {
+	var output strings.Builder
+	files := map[string][]string{}
+	for i, loc := range locations {
+		if i == maxLocations {
+			fmt.Fprintf(&output, "\n... and %d more\n", len(locations)-maxLocations)
+			break
+		}
+		path, err := loc.URI.Path()
+		if err != nil {
+			continue
+		}
+		line := int(loc.Range.Start.Line)
+		fmt.Fprintf(&output, "\n%s:%d:%d\n", relativePath(workingDir, path), line+1, loc.Range.Start.Character+1)
+
+		lines, ok := files[path]
+		if !ok {
+			content, err := os.ReadFile(path)
+			if err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			files[path] = lines
+		}
+		end := min(len(lines), line+snippetLines)
+		for i := line; i < end; i++ {
+			fmt.Fprintf(&output, "%6d|%s\n", i+1, strings.TrimRight(lines[i], "\r"))
+		}
+	}
+	return output.String()
+}
+
+// relativePath returns path relative to the working directory when it is
+// inside of it.
+func relativePath(workingDir, path string) string {
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSymbolPosition(t *testing.T) {
+	t.Parallel()
+
+	content := "package main\n\nfunc main() {\n\tfmt.Println(helper())\n}\n\nfunc helper() string {\n\treturn \"\"\n}\n"
+
+	tests := []struct {
+		name       string
+		line       int
+		column     int
+		symbol     string
+		wantLine   int
+		wantColumn int
+		wantErr    bool
+	}{
+		{name: "explicit position", line: 4, column: 7, wantLine: 4, wantColumn: 7},
+		{name: "line only", line: 4, wantLine: 4, wantColumn: 2},
+		{name: "symbol on line", line: 4, symbol: "helper", wantLine: 4, wantColumn: 14},
+		{name: "symbol in file", symbol: "helper", wantLine: 4, wantColumn: 14},
+		{name: "qualified symbol", symbol: "fmt.Println", wantLine: 4, wantColumn: 6},
+		{name: "partial word", symbol: "help", wantErr: true},
+		{name: "not on line", line: 1, symbol: "helper", wantErr: true},
+		{name: "not found", symbol: "missing", wantErr: true},
+		{name: "line out of range", line: 100, symbol: "helper", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			line, column, err := findSymbolPosition(content, tt.line, tt.column, tt.symbol)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantLine, line)
+			require.Equal(t, tt.wantColumn, column)
+		})
+	}
+}
+
+func TestFormatLocationSnippets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc helper() string {\n\treturn \"\"\n}\n"), 0o644))
+
+	output := formatLocationSnippets([]protocol.Location{{
+		URI:   protocol.URIFromPath(path),
+		Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 5}},
+	}}, dir)
+	require.Equal(t, "\nmain.go:3:6\n     3|func helper() string {\n     4|\treturn \"\"\n     5|}\n", output)
+}
@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+type DeleteParams struct {
+	Path      string `json:"path" description:"The path of the file or directory to delete"`
+	Recursive bool   `json:"recursive,omitempty" description:"Delete a directory that is not empty, with everything in it"`
+}
+
+type DeletePermissionsParams struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir,omitempty"`
+	// Files are the deleted file, when it is a text file, and the files the
+	// LSP servers change because of the deletion.
+	Files []WorkspaceFileChange `json:"files,omitempty"`
+	// Unrestorable are the deleted files that aren't kept in the history,
+	// as they are binary or too large.
+	Unrestorable []string `json:"unrestorable,omitempty"`
+}
+
+type DeleteResponseMetadata struct {
+	Path     string   `json:"path"`
+	IsDir    bool     `json:"is_dir,omitempty"`
+	Removals int      `json:"removals"`
+	Files    []string `json:"files,omitempty"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
+}
+
+const DeleteToolName = "delete"
+
+//go:embed delete.md
+var deleteDescription []byte
+
+func NewDeleteTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		DeleteToolName,
+		string(deleteDescription),
+		func(ctx context.Context, params DeleteParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			path, err := checkFileOperationPath(workingDir, params.Path, "path")
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			info, err := os.Lstat(path)
+			if os.IsNotExist(err) {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("%s does not exist", path)), nil
+			}
+			if err != nil {
+				return fantasy.ToolResponse{}, fmt.Errorf("error checking path: %w", err)
+			}
+			if info.IsDir() && !params.Recursive {
+				entries, err := os.ReadDir(path)
+				if err != nil {
+					return fantasy.ToolResponse{}, fmt.Errorf("error reading directory: %w", err)
+				}
+				if len(entries) > 0 {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("%s is a directory that is not empty, set recursive to true to delete it with everything in it", path)), nil
+				}
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for deleting files")
+			}
+
+			defer touchLSPs.use(ctx, path)()
+			edits := fileOperationEdits(ctx, lspClients, path, info.IsDir(), workingDir, func(client *lsp.Client) (protocol.WorkspaceEdit, error) {
+				return client.WillDeleteFile(ctx, path)
+			})
+			editChanges := fileOperationChanges(edits)
+
+			contents, err := textFiles(path)
+			if err != nil {
+				slog.Warn("Failed to read deleted files for history", "error", err)
+			}
+			unrestorable, err := unrestorableFiles(path, contents)
+			if err != nil {
+				slog.Warn("Failed to list deleted files", "error", err)
+			}
+			removals := 0
+			for _, content := range contents {
+				removals += strings.Count(content, "\n")
+			}
+
+			permissionFiles := editChanges
+			if content, ok := contents[path]; ok {
+				_, _, fileRemovals := diff.GenerateDiff(content, "", relativePath(workingDir, path))
+				permissionFiles = append([]WorkspaceFileChange{{
+					FilePath:   path,
+					OldContent: content,
+					Removals:   fileRemovals,
+				}}, editChanges...)
+			}
+			var snapshotPaths []string
+			for _, change := range permissionFiles {
+				snapshotPaths = append(snapshotPaths, change.finalPath())
+			}
+			snapshot := snapshotDiagnostics(ctx, lspClients, snapshotPaths...)
+
+			p := permissions.Request(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    DeleteToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Delete %s", relativePath(workingDir, path)),
+				Params: DeletePermissionsParams{
+					Path:         path,
+					IsDir:        info.IsDir(),
+					Files:        permissionFiles,
+					Unrestorable: unrestorable,
+				},
+			})
+			if !p {
+				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+			}
+
+			changedPaths, applied := applyFileOperationEdits(ctx, files, tracker, sessionID, edits)
+
+			// Edits may have changed the files being deleted.
+			if len(edits) > 0 {
+				if contents, err = textFiles(path); err != nil {
+					slog.Warn("Failed to read deleted files for history", "error", err)
+				}
+			}
+			if err := os.RemoveAll(path); err != nil {
+				undoFileOperationEdits(ctx, files, tracker, sessionID, applied)
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to delete %s: %s", path, err)), nil
+			}
+			forgetFileRecords(ctx, tracker, path)
+
+			var historyChanges []history.FileChange
+			for _, file := range slices.Sorted(maps.Keys(contents)) {
+				historyChanges = append(historyChanges, history.FileChange{Path: file, OldContent: contents[file]})
+			}
+			if _, err := files.RecordChanges(ctx, sessionID, historyChanges); err != nil {
+				slog.Error("Error recording file history", "error", err)
+			}
+
+			for name, client := range lspClients.Seq2() {
+				if err := client.DidDeleteFile(ctx, path); err != nil {
+					slog.Warn("Failed to notify LSP of deleted file", "lsp", name, "error", err)
+				}
+			}
+			var remaining []string
+			for _, changed := range changedPaths {
+				if _, deleted := fsext.UnderPath(path, changed); !deleted {
+					remaining = append(remaining, changed)
+				}
+			}
+			notifyLSPsOfFiles(ctx, lspClients, remaining)
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "<result>\nDeleted %s\n", relativePath(workingDir, path))
+			if len(editChanges) > 0 {
+				output.WriteString("Updated references in:\n")
+				output.WriteString(formatWorkspaceChanges(editChanges, workingDir))
+			}
+			output.WriteString("</result>\n")
+			diagnostics, delta := snapshot.compare(lspClients)
+			output.WriteString(diagnostics)
+
+			relPaths := make([]string, len(remaining))
+			for i, changed := range remaining {
+				relPaths[i] = relativePath(workingDir, changed)
+			}
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse(output.String()),
+				DeleteResponseMetadata{
+					Path:        relativePath(workingDir, path),
+					IsDir:       info.IsDir(),
+					Removals:    removals,
+					Files:       relPaths,
+					Diagnostics: delta,
+				},
+			), nil
+		})
+}
@@ -3,8 +3,10 @@ package tools
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -24,11 +26,14 @@ const DiagnosticsToolName = "lsp_diagnostics"
 //go:embed diagnostics.md
 var diagnosticsDescription []byte
 
-func NewDiagnosticsTool(lspClients *csync.Map[string, *lsp.Client]) fantasy.AgentTool {
+func NewDiagnosticsTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		DiagnosticsToolName,
 		string(diagnosticsDescription),
 		func(ctx context.Context, params DiagnosticsParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.FilePath != "" {
+				defer touchLSPs.use(ctx, params.FilePath)()
+			}
 			if lspClients.Len() == 0 {
 				return fantasy.NewTextErrorResponse("no LSP clients available"), nil
 			}
@@ -52,6 +57,25 @@ func notifyLSPs(ctx context.Context, lsps *csync.Map[string, *lsp.Client], filep
 	}
 }
 
+// notifyLSPsOfFiles notifies the LSP clients about several changed files and
+// waits for their diagnostics once.
+func notifyLSPsOfFiles(ctx context.Context, lsps *csync.Map[string, *lsp.Client], filepaths []string) {
+	for client := range lsps.Seq() {
+		notified := false
+		for _, filepath := range filepaths {
+			if !client.HandlesFile(filepath) {
+				continue
+			}
+			_ = client.OpenFileOnDemand(ctx, filepath)
+			_ = client.NotifyChange(ctx, filepath)
+			notified = true
+		}
+		if notified {
+			client.WaitForDiagnostics(ctx, 5*time.Second)
+		}
+	}
+}
+
 func getDiagnostics(filePath string, lsps *csync.Map[string, *lsp.Client]) string {
 	fileDiagnostics := []string{}
 	projectDiagnostics := []string{}
@@ -183,3 +207,141 @@ func countSeverity(diagnostics []string, severity string) int {
 	}
 	return count
 }
+
+// DiagnosticsDelta counts how a change affected the diagnostics.
+type DiagnosticsDelta struct {
+	New       int `json:"new"`
+	Resolved  int `json:"resolved"`
+	Unchanged int `json:"unchanged"`
+}
+
+// diagnosticEntry is a reported diagnostic, formatted for the model.
+type diagnosticEntry struct {
+	// key identifies the diagnostic regardless of its position, which
+	// changes as lines are added or removed above it.
+	key  string
+	text string
+}
+
+// diagnosticsSnapshot is the diagnostics the LSP clients reported before a
+// change, to tell the diagnostics the change introduced from the ones that
+// were already there.
+type diagnosticsSnapshot struct {
+	entries []diagnosticEntry
+}
+
+// snapshotDiagnostics records the current diagnostics of all files. The
+// given files are opened first if needed, so their existing diagnostics
+// are known before they change.
+func snapshotDiagnostics(ctx context.Context, lsps *csync.Map[string, *lsp.Client], filepaths ...string) diagnosticsSnapshot {
+	for client := range lsps.Seq() {
+		opened := false
+		for _, filepath := range filepaths {
+			if !client.HandlesFile(filepath) || client.IsFileOpen(filepath) {
+				continue
+			}
+			if _, err := os.Stat(filepath); err != nil {
+				continue
+			}
+			if err := client.OpenFile(ctx, filepath); err == nil {
+				opened = true
+			}
+		}
+		if opened {
+			client.WaitForDiagnostics(ctx, 2*time.Second)
+		}
+	}
+	return diagnosticsSnapshot{entries: collectDiagnostics(lsps)}
+}
+
+func collectDiagnostics(lsps *csync.Map[string, *lsp.Client]) []diagnosticEntry {
+	var entries []diagnosticEntry
+	for lspName, client := range lsps.Seq2() {
+		for location, diags := range client.GetDiagnostics() {
+			path, err := location.Path()
+			if err != nil {
+				slog.Error("Failed to convert diagnostic location URI to path", "uri", location, "error", err)
+				continue
+			}
+			for _, diag := range diags {
+				entries = append(entries, newDiagnosticEntry(lspName, path, diag))
+			}
+		}
+	}
+	return entries
+}
+
+func newDiagnosticEntry(lspName, path string, diag protocol.Diagnostic) diagnosticEntry {
+	return diagnosticEntry{
+		key:  fmt.Sprintf("%s\x00%s\x00%d\x00%s\x00%v\x00%s", lspName, path, diag.Severity, diag.Source, diag.Code, diag.Message),
+		text: formatDiagnostic(path, diag, lspName),
+	}
+}
+
+// compare reports the diagnostics introduced and resolved since the
+// snapshot, and how many are unchanged. It returns an empty string and a
+// nil delta when there are no diagnostics at all.
+func (s diagnosticsSnapshot) compare(lsps *csync.Map[string, *lsp.Client]) (string, *DiagnosticsDelta) {
+	added, resolved, unchanged := diffDiagnostics(s.entries, collectDiagnostics(lsps))
+	if len(added) == 0 && len(resolved) == 0 && unchanged == 0 {
+		return "", nil
+	}
+
+	sortDiagnostics(added)
+	sortDiagnostics(resolved)
+
+	var output strings.Builder
+	writeDiagnostics(&output, "new_diagnostics", added)
+	writeDiagnostics(&output, "resolved_diagnostics", resolved)
+	output.WriteString("\n<diagnostic_summary>\n")
+	fmt.Fprintf(&output, "New: %d (%d errors, %d warnings)\n", len(added), countSeverity(added, "Error"), countSeverity(added, "Warn"))
+	fmt.Fprintf(&output, "Resolved: %d\n", len(resolved))
+	fmt.Fprintf(&output, "Unchanged: %d, already there before this change and not listed\n", unchanged)
+	output.WriteString("</diagnostic_summary>\n")
+
+	out := output.String()
+	slog.Debug("Diagnostics delta", "output", out)
+	return out, &DiagnosticsDelta{
+		New:       len(added),
+		Resolved:  len(resolved),
+		Unchanged: unchanged,
+	}
+}
+
+// diffDiagnostics matches the diagnostics after a change to the ones
+// before it. Diagnostics reported several times are matched as many times.
+func diffDiagnostics(before, after []diagnosticEntry) (added, resolved []string, unchanged int) {
+	remaining := make(map[string]int, len(before))
+	for _, entry := range before {
+		remaining[entry.key]++
+	}
+	for _, entry := range after {
+		if remaining[entry.key] > 0 {
+			remaining[entry.key]--
+			unchanged++
+			continue
+		}
+		added = append(added, entry.text)
+	}
+	for _, entry := range before {
+		if remaining[entry.key] > 0 {
+			remaining[entry.key]--
+			resolved = append(resolved, entry.text)
+		}
+	}
+	return added, resolved, unchanged
+}
+
+// withDiagnosticsDelta adds the delta to the metadata of a response, as its
+// diagnostics field.
+func withDiagnosticsDelta(response fantasy.ToolResponse, delta *DiagnosticsDelta) fantasy.ToolResponse {
+	if delta == nil || response.Metadata == "" {
+		return response
+	}
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(response.Metadata), &meta); err != nil {
+		return response
+	}
+	meta["diagnostics"] = delta
+	return fantasy.WithResponseMetadata(response, meta)
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diag := func(line uint32, severity protocol.DiagnosticSeverity, message string) protocol.Diagnostic {
+		return protocol.Diagnostic{
+			Range:    protocol.Range{Start: protocol.Position{Line: line}},
+			Severity: severity,
+			Message:  message,
+		}
+	}
+	before := []diagnosticEntry{
+		newDiagnosticEntry("gopls", "/project/main.go", diag(3, protocol.SeverityWarning, "unused parameter")),
+		newDiagnosticEntry("gopls", "/project/main.go", diag(10, protocol.SeverityError, "undefined: foo")),
+		newDiagnosticEntry("gopls", "/project/main.go", diag(12, protocol.SeverityError, "undefined: foo")),
+	}
+	after := []diagnosticEntry{
+		// Moved down by the edit, but still the same diagnostic.
+		newDiagnosticEntry("gopls", "/project/main.go", diag(5, protocol.SeverityWarning, "unused parameter")),
+		newDiagnosticEntry("gopls", "/project/main.go", diag(14, protocol.SeverityError, "undefined: foo")),
+		newDiagnosticEntry("gopls", "/project/util.go", diag(1, protocol.SeverityError, "missing return")),
+	}
+
+	added, resolved, unchanged := diffDiagnostics(before, after)
+	require.Equal(t, []string{"Error: /project/util.go:2:1 [gopls] missing return"}, added)
+	require.Equal(t, []string{"Error: /project/main.go:11:1 [gopls] undefined: foo"}, resolved)
+	require.Equal(t, 2, unchanged)
+
+	added, resolved, unchanged = diffDiagnostics(nil, nil)
+	require.Empty(t, added)
+	require.Empty(t, resolved)
+	require.Zero(t, unchanged)
+}
+
+func TestWithDiagnosticsDelta(t *testing.T) {
+	t.Parallel()
+
+	response := fantasy.WithResponseMetadata(fantasy.NewTextResponse("ok"), EditResponseMetadata{Additions: 1})
+	require.Equal(t, response, withDiagnosticsDelta(response, nil))
+
+	response = withDiagnosticsDelta(response, &DiagnosticsDelta{New: 1, Unchanged: 2})
+	require.JSONEq(t, `{"additions":1,"removals":0,"diagnostics":{"new":1,"resolved":0,"unchanged":2}}`, response.Metadata)
+}
@@ -0,0 +1,430 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/log"
+)
+
+// document is the text extracted from a PDF or office document.
+type document struct {
+	// pages has the text of every page, or a single one for formats that
+	// aren't paged.
+	pages []string
+	// first is the 1-based number of the first of the pages, when they
+	// don't start at the first page of the document.
+	first int
+	// truncated is set when the text was too large to be extracted whole,
+	// so the last pages are missing.
+	truncated bool
+	// pageName labels the pages, as "Page" or "Slide", for paged formats.
+	pageName string
+	// renderPage renders the 1-based page as a PNG image, for formats that
+	// can be.
+	renderPage func(ctx context.Context, page int) ([]byte, error)
+}
+
+// documentExtractor extracts the text of the document. It can extract only
+// the pages of the range, when the format allows it, see
+// [document.selectPages].
+type documentExtractor func(ctx context.Context, path string, pages pageRange) (*document, error)
+
+// documentExtractors are the extractors of the document formats view reads,
+// by file extension.
+var documentExtractors = map[string]documentExtractor{
+	".pdf":  extractPDF,
+	".docx": extractDOCX,
+	".pptx": extractPPTX,
+	".odt":  extractODT,
+}
+
+// maxDocumentPartSize is how much of a part of an office document is read,
+// as they are compressed.
+const maxDocumentPartSize = 8 * MaxReadSize
+
+// pageRange is a range of 1-based pages, inclusive. Zero bounds are the
+// first and last pages of the document.
+type pageRange struct {
+	first, last int
+}
+
+// parsePageRange parses a page, as "3", or a range of pages, as "3-7".
+func parsePageRange(s string) (pageRange, error) {
+	if s == "" {
+		return pageRange{}, nil
+	}
+	first, last, isRange := strings.Cut(s, "-")
+	var r pageRange
+	var err error
+	if r.first, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || r.first < 1 {
+		return pageRange{}, fmt.Errorf("invalid pages %q, use a page number or a range such as 3-7", s)
+	}
+	r.last = r.first
+	if isRange {
+		if r.last, err = strconv.Atoi(strings.TrimSpace(last)); err != nil || r.last < r.first {
+			return pageRange{}, fmt.Errorf("invalid pages %q, use a page number or a range such as 3-7", s)
+		}
+	}
+	return r, nil
+}
+
+// number returns the 1-based number of the page at index i of the pages.
+func (d *document) number(i int) int {
+	return max(d.first, 1) + i
+}
+
+// selectPages keeps only the pages of the range. It returns a message
+// telling the model why they can't be selected.
+func (d *document) selectPages(r pageRange) string {
+	if r == (pageRange{}) {
+		return ""
+	}
+	if d.pageName == "" {
+		return "pages can only be used with PDF files and presentations"
+	}
+	if len(d.pages) == 0 {
+		return "the document has no pages"
+	}
+	start, end := r.first-d.number(0), r.last-d.number(0)+1
+	if start < 0 || start >= len(d.pages) {
+		return fmt.Sprintf("page %d is out of range, the document has %d pages", r.first, d.number(len(d.pages)-1))
+	}
+	d.first = r.first
+	d.pages = d.pages[start:min(end, len(d.pages))]
+	return ""
+}
+
+// text returns the text of the document, with a header line before every
+// page of paged formats, and the 0-based lines of these headers.
+func (d *document) text() (string, []int) {
+	if d.pageName == "" {
+		return strings.Join(d.pages, "\n"), nil
+	}
+	var (
+		sb      strings.Builder
+		headers = make([]int, len(d.pages))
+		line    int
+	)
+	for i, page := range d.pages {
+		headers[i] = line
+		page = strings.TrimRight(page, " \t\r\n")
+		if strings.TrimSpace(page) == "" {
+			page = "(no text)"
+		}
+		fmt.Fprintf(&sb, "--- %s %d ---\n%s\n", d.pageName, d.number(i), page)
+		line += 2 + strings.Count(page, "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), headers
+}
+
+// blankPage returns the first 1-based page without text whose header is in
+// the lines from offset to end, or 0 when there is none.
+func (d *document) blankPage(headers []int, offset, end int) int {
+	for i, line := range headers {
+		if line >= offset && line < end && strings.TrimSpace(d.pages[i]) == "" {
+			return d.number(i)
+		}
+	}
+	return 0
+}
+
+var (
+	getPDFToText = sync.OnceValue(func() string { return lookPoppler("pdftotext") })
+	getPDFToPPM  = sync.OnceValue(func() string { return lookPoppler("pdftoppm") })
+)
+
+func lookPoppler(name string) string {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		if log.Initialized() {
+			slog.Warn("Poppler tool not found in $PATH. PDF files can't be read.", "tool", name)
+		}
+		return ""
+	}
+	return path
+}
+
+// errNoPDFToText is returned when the PDF tools aren't installed.
+var errNoPDFToText = errors.New("reading PDF files needs pdftotext, from poppler (poppler-utils), install it and try again")
+
+// extractPDF extracts the text of the pages of the range, up to
+// maxDocumentPartSize bytes.
+func extractPDF(ctx context.Context, path string, r pageRange) (*document, error) {
+	name := getPDFToText()
+	if name == "" {
+		return nil, errNoPDFToText
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := []string{"-layout", "-enc", "UTF-8"}
+	if r.first > 0 {
+		args = append(args, "-f", strconv.Itoa(r.first))
+	}
+	if r.last > 0 {
+		args = append(args, "-l", strconv.Itoa(r.last))
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, append(args, path, "-")...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(stdout, maxDocumentPartSize+1))
+	truncated := len(out) > maxDocumentPartSize
+	if truncated {
+		// The rest of the text isn't needed.
+		cancel()
+		out = out[:maxDocumentPartSize]
+	}
+	if waitErr := cmd.Wait(); waitErr != nil && !truncated {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", waitErr, msg)
+		}
+		return nil, waitErr
+	}
+	if err != nil && !truncated {
+		return nil, err
+	}
+
+	// pdftotext ends every page with a form feed.
+	pages := strings.Split(strings.TrimSuffix(string(out), "\f"), "\f")
+	if truncated && len(pages) > 1 {
+		// The last page is cut.
+		pages = pages[:len(pages)-1]
+	}
+	return &document{
+		pages:     pages,
+		first:     r.first,
+		truncated: truncated,
+		pageName:  "Page",
+		renderPage: func(ctx context.Context, page int) ([]byte, error) {
+			return renderPDFPage(ctx, path, page)
+		},
+	}, nil
+}
+
+func renderPDFPage(ctx context.Context, path string, page int) ([]byte, error) {
+	name := getPDFToPPM()
+	if name == "" {
+		return nil, errors.New("pdftoppm, from poppler (poppler-utils), is not installed")
+	}
+	dir, err := os.MkdirTemp("", "crush-pdf-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "page")
+	n := strconv.Itoa(page)
+	cmd := exec.CommandContext(ctx, name, "-png", "-r", "100", "-f", n, "-l", n, "-singlefile", path, root)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.ReadFile(root + ".png")
+}
+
+func extractDOCX(_ context.Context, path string, _ pageRange) (*document, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// Table cells are collected apart to be written on a single row.
+	var sb, cell strings.Builder
+	out := &sb
+	err = walkDocumentPart(&r.Reader, "word/document.xml", func(tok xml.Token) {
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "tr":
+				sb.WriteString("|")
+			case "tc":
+				cell.Reset()
+				out = &cell
+			case "tab":
+				out.WriteString("\t")
+			case "br", "cr":
+				out.WriteString("\n")
+			case "pStyle":
+				// Headings are styled Heading1 to Heading9.
+				style := xmlAttr(tok, "val")
+				if level, err := strconv.Atoi(strings.TrimPrefix(style, "Heading")); err == nil && strings.HasPrefix(style, "Heading") {
+					out.WriteString(strings.Repeat("#", level) + " ")
+				}
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "tr":
+				sb.WriteString("\n")
+			case "tc":
+				out = &sb
+				fmt.Fprintf(&sb, " %s |", strings.Join(strings.Fields(cell.String()), " "))
+			case "p":
+				out.WriteString("\n")
+			}
+		case xml.CharData:
+			out.Write(tok)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &document{pages: []string{sb.String()}}, nil
+}
+
+func extractPPTX(_ context.Context, path string, _ pageRange) (*document, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var slides []int
+	for _, f := range r.File {
+		name, ok := strings.CutPrefix(f.Name, "ppt/slides/slide")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, ".xml")); err == nil && strings.HasSuffix(name, ".xml") {
+			slides = append(slides, n)
+		}
+	}
+	slices.Sort(slides)
+
+	doc := &document{pageName: "Slide"}
+	for _, n := range slides {
+		var sb strings.Builder
+		err := walkDocumentPart(&r.Reader, fmt.Sprintf("ppt/slides/slide%d.xml", n), func(tok xml.Token) {
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				if tok.Name.Local == "br" {
+					sb.WriteString("\n")
+				}
+			case xml.EndElement:
+				if tok.Name.Local == "p" {
+					sb.WriteString("\n")
+				}
+			case xml.CharData:
+				sb.Write(tok)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		doc.pages = append(doc.pages, sb.String())
+	}
+	return doc, nil
+}
+
+func extractODT(_ context.Context, path string, _ pageRange) (*document, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var sb strings.Builder
+	err = walkDocumentPart(&r.Reader, "content.xml", func(tok xml.Token) {
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "tab":
+				sb.WriteString("\t")
+			case "line-break":
+				sb.WriteString("\n")
+			case "s":
+				// Runs of spaces are stored as a count.
+				n, err := strconv.Atoi(xmlAttr(tok, "c"))
+				if err != nil {
+					n = 1
+				}
+				sb.WriteString(strings.Repeat(" ", n))
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "p", "h":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			sb.Write(tok)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &document{pages: []string{sb.String()}}, nil
+}
+
+// walkDocumentPart calls fn with the tokens of the XML part of the document
+// body. Character data is only passed inside text elements, as whitespace
+// between the other elements is only formatting.
+func walkDocumentPart(r *zip.Reader, name string, fn func(xml.Token)) error {
+	f, err := r.Open(name)
+	if err != nil {
+		return fmt.Errorf("not a valid document: %w", err)
+	}
+	defer f.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(f, maxDocumentPartSize))
+	var inText int
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid document: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if isTextElement(t.Name) {
+				inText++
+			}
+		case xml.EndElement:
+			if isTextElement(t.Name) {
+				inText--
+			}
+		case xml.CharData:
+			if inText == 0 {
+				continue
+			}
+		}
+		fn(tok)
+	}
+}
+
+// isTextElement reports whether the element holds the text of a run: w:t in
+// DOCX, a:t in PPTX, or any text: element in ODT, where text is stored in
+// paragraphs and spans.
+func isTextElement(name xml.Name) bool {
+	return name.Local == "t" || strings.HasPrefix(name.Space, "urn:oasis:names:tc:opendocument:xmlns:text")
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
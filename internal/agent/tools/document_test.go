@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+	return path
+}
+
+// writePDF writes a PDF with a page of text and a blank page.
+func writePDF(t *testing.T) string {
+	t.Helper()
+	stream := "BT /F1 24 Tf 72 720 Td (Hello PDF) Tj ET"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 5 0 R /Resources << /Font << /F1 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	pdf := "%PDF-1.4\n"
+	var offsets []int
+	for i, obj := range objects {
+		offsets = append(offsets, len(pdf))
+		pdf += fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := len(pdf)
+	pdf += fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		pdf += fmt.Sprintf("%010d 00000 n \n", offset)
+	}
+	pdf += fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "spec.pdf")
+	require.NoError(t, os.WriteFile(path, []byte(pdf), 0o644))
+	return path
+}
+
+func TestExtractDocument(t *testing.T) {
+	t.Parallel()
+
+	t.Run("docx", func(t *testing.T) {
+		t.Parallel()
+		path := writeZip(t, "spec.docx", map[string]string{
+			"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:body>
+    <w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Overview</w:t></w:r></w:p>
+    <w:p><w:r><w:t xml:space="preserve">The API </w:t></w:r><w:r><w:t>returns JSON.</w:t></w:r></w:p>
+    <w:tbl>
+      <w:tr><w:tc><w:p><w:r><w:t>Code</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Meaning</w:t></w:r></w:p></w:tc></w:tr>
+      <w:tr><w:tc><w:p><w:r><w:t>404</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Not</w:t></w:r><w:r><w:tab/><w:t>found</w:t></w:r></w:p></w:tc></w:tr>
+    </w:tbl>
+    <w:p><w:r><w:t>End</w:t></w:r><w:r><w:br/><w:t>of spec</w:t></w:r></w:p>
+  </w:body>
+</w:document>`,
+		})
+		doc, err := extractDOCX(t.Context(), path, pageRange{})
+		require.NoError(t, err)
+		text, headers := doc.text()
+		require.Nil(t, headers)
+		require.Equal(t, "# Overview\nThe API returns JSON.\n| Code | Meaning |\n| 404 | Not found |\nEnd\nof spec\n", text)
+	})
+
+	t.Run("pptx", func(t *testing.T) {
+		t.Parallel()
+		slide := func(text string) string {
+			return `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+  <p:cSld><p:spTree><p:sp><p:txBody><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:txBody></p:sp></p:spTree></p:cSld>
+</p:sld>`
+		}
+		path := writeZip(t, "deck.pptx", map[string]string{
+			"ppt/slides/slide10.xml":            slide("Ten"),
+			"ppt/slides/slide2.xml":             slide("Two"),
+			"ppt/slides/slide1.xml":             slide("One"),
+			"ppt/slides/_rels/slide1.xml.rels":  "<Relationships/>",
+			"ppt/slideLayouts/slideLayout1.xml": slide("Layout"),
+		})
+		doc, err := extractPPTX(t.Context(), path, pageRange{})
+		require.NoError(t, err)
+		text, headers := doc.text()
+		require.Equal(t, "--- Slide 1 ---\nOne\n--- Slide 2 ---\nTwo\n--- Slide 3 ---\nTen", text)
+		require.Equal(t, []int{0, 2, 4}, headers)
+	})
+
+	t.Run("odt", func(t *testing.T) {
+		t.Parallel()
+		path := writeZip(t, "notes.odt", map[string]string{
+			"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+  <office:body><office:text>
+    <text:h text:outline-level="1">Notes</text:h>
+    <text:p>a<text:s text:c="3"/>b <text:span>c</text:span></text:p>
+  </office:text></office:body>
+</office:document-content>`,
+		})
+		doc, err := extractODT(t.Context(), path, pageRange{})
+		require.NoError(t, err)
+		text, _ := doc.text()
+		require.Equal(t, "Notes\na   b c\n", text)
+	})
+
+	t.Run("pdf", func(t *testing.T) {
+		t.Parallel()
+		if getPDFToText() == "" {
+			t.Skip("pdftotext is not in $PATH")
+		}
+		doc, err := extractPDF(t.Context(), writePDF(t), pageRange{})
+		require.NoError(t, err)
+		require.Len(t, doc.pages, 2)
+		require.Contains(t, doc.pages[0], "Hello PDF")
+		require.Equal(t, 2, doc.blankPage([]int{0, 2}, 0, 3))
+	})
+
+	t.Run("pdf pages", func(t *testing.T) {
+		t.Parallel()
+		if getPDFToText() == "" {
+			t.Skip("pdftotext is not in $PATH")
+		}
+		doc, err := extractPDF(t.Context(), writePDF(t), pageRange{first: 2, last: 2})
+		require.NoError(t, err)
+		require.Empty(t, doc.selectPages(pageRange{first: 2, last: 2}))
+		text, _ := doc.text()
+		require.Equal(t, "--- Page 2 ---\n(no text)", text)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		path := writeZip(t, "empty.docx", map[string]string{"other.xml": "<a/>"})
+		_, err := extractDOCX(t.Context(), path, pageRange{})
+		require.Error(t, err)
+	})
+}
+
+func TestDocumentBlankPage(t *testing.T) {
+	t.Parallel()
+
+	doc := &document{pages: []string{"one\ntwo\n", "  \n", "three"}, pageName: "Page"}
+	text, headers := doc.text()
+	require.Equal(t, "--- Page 1 ---\none\ntwo\n--- Page 2 ---\n(no text)\n--- Page 3 ---\nthree", text)
+	require.Equal(t, []int{0, 3, 5}, headers)
+	require.Equal(t, 2, doc.blankPage(headers, 0, 7))
+	require.Equal(t, 0, doc.blankPage(headers, 0, 3))
+	require.Equal(t, 0, doc.blankPage(headers, 4, 7))
+}
+
+func TestParsePageRange(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]pageRange{
+		"":       {},
+		"3":      {first: 3, last: 3},
+		"3-7":    {first: 3, last: 7},
+		" 3 - 7": {first: 3, last: 7},
+	} {
+		got, err := parsePageRange(input)
+		require.NoError(t, err, input)
+		require.Equal(t, want, got, input)
+	}
+	for _, input := range []string{"0", "a", "7-3", "3-", "-3"} {
+		_, err := parsePageRange(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestDocumentSelectPages(t *testing.T) {
+	t.Parallel()
+
+	newDoc := func() *document {
+		return &document{pages: []string{"one", "two", "three", "four"}, pageName: "Page"}
+	}
+
+	doc := newDoc()
+	require.Empty(t, doc.selectPages(pageRange{first: 2, last: 3}))
+	text, headers := doc.text()
+	require.Equal(t, "--- Page 2 ---\ntwo\n--- Page 3 ---\nthree", text)
+	require.Equal(t, []int{0, 2}, headers)
+
+	doc = newDoc()
+	require.Empty(t, doc.selectPages(pageRange{first: 3, last: 9}))
+	require.Equal(t, []string{"three", "four"}, doc.pages)
+
+	require.Contains(t, newDoc().selectPages(pageRange{first: 5, last: 5}), "the document has 4 pages")
+	require.Contains(t, (&document{pages: []string{"text"}}).selectPages(pageRange{first: 1, last: 1}), "only be used with PDF files")
+
+	// Extracted pages are numbered from the first one.
+	doc = &document{pages: []string{"three", "four"}, first: 3, pageName: "Page"}
+	require.Empty(t, doc.selectPages(pageRange{first: 4, last: 4}))
+	require.Equal(t, []string{"four"}, doc.pages)
+	require.Equal(t, 4, doc.number(0))
+}
+
+func TestViewDocument(t *testing.T) {
+	t.Parallel()
+
+	// Three pages of 3MB, more than MaxReadSize together.
+	line := strings.Repeat("x", 1000)
+	page := strings.Repeat(line+"\n", 3*1024)
+	extract := func(context.Context, string, pageRange) (*document, error) {
+		return &document{pages: []string{page, page, "last page"}, pageName: "Page"}, nil
+	}
+	view := func(params ViewParams) string {
+		t.Helper()
+		resp, err := viewDocument(t.Context(), "doc.pdf", extract, params)
+		require.NoError(t, err)
+		require.False(t, resp.IsError, resp.Content)
+		return resp.Content
+	}
+
+	t.Run("offset past the first 5MB", func(t *testing.T) {
+		t.Parallel()
+		content := view(ViewParams{Offset: 2 * (3*1024 + 1), Limit: 10})
+		require.Contains(t, content, "--- Page 3 ---")
+		require.Contains(t, content, "last page")
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		t.Parallel()
+		content := view(ViewParams{Pages: "3", Limit: 10})
+		require.Contains(t, content, "     1|--- Page 3 ---\n     2|last page")
+		require.NotContains(t, content, line)
+	})
+
+	t.Run("window larger than MaxReadSize", func(t *testing.T) {
+		t.Parallel()
+		content := view(ViewParams{Limit: 8 * 1024})
+		require.Less(t, len(content), MaxReadSize+MaxReadSize/10)
+		require.Contains(t, content, "File has more lines")
+	})
+
+	t.Run("invalid pages", func(t *testing.T) {
+		t.Parallel()
+		resp, err := viewDocument(t.Context(), "doc.pdf", extract, ViewParams{Pages: "4", Limit: 10})
+		require.NoError(t, err)
+		require.True(t, resp.IsError)
+		require.Contains(t, resp.Content, "the document has 3 pages")
+	})
+}
@@ -8,12 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
 
@@ -39,6 +39,8 @@ type EditResponseMetadata struct {
 	Removals   int    `json:"removals"`
 	OldContent string `json:"old_content,omitempty"`
 	NewContent string `json:"new_content,omitempty"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
 }
 
 const EditToolName = "edit"
@@ -50,10 +52,12 @@ type editContext struct {
 	ctx         context.Context
 	permissions permission.Service
 	files       history.Service
+	tracker     filetracker.Service
+	formatter   *Formatter
 	workingDir  string
 }
 
-func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) fantasy.AgentTool {
+func NewEditTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, formatter *Formatter, workingDir string) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		EditToolName,
 		string(editDescription),
@@ -63,11 +67,13 @@ func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 			}
 
 			params.FilePath = filepathext.SmartJoin(workingDir, params.FilePath)
+			defer touchLSPs.use(ctx, params.FilePath)()
+			snapshot := snapshotDiagnostics(ctx, lspClients, params.FilePath)
 
 			var response fantasy.ToolResponse
 			var err error
 
-			editCtx := editContext{ctx, permissions, files, workingDir}
+			editCtx := editContext{ctx, permissions, files, tracker, formatter, workingDir}
 
 			if params.OldString == "" {
 				response, err = createNewFile(editCtx, params.FilePath, params.NewString, call)
@@ -88,10 +94,9 @@ func NewEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 
 			notifyLSPs(ctx, lspClients, params.FilePath)
 
-			text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
-			text += getDiagnostics(params.FilePath, lspClients)
-			response.Content = text
-			return response, nil
+			diagnostics, delta := snapshot.compare(lspClients)
+			response.Content = fmt.Sprintf("<result>\n%s\n</result>\n", response.Content) + diagnostics
+			return withDiagnosticsDelta(response, delta), nil
 		})
 }
 
@@ -140,11 +145,14 @@ func createNewFile(edit editContext, filePath, content string, call fantasy.Tool
 		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
+	defer edit.tracker.Writing(filePath)()
 	err = os.WriteFile(filePath, []byte(content), 0o644)
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	content, note := edit.formatter.formatChange(edit.ctx, filePath, "", content, fsext.TextFormat{}, false, nil, &additions, &removals)
+
 	// File can't be in the history so we create a new file history
 	_, err = edit.files.Create(edit.ctx, sessionID, filePath, "")
 	if err != nil {
@@ -159,11 +167,10 @@ func createNewFile(edit editContext, filePath, content string, call fantasy.Tool
 		slog.Error("Error creating file history version", "error", err)
 	}
 
-	recordFileWrite(filePath)
-	recordFileRead(filePath)
+	recordFileWrite(edit.ctx, edit.tracker, filePath)
 
 	return fantasy.WithResponseMetadata(
-		fantasy.NewTextResponse("File created: "+filePath),
+		fantasy.NewTextResponse("File created: "+filePath+note),
 		EditResponseMetadata{
 			OldContent: "",
 			NewContent: content,
@@ -186,48 +193,41 @@ func deleteContent(edit editContext, filePath, oldString string, replaceAll bool
 		return fantasy.NewTextErrorResponse(fmt.Sprintf("path is a directory, not a file: %s", filePath)), nil
 	}
 
-	if getLastReadTime(filePath).IsZero() {
-		return fantasy.NewTextErrorResponse("you must read the file before editing it. Use the View tool first"), nil
+	base, stale, readErr, err := staleBase(edit.ctx, edit.tracker, filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
 	}
-
-	modTime := fileInfo.ModTime()
-	lastRead := getLastReadTime(filePath)
-	if modTime.After(lastRead) {
-		return fantasy.NewTextErrorResponse(
-			fmt.Sprintf("file %s has been modified since it was last read (mod time: %s, last read: %s)",
-				filePath, modTime.Format(time.RFC3339), lastRead.Format(time.RFC3339),
-			)), nil
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
-	content, err := os.ReadFile(filePath)
+	content, format, readErr, err := readText(filePath)
 	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
+		return fantasy.ToolResponse{}, err
+	}
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
-	oldContent, isCrlf := fsext.ToUnixLineEndings(string(content))
-
-	var newContent string
-	var deletionCount int
-
-	if replaceAll {
-		newContent = strings.ReplaceAll(oldContent, oldString, "")
-		deletionCount = strings.Count(oldContent, oldString)
-		if deletionCount == 0 {
-			return fantasy.NewTextErrorResponse("old_string not found in file. Make sure it matches exactly, including whitespace and line breaks"), nil
-		}
+	oldContent, isCrlf := fsext.ToUnixLineEndings(content)
+	if stale {
+		base, _ = fsext.ToUnixLineEndings(base)
 	} else {
-		index := strings.Index(oldContent, oldString)
-		if index == -1 {
-			return fantasy.NewTextErrorResponse("old_string not found in file. Make sure it matches exactly, including whitespace and line breaks"), nil
-		}
+		base = oldContent
+	}
 
-		lastIndex := strings.LastIndex(oldContent, oldString)
-		if index != lastIndex {
-			return fantasy.NewTextErrorResponse("old_string appears multiple times in the file. Please provide more context to ensure a unique match, or set replace_all to true"), nil
+	matches, err := findEditMatches(base, oldString, "", replaceAll)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(err.Error()), nil
+	}
+	newContent := matches.apply(base)
+	var mergeNote string
+	if stale {
+		var conflict *mergeConflict
+		newContent, mergeNote, conflict = mergeDiskChanges(filePath, base, newContent, oldContent)
+		if conflict != nil {
+			return conflict.response(conflict.Error()), nil
 		}
-
-		newContent = oldContent[:index] + oldContent[index+len(oldString):]
-		deletionCount = 1
 	}
 
 	sessionID := GetSessionFromContext(edit.ctx)
@@ -261,42 +261,25 @@ func deleteContent(edit editContext, filePath, oldString string, replaceAll bool
 		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	if isCrlf {
-		newContent, _ = fsext.ToWindowsLineEndings(newContent)
-	}
-
-	err = os.WriteFile(filePath, []byte(newContent), 0o644)
+	defer edit.tracker.Writing(filePath)()
+	writeErr, err := writeText(filePath, withLineEndings(newContent, isCrlf), format)
 	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
+		return fantasy.ToolResponse{}, err
 	}
-
-	// Check if file exists in history
-	file, err := edit.files.GetByPathAndSession(edit.ctx, filePath, sessionID)
-	if err != nil {
-		_, err = edit.files.Create(edit.ctx, sessionID, filePath, oldContent)
-		if err != nil {
-			// Log error but don't fail the operation
-			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
-		}
+	if writeErr != "" {
+		return fantasy.NewTextErrorResponse(writeErr), nil
 	}
-	if file.Content != oldContent {
-		// User Manually changed the content store an intermediate version
-		_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, oldContent)
-		if err != nil {
-			slog.Error("Error creating file history version", "error", err)
-		}
-	}
-	// Store the new version
-	_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, "")
-	if err != nil {
-		slog.Error("Error creating file history version", "error", err)
+
+	newContent, note := edit.formatter.formatChange(edit.ctx, filePath, oldContent, newContent, format, isCrlf, nil, &additions, &removals)
+
+	if err := updateFileHistory(edit.ctx, edit.files, sessionID, filePath, oldContent, newContent); err != nil {
+		return fantasy.ToolResponse{}, err
 	}
 
-	recordFileWrite(filePath)
-	recordFileRead(filePath)
+	recordFileWrite(edit.ctx, edit.tracker, filePath)
 
 	return fantasy.WithResponseMetadata(
-		fantasy.NewTextResponse("Content deleted from file: "+filePath),
+		fantasy.NewTextResponse("Content deleted from file: "+filePath+matches.note()+mergeNote+note),
 		EditResponseMetadata{
 			OldContent: oldContent,
 			NewContent: newContent,
@@ -319,48 +302,41 @@ func replaceContent(edit editContext, filePath, oldString, newString string, rep
 		return fantasy.NewTextErrorResponse(fmt.Sprintf("path is a directory, not a file: %s", filePath)), nil
 	}
 
-	if getLastReadTime(filePath).IsZero() {
-		return fantasy.NewTextErrorResponse("you must read the file before editing it. Use the View tool first"), nil
+	base, stale, readErr, err := staleBase(edit.ctx, edit.tracker, filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
 	}
-
-	modTime := fileInfo.ModTime()
-	lastRead := getLastReadTime(filePath)
-	if modTime.After(lastRead) {
-		return fantasy.NewTextErrorResponse(
-			fmt.Sprintf("file %s has been modified since it was last read (mod time: %s, last read: %s)",
-				filePath, modTime.Format(time.RFC3339), lastRead.Format(time.RFC3339),
-			)), nil
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
-	content, err := os.ReadFile(filePath)
+	content, format, readErr, err := readText(filePath)
 	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
+		return fantasy.ToolResponse{}, err
+	}
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
-	oldContent, isCrlf := fsext.ToUnixLineEndings(string(content))
-
-	var newContent string
-	var replacementCount int
-
-	if replaceAll {
-		newContent = strings.ReplaceAll(oldContent, oldString, newString)
-		replacementCount = strings.Count(oldContent, oldString)
-		if replacementCount == 0 {
-			return fantasy.NewTextErrorResponse("old_string not found in file. Make sure it matches exactly, including whitespace and line breaks"), nil
-		}
+	oldContent, isCrlf := fsext.ToUnixLineEndings(content)
+	if stale {
+		base, _ = fsext.ToUnixLineEndings(base)
 	} else {
-		index := strings.Index(oldContent, oldString)
-		if index == -1 {
-			return fantasy.NewTextErrorResponse("old_string not found in file. Make sure it matches exactly, including whitespace and line breaks"), nil
-		}
+		base = oldContent
+	}
 
-		lastIndex := strings.LastIndex(oldContent, oldString)
-		if index != lastIndex {
-			return fantasy.NewTextErrorResponse("old_string appears multiple times in the file. Please provide more context to ensure a unique match, or set replace_all to true"), nil
+	matches, err := findEditMatches(base, oldString, newString, replaceAll)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(err.Error()), nil
+	}
+	newContent := matches.apply(base)
+	var mergeNote string
+	if stale {
+		var conflict *mergeConflict
+		newContent, mergeNote, conflict = mergeDiskChanges(filePath, base, newContent, oldContent)
+		if conflict != nil {
+			return conflict.response(conflict.Error()), nil
 		}
-
-		newContent = oldContent[:index] + newString + oldContent[index+len(oldString):]
-		replacementCount = 1
 	}
 
 	if oldContent == newContent {
@@ -396,42 +372,25 @@ func replaceContent(edit editContext, filePath, oldString, newString string, rep
 		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	if isCrlf {
-		newContent, _ = fsext.ToWindowsLineEndings(newContent)
-	}
-
-	err = os.WriteFile(filePath, []byte(newContent), 0o644)
+	defer edit.tracker.Writing(filePath)()
+	writeErr, err := writeText(filePath, withLineEndings(newContent, isCrlf), format)
 	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
+		return fantasy.ToolResponse{}, err
 	}
-
-	// Check if file exists in history
-	file, err := edit.files.GetByPathAndSession(edit.ctx, filePath, sessionID)
-	if err != nil {
-		_, err = edit.files.Create(edit.ctx, sessionID, filePath, oldContent)
-		if err != nil {
-			// Log error but don't fail the operation
-			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
-		}
+	if writeErr != "" {
+		return fantasy.NewTextErrorResponse(writeErr), nil
 	}
-	if file.Content != oldContent {
-		// User Manually changed the content store an intermediate version
-		_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, oldContent)
-		if err != nil {
-			slog.Debug("Error creating file history version", "error", err)
-		}
-	}
-	// Store the new version
-	_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, newContent)
-	if err != nil {
-		slog.Error("Error creating file history version", "error", err)
+
+	newContent, note := edit.formatter.formatChange(edit.ctx, filePath, oldContent, newContent, format, isCrlf, nil, &additions, &removals)
+
+	if err := updateFileHistory(edit.ctx, edit.files, sessionID, filePath, oldContent, newContent); err != nil {
+		return fantasy.ToolResponse{}, err
 	}
 
-	recordFileWrite(filePath)
-	recordFileRead(filePath)
+	recordFileWrite(edit.ctx, edit.tracker, filePath)
 
 	return fantasy.WithResponseMetadata(
-		fantasy.NewTextResponse("Content replaced in file: "+filePath),
+		fantasy.NewTextResponse("Content replaced in file: "+filePath+matches.note()+mergeNote+note),
 		EditResponseMetadata{
 			OldContent: oldContent,
 			NewContent: newContent,
@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type EditLinesParams struct {
+	FilePath   string `json:"file_path" description:"The absolute path to the file to modify"`
+	StartLine  int    `json:"start_line" description:"The first line to replace, 1-based"`
+	EndLine    int    `json:"end_line" description:"The last line to replace, inclusive. Set to start_line - 1 to insert before start_line"`
+	OldContent string `json:"old_content" description:"The current content of the lines of the range, without the line numbers. When inserting, the content of start_line, empty when appending"`
+	NewContent string `json:"new_content" description:"The lines to put in place of the range, empty to delete it"`
+}
+
+const EditLinesToolName = "edit_lines"
+
+//go:embed edit_lines.md
+var editLinesDescription []byte
+
+func NewEditLinesTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, formatter *Formatter, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		EditLinesToolName,
+		string(editLinesDescription),
+		func(ctx context.Context, params EditLinesParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.FilePath == "" {
+				return fantasy.NewTextErrorResponse("file_path is required"), nil
+			}
+			if params.StartLine < 1 {
+				return fantasy.NewTextErrorResponse("start_line must be at least 1"), nil
+			}
+
+			params.FilePath = filepathext.SmartJoin(workingDir, params.FilePath)
+			defer touchLSPs.use(ctx, params.FilePath)()
+			snapshot := snapshotDiagnostics(ctx, lspClients, params.FilePath)
+
+			editCtx := editContext{ctx, permissions, files, tracker, formatter, workingDir}
+			response, err := editLines(editCtx, params, call)
+			if err != nil {
+				return response, err
+			}
+			if response.IsError {
+				return response, nil
+			}
+
+			notifyLSPs(ctx, lspClients, params.FilePath)
+
+			diagnostics, delta := snapshot.compare(lspClients)
+			response.Content = fmt.Sprintf("<result>\n%s\n</result>\n", response.Content) + diagnostics
+			return withDiagnosticsDelta(response, delta), nil
+		})
+}
+
+func editLines(edit editContext, params EditLinesParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	filePath := params.FilePath
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("file not found: %s", filePath)), nil
+		}
+		return fantasy.ToolResponse{}, fmt.Errorf("failed to access file: %w", err)
+	}
+	if fileInfo.IsDir() {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("path is a directory, not a file: %s", filePath)), nil
+	}
+
+	// Line numbers can't be merged with changes made on disk, so the file
+	// must be read again when it changed.
+	status, err := fileStatus(edit.ctx, edit.tracker, filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	switch status {
+	case filetracker.StatusUnread:
+		return fantasy.NewTextErrorResponse("you must read the file before editing it. Use the View tool first"), nil
+	case filetracker.StatusChanged:
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("file %s has been modified since it was last read, read it again to get the current line numbers", filePath)), nil
+	}
+
+	content, format, readErr, err := readText(filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
+	}
+
+	oldContent, isCrlf := fsext.ToUnixLineEndings(content)
+	newContent, summary, lineErr := replaceLines(oldContent, params)
+	if lineErr != "" {
+		return fantasy.NewTextErrorResponse(lineErr), nil
+	}
+	if oldContent == newContent {
+		return fantasy.NewTextErrorResponse("new content is the same as old content. No changes made."), nil
+	}
+
+	sessionID := GetSessionFromContext(edit.ctx)
+	if sessionID == "" {
+		return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for editing a file")
+	}
+
+	_, additions, removals := diff.GenerateDiff(
+		oldContent,
+		newContent,
+		strings.TrimPrefix(filePath, edit.workingDir),
+	)
+
+	p := edit.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        fsext.PathOrPrefix(filePath, edit.workingDir),
+			ToolCallID:  call.ID,
+			ToolName:    EditLinesToolName,
+			Action:      "write",
+			Description: fmt.Sprintf("%s in file %s", summary, filePath),
+			Params: EditPermissionsParams{
+				FilePath:   filePath,
+				OldContent: oldContent,
+				NewContent: newContent,
+			},
+		},
+	)
+	if !p {
+		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	defer edit.tracker.Writing(filePath)()
+	writeErr, err := writeText(filePath, withLineEndings(newContent, isCrlf), format)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	if writeErr != "" {
+		return fantasy.NewTextErrorResponse(writeErr), nil
+	}
+
+	newContent, note := edit.formatter.formatChange(edit.ctx, filePath, oldContent, newContent, format, isCrlf, nil, &additions, &removals)
+
+	if err := updateFileHistory(edit.ctx, edit.files, sessionID, filePath, oldContent, newContent); err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+
+	recordFileWrite(edit.ctx, edit.tracker, filePath)
+
+	return fantasy.WithResponseMetadata(
+		fantasy.NewTextResponse(fmt.Sprintf("%s in file: %s%s", summary, filePath, note)),
+		EditResponseMetadata{
+			OldContent: oldContent,
+			NewContent: newContent,
+			Additions:  additions,
+			Removals:   removals,
+		}), nil
+}
+
+// replaceLines replaces the lines of the params in the content, which has LF
+// line endings, after checking the old content matches. It returns the new
+// content and a summary of the change, or a message telling the model why
+// the lines can't be replaced.
+func replaceLines(content string, params EditLinesParams) (newContent, summary, message string) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start, end := params.StartLine, params.EndLine
+	if start > len(lines)+1 {
+		return "", "", fmt.Sprintf("start_line %d is out of range, the file has %d lines", start, len(lines))
+	}
+	if end < start-1 || end > len(lines) {
+		return "", "", fmt.Sprintf("end_line %d is out of range, it must be between %d and %d", end, start-1, len(lines))
+	}
+
+	// The old content makes sure the line numbers still point to the lines
+	// the model saw. An insertion is checked against the line it goes
+	// before.
+	guardEnd := max(end, start)
+	if start > len(lines) {
+		if params.OldContent != "" {
+			return "", "", fmt.Sprintf("old_content must be empty when appending after the last line (%d)", len(lines))
+		}
+	} else if msg := checkOldLines(lines[start-1:guardEnd], start, params.OldContent); msg != "" {
+		return "", "", msg
+	}
+
+	var replacement []string
+	if params.NewContent != "" {
+		replacement = strings.SplitAfter(strings.TrimSuffix(params.NewContent, "\n"), "\n")
+		replacement[len(replacement)-1] += "\n"
+	}
+
+	before, after := lines[:start-1], lines[end:]
+	if n := len(before); n > 0 && len(replacement) > 0 && !strings.HasSuffix(before[n-1], "\n") {
+		// Appending to a file that doesn't end with a newline.
+		before = append(before[:n-1:n-1], before[n-1]+"\n")
+	}
+	if len(after) == 0 && len(replacement) > 0 && !strings.HasSuffix(content, "\n") && content != "" {
+		// Keep the file without a final newline.
+		replacement[len(replacement)-1] = strings.TrimSuffix(replacement[len(replacement)-1], "\n")
+	}
+	newContent = strings.Join(before, "") + strings.Join(replacement, "") + strings.Join(after, "")
+
+	switch {
+	case end < start:
+		summary = fmt.Sprintf("%d line(s) inserted before line %d", len(replacement), start)
+	case len(replacement) == 0:
+		summary = fmt.Sprintf("Lines %d-%d deleted", start, end)
+	default:
+		summary = fmt.Sprintf("Lines %d-%d replaced with %d line(s)", start, end, len(replacement))
+	}
+	return newContent, summary, ""
+}
+
+// checkOldLines checks the lines, starting at the 1-based line start, are
+// the expected ones, ignoring trailing whitespace.
+func checkOldLines(lines []string, start int, expected string) string {
+	expected = strings.ReplaceAll(expected, "\r\n", "\n")
+	expectedLines := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	if len(expectedLines) != len(lines) {
+		return fmt.Sprintf("old_content has %d line(s) but lines %d-%d are %d line(s). Give the current content of every line of the range. No changes made.", len(expectedLines), start, start+len(lines)-1, len(lines))
+	}
+	for i, line := range lines {
+		actual := strings.TrimRight(line, " \t\r\n")
+		if actual != strings.TrimRight(expectedLines[i], " \t\r") {
+			return fmt.Sprintf("line %d doesn't match old_content, it is:\n%s\nThe line numbers are out of date, view the file again. No changes made.", start+i, actual)
+		}
+	}
+	return ""
+}
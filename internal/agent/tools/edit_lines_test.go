@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceLines(t *testing.T) {
+	t.Parallel()
+
+	const content = "one\ntwo\nthree\nfour\n"
+	for _, tt := range []struct {
+		name    string
+		content string
+		params  EditLinesParams
+		want    string
+		summary string
+		message string
+	}{
+		{
+			name:    "replace one line",
+			params:  EditLinesParams{StartLine: 2, EndLine: 2, OldContent: "two", NewContent: "TWO"},
+			want:    "one\nTWO\nthree\nfour\n",
+			summary: "Lines 2-2 replaced with 1 line(s)",
+		},
+		{
+			name:    "replace a range with more lines",
+			params:  EditLinesParams{StartLine: 2, EndLine: 3, OldContent: "two\nthree  \n", NewContent: "a\nb\nc\n"},
+			want:    "one\na\nb\nc\nfour\n",
+			summary: "Lines 2-3 replaced with 3 line(s)",
+		},
+		{
+			name:    "delete",
+			params:  EditLinesParams{StartLine: 1, EndLine: 2, OldContent: "one\ntwo"},
+			want:    "three\nfour\n",
+			summary: "Lines 1-2 deleted",
+		},
+		{
+			name:    "insert",
+			params:  EditLinesParams{StartLine: 3, EndLine: 2, OldContent: "three", NewContent: "two and a half"},
+			want:    "one\ntwo\ntwo and a half\nthree\nfour\n",
+			summary: "1 line(s) inserted before line 3",
+		},
+		{
+			name:    "append",
+			params:  EditLinesParams{StartLine: 5, EndLine: 4, NewContent: "five"},
+			want:    "one\ntwo\nthree\nfour\nfive\n",
+			summary: "1 line(s) inserted before line 5",
+		},
+		{
+			name:    "append without final newline",
+			content: "one\ntwo",
+			params:  EditLinesParams{StartLine: 3, EndLine: 2, NewContent: "three"},
+			want:    "one\ntwo\nthree",
+			summary: "1 line(s) inserted before line 3",
+		},
+		{
+			name:    "replace last line without final newline",
+			content: "one\ntwo",
+			params:  EditLinesParams{StartLine: 2, EndLine: 2, OldContent: "two", NewContent: "TWO"},
+			want:    "one\nTWO",
+			summary: "Lines 2-2 replaced with 1 line(s)",
+		},
+		{
+			name:    "first line drifted",
+			params:  EditLinesParams{StartLine: 2, EndLine: 2, OldContent: "three", NewContent: "THREE"},
+			message: "line 2 doesn't match old_content, it is:\ntwo\n",
+		},
+		{
+			name:    "last line drifted",
+			params:  EditLinesParams{StartLine: 1, EndLine: 3, OldContent: "one\ntwo\nfour"},
+			message: "line 3 doesn't match old_content",
+		},
+		{
+			name:    "line inside the range changed",
+			params:  EditLinesParams{StartLine: 1, EndLine: 3, OldContent: "one\nTWO\nthree"},
+			message: "line 2 doesn't match old_content",
+		},
+		{
+			name:    "old content of another length",
+			params:  EditLinesParams{StartLine: 1, EndLine: 3, OldContent: "one\nthree"},
+			message: "old_content has 2 line(s) but lines 1-3 are 3 line(s)",
+		},
+		{
+			name:    "append with old content",
+			params:  EditLinesParams{StartLine: 5, EndLine: 4, OldContent: "four", NewContent: "five"},
+			message: "old_content must be empty when appending",
+		},
+		{
+			name:    "out of range",
+			params:  EditLinesParams{StartLine: 4, EndLine: 5, OldContent: "four"},
+			message: "end_line 5 is out of range",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := tt.content
+			if c == "" {
+				c = content
+			}
+			got, summary, message := replaceLines(c, tt.params)
+			if tt.message != "" {
+				require.Contains(t, message, tt.message)
+				return
+			}
+			require.Empty(t, message)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.summary, summary)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/watcher"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditEncodedFile(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []fsext.TextFormat{
+		{Encoding: fsext.UTF16LE, BOM: true},
+		{Encoding: fsext.Latin1},
+	} {
+		t.Run(format.String(), func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := db.Connect(t.Context(), t.TempDir())
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			q := db.New(conn)
+			files := history.NewService(q, conn)
+			tracker := filetracker.NewService(q, watcher.NewService(t.TempDir()))
+			sess, err := session.NewService(q).Create(t.Context(), "session")
+			require.NoError(t, err)
+			ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+
+			permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+			tool := NewEditTool(csync.NewMap[string, *lsp.Client](), nil, permissions, files, tracker, nil, t.TempDir())
+
+			path := filepath.Join(t.TempDir(), "notes.txt")
+			write := func(text string) {
+				t.Helper()
+				data, err := format.Encode(text)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, data, 0o644))
+			}
+			edit := func(oldString, newString string) {
+				t.Helper()
+				input, err := json.Marshal(EditParams{FilePath: path, OldString: oldString, NewString: newString})
+				require.NoError(t, err)
+				resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: EditToolName, Input: string(input)})
+				require.NoError(t, err)
+				require.False(t, resp.IsError, resp.Content)
+			}
+			requireContent := func(text string) {
+				t.Helper()
+				content, got, err := fsext.ReadTextFile(path)
+				require.NoError(t, err)
+				require.Equal(t, format, got)
+				require.Equal(t, text, content)
+			}
+
+			write("café\r\ntwo\r\nthree\r\n")
+			require.NoError(t, tracker.RecordRead(ctx, sess.ID, path))
+
+			edit("two", "deux")
+			requireContent("café\r\ndeux\r\nthree\r\n")
+
+			// The change made outside is merged with the next edit, which
+			// needs the content last read to match the file record.
+			write("zéro\r\ncafé\r\ndeux\r\nthree\r\n")
+			edit("three", "trois")
+			requireContent("zéro\r\ncafé\r\ndeux\r\ntrois\r\n")
+
+			// The history keeps the text with LF line endings, not the bytes
+			// on disk.
+			versions, err := files.ListBySession(t.Context(), sess.ID)
+			require.NoError(t, err)
+			require.NotEmpty(t, versions)
+			for _, version := range versions {
+				require.True(t, utf8.ValidString(version.Content))
+				require.NotContains(t, version.Content, "\ufeff")
+			}
+			latest, err := files.GetByPathAndSession(t.Context(), path, sess.ID)
+			require.NoError(t, err)
+			require.Equal(t, "zéro\ncafé\ndeux\ntrois\n", latest.Content)
+		})
+	}
+}
+
+func TestEditSlowFormatter(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("formatter commands are unix tools")
+	}
+
+	root := t.TempDir()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	workspace := watcher.NewService(root)
+	tracker := filetracker.NewService(q, workspace)
+	sess, err := session.NewService(q).Create(t.Context(), "session")
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+
+	// Writes before the watcher started are missed, so keep writing.
+	changes := workspace.Subscribe(t.Context())
+	go workspace.Watch(t.Context())
+	require.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "probe.txt"), []byte("probe\n"), 0o644))
+		select {
+		case <-changes:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 200*time.Millisecond)
+
+	events := tracker.Subscribe(t.Context())
+	go tracker.Watch(t.Context())
+
+	path := filepath.Join(root, "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o644))
+	require.NoError(t, tracker.RecordRead(ctx, sess.ID, path))
+
+	// The file changes on disk while the formatter runs, which isn't a
+	// change made outside of the session.
+	formatter := NewFormatter(config.Formatters{
+		"slow": {
+			FileTypes: []string{"txt"},
+			Command:   "sh",
+			Args:      []string{"-c", "sleep 1; tr a-z A-Z"},
+		},
+	}, nil, root)
+	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+	tool := NewEditTool(csync.NewMap[string, *lsp.Client](), nil, permissions, history.NewService(q, conn), tracker, formatter, root)
+	input, err := json.Marshal(EditParams{FilePath: path, OldString: "one", NewString: "two"})
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: EditToolName, Input: string(input)})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "TWO\n", string(content))
+
+	time.Sleep(500 * time.Millisecond)
+	require.Empty(t, events)
+
+	require.NoError(t, os.WriteFile(path, []byte("three\n"), 0o644))
+	require.Eventually(t, func() bool { return len(events) > 0 }, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestEditConflict(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	tracker := filetracker.NewService(q, watcher.NewService(t.TempDir()))
+	sess, err := session.NewService(q).Create(t.Context(), "session")
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+
+	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+	tool := NewEditTool(csync.NewMap[string, *lsp.Client](), nil, permissions, history.NewService(q, conn), tracker, nil, t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644))
+	require.NoError(t, tracker.RecordRead(ctx, sess.ID, path))
+	require.NoError(t, os.WriteFile(path, []byte("one\nzwei\nthree\n"), 0o644))
+
+	input, err := json.Marshal(EditParams{FilePath: path, OldString: "two", NewString: "deux"})
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: EditToolName, Input: string(input)})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "<on_disk>\nzwei\n</on_disk>")
+
+	// The conflicts are kept for the UI.
+	var meta MergeConflictMetadata
+	require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+	require.Equal(t, path, meta.FilePath)
+	require.Len(t, meta.Conflicts, 1)
+	require.Equal(t, "zwei\n", meta.Conflicts[0].Theirs)
+	require.Equal(t, "deux\n", meta.Conflicts[0].Ours)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "one\nzwei\nthree\n", string(content))
+}
+
+func TestEditDeleteContentHistory(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	tracker := filetracker.NewService(q, watcher.NewService(t.TempDir()))
+	sess, err := session.NewService(q).Create(t.Context(), "session")
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+
+	files := history.NewService(q, conn)
+	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
+	tool := NewEditTool(csync.NewMap[string, *lsp.Client](), nil, permissions, files, tracker, nil, t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644))
+	require.NoError(t, tracker.RecordRead(ctx, sess.ID, path))
+
+	input, err := json.Marshal(EditParams{FilePath: path, OldString: "two\n"})
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: EditToolName, Input: string(input)})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+
+	// The latest version is the content left after the deletion, the
+	// baseline the next edit of the file is compared to.
+	file, err := files.GetByPathAndSession(ctx, path, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, "one\nthree\n", file.Content)
+}
@@ -1,53 +1,198 @@
 package tools
 
 import (
-	"sync"
-	"time"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
 )
 
-// File record to track when files were read/written
-type fileRecord struct {
-	path      string
-	readTime  time.Time
-	writeTime time.Time
+// fileStatus returns what the session of the context knows about the current
+// content of the file.
+func fileStatus(ctx context.Context, tracker filetracker.Service, path string) (filetracker.Status, error) {
+	status, err := tracker.Status(ctx, GetSessionFromContext(ctx), path)
+	if err != nil {
+		return status, fmt.Errorf("error checking file record: %w", err)
+	}
+	return status, nil
 }
 
-var (
-	fileRecords     = make(map[string]fileRecord)
-	fileRecordMutex sync.RWMutex
-)
+// staleBase checks that the session of the context read the file before
+// changing it. When the file changed on disk since, it returns the content
+// the session last saw, so its changes can be merged with the ones on disk,
+// see mergeDiskChanges. The message tells the model why it can't change the
+// file.
+func staleBase(ctx context.Context, tracker filetracker.Service, path string) (base string, stale bool, message string, err error) {
+	status, err := fileStatus(ctx, tracker, path)
+	if err != nil {
+		return "", false, "", err
+	}
+	switch status {
+	case filetracker.StatusUnread:
+		return "", false, "you must read the file before editing it. Use the View tool first", nil
+	case filetracker.StatusCurrent:
+		return "", false, "", nil
+	}
+	base, ok, err := tracker.Base(ctx, GetSessionFromContext(ctx), path)
+	if err != nil {
+		return "", false, "", fmt.Errorf("error reading file history: %w", err)
+	}
+	if ok {
+		// The record keeps the content as it was on disk.
+		base, _, err = fsext.DecodeText([]byte(base))
+		ok = err == nil
+	}
+	if !ok {
+		return "", false, fmt.Sprintf("file %s has been modified since it was last read, read it again before editing it", path), nil
+	}
+	return base, true, "", nil
+}
+
+// readText reads the file as UTF-8 text, and returns the format to write it
+// back in, see writeText. The message tells the model why the file can't be
+// edited.
+func readText(path string) (content string, format fsext.TextFormat, message string, err error) {
+	content, format, err = fsext.ReadTextFile(path)
+	if errors.Is(err, fsext.ErrBinary) {
+		return "", format, fmt.Sprintf("file %s is a binary file and can't be edited as text", path), nil
+	}
+	if err != nil {
+		return "", format, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, format, "", nil
+}
 
-func recordFileRead(path string) {
-	fileRecordMutex.Lock()
-	defer fileRecordMutex.Unlock()
+// writeText writes the UTF-8 content to the file in the format it was read
+// in. The message tells the model why the content can't be written.
+func writeText(path, content string, format fsext.TextFormat) (message string, err error) {
+	data, err := format.Encode(content)
+	if err != nil {
+		return fmt.Sprintf("file %s can't be written: %s", path, err), nil
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return "", nil
+}
 
-	record, exists := fileRecords[path]
-	if !exists {
-		record = fileRecord{path: path}
+// withLineEndings converts content with LF line endings to CRLF ones when
+// crlf is set, to write it to a file using them.
+func withLineEndings(content string, crlf bool) string {
+	if crlf {
+		content, _ = fsext.ToWindowsLineEndings(content)
 	}
-	record.readTime = time.Now()
-	fileRecords[path] = record
+	return content
 }
 
-func getLastReadTime(path string) time.Time {
-	fileRecordMutex.RLock()
-	defer fileRecordMutex.RUnlock()
+// MergeConflictMetadata is the metadata of the response of an edit tool
+// whose changes conflict with the ones made to the file on disk, so they can
+// be shown to the user.
+type MergeConflictMetadata struct {
+	FilePath  string          `json:"file_path"`
+	Conflicts []diff.Conflict `json:"conflicts"`
+}
 
-	record, exists := fileRecords[path]
-	if !exists {
-		return time.Time{}
+// mergeConflict is the error of a merge of conflicting changes. It tells
+// the model which changes conflict.
+type mergeConflict struct {
+	MergeConflictMetadata
+}
+
+func (c *mergeConflict) Error() string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "file %s has been modified since it was last read, and the changes conflict with yours. No changes made.\n", c.FilePath)
+	for _, conflict := range c.Conflicts {
+		fmt.Fprintf(&msg, "\n<conflict line=\"%d\">\n<on_disk>\n%s</on_disk>\n<yours>\n%s</yours>\n</conflict>\n", conflict.Line, conflict.Theirs, conflict.Ours)
 	}
-	return record.readTime
+	msg.WriteString("\nRead the file again and redo your change on top of the current content.")
+	return msg.String()
 }
 
-func recordFileWrite(path string) {
-	fileRecordMutex.Lock()
-	defer fileRecordMutex.Unlock()
+// response returns the error response of the tool, with the conflicts as
+// metadata.
+func (c *mergeConflict) response(message string) fantasy.ToolResponse {
+	return fantasy.WithResponseMetadata(fantasy.NewTextErrorResponse(message), c.MergeConflictMetadata)
+}
+
+// mergeDiskChanges merges the changes made to a file on disk since the
+// session last saw it, from base to current, with the changes of the session,
+// from base to changed. It returns the merged content with the line endings
+// of current and a note for the model, or the conflicting changes.
+func mergeDiskChanges(path, base, changed, current string) (merged, note string, conflict *mergeConflict) {
+	base, _ = fsext.ToUnixLineEndings(base)
+	changed, _ = fsext.ToUnixLineEndings(changed)
+	current, isCrlf := fsext.ToUnixLineEndings(current)
+
+	result := diff.Merge3(base, changed, current)
+	if len(result.Conflicts) > 0 {
+		return "", "", &mergeConflict{MergeConflictMetadata{FilePath: path, Conflicts: result.Conflicts}}
+	}
+	merged = result.Content
+	if isCrlf {
+		merged, _ = fsext.ToWindowsLineEndings(merged)
+	}
+	return merged, fmt.Sprintf("\n%s had been modified since it was last read, the changes were merged with yours.", path), nil
+}
+
+// updateFileHistory stores the new content of a file in the history of the
+// session. The old content is stored first when it isn't the latest version,
+// as the file was changed since by someone else. Failing to store a version
+// is only logged.
+func updateFileHistory(ctx context.Context, files history.Service, sessionID, path, oldContent, newContent string) error {
+	file, err := files.GetByPathAndSession(ctx, path, sessionID)
+	if err != nil {
+		file, err = files.Create(ctx, sessionID, path, oldContent)
+		if err != nil {
+			return fmt.Errorf("error creating file history: %w", err)
+		}
+	}
+	if file.Content != history.Text(oldContent) {
+		if _, err := files.CreateVersion(ctx, sessionID, path, oldContent); err != nil {
+			slog.Error("Error creating file history version", "error", err)
+		}
+	}
+	if _, err := files.CreateVersion(ctx, sessionID, path, newContent); err != nil {
+		slog.Error("Error creating file history version", "error", err)
+	}
+	return nil
+}
+
+// recordFileRead records that the session of the context read the current
+// content of the file.
+func recordFileRead(ctx context.Context, tracker filetracker.Service, path string) {
+	if err := tracker.RecordRead(ctx, GetSessionFromContext(ctx), path); err != nil {
+		slog.Error("Error recording file read", "path", path, "error", err)
+	}
+}
+
+// recordFileWrite records that the session of the context wrote the current
+// content of the file.
+func recordFileWrite(ctx context.Context, tracker filetracker.Service, path string) {
+	if err := tracker.RecordWrite(ctx, GetSessionFromContext(ctx), path); err != nil {
+		slog.Error("Error recording file write", "path", path, "error", err)
+	}
+}
+
+// moveFileRecords moves the records of the files at or under oldPath to
+// newPath, after the files were moved.
+func moveFileRecords(ctx context.Context, tracker filetracker.Service, oldPath, newPath string) {
+	if err := tracker.Move(ctx, GetSessionFromContext(ctx), oldPath, newPath); err != nil {
+		slog.Error("Error moving file records", "path", oldPath, "error", err)
+	}
+}
 
-	record, exists := fileRecords[path]
-	if !exists {
-		record = fileRecord{path: path}
+// forgetFileRecords removes the records of the files at or under the path,
+// after the files were deleted.
+func forgetFileRecords(ctx context.Context, tracker filetracker.Service, path string) {
+	if err := tracker.Forget(ctx, GetSessionFromContext(ctx), path); err != nil {
+		slog.Error("Error removing file records", "path", path, "error", err)
 	}
-	record.writeTime = time.Now()
-	fileRecords[path] = record
 }
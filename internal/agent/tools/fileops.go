@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// lspFileEdit is an edit an LSP server asked for before a file operation,
+// e.g. updating the imports of a moved file.
+type lspFileEdit struct {
+	changes []WorkspaceFileChange
+}
+
+// fileOperationEdits asks the LSP servers concerned by the path for the
+// edits to make before a file operation. Servers failing to answer, and
+// edits that can't be applied, are skipped.
+func fileOperationEdits(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], path string, isDir bool, workingDir string, request func(*lsp.Client) (protocol.WorkspaceEdit, error)) []lspFileEdit {
+	var edits []lspFileEdit
+	for name, client := range lspClients.Seq2() {
+		if !isDir && !client.HandlesFile(path) {
+			continue
+		}
+		edit, err := request(client)
+		if err != nil {
+			slog.Warn("LSP file operation request failed", "lsp", name, "error", err)
+			continue
+		}
+		changes, err := previewWorkspaceEdit(edit, workingDir)
+		if err != nil {
+			slog.Warn("Skipping LSP file operation edit", "lsp", name, "error", err)
+			continue
+		}
+		if len(changes) > 0 {
+			edits = append(edits, lspFileEdit{changes: changes})
+		}
+	}
+	return edits
+}
+
+// fileOperationChanges returns all the file changes of the edits.
+func fileOperationChanges(edits []lspFileEdit) []WorkspaceFileChange {
+	var changes []WorkspaceFileChange
+	for _, edit := range edits {
+		changes = append(changes, edit.changes...)
+	}
+	return changes
+}
+
+// applyFileOperationEdits applies the edits and records them in the
+// history. It returns the paths of the changed files, and the edits applied
+// so they can be undone if the operation fails, see undoFileOperationEdits.
+func applyFileOperationEdits(ctx context.Context, files history.Service, tracker filetracker.Service, sessionID string, edits []lspFileEdit) ([]string, []lspFileEdit) {
+	var paths []string
+	var applied []lspFileEdit
+	for _, edit := range edits {
+		changed, err := applyWorkspaceEdit(ctx, files, tracker, sessionID, edit.changes)
+		if err != nil {
+			slog.Warn("Failed to apply LSP file operation edit", "error", err)
+			continue
+		}
+		paths = append(paths, changed...)
+		applied = append(applied, edit)
+	}
+	return paths, applied
+}
+
+// undoFileOperationEdits restores the files the applied edits changed, when
+// the file operation they were made for failed, and records it in the
+// history.
+func undoFileOperationEdits(ctx context.Context, files history.Service, tracker filetracker.Service, sessionID string, applied []lspFileEdit) {
+	for _, edit := range slices.Backward(applied) {
+		for _, change := range slices.Backward(edit.changes) {
+			if err := undoPatchChange(change.patchChange()); err != nil {
+				slog.Error("Failed to undo LSP file operation edit", "file", change.FilePath, "error", err)
+				continue
+			}
+			if change.created {
+				continue
+			}
+			if err := updateFileHistory(ctx, files, sessionID, change.FilePath, change.NewContent, change.OldContent); err != nil {
+				slog.Error("Error updating file history", "path", change.FilePath, "error", err)
+			}
+			recordFileWrite(ctx, tracker, change.FilePath)
+		}
+	}
+}
+
+// unrestorableFiles returns the regular files at the path, or under it for
+// directories, that aren't in contents, see textFiles: they can't be
+// restored from the history.
+func unrestorableFiles(path string, contents map[string]string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := contents[p]; !ok && d.Type().IsRegular() {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// checkFileOperationPath resolves a path given to a file operation tool,
+// which must be inside the working directory.
+func checkFileOperationPath(workingDir, path, name string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	absPath := filepath.Clean(filepathext.SmartJoin(workingDir, path))
+	if absPath == filepath.Clean(workingDir) {
+		return "", fmt.Errorf("%s cannot be the working directory", name)
+	}
+	if !fsext.HasPrefix(absPath, workingDir) {
+		return "", fmt.Errorf("%s %s is outside of the working directory", name, absPath)
+	}
+	return absPath, nil
+}
+
+// textFiles returns the content of the text files at the path, or under it
+// for directories, for recording them in the history. Binary and large
+// files are skipped.
+func textFiles(path string) (map[string]string, error) {
+	contents := make(map[string]string)
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > MaxReadSize {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil || !utf8.Valid(content) {
+			return nil
+		}
+		contents[p] = string(content)
+		return nil
+	})
+	return contents, err
+}
+
+// copyPath copies a file or a directory with its content, keeping
+// permissions and symbolic links.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, content, info.Mode().Perm())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFileOperationPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path, err := checkFileOperationPath(dir, "a/b.txt", "path")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "a", "b.txt"), path)
+
+	_, err = checkFileOperationPath(dir, "", "path")
+	require.ErrorContains(t, err, "path is required")
+	_, err = checkFileOperationPath(dir, ".", "path")
+	require.ErrorContains(t, err, "cannot be the working directory")
+	_, err = checkFileOperationPath(dir, "../outside.txt", "source_path")
+	require.ErrorContains(t, err, "source_path")
+	require.ErrorContains(t, err, "outside of the working directory")
+}
+
+func TestCopyPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "bin"), []byte{0xff, 0xfe, 0x00}, 0o644))
+
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, copyPath(src, dst))
+
+	info, err := os.Stat(filepath.Join(dst, "sub", "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	contents, err := textFiles(dst)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		filepath.Join(dst, "a.txt"):         "a\n",
+		filepath.Join(dst, "sub", "run.sh"): "#!/bin/sh\n",
+	}, contents)
+}
+
+func TestUndoFileOperationEdits(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	changed := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	renamed := filepath.Join(dir, "old.go")
+	require.NoError(t, os.WriteFile(changed, []byte("import \"b\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(created, []byte("package a\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "renamed.go"), []byte("package b\n"), 0o644))
+
+	// The edits were applied before a move that failed.
+	applied := []lspFileEdit{{changes: []WorkspaceFileChange{
+		{FilePath: changed, OldContent: "import \"a\"\n", NewContent: "import \"b\"\n"},
+		{FilePath: created, NewContent: "package a\n", created: true},
+		{FilePath: renamed, NewPath: filepath.Join(dir, "renamed.go"), OldContent: "package a\n", NewContent: "package b\n"},
+	}}}
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	files := &mockHistoryService{Broker: pubsub.NewBroker[history.File]()}
+	undoFileOperationEdits(ctx, files, &mockFileTracker{}, "session", applied)
+
+	content, err := os.ReadFile(changed)
+	require.NoError(t, err)
+	require.Equal(t, "import \"a\"\n", string(content))
+	require.NoFileExists(t, created)
+	require.NoFileExists(t, filepath.Join(dir, "renamed.go"))
+	content, err = os.ReadFile(renamed)
+	require.NoError(t, err)
+	require.Equal(t, "package a\n", string(content))
+}
+
+func TestUnrestorableFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "image.png"), []byte{0x89, 0x50, 0xff, 0x00}, 0o644))
+
+	contents, err := textFiles(dir)
+	require.NoError(t, err)
+	paths, err := unrestorableFiles(dir, contents)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "sub", "image.png")}, paths)
+}
+
+func TestMoveHistoryChanges(t *testing.T) {
+	t.Parallel()
+
+	src := filepath.Join("work", "pkg")
+	dst := filepath.Join("work", "lib")
+	original := map[string]string{
+		filepath.Join(src, "a.go"): "package pkg\n",
+		filepath.Join(src, "b.go"): "import \"x/pkg\"\n",
+	}
+	// The LSP edits changed b.go before the move.
+	contents := map[string]string{
+		filepath.Join(src, "a.go"): "package pkg\n",
+		filepath.Join(src, "b.go"): "import \"x/lib\"\n",
+	}
+
+	require.Equal(t, []history.FileChange{
+		{Path: filepath.Join(src, "a.go"), OldContent: "package pkg\n"},
+		{Path: filepath.Join(dst, "a.go"), NewContent: "package pkg\n"},
+		{Path: filepath.Join(src, "b.go"), OldContent: "import \"x/pkg\"\n"},
+		{Path: filepath.Join(dst, "b.go"), NewContent: "import \"x/lib\"\n"},
+	}, moveHistoryChanges(src, dst, original, contents))
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/lsp"
+)
+
+const (
+	defaultFormatTimeout = 10 * time.Second
+	maxFormatDiffLines   = 100
+)
+
+// Formatter formats the files the edit tools write with the formatters
+// configured for their languages. A nil Formatter formats nothing.
+type Formatter struct {
+	formatters config.Formatters
+	lspClients *csync.Map[string, *lsp.Client]
+	workingDir string
+}
+
+func NewFormatter(formatters config.Formatters, lspClients *csync.Map[string, *lsp.Client], workingDir string) *Formatter {
+	return &Formatter{
+		formatters: formatters,
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+// formatText formats the file like formatFile when its text is stored as
+// plain UTF-8, as formatters write their output back that way.
+func (f *Formatter) formatText(ctx context.Context, path, content string, format fsext.TextFormat) (string, string) {
+	if !format.Plain() {
+		return content, ""
+	}
+	return f.formatFile(ctx, path, content)
+}
+
+// formatChange formats the file written with newContent like formatText and
+// returns its content afterwards and the note for the model. The contents
+// use LF line endings, crlf tells the file was written with CRLF ones, so
+// only the changes of the formatter show in the diff. When the formatter
+// changed the file, the diff of the change from oldContent is regenerated
+// into patch, if not nil, additions and removals.
+func (f *Formatter) formatChange(ctx context.Context, path, oldContent, newContent string, format fsext.TextFormat, crlf bool, patch *string, additions, removals *int) (string, string) {
+	formatted, note := f.formatText(ctx, path, withLineEndings(newContent, crlf), format)
+	formatted, _ = fsext.ToUnixLineEndings(formatted)
+	if formatted == newContent {
+		return formatted, note
+	}
+	p, a, r := diff.GenerateDiff(oldContent, formatted, strings.TrimPrefix(path, f.workingDir))
+	if patch != nil {
+		*patch = p
+	}
+	*additions, *removals = a, r
+	return formatted, note
+}
+
+// formatFile formats a file that was just written with content and writes
+// the result back. It returns the content of the file afterwards and a note
+// for the model describing what the formatter changed, if anything.
+func (f *Formatter) formatFile(ctx context.Context, path, content string) (string, string) {
+	if f == nil {
+		return content, ""
+	}
+	name, cfg, ok := f.formatterFor(path)
+	if !ok {
+		return content, ""
+	}
+
+	timeout := defaultFormatTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var formatted, by string
+	var err error
+	if cfg.Command != "" {
+		by = cfg.Command
+		formatted, err = runFormatCommand(ctx, cfg, path, content, f.workingDir)
+	} else {
+		by, formatted, err = f.formatWithLSP(ctx, path)
+	}
+	if err != nil {
+		slog.Warn("Failed to format file", "formatter", name, "file", path, "error", err)
+		return content, fmt.Sprintf("\nThe file was not formatted, the %s formatter failed: %s", name, err)
+	}
+	if formatted == content {
+		return content, ""
+	}
+	if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+		slog.Warn("Failed to write formatted file", "file", path, "error", err)
+		return content, fmt.Sprintf("\nThe file was not formatted, writing it failed: %s", err)
+	}
+	return formatted, formatNote(by, content, formatted, relativePath(f.workingDir, path))
+}
+
+// formatterFor returns the first enabled formatter, by name, handling the
+// file.
+func (f *Formatter) formatterFor(path string) (string, config.FormatterConfig, bool) {
+	names := make([]string, 0, len(f.formatters))
+	for name := range f.formatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		cfg := f.formatters[name]
+		if cfg.Disabled || (cfg.Command == "" && !cfg.LSP) {
+			continue
+		}
+		if lsp.MatchesFileTypes(cfg.FileTypes, path) {
+			return name, cfg, true
+		}
+	}
+	return "", config.FormatterConfig{}, false
+}
+
+// formatWithLSP formats the file with the first LSP server handling it that
+// supports formatting. It returns the name of the server and the result.
+func (f *Formatter) formatWithLSP(ctx context.Context, path string) (string, string, error) {
+	if f.lspClients == nil {
+		return "", "", errors.New("no LSP server is running")
+	}
+	for client := range f.lspClients.Seq() {
+		if !client.HandlesFile(path) {
+			continue
+		}
+		formatted, err := client.Format(ctx, path)
+		if errors.Is(err, lsp.ErrFormattingNotSupported) {
+			continue
+		}
+		return client.GetName(), formatted, err
+	}
+	return "", "", errors.New("no LSP server can format this file")
+}
+
+// runFormatCommand pipes the content through the formatter command, with
+// {file} in its arguments replaced by the path of the file.
+func runFormatCommand(ctx context.Context, cfg config.FormatterConfig, path, content, workingDir string) (string, error) {
+	args := make([]string, len(cfg.Args))
+	for i, arg := range cfg.Args {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+	cmd := exec.CommandContext(ctx, cfg.Command, args...)
+	cmd.Dir = workingDir
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	if stdout.Len() == 0 && len(content) > 0 {
+		return "", errors.New("the command printed nothing")
+	}
+	return stdout.String(), nil
+}
+
+// formatNote tells the model how the formatter changed the file, so it
+// doesn't base its next edits on the unformatted content.
+func formatNote(formatter, before, after, relPath string) string {
+	before, _ = fsext.ToUnixLineEndings(before)
+	after, _ = fsext.ToUnixLineEndings(after)
+	patch, _, _ := diff.GenerateDiff(before, after, relPath)
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	if len(lines) > maxFormatDiffLines {
+		lines = append(lines[:maxFormatDiffLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxFormatDiffLines))
+	}
+	return fmt.Sprintf("\nThe file was then formatted with %s, which changed it as follows:\n%s", formatter, strings.Join(lines, "\n"))
+}
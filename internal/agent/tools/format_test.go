@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatterFormatFile(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("formatter commands are unix tools")
+	}
+
+	formatter := NewFormatter(config.Formatters{
+		"upper": {
+			FileTypes: []string{"txt"},
+			Command:   "tr",
+			Args:      []string{"a-z", "A-Z"},
+		},
+		"path": {
+			FileTypes: []string{".md"},
+			Command:   "sh",
+			Args:      []string{"-c", `cat; echo "$0"`, "{file}"},
+		},
+		"broken": {
+			FileTypes: []string{"go"},
+			Command:   "false",
+		},
+		"disabled": {
+			Disabled:  true,
+			FileTypes: []string{"py"},
+			Command:   "tr",
+			Args:      []string{"a-z", "A-Z"},
+		},
+	}, nil, t.TempDir())
+
+	write := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("formats and writes the file", func(t *testing.T) {
+		t.Parallel()
+		path := write(t, "a.txt", "hello\n")
+		content, note := formatter.formatFile(t.Context(), path, "hello\n")
+		require.Equal(t, "HELLO\n", content)
+		require.Contains(t, note, "formatted with tr")
+		require.Contains(t, note, "+HELLO")
+		written, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "HELLO\n", string(written))
+	})
+
+	t.Run("replaces the file placeholder", func(t *testing.T) {
+		t.Parallel()
+		path := write(t, "README.md", "# Title\n")
+		content, _ := formatter.formatFile(t.Context(), path, "# Title\n")
+		require.Equal(t, "# Title\n"+path+"\n", content)
+	})
+
+	t.Run("keeps the content when the formatter fails", func(t *testing.T) {
+		t.Parallel()
+		path := write(t, "main.go", "package main\n")
+		content, note := formatter.formatFile(t.Context(), path, "package main\n")
+		require.Equal(t, "package main\n", content)
+		require.Contains(t, note, "the broken formatter failed")
+	})
+
+	t.Run("skips other files", func(t *testing.T) {
+		t.Parallel()
+		for _, name := range []string{"main.py", "main.rs"} {
+			path := write(t, name, "x = 1\n")
+			content, note := formatter.formatFile(t.Context(), path, "x = 1\n")
+			require.Equal(t, "x = 1\n", content)
+			require.Empty(t, note)
+		}
+	})
+
+	t.Run("nil formatter", func(t *testing.T) {
+		t.Parallel()
+		var formatter *Formatter
+		content, note := formatter.formatFile(t.Context(), "a.txt", "hello\n")
+		require.Equal(t, "hello\n", content)
+		require.Empty(t, note)
+	})
+}
+
+func TestFormatterFormatChange(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("formatter commands are unix tools")
+	}
+
+	dir := t.TempDir()
+	formatter := NewFormatter(config.Formatters{
+		"upper": {
+			FileTypes: []string{"txt"},
+			Command:   "tr",
+			Args:      []string{"a-z", "A-Z"},
+		},
+	}, nil, dir)
+
+	path := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\n"), 0o644))
+	patch, additions, removals := "unformatted", 1, 1
+	content, note := formatter.formatChange(t.Context(), path, "one\nTWO\n", "one\ntwo\n", fsext.TextFormat{}, false, &patch, &additions, &removals)
+	require.Equal(t, "ONE\nTWO\n", content)
+	require.NotEmpty(t, note)
+	require.Contains(t, patch, "+ONE")
+	require.Equal(t, 1, additions)
+	require.Equal(t, 1, removals)
+
+	// Without a change by the formatter, the diff is kept.
+	path = filepath.Join(dir, "b.md")
+	require.NoError(t, os.WriteFile(path, []byte("new\n"), 0o644))
+	patch, additions, removals = "unformatted", 1, 0
+	content, note = formatter.formatChange(t.Context(), path, "", "new\n", fsext.TextFormat{}, false, &patch, &additions, &removals)
+	require.Equal(t, "new\n", content)
+	require.Empty(t, note)
+	require.Equal(t, "unformatted", patch)
+	require.Equal(t, 1, additions)
+	require.Zero(t, removals)
+
+	// CRLF files are formatted as written, and diffed with LF line endings
+	// so only the changes of the formatter show.
+	path = filepath.Join(dir, "c.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\r\ntwo\r\n"), 0o644))
+	patch, additions, removals = "unformatted", 1, 1
+	content, note = formatter.formatChange(t.Context(), path, "one\nTWO\n", "one\ntwo\n", fsext.TextFormat{}, true, &patch, &additions, &removals)
+	require.Equal(t, "ONE\nTWO\n", content)
+	require.NotEmpty(t, note)
+	require.Contains(t, patch, "+ONE")
+	require.NotContains(t, patch, "\r")
+	require.Equal(t, 1, additions)
+	require.Equal(t, 1, removals)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "ONE\r\nTWO\r\n", string(data))
+}
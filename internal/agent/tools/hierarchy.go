@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+type CallHierarchyParams struct {
+	FilePath  string `json:"file_path" description:"The path to the file containing the function"`
+	Line      int    `json:"line,omitempty" description:"The line of the function (1-based)"`
+	Column    int    `json:"column,omitempty" description:"The column of the function (1-based)"`
+	Symbol    string `json:"symbol,omitempty" description:"The function name, used to find the position when line or column are not given"`
+	Direction string `json:"direction,omitempty" description:"incoming to list the callers, transitively, or outgoing to list the functions it calls (default incoming)"`
+	Depth     int    `json:"depth,omitempty" description:"How many levels of calls to follow (default 3, at most 6)"`
+}
+
+type TypeHierarchyParams struct {
+	FilePath  string `json:"file_path" description:"The path to the file containing the type"`
+	Line      int    `json:"line,omitempty" description:"The line of the type (1-based)"`
+	Column    int    `json:"column,omitempty" description:"The column of the type (1-based)"`
+	Symbol    string `json:"symbol,omitempty" description:"The type name, used to find the position when line or column are not given"`
+	Direction string `json:"direction,omitempty" description:"subtypes to list the types implementing or extending it, or supertypes to list the types it implements or extends (default subtypes)"`
+	Depth     int    `json:"depth,omitempty" description:"How many levels of the hierarchy to follow (default 3, at most 6)"`
+}
+
+const (
+	CallHierarchyToolName = "lsp_call_hierarchy"
+	TypeHierarchyToolName = "lsp_type_hierarchy"
+
+	defaultHierarchyDepth = 3
+	maxHierarchyDepth     = 6
+	// maxHierarchyNodes is how many items a hierarchy tree has at most.
+	maxHierarchyNodes = 200
+)
+
+//go:embed call_hierarchy.md
+var callHierarchyDescription []byte
+
+//go:embed type_hierarchy.md
+var typeHierarchyDescription []byte
+
+// hierarchyExpandFunc returns the items an item of a hierarchy leads to.
+type hierarchyExpandFunc func(ctx context.Context, item lsp.HierarchyItem) ([]lsp.HierarchyCall, error)
+
+func NewCallHierarchyTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		CallHierarchyToolName,
+		string(callHierarchyDescription),
+		func(ctx context.Context, params CallHierarchyParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			client, pos, done, err := resolveLSPPosition(ctx, lspClients, touchLSPs, workingDir, LSPPositionParams{
+				FilePath: params.FilePath,
+				Line:     params.Line,
+				Column:   params.Column,
+				Symbol:   params.Symbol,
+			})
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			defer done()
+
+			var expand hierarchyExpandFunc
+			var what, noun, sites string
+			switch params.Direction {
+			case "", "incoming":
+				expand, what, noun, sites = client.IncomingCalls, "incoming calls", "caller", "calls at line"
+			case "outgoing":
+				expand, what, noun, sites = client.OutgoingCalls, "outgoing calls", "callee", "called at line"
+			default:
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid direction %q, use incoming or outgoing", params.Direction)), nil
+			}
+
+			roots, err := client.PrepareCallHierarchy(ctx, pos.path, pos.line, pos.column)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get the call hierarchy of %s: %s", pos, err)), nil
+			}
+			if len(roots) == 0 {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("no function or method at %s", pos)), nil
+			}
+			return hierarchyResponse(ctx, roots, expand, hierarchyDepth(params.Depth), what, noun, sites, pos, workingDir), nil
+		})
+}
+
+func NewTypeHierarchyTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		TypeHierarchyToolName,
+		string(typeHierarchyDescription),
+		func(ctx context.Context, params TypeHierarchyParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			client, pos, done, err := resolveLSPPosition(ctx, lspClients, touchLSPs, workingDir, LSPPositionParams{
+				FilePath: params.FilePath,
+				Line:     params.Line,
+				Column:   params.Column,
+				Symbol:   params.Symbol,
+			})
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			defer done()
+
+			var types func(context.Context, lsp.HierarchyItem) ([]lsp.HierarchyItem, error)
+			var what, noun string
+			switch params.Direction {
+			case "", "subtypes":
+				types, what, noun = client.Subtypes, "subtypes", "subtype"
+			case "supertypes":
+				types, what, noun = client.Supertypes, "supertypes", "supertype"
+			default:
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid direction %q, use subtypes or supertypes", params.Direction)), nil
+			}
+			expand := func(ctx context.Context, item lsp.HierarchyItem) ([]lsp.HierarchyCall, error) {
+				items, err := types(ctx, item)
+				calls := make([]lsp.HierarchyCall, 0, len(items))
+				for _, item := range items {
+					calls = append(calls, lsp.HierarchyCall{Item: item})
+				}
+				return calls, err
+			}
+
+			roots, err := client.PrepareTypeHierarchy(ctx, pos.path, pos.line, pos.column)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get the type hierarchy of %s: %s", pos, err)), nil
+			}
+			if len(roots) == 0 {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("no type at %s", pos)), nil
+			}
+			return hierarchyResponse(ctx, roots, expand, hierarchyDepth(params.Depth), what, noun, "", pos, workingDir), nil
+		})
+}
+
+func hierarchyDepth(depth int) int {
+	if depth <= 0 {
+		return defaultHierarchyDepth
+	}
+	return min(depth, maxHierarchyDepth)
+}
+
+// hierarchyResponse follows the hierarchy from the roots and lists it. what
+// names the followed relation, noun the items it leads to.
+func hierarchyResponse(ctx context.Context, roots []lsp.HierarchyItem, expand hierarchyExpandFunc, depth int, what, noun, sites string, pos lspPosition, workingDir string) fantasy.ToolResponse {
+	tree := buildHierarchy(ctx, roots, depth, expand)
+	if len(tree.roots) == 1 && tree.roots[0].err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get %s of %s: %s", what, pos, tree.roots[0].err))
+	}
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "%s of %s, up to %d level(s):\n\n", upperFirst(what), pos, depth)
+	output.WriteString(formatHierarchy(tree, sites, workingDir))
+	items, files := tree.count()
+	fmt.Fprintf(&output, "\n%d unique %s(s) in %d file(s).\n", items, noun, files)
+	if tree.truncated {
+		fmt.Fprintf(&output, "The tree was cut at %d entries, call this tool again on a branch to follow it.\n", maxHierarchyNodes)
+	} else if tree.deeper {
+		output.WriteString("Items on the last level were not followed, increase depth or call this tool again on one of them to go further.\n")
+	}
+	return fantasy.NewTextResponse(output.String())
+}
+
+// hierarchyNode is an item of a hierarchy tree and the items it leads to.
+type hierarchyNode struct {
+	item lsp.HierarchyItem
+	// ranges are the call sites between the item and its parent.
+	ranges   []protocol.Range
+	children []*hierarchyNode
+	// repeated is set when the item is already listed elsewhere in the
+	// tree, with its children.
+	repeated bool
+	// err is set when following the hierarchy from the item failed.
+	err error
+}
+
+type hierarchyTree struct {
+	roots []*hierarchyNode
+	// truncated is set when the tree hit maxHierarchyNodes.
+	truncated bool
+	// deeper is set when the tree stopped at the depth limit with items
+	// left to follow.
+	deeper bool
+}
+
+// buildHierarchy follows the hierarchy from the roots breadth first, up to
+// depth levels and maxHierarchyNodes items. Items that appear several times
+// are only followed once, which also stops at cycles.
+func buildHierarchy(ctx context.Context, roots []lsp.HierarchyItem, depth int, expand hierarchyExpandFunc) *hierarchyTree {
+	tree := &hierarchyTree{}
+	seen := make(map[string]bool)
+	var level []*hierarchyNode
+	for _, item := range roots {
+		node := &hierarchyNode{item: item}
+		tree.roots = append(tree.roots, node)
+		seen[hierarchyKey(item)] = true
+		level = append(level, node)
+	}
+
+	nodes := len(roots)
+	for range depth {
+		var next []*hierarchyNode
+		for _, node := range level {
+			if ctx.Err() != nil {
+				node.err = ctx.Err()
+				continue
+			}
+			calls, err := expand(ctx, node.item)
+			if err != nil {
+				node.err = err
+				continue
+			}
+			slices.SortStableFunc(calls, func(a, b lsp.HierarchyCall) int {
+				return cmp.Or(
+					strings.Compare(string(a.Item.URI), string(b.Item.URI)),
+					cmp.Compare(a.Item.SelectionRange.Start.Line, b.Item.SelectionRange.Start.Line),
+				)
+			})
+			for _, call := range calls {
+				if nodes == maxHierarchyNodes {
+					tree.truncated = true
+					break
+				}
+				nodes++
+				child := &hierarchyNode{item: call.Item, ranges: call.Ranges}
+				node.children = append(node.children, child)
+				key := hierarchyKey(call.Item)
+				if seen[key] {
+					child.repeated = true
+					continue
+				}
+				seen[key] = true
+				next = append(next, child)
+			}
+		}
+		level = next
+		if len(level) == 0 || tree.truncated {
+			break
+		}
+	}
+	tree.deeper = len(level) > 0 && !tree.truncated
+	return tree
+}
+
+func hierarchyKey(item lsp.HierarchyItem) string {
+	start := item.SelectionRange.Start
+	return fmt.Sprintf("%s:%d:%d", item.URI, start.Line, start.Character)
+}
+
+// count returns the number of distinct items below the roots and the number
+// of files they are in.
+func (t *hierarchyTree) count() (int, int) {
+	items := make(map[string]bool)
+	files := make(map[protocol.DocumentURI]bool)
+	var walk func(nodes []*hierarchyNode)
+	walk = func(nodes []*hierarchyNode) {
+		for _, node := range nodes {
+			items[hierarchyKey(node.item)] = true
+			files[node.item.URI] = true
+			walk(node.children)
+		}
+	}
+	for _, root := range t.roots {
+		walk(root.children)
+	}
+	return len(items), len(files)
+}
+
+// formatHierarchy prints the tree as an indented list. sites describes the
+// call sites of each item, if any.
+func formatHierarchy(tree *hierarchyTree, sites, workingDir string) string {
+	var output strings.Builder
+	var walk func(node *hierarchyNode, depth int)
+	walk = func(node *hierarchyNode, depth int) {
+		item := node.item
+		location := string(item.URI)
+		if path, err := item.URI.Path(); err == nil {
+			location = relativePath(workingDir, path)
+		}
+		fmt.Fprintf(&output, "%s- %s %s", strings.Repeat("  ", depth), lsp.SymbolKindName(item.Kind), item.Name)
+		if item.Detail != "" {
+			fmt.Fprintf(&output, " (%s)", item.Detail)
+		}
+		fmt.Fprintf(&output, " %s:%d:%d", location, item.SelectionRange.Start.Line+1, item.SelectionRange.Start.Character+1)
+		if sites != "" && len(node.ranges) > 0 {
+			lines := make([]string, 0, len(node.ranges))
+			for _, r := range node.ranges {
+				lines = append(lines, fmt.Sprint(r.Start.Line+1))
+			}
+			plural := ""
+			if len(lines) > 1 {
+				plural = "s"
+			}
+			fmt.Fprintf(&output, ", %s%s %s", sites, plural, strings.Join(lines, ", "))
+		}
+		if node.repeated {
+			output.WriteString(" (listed elsewhere)")
+		}
+		if node.err != nil {
+			fmt.Fprintf(&output, " (failed to follow: %s)", node.err)
+		}
+		output.WriteString("\n")
+		for _, child := range node.children {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range tree.roots {
+		walk(root, 0)
+	}
+	return output.String()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func hierarchyItem(name string, line uint32) lsp.HierarchyItem {
+	return lsp.HierarchyItem{
+		Name:           name,
+		Kind:           protocol.Function,
+		URI:            protocol.URIFromPath("/project/" + name + ".go"),
+		SelectionRange: protocol.Range{Start: protocol.Position{Line: line, Character: 5}},
+	}
+}
+
+func TestBuildHierarchy(t *testing.T) {
+	t.Parallel()
+
+	// run is called by main and serve, serve by main, and main by run.
+	calls := map[string][]lsp.HierarchyCall{
+		"run": {
+			{Item: hierarchyItem("serve", 3), Ranges: []protocol.Range{{Start: protocol.Position{Line: 7}}}},
+			{Item: hierarchyItem("main", 1), Ranges: []protocol.Range{{Start: protocol.Position{Line: 4}}, {Start: protocol.Position{Line: 9}}}},
+		},
+		"serve": {{Item: hierarchyItem("main", 1)}},
+		"main":  {{Item: hierarchyItem("run", 10)}},
+	}
+	expand := func(_ context.Context, item lsp.HierarchyItem) ([]lsp.HierarchyCall, error) {
+		return calls[item.Name], nil
+	}
+
+	t.Run("follows each item once", func(t *testing.T) {
+		t.Parallel()
+		tree := buildHierarchy(t.Context(), []lsp.HierarchyItem{hierarchyItem("run", 10)}, 5, expand)
+		require.False(t, tree.truncated)
+		require.False(t, tree.deeper)
+		require.Equal(t, "- function run /project/run.go:11:6\n"+
+			"  - function main /project/main.go:2:6, calls at lines 5, 10\n"+
+			"    - function run /project/run.go:11:6 (listed elsewhere)\n"+
+			"  - function serve /project/serve.go:4:6, calls at line 8\n"+
+			"    - function main /project/main.go:2:6 (listed elsewhere)\n",
+			formatHierarchy(tree, "calls at line", "/other"))
+		items, files := tree.count()
+		require.Equal(t, 3, items)
+		require.Equal(t, 3, files)
+	})
+
+	t.Run("stops at the depth", func(t *testing.T) {
+		t.Parallel()
+		tree := buildHierarchy(t.Context(), []lsp.HierarchyItem{hierarchyItem("run", 10)}, 1, expand)
+		require.True(t, tree.deeper)
+		require.Len(t, tree.roots[0].children, 2)
+		require.Empty(t, tree.roots[0].children[0].children)
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		t.Parallel()
+		failing := func(_ context.Context, item lsp.HierarchyItem) ([]lsp.HierarchyCall, error) {
+			if item.Name == "serve" {
+				return nil, errors.New("server crashed")
+			}
+			return calls[item.Name], nil
+		}
+		tree := buildHierarchy(t.Context(), []lsp.HierarchyItem{hierarchyItem("run", 10)}, 2, failing)
+		require.Contains(t, formatHierarchy(tree, "", "/project"), "- function serve serve.go:4:6 (failed to follow: server crashed)\n")
+	})
+}
+
+func TestBuildHierarchyTruncates(t *testing.T) {
+	t.Parallel()
+
+	// Every function has ten distinct callers.
+	expand := func(_ context.Context, item lsp.HierarchyItem) ([]lsp.HierarchyCall, error) {
+		var calls []lsp.HierarchyCall
+		for i := range 10 {
+			calls = append(calls, lsp.HierarchyCall{Item: hierarchyItem(fmt.Sprintf("%s_%d", item.Name, i), 1)})
+		}
+		return calls, nil
+	}
+	tree := buildHierarchy(t.Context(), []lsp.HierarchyItem{hierarchyItem("f", 1)}, maxHierarchyDepth, expand)
+	require.True(t, tree.truncated)
+	require.False(t, tree.deeper)
+	items, _ := tree.count()
+	require.Equal(t, maxHierarchyNodes-1, items)
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/lsp"
+)
+
+const HoverToolName = "lsp_hover"
+
+//go:embed hover.md
+var hoverDescription []byte
+
+func NewHoverTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		HoverToolName,
+		string(hoverDescription),
+		func(ctx context.Context, params LSPPositionParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			client, pos, done, err := resolveLSPPosition(ctx, lspClients, touchLSPs, workingDir, params)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			defer done()
+
+			text, err := client.Hover(ctx, pos.path, pos.line, pos.column)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get hover information: %s", err)), nil
+			}
+			if text == "" {
+				return fantasy.NewTextResponse(fmt.Sprintf("No hover information for %s", pos)), nil
+			}
+			return fantasy.NewTextResponse(fmt.Sprintf("%s:\n\n%s", pos, text)), nil
+		})
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/shell"
@@ -12,6 +15,14 @@ import (
 
 const (
 	JobOutputToolName = "job_output"
+
+	// DefaultJobOutputWaitTimeout is how long wait_for waits by default.
+	DefaultJobOutputWaitTimeout = 30 * time.Second
+	// MaxJobOutputWaitTimeout is the longest wait_for can wait.
+	MaxJobOutputWaitTimeout = 10 * time.Minute
+	// MaxJobOutputBytes is the most output a call returns, the end of it by
+	// default, or from since on.
+	MaxJobOutputBytes = 8 * 1024
 )
 
 //go:embed job_output.md
@@ -19,6 +30,10 @@ var jobOutputDescription []byte
 
 type JobOutputParams struct {
 	ShellID string `json:"shell_id" description:"The ID of the background shell to retrieve output from"`
+	Since   *int64 `json:"since,omitempty" description:"Return the output from this offset on instead of the end of it, use the next offset reported by a previous call or 0 for the start"`
+	Tail    int    `json:"tail,omitempty" description:"Only return the last N lines of the output"`
+	WaitFor string `json:"wait_for,omitempty" description:"Regular expression to wait for in the output after since; returns once it matches, the job exits or the timeout passes"`
+	Timeout int    `json:"timeout,omitempty" description:"Seconds to wait for wait_for before returning (default 30, max 600)"`
 }
 
 type JobOutputResponseMetadata struct {
@@ -27,6 +42,7 @@ type JobOutputResponseMetadata struct {
 	Description      string `json:"description"`
 	Done             bool   `json:"done"`
 	WorkingDirectory string `json:"working_directory"`
+	NextOffset       int64  `json:"next_offset"`
 }
 
 func NewJobOutputTool() fantasy.AgentTool {
@@ -44,14 +60,58 @@ func NewJobOutputTool() fantasy.AgentTool {
 				return fantasy.NewTextErrorResponse(fmt.Sprintf("background shell not found: %s", params.ShellID)), nil
 			}
 
-			stdout, stderr, done, err := bgShell.GetOutput()
+			var since int64
+			if params.Since != nil {
+				since = *params.Since
+			}
 
-			var outputParts []string
-			if stdout != "" {
-				outputParts = append(outputParts, stdout)
+			var notes []string
+			if params.WaitFor != "" {
+				re, err := regexp.Compile(params.WaitFor)
+				if err != nil {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid wait_for pattern: %s", err)), nil
+				}
+				timeout := DefaultJobOutputWaitTimeout
+				if params.Timeout > 0 {
+					timeout = min(time.Duration(params.Timeout)*time.Second, MaxJobOutputWaitTimeout)
+				}
+				waitCtx, cancel := context.WithTimeout(ctx, timeout)
+				matched := bgShell.WaitForOutput(waitCtx, since, re)
+				cancel()
+				switch {
+				case matched:
+					notes = append(notes, fmt.Sprintf("Output matched %q", params.WaitFor))
+				case bgShell.IsDone():
+					notes = append(notes, fmt.Sprintf("Job exited before output matched %q", params.WaitFor))
+				case ctx.Err() != nil:
+					return fantasy.ToolResponse{}, ctx.Err()
+				default:
+					notes = append(notes, fmt.Sprintf("Timed out after %s waiting for output to match %q", timeout, params.WaitFor))
+				}
+			}
+
+			_, _, done, err := bgShell.GetOutput()
+			output, start, next := bgShell.ReadOutput(since)
+			if start > since {
+				notes = append(notes, fmt.Sprintf("Output before offset %d was dropped from the buffer", start))
 			}
-			if stderr != "" {
-				outputParts = append(outputParts, stderr)
+			if params.Tail > 0 {
+				output = tailLines(output, params.Tail)
+			}
+			if len(output) > MaxJobOutputBytes {
+				if params.Since != nil && params.Tail == 0 {
+					output = headBytes(output, MaxJobOutputBytes)
+					next = start + int64(len(output))
+					notes = append(notes, fmt.Sprintf("More output follows, call again with since %d to read it", next))
+				} else {
+					output = tailBytes(output, MaxJobOutputBytes)
+					notes = append(notes, fmt.Sprintf("Output before offset %d was skipped, set since to read it", next-int64(len(output))))
+				}
+			}
+
+			var outputParts []string
+			if output != "" {
+				outputParts = append(outputParts, strings.TrimSuffix(output, "\n"))
 			}
 
 			status := "running"
@@ -65,7 +125,7 @@ func NewJobOutputTool() fantasy.AgentTool {
 				}
 			}
 
-			output := strings.Join(outputParts, "\n")
+			output = strings.Join(outputParts, "\n")
 
 			metadata := JobOutputResponseMetadata{
 				ShellID:          params.ShellID,
@@ -73,13 +133,46 @@ func NewJobOutputTool() fantasy.AgentTool {
 				Description:      bgShell.Description,
 				Done:             done,
 				WorkingDirectory: bgShell.WorkingDir,
+				NextOffset:       next,
 			}
 
 			if output == "" {
 				output = BashNoOutput
 			}
 
-			result := fmt.Sprintf("Status: %s\n\n%s", status, output)
+			header := fmt.Sprintf("Status: %s\nNext offset: %d", status, next)
+			for _, note := range notes {
+				header += "\n" + note
+			}
+			result := fmt.Sprintf("%s\n\n%s", header, output)
 			return fantasy.WithResponseMetadata(fantasy.NewTextResponse(result), metadata), nil
 		})
 }
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[len(lines)-n:], "\n")
+}
+
+// headBytes returns the first n bytes of s at most, without splitting a
+// character.
+func headBytes(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// tailBytes returns the last n bytes of s at most, without splitting a
+// character.
+func tailBytes(s string, n int) string {
+	i := len(s) - n
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return s[i:]
+}
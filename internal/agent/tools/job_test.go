@@ -2,9 +2,13 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/stretchr/testify/require"
 )
@@ -17,7 +21,7 @@ func TestBackgroundShell_Integration(t *testing.T) {
 
 	// Start a background shell
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "echo 'hello background' && echo 'done'", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "echo 'hello background' && echo 'done'", "")
 	require.NoError(t, err)
 	require.NotEmpty(t, bgShell.ID)
 
@@ -44,7 +48,7 @@ func TestBackgroundShell_Kill(t *testing.T) {
 
 	// Start a long-running background shell
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "sleep 100", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "sleep 100", "")
 	require.NoError(t, err)
 
 	// Kill it
@@ -67,7 +71,7 @@ func TestBackgroundShell_MultipleOutputCalls(t *testing.T) {
 
 	// Start a background shell
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "echo 'step 1' && echo 'step 2' && echo 'step 3'", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "echo 'step 1' && echo 'step 2' && echo 'step 3'", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -103,7 +107,7 @@ func TestBackgroundShell_EmptyOutput(t *testing.T) {
 
 	// Start a background shell with no output
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "sleep 0.1", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "sleep 0.1", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -125,7 +129,7 @@ func TestBackgroundShell_ExitCode(t *testing.T) {
 
 	// Start a background shell that exits with non-zero code
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "echo 'failing' && exit 42", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "echo 'failing' && exit 42", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -153,7 +157,7 @@ func TestBackgroundShell_WithBlockFuncs(t *testing.T) {
 
 	// Start a background shell with a blocked command
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, blockFuncs, "curl example.com", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, blockFuncs, nil, "curl example.com", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -182,7 +186,7 @@ func TestBackgroundShell_StdoutAndStderr(t *testing.T) {
 
 	// Start a background shell with both stdout and stderr
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "echo 'stdout message' && echo 'stderr message' >&2", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "echo 'stdout message' && echo 'stderr message' >&2", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -204,7 +208,7 @@ func TestBackgroundShell_ConcurrentAccess(t *testing.T) {
 
 	// Start a background shell
 	bgManager := shell.GetBackgroundShellManager()
-	bgShell, err := bgManager.Start(ctx, workingDir, nil, "for i in 1 2 3 4 5; do echo \"line $i\"; sleep 0.05; done", "")
+	bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "for i in 1 2 3 4 5; do echo \"line $i\"; sleep 0.05; done", "")
 	require.NoError(t, err)
 	defer bgManager.Kill(bgShell.ID)
 
@@ -257,7 +261,7 @@ func TestBackgroundShell_List(t *testing.T) {
 	// Start multiple background shells
 	shells := make([]*shell.BackgroundShell, 3)
 	for i := range 3 {
-		bgShell, err := bgManager.Start(ctx, workingDir, nil, "sleep 1", "")
+		bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "sleep 1", "")
 		require.NoError(t, err)
 		shells[i] = bgShell
 	}
@@ -286,7 +290,7 @@ func TestBackgroundShell_AutoBackground(t *testing.T) {
 	t.Run("quick command completes synchronously", func(t *testing.T) {
 		t.Parallel()
 		bgManager := shell.GetBackgroundShellManager()
-		bgShell, err := bgManager.Start(ctx, workingDir, nil, "echo 'quick'", "")
+		bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "echo 'quick'", "")
 		require.NoError(t, err)
 
 		// Wait threshold time
@@ -307,7 +311,7 @@ func TestBackgroundShell_AutoBackground(t *testing.T) {
 	t.Run("long command stays in background", func(t *testing.T) {
 		t.Parallel()
 		bgManager := shell.GetBackgroundShellManager()
-		bgShell, err := bgManager.Start(ctx, workingDir, nil, "sleep 20 && echo '20 seconds completed'", "")
+		bgShell, err := bgManager.Start(ctx, workingDir, nil, nil, "sleep 20 && echo '20 seconds completed'", "")
 		require.NoError(t, err)
 		defer bgManager.Kill(bgShell.ID)
 
@@ -327,3 +331,95 @@ func TestBackgroundShell_AutoBackground(t *testing.T) {
 		require.Equal(t, bgShell.ID, retrieved.ID)
 	})
 }
+
+func TestJobOutputTool(t *testing.T) {
+	t.Parallel()
+
+	bgManager := shell.GetBackgroundShellManager()
+	bgShell, err := bgManager.Start(t.Context(), t.TempDir(), nil, nil, "printf 'one\ntwo\nthree\n'; sleep 0.5; echo ready; sleep 10", "")
+	require.NoError(t, err)
+	defer bgManager.Kill(bgShell.ID)
+
+	tool := NewJobOutputTool()
+	run := func(params JobOutputParams) (string, JobOutputResponseMetadata) {
+		params.ShellID = bgShell.ID
+		input, err := json.Marshal(params)
+		require.NoError(t, err)
+		resp, err := tool.Run(t.Context(), fantasy.ToolCall{ID: "call", Name: JobOutputToolName, Input: string(input)})
+		require.NoError(t, err)
+		require.False(t, resp.IsError, resp.Content)
+		var meta JobOutputResponseMetadata
+		require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+		return resp.Content, meta
+	}
+
+	content, meta := run(JobOutputParams{WaitFor: `ready`, Timeout: 5})
+	require.Contains(t, content, "Status: running")
+	require.Contains(t, content, `Output matched "ready"`)
+	require.Contains(t, content, "one\ntwo\nthree\nready")
+	require.EqualValues(t, len("one\ntwo\nthree\nready\n"), meta.NextOffset)
+
+	content, _ = run(JobOutputParams{Tail: 2})
+	require.Contains(t, content, "three\nready")
+	require.NotContains(t, content, "two")
+
+	content, _ = run(JobOutputParams{Since: &meta.NextOffset})
+	require.Contains(t, content, BashNoOutput)
+
+	start := time.Now()
+	content, _ = run(JobOutputParams{Since: &meta.NextOffset, WaitFor: `never`, Timeout: 1})
+	require.Contains(t, content, "Timed out after 1s")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestJobOutputTool_Limit(t *testing.T) {
+	t.Parallel()
+
+	bgManager := shell.GetBackgroundShellManager()
+	bgShell, err := bgManager.Start(t.Context(), t.TempDir(), nil, nil, "seq 1 5000", "")
+	require.NoError(t, err)
+	defer bgManager.Kill(bgShell.ID)
+	bgShell.Wait()
+
+	tool := NewJobOutputTool()
+	run := func(params JobOutputParams) (string, JobOutputResponseMetadata) {
+		params.ShellID = bgShell.ID
+		input, err := json.Marshal(params)
+		require.NoError(t, err)
+		resp, err := tool.Run(t.Context(), fantasy.ToolCall{ID: "call", Name: JobOutputToolName, Input: string(input)})
+		require.NoError(t, err)
+		require.False(t, resp.IsError, resp.Content)
+		var meta JobOutputResponseMetadata
+		require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+		return resp.Content, meta
+	}
+
+	total := int64(len(seqOutput(5000)))
+
+	// The end of the output by default.
+	content, meta := run(JobOutputParams{})
+	require.Contains(t, content, "\n5000")
+	require.NotContains(t, content, "\n1\n")
+	require.Contains(t, content, fmt.Sprintf("Output before offset %d was skipped", total-MaxJobOutputBytes))
+	require.Equal(t, total, meta.NextOffset)
+
+	// Reading from an offset pages through the output.
+	var since int64
+	content, meta = run(JobOutputParams{Since: &since})
+	require.Contains(t, content, "\n\n1\n2\n")
+	require.NotContains(t, content, "5000")
+	require.EqualValues(t, MaxJobOutputBytes, meta.NextOffset)
+	require.Contains(t, content, fmt.Sprintf("More output follows, call again with since %d", MaxJobOutputBytes))
+
+	content, meta = run(JobOutputParams{Since: &meta.NextOffset})
+	require.Equal(t, 2*MaxJobOutputBytes, int(meta.NextOffset))
+	require.NotContains(t, content, "\n\n1\n")
+}
+
+func seqOutput(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	return sb.String()
+}
@@ -0,0 +1,19 @@
+package tools
+
+import "context"
+
+// LSPToucher is told which files the tools are about to use with the LSP
+// servers. It starts the servers handling them that aren't running because
+// they're started lazily or were shut down for being idle, and keeps the
+// running ones from being shut down until the returned function is called.
+// Without files it only marks the running servers as used.
+type LSPToucher func(ctx context.Context, paths ...string) (done func())
+
+// use calls touch, if any, and returns the function to call once the tool is
+// done with the servers.
+func (touch LSPToucher) use(ctx context.Context, paths ...string) (done func()) {
+	if touch == nil {
+		return func() {}
+	}
+	return touch(ctx, paths...)
+}
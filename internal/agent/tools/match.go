@@ -0,0 +1,280 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// matchStrategy is how old_string was found in the content of a file.
+type matchStrategy int
+
+const (
+	matchExact matchStrategy = iota
+	matchLineEndings
+	matchTrailingWhitespace
+	matchIndentation
+)
+
+func (s matchStrategy) String() string {
+	switch s {
+	case matchLineEndings:
+		return "after normalizing line endings"
+	case matchTrailingWhitespace:
+		return "when ignoring trailing whitespace"
+	case matchIndentation:
+		return "when ignoring indentation, and new_string was re-indented to match the file"
+	default:
+		return "exactly"
+	}
+}
+
+// maxCandidateLines caps the size of the closest region shown when old_string
+// isn't found.
+const maxCandidateLines = 30
+
+// editMatch is a region of the content matching old_string, and what to
+// replace it with.
+type editMatch struct {
+	start, end  int
+	replacement string
+}
+
+// editMatches is the result of looking for old_string in the content.
+type editMatches struct {
+	matches  []editMatch
+	strategy matchStrategy
+}
+
+// apply replaces the matches in the content.
+func (m editMatches) apply(content string) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range m.matches {
+		sb.WriteString(content[last:match.start])
+		sb.WriteString(match.replacement)
+		last = match.end
+	}
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+// note tells the model how old_string matched when it didn't exactly.
+func (m editMatches) note() string {
+	if m.strategy == matchExact {
+		return ""
+	}
+	return fmt.Sprintf("\nold_string did not match exactly, it matched %s.", m.strategy)
+}
+
+// findEditMatches looks for old_string in the content, exactly first and then
+// more and more tolerantly, with differences in line endings, trailing
+// whitespace and indentation. Tolerant matches are only accepted when they
+// are unique. The errors are meant for the model.
+func findEditMatches(content, oldString, newString string, replaceAll bool) (editMatches, error) {
+	if matches := exactMatches(content, oldString, newString); len(matches) > 0 {
+		if len(matches) > 1 && !replaceAll {
+			return editMatches{}, errors.New("old_string appears multiple times in the file. Please provide more context to ensure a unique match, or set replace_all to true")
+		}
+		return editMatches{matches: matches, strategy: matchExact}, nil
+	}
+
+	oldNormalized := strings.ReplaceAll(oldString, "\r\n", "\n")
+	newNormalized := strings.ReplaceAll(newString, "\r\n", "\n")
+	if oldNormalized != oldString {
+		if matches := exactMatches(content, oldNormalized, newNormalized); len(matches) > 0 {
+			return uniqueMatch(matches, matchLineEndings)
+		}
+	}
+
+	for _, strategy := range []matchStrategy{matchTrailingWhitespace, matchIndentation} {
+		if matches := lineMatches(content, oldNormalized, newNormalized, strategy); len(matches) > 0 {
+			return uniqueMatch(matches, strategy)
+		}
+	}
+
+	msg := "old_string not found in file. Make sure it matches exactly, including whitespace and line breaks"
+	if start, lines := closestRegion(content, oldNormalized); len(lines) > 0 {
+		msg += fmt.Sprintf(". The closest match is at lines %d-%d:\n%s", start+1, start+len(lines), addLineNumbers(strings.Join(lines, "\n"), start+1))
+	}
+	return editMatches{}, errors.New(msg)
+}
+
+func uniqueMatch(matches []editMatch, strategy matchStrategy) (editMatches, error) {
+	if len(matches) > 1 {
+		return editMatches{}, fmt.Errorf("old_string was not found exactly, and matches %d places %s. Please provide the exact text or more context", len(matches), strategy)
+	}
+	return editMatches{matches: matches, strategy: strategy}, nil
+}
+
+func exactMatches(content, oldString, newString string) []editMatch {
+	if oldString == "" {
+		return nil
+	}
+	var matches []editMatch
+	for offset := 0; ; {
+		index := strings.Index(content[offset:], oldString)
+		if index == -1 {
+			return matches
+		}
+		start := offset + index
+		offset = start + len(oldString)
+		matches = append(matches, editMatch{start: start, end: offset, replacement: newString})
+	}
+}
+
+// lineMatches finds the runs of whole lines of the content matching the
+// lines of old_string with the strategy.
+func lineMatches(content, oldString, newString string, strategy matchStrategy) []editMatch {
+	oldLines := strings.Split(strings.TrimSuffix(oldString, "\n"), "\n")
+	if strings.TrimSpace(oldString) == "" {
+		return nil
+	}
+	lines, offsets := splitLines(content)
+
+	var matches []editMatch
+	for i := 0; i+len(oldLines) <= len(lines); i++ {
+		if !linesMatch(lines[i:i+len(oldLines)], oldLines, strategy) {
+			continue
+		}
+		last := i + len(oldLines) - 1
+		end := offsets[last] + len(lines[last])
+		if strings.HasSuffix(oldString, "\n") && end < len(content) {
+			end++
+		}
+		replacement := newString
+		if strategy == matchIndentation {
+			replacement = reindent(newString, oldLines, lines[i:i+len(oldLines)])
+		}
+		matches = append(matches, editMatch{start: offsets[i], end: end, replacement: replacement})
+		i = last
+	}
+	return matches
+}
+
+func linesMatch(lines, oldLines []string, strategy matchStrategy) bool {
+	for i, line := range lines {
+		a, b := strings.TrimRight(line, " \t"), strings.TrimRight(oldLines[i], " \t")
+		if strategy == matchIndentation {
+			a, b = strings.TrimLeft(a, " \t"), strings.TrimLeft(b, " \t")
+		}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLines returns the lines of the content and the offsets they start at.
+func splitLines(content string) ([]string, []int) {
+	lines := strings.Split(content, "\n")
+	offsets := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		offsets[i] = offset
+		offset += len(line) + 1
+	}
+	return lines, offsets
+}
+
+// reindent moves new_string from the indentation of old_string to the one of
+// the lines it matched, converting between tabs and spaces if they differ.
+func reindent(newString string, oldLines, matched []string) string {
+	var oldIndent, fileIndent string
+	for i, line := range oldLines {
+		if strings.TrimSpace(line) != "" {
+			oldIndent, fileIndent = leadingWhitespace(line), leadingWhitespace(matched[i])
+			break
+		}
+	}
+	if oldIndent == fileIndent {
+		return newString
+	}
+
+	newLines := strings.Split(newString, "\n")
+	for i, line := range newLines {
+		indent := leadingWhitespace(line)
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(indent, oldIndent) {
+			continue
+		}
+		relative := convertIndent(indent[len(oldIndent):], oldIndent, fileIndent)
+		newLines[i] = fileIndent + relative + line[len(indent):]
+	}
+	return strings.Join(newLines, "\n")
+}
+
+// convertIndent converts relative indentation written like oldIndent to the
+// style of fileIndent, when one uses tabs and the other spaces.
+func convertIndent(relative, oldIndent, fileIndent string) string {
+	oldTabs, fileTabs := strings.Contains(oldIndent, "\t"), strings.Contains(fileIndent, "\t")
+	switch {
+	case fileTabs && !oldTabs && !strings.Contains(relative, "\t"):
+		width := indentWidth(len(oldIndent), strings.Count(fileIndent, "\t"))
+		return strings.Repeat("\t", len(relative)/width) + strings.Repeat(" ", len(relative)%width)
+	case oldTabs && !fileTabs && strings.Trim(relative, "\t") == "":
+		width := indentWidth(len(fileIndent), strings.Count(oldIndent, "\t"))
+		return strings.Repeat(" ", len(relative)*width)
+	}
+	return relative
+}
+
+// indentWidth returns how many spaces a tab stands for, given the same
+// indentation in spaces and tabs.
+func indentWidth(spaces, tabs int) int {
+	if tabs == 0 || spaces == 0 || spaces%tabs != 0 {
+		return 4
+	}
+	return spaces / tabs
+}
+
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// closestRegion returns the lines of the content most similar to old_string,
+// and the index of the first one.
+func closestRegion(content, oldString string) (int, []string) {
+	oldLines := strings.Split(strings.TrimSuffix(oldString, "\n"), "\n")
+	lines, _ := splitLines(content)
+	if len(oldLines) > len(lines) {
+		oldLines = oldLines[:len(lines)]
+	}
+
+	best, bestScore := -1, 0.0
+	for i := 0; i+len(oldLines) <= len(lines); i++ {
+		score := 0.0
+		for j, oldLine := range oldLines {
+			score += lineSimilarity(strings.TrimSpace(lines[i+j]), strings.TrimSpace(oldLine))
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	// Regions sharing less than half of old_string aren't worth showing.
+	if best == -1 || bestScore < float64(len(oldLines))/2 {
+		return 0, nil
+	}
+	region := lines[best : best+len(oldLines)]
+	if len(region) > maxCandidateLines {
+		region = region[:maxCandidateLines]
+	}
+	return best, region
+}
+
+// lineSimilarity returns how much two lines share at their start and end,
+// from 0 to 1.
+func lineSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	longest := max(len(a), len(b))
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return float64(prefix+suffix) / float64(longest)
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindEditMatches(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exact", func(t *testing.T) {
+		t.Parallel()
+		content := "a := 1\nb := 2\n"
+		matches, err := findEditMatches(content, "b := 2", "b := 3", false)
+		require.NoError(t, err)
+		require.Equal(t, matchExact, matches.strategy)
+		require.Equal(t, "a := 1\nb := 3\n", matches.apply(content))
+		require.Empty(t, matches.note())
+	})
+
+	t.Run("exact multiple", func(t *testing.T) {
+		t.Parallel()
+		content := "x\nx\n"
+		_, err := findEditMatches(content, "x", "y", false)
+		require.ErrorContains(t, err, "appears multiple times")
+
+		matches, err := findEditMatches(content, "x", "y", true)
+		require.NoError(t, err)
+		require.Equal(t, "y\ny\n", matches.apply(content))
+	})
+
+	t.Run("line endings", func(t *testing.T) {
+		t.Parallel()
+		content := "one\ntwo\nthree\n"
+		matches, err := findEditMatches(content, "one\r\ntwo\r\n", "uno\r\ndos\r\n", false)
+		require.NoError(t, err)
+		require.Equal(t, matchLineEndings, matches.strategy)
+		require.Equal(t, "uno\ndos\nthree\n", matches.apply(content))
+		require.Contains(t, matches.note(), "normalizing line endings")
+	})
+
+	t.Run("trailing whitespace", func(t *testing.T) {
+		t.Parallel()
+		content := "func f() {  \n\treturn 1\n}\n"
+		matches, err := findEditMatches(content, "func f() {\n\treturn 1\n", "func f() {\n\treturn 2\n", false)
+		require.NoError(t, err)
+		require.Equal(t, matchTrailingWhitespace, matches.strategy)
+		require.Equal(t, "func f() {\n\treturn 2\n}\n", matches.apply(content))
+	})
+
+	t.Run("indentation with tabs", func(t *testing.T) {
+		t.Parallel()
+		content := "func f() {\n\tif ok {\n\t\treturn 1\n\t}\n}\n"
+		old := "    if ok {\n        return 1\n    }"
+		replacement := "    if ok {\n        log()\n        return 2\n    }"
+		matches, err := findEditMatches(content, old, replacement, false)
+		require.NoError(t, err)
+		require.Equal(t, matchIndentation, matches.strategy)
+		require.Equal(t, "func f() {\n\tif ok {\n\t\tlog()\n\t\treturn 2\n\t}\n}\n", matches.apply(content))
+		require.Contains(t, matches.note(), "re-indented")
+	})
+
+	t.Run("indentation with spaces", func(t *testing.T) {
+		t.Parallel()
+		content := "def f():\n    if ok:\n        return 1\n"
+		matches, err := findEditMatches(content, "if ok:\n    return 1\n", "if ok:\n    return 2\n", false)
+		require.NoError(t, err)
+		require.Equal(t, matchIndentation, matches.strategy)
+		require.Equal(t, "def f():\n    if ok:\n        return 2\n", matches.apply(content))
+	})
+
+	t.Run("tolerant match must be unique", func(t *testing.T) {
+		t.Parallel()
+		content := "\tx := 1 \n\tx := 1\t\n"
+		_, err := findEditMatches(content, "x := 1\n", "x := 2\n", true)
+		require.ErrorContains(t, err, "matches 2 places when ignoring indentation")
+	})
+
+	t.Run("not found shows the closest region", func(t *testing.T) {
+		t.Parallel()
+		content := "package main\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+		_, err := findEditMatches(content, "func main() {\n\tfmt.Println(\"hallo\")\n}", "", false)
+		require.ErrorContains(t, err, "old_string not found in file")
+		require.ErrorContains(t, err, "The closest match is at lines 3-5")
+		require.ErrorContains(t, err, "fmt.Println(\"hello\")")
+	})
+
+	t.Run("not found without anything close", func(t *testing.T) {
+		t.Parallel()
+		_, err := findEditMatches("alpha\nbeta\n", "something else entirely", "", false)
+		require.ErrorContains(t, err, "old_string not found in file")
+		require.NotContains(t, err.Error(), "closest")
+	})
+}
@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+type MoveParams struct {
+	SourcePath      string `json:"source_path" description:"The path of the file or directory to move"`
+	DestinationPath string `json:"destination_path" description:"The new path of the file or directory, which must not exist"`
+}
+
+type MovePermissionsParams struct {
+	SourcePath      string                `json:"source_path"`
+	DestinationPath string                `json:"destination_path"`
+	Files           []WorkspaceFileChange `json:"files,omitempty"`
+}
+
+type MoveResponseMetadata struct {
+	SourcePath      string   `json:"source_path"`
+	DestinationPath string   `json:"destination_path"`
+	Files           []string `json:"files,omitempty"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
+}
+
+const MoveToolName = "move"
+
+//go:embed move.md
+var moveDescription []byte
+
+func NewMoveTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		MoveToolName,
+		string(moveDescription),
+		func(ctx context.Context, params MoveParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			src, err := checkFileOperationPath(workingDir, params.SourcePath, "source_path")
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			dst, err := checkFileOperationPath(workingDir, params.DestinationPath, "destination_path")
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			info, err := os.Lstat(src)
+			if os.IsNotExist(err) {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("%s does not exist", src)), nil
+			}
+			if err != nil {
+				return fantasy.ToolResponse{}, fmt.Errorf("error checking source: %w", err)
+			}
+			if _, err := os.Lstat(dst); err == nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("%s already exists, delete it first to replace it", dst)), nil
+			}
+			if info.IsDir() {
+				if _, inside := fsext.UnderPath(src, dst); inside {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("cannot move %s into itself", src)), nil
+				}
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for moving files")
+			}
+
+			defer touchLSPs.use(ctx, src)()
+			edits := fileOperationEdits(ctx, lspClients, src, info.IsDir(), workingDir, func(client *lsp.Client) (protocol.WorkspaceEdit, error) {
+				return client.WillRenameFile(ctx, src, dst)
+			})
+			editChanges := fileOperationChanges(edits)
+			var editPaths []string
+			for _, change := range editChanges {
+				editPaths = append(editPaths, change.finalPath())
+			}
+			snapshot := snapshotDiagnostics(ctx, lspClients, editPaths...)
+
+			p := permissions.Request(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    MoveToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Move %s to %s", relativePath(workingDir, src), relativePath(workingDir, dst)),
+				Params: MovePermissionsParams{
+					SourcePath:      src,
+					DestinationPath: dst,
+					Files:           editChanges,
+				},
+			})
+			if !p {
+				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+			}
+
+			// The history restores the moved files as they were before the
+			// edits, which may change some of them.
+			original, err := textFiles(src)
+			if err != nil {
+				slog.Warn("Failed to read moved files for history", "error", err)
+			}
+
+			// The edits refer to the files at their current paths, so they
+			// are applied first, and undone if the move fails.
+			changedPaths, applied := applyFileOperationEdits(ctx, files, tracker, sessionID, edits)
+
+			contents, err := textFiles(src)
+			if err != nil {
+				slog.Warn("Failed to read moved files for history", "error", err)
+			}
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				undoFileOperationEdits(ctx, files, tracker, sessionID, applied)
+				return fantasy.ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
+			}
+			if err := os.Rename(src, dst); err != nil {
+				undoFileOperationEdits(ctx, files, tracker, sessionID, applied)
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to move %s: %s", src, err)), nil
+			}
+			moveFileRecords(ctx, tracker, src, dst)
+
+			if _, err := files.RecordChanges(ctx, sessionID, moveHistoryChanges(src, dst, original, contents)); err != nil {
+				slog.Error("Error recording file history", "error", err)
+			}
+
+			for name, client := range lspClients.Seq2() {
+				if err := client.DidRenameFile(ctx, src, dst); err != nil {
+					slog.Warn("Failed to notify LSP of moved file", "lsp", name, "error", err)
+				}
+			}
+			changedPaths = movedEditPaths(changedPaths, src, dst)
+			notifyPaths := changedPaths
+			if !info.IsDir() {
+				notifyPaths = append(notifyPaths, dst)
+			}
+			notifyLSPsOfFiles(ctx, lspClients, notifyPaths)
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "<result>\nMoved %s to %s\n", relativePath(workingDir, src), relativePath(workingDir, dst))
+			if len(editChanges) > 0 {
+				output.WriteString("Updated references in:\n")
+				output.WriteString(formatWorkspaceChanges(editChanges, workingDir))
+			}
+			output.WriteString("</result>\n")
+			diagnostics, delta := snapshot.compare(lspClients)
+			output.WriteString(diagnostics)
+
+			relPaths := make([]string, len(changedPaths))
+			for i, path := range changedPaths {
+				relPaths[i] = relativePath(workingDir, path)
+			}
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse(output.String()),
+				MoveResponseMetadata{
+					SourcePath:      relativePath(workingDir, src),
+					DestinationPath: relativePath(workingDir, dst),
+					Files:           relPaths,
+					Diagnostics:     delta,
+				},
+			), nil
+		})
+}
+
+// movedEditPaths returns where the files changed before a move are after
+// it.
+func movedEditPaths(paths []string, src, dst string) []string {
+	moved := make([]string, len(paths))
+	for i, path := range paths {
+		moved[i] = path
+		if rel, ok := fsext.UnderPath(src, path); ok {
+			moved[i] = filepath.Join(dst, rel)
+		}
+	}
+	return moved
+}
+
+// moveHistoryChanges returns the history changes of the files moved from src
+// to dst: each source file goes from its content before the LSP edits to
+// nothing, and its destination from nothing to its moved content.
+func moveHistoryChanges(src, dst string, original, contents map[string]string) []history.FileChange {
+	var changes []history.FileChange
+	for _, path := range slices.Sorted(maps.Keys(contents)) {
+		oldContent, ok := original[path]
+		if !ok {
+			oldContent = contents[path]
+		}
+		rel, _ := fsext.UnderPath(src, path)
+		changes = append(changes,
+			history.FileChange{Path: path, OldContent: oldContent},
+			history.FileChange{Path: filepath.Join(dst, rel), NewContent: contents[path]},
+		)
+	}
+	return changes
+}
@@ -8,12 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/lsp"
@@ -50,6 +50,8 @@ type MultiEditResponseMetadata struct {
 	NewContent   string       `json:"new_content,omitempty"`
 	EditsApplied int          `json:"edits_applied"`
 	EditsFailed  []FailedEdit `json:"edits_failed,omitempty"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
 }
 
 const MultiEditToolName = "multiedit"
@@ -57,7 +59,7 @@ const MultiEditToolName = "multiedit"
 //go:embed multiedit.md
 var multieditDescription []byte
 
-func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) fantasy.AgentTool {
+func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, formatter *Formatter, workingDir string) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		MultiEditToolName,
 		string(multieditDescription),
@@ -71,6 +73,8 @@ func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions pe
 			}
 
 			params.FilePath = filepathext.SmartJoin(workingDir, params.FilePath)
+			defer touchLSPs.use(ctx, params.FilePath)()
+			snapshot := snapshotDiagnostics(ctx, lspClients, params.FilePath)
 
 			// Validate all edits before applying any
 			if err := validateEdits(params.Edits); err != nil {
@@ -80,7 +84,7 @@ func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions pe
 			var response fantasy.ToolResponse
 			var err error
 
-			editCtx := editContext{ctx, permissions, files, workingDir}
+			editCtx := editContext{ctx, permissions, files, tracker, formatter, workingDir}
 			// Handle file creation case (first edit has empty old_string)
 			if len(params.Edits) > 0 && params.Edits[0].OldString == "" {
 				response, err = processMultiEditWithCreation(editCtx, params, call)
@@ -99,11 +103,10 @@ func NewMultiEditTool(lspClients *csync.Map[string, *lsp.Client], permissions pe
 			// Notify LSP clients about the change
 			notifyLSPs(ctx, lspClients, params.FilePath)
 
-			// Wait for LSP diagnostics and add them to the response
-			text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
-			text += getDiagnostics(params.FilePath, lspClients)
-			response.Content = text
-			return response, nil
+			// Add the diagnostics the edits introduced or resolved to the response
+			diagnostics, delta := snapshot.compare(lspClients)
+			response.Content = fmt.Sprintf("<result>\n%s\n</result>\n", response.Content) + diagnostics
+			return withDiagnosticsDelta(response, delta), nil
 		})
 }
 
@@ -142,9 +145,10 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 
 	// Apply remaining edits to the content, tracking failures
 	var failedEdits []FailedEdit
+	var matchNotes string
 	for i := 1; i < len(params.Edits); i++ {
 		edit := params.Edits[i]
-		newContent, err := applyEditToContent(currentContent, edit)
+		newContent, matchNote, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			failedEdits = append(failedEdits, FailedEdit{
 				Index: i + 1,
@@ -153,6 +157,9 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 			})
 			continue
 		}
+		if matchNote != "" {
+			matchNotes += fmt.Sprintf("\nEdit %d: %s", i+1, strings.TrimPrefix(matchNote, "\n"))
+		}
 		currentContent = newContent
 	}
 
@@ -190,11 +197,14 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 	}
 
 	// Write the file
+	defer edit.tracker.Writing(params.FilePath)()
 	err := os.WriteFile(params.FilePath, []byte(currentContent), 0o644)
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	currentContent, note := edit.formatter.formatChange(edit.ctx, params.FilePath, "", currentContent, fsext.TextFormat{}, false, nil, &additions, &removals)
+
 	// Update file history
 	_, err = edit.files.Create(edit.ctx, sessionID, params.FilePath, "")
 	if err != nil {
@@ -206,8 +216,7 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 		slog.Error("Error creating file history version", "error", err)
 	}
 
-	recordFileWrite(params.FilePath)
-	recordFileRead(params.FilePath)
+	recordFileWrite(edit.ctx, edit.tracker, params.FilePath)
 
 	var message string
 	if len(failedEdits) > 0 {
@@ -217,7 +226,7 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 	}
 
 	return fantasy.WithResponseMetadata(
-		fantasy.NewTextResponse(message),
+		fantasy.NewTextResponse(message+matchNotes+note),
 		MultiEditResponseMetadata{
 			OldContent:   "",
 			NewContent:   currentContent,
@@ -243,34 +252,38 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 		return fantasy.NewTextErrorResponse(fmt.Sprintf("path is a directory, not a file: %s", params.FilePath)), nil
 	}
 
-	// Check if file was read before editing
-	if getLastReadTime(params.FilePath).IsZero() {
-		return fantasy.NewTextErrorResponse("you must read the file before editing it. Use the View tool first"), nil
+	// Check if the file was read before editing, and get the content the
+	// edits apply to if it changed since
+	base, stale, readErr, err := staleBase(edit.ctx, edit.tracker, params.FilePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
 	}
-
-	// Check if file was modified since last read
-	modTime := fileInfo.ModTime()
-	lastRead := getLastReadTime(params.FilePath)
-	if modTime.After(lastRead) {
-		return fantasy.NewTextErrorResponse(
-			fmt.Sprintf("file %s has been modified since it was last read (mod time: %s, last read: %s)",
-				params.FilePath, modTime.Format(time.RFC3339), lastRead.Format(time.RFC3339),
-			)), nil
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
 	// Read current file content
-	content, err := os.ReadFile(params.FilePath)
+	content, format, readErr, err := readText(params.FilePath)
 	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
+		return fantasy.ToolResponse{}, err
+	}
+	if readErr != "" {
+		return fantasy.NewTextErrorResponse(readErr), nil
 	}
 
-	oldContent, isCrlf := fsext.ToUnixLineEndings(string(content))
-	currentContent := oldContent
+	oldContent, isCrlf := fsext.ToUnixLineEndings(content)
+	if stale {
+		base, _ = fsext.ToUnixLineEndings(base)
+	} else {
+		base = oldContent
+	}
+	currentContent := base
 
 	// Apply all edits sequentially, tracking failures
 	var failedEdits []FailedEdit
+	var matchNotes string
 	for i, edit := range params.Edits {
-		newContent, err := applyEditToContent(currentContent, edit)
+		newContent, matchNote, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			failedEdits = append(failedEdits, FailedEdit{
 				Index: i + 1,
@@ -279,11 +292,14 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 			})
 			continue
 		}
+		if matchNote != "" {
+			matchNotes += fmt.Sprintf("\nEdit %d: %s", i+1, strings.TrimPrefix(matchNote, "\n"))
+		}
 		currentContent = newContent
 	}
 
 	// Check if content actually changed
-	if oldContent == currentContent {
+	if base == currentContent {
 		// If we have failed edits, report them
 		if len(failedEdits) > 0 {
 			return fantasy.WithResponseMetadata(
@@ -297,6 +313,16 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 		return fantasy.NewTextErrorResponse("no changes made - all edits resulted in identical content"), nil
 	}
 
+	// Merge the edits with the changes made on disk since the file was read
+	var mergeNote string
+	if stale {
+		var conflict *mergeConflict
+		currentContent, mergeNote, conflict = mergeDiskChanges(params.FilePath, base, currentContent, oldContent)
+		if conflict != nil {
+			return conflict.response(conflict.Error()), nil
+		}
+	}
+
 	// Get session and message IDs
 	sessionID := GetSessionFromContext(edit.ctx)
 	if sessionID == "" {
@@ -330,40 +356,23 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	if isCrlf {
-		currentContent, _ = fsext.ToWindowsLineEndings(currentContent)
-	}
-
 	// Write the updated content
-	err = os.WriteFile(params.FilePath, []byte(currentContent), 0o644)
-	if err != nil {
-		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
-	}
-
-	// Update file history
-	file, err := edit.files.GetByPathAndSession(edit.ctx, params.FilePath, sessionID)
+	defer edit.tracker.Writing(params.FilePath)()
+	writeErr, err := writeText(params.FilePath, withLineEndings(currentContent, isCrlf), format)
 	if err != nil {
-		_, err = edit.files.Create(edit.ctx, sessionID, params.FilePath, oldContent)
-		if err != nil {
-			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
-		}
+		return fantasy.ToolResponse{}, err
 	}
-	if file.Content != oldContent {
-		// User manually changed the content, store an intermediate version
-		_, err = edit.files.CreateVersion(edit.ctx, sessionID, params.FilePath, oldContent)
-		if err != nil {
-			slog.Error("Error creating file history version", "error", err)
-		}
+	if writeErr != "" {
+		return fantasy.NewTextErrorResponse(writeErr), nil
 	}
 
-	// Store the new version
-	_, err = edit.files.CreateVersion(edit.ctx, sessionID, params.FilePath, currentContent)
-	if err != nil {
-		slog.Error("Error creating file history version", "error", err)
+	currentContent, note := edit.formatter.formatChange(edit.ctx, params.FilePath, oldContent, currentContent, format, isCrlf, nil, &additions, &removals)
+
+	if err := updateFileHistory(edit.ctx, edit.files, sessionID, params.FilePath, oldContent, currentContent); err != nil {
+		return fantasy.ToolResponse{}, err
 	}
 
-	recordFileWrite(params.FilePath)
-	recordFileRead(params.FilePath)
+	recordFileWrite(edit.ctx, edit.tracker, params.FilePath)
 
 	var message string
 	if len(failedEdits) > 0 {
@@ -373,7 +382,7 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 	}
 
 	return fantasy.WithResponseMetadata(
-		fantasy.NewTextResponse(message),
+		fantasy.NewTextResponse(message+matchNotes+mergeNote+note),
 		MultiEditResponseMetadata{
 			OldContent:   oldContent,
 			NewContent:   currentContent,
@@ -385,38 +394,20 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 	), nil
 }
 
-func applyEditToContent(content string, edit MultiEditOperation) (string, error) {
+// applyEditToContent applies the edit to the content. It also returns a note
+// for the model when old_string didn't match exactly.
+func applyEditToContent(content string, edit MultiEditOperation) (string, string, error) {
 	if edit.OldString == "" && edit.NewString == "" {
-		return content, nil
+		return content, "", nil
 	}
 
 	if edit.OldString == "" {
-		return "", fmt.Errorf("old_string cannot be empty for content replacement")
+		return "", "", fmt.Errorf("old_string cannot be empty for content replacement")
 	}
 
-	var newContent string
-	var replacementCount int
-
-	if edit.ReplaceAll {
-		newContent = strings.ReplaceAll(content, edit.OldString, edit.NewString)
-		replacementCount = strings.Count(content, edit.OldString)
-		if replacementCount == 0 {
-			return "", fmt.Errorf("old_string not found in content. Make sure it matches exactly, including whitespace and line breaks")
-		}
-	} else {
-		index := strings.Index(content, edit.OldString)
-		if index == -1 {
-			return "", fmt.Errorf("old_string not found in content. Make sure it matches exactly, including whitespace and line breaks")
-		}
-
-		lastIndex := strings.LastIndex(content, edit.OldString)
-		if index != lastIndex {
-			return "", fmt.Errorf("old_string appears multiple times in the content. Please provide more context to ensure a unique match, or set replace_all to true")
-		}
-
-		newContent = content[:index] + edit.NewString + content[index+len(edit.OldString):]
-		replacementCount = 1
+	matches, err := findEditMatches(content, edit.OldString, edit.NewString, edit.ReplaceAll)
+	if err != nil {
+		return "", "", err
 	}
-
-	return newContent, nil
+	return matches.apply(content), matches.note(), nil
 }
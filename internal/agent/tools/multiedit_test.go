@@ -4,9 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/permission"
@@ -28,6 +30,8 @@ func (m *mockPermissionService) Deny(req permission.PermissionRequest) {}
 
 func (m *mockPermissionService) GrantPersistent(req permission.PermissionRequest) {}
 
+func (m *mockPermissionService) AddRules(rules ...permission.Rule) {}
+
 func (m *mockPermissionService) AutoApproveSession(sessionID string) {}
 
 func (m *mockPermissionService) SetSkipRequests(skip bool) {}
@@ -52,6 +56,10 @@ func (m *mockHistoryService) CreateVersion(ctx context.Context, sessionID, path,
 	return history.File{}, nil
 }
 
+func (m *mockHistoryService) RecordChanges(ctx context.Context, sessionID string, changes []history.FileChange) ([]history.File, error) {
+	return nil, nil
+}
+
 func (m *mockHistoryService) GetByPathAndSession(ctx context.Context, path, sessionID string) (history.File, error) {
 	return history.File{Path: path, Content: ""}, nil
 }
@@ -76,13 +84,85 @@ func (m *mockHistoryService) DeleteSessionFiles(ctx context.Context, sessionID s
 	return nil
 }
 
+// mockFileTracker keeps the hashes of the files sessions read or wrote in
+// memory.
+type mockFileTracker struct {
+	*pubsub.Broker[filetracker.StaleFile]
+	mu     sync.Mutex
+	hashes map[[2]string]string
+}
+
+func (m *mockFileTracker) RecordRead(ctx context.Context, sessionID, path string) error {
+	return m.record(sessionID, path)
+}
+
+func (m *mockFileTracker) Writing(path string) func() {
+	return func() {}
+}
+
+func (m *mockFileTracker) RecordWrite(ctx context.Context, sessionID, path string) error {
+	return m.record(sessionID, path)
+}
+
+func (m *mockFileTracker) record(sessionID, path string) error {
+	hash, err := filetracker.HashFile(path)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashes == nil {
+		m.hashes = make(map[[2]string]string)
+	}
+	m.hashes[[2]string{sessionID, path}] = hash
+	return nil
+}
+
+func (m *mockFileTracker) Status(ctx context.Context, sessionID, path string) (filetracker.Status, error) {
+	m.mu.Lock()
+	hash, ok := m.hashes[[2]string{sessionID, path}]
+	m.mu.Unlock()
+	if !ok {
+		return filetracker.StatusUnread, nil
+	}
+	current, err := filetracker.HashFile(path)
+	if err != nil || current != hash {
+		return filetracker.StatusChanged, nil
+	}
+	return filetracker.StatusCurrent, nil
+}
+
+func (m *mockFileTracker) Base(ctx context.Context, sessionID, path string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (m *mockFileTracker) Get(ctx context.Context, sessionID, path string) (filetracker.Record, error) {
+	return filetracker.Record{}, nil
+}
+
+func (m *mockFileTracker) ListBySession(ctx context.Context, sessionID string) ([]filetracker.Record, error) {
+	return nil, nil
+}
+
+func (m *mockFileTracker) Move(ctx context.Context, sessionID, oldPath, newPath string) error {
+	return nil
+}
+
+func (m *mockFileTracker) Forget(ctx context.Context, sessionID, path string) error {
+	return nil
+}
+
+func (m *mockFileTracker) Watch(ctx context.Context) error {
+	return nil
+}
+
 func TestApplyEditToContentPartialSuccess(t *testing.T) {
 	t.Parallel()
 
 	content := "line 1\nline 2\nline 3\n"
 
 	// Test successful edit.
-	newContent, err := applyEditToContent(content, MultiEditOperation{
+	newContent, _, err := applyEditToContent(content, MultiEditOperation{
 		OldString: "line 1",
 		NewString: "LINE 1",
 	})
@@ -91,7 +171,7 @@ func TestApplyEditToContentPartialSuccess(t *testing.T) {
 	require.Contains(t, newContent, "line 2")
 
 	// Test failed edit (string not found).
-	_, err = applyEditToContent(content, MultiEditOperation{
+	_, _, err = applyEditToContent(content, MultiEditOperation{
 		OldString: "line 99",
 		NewString: "LINE 99",
 	})
@@ -114,12 +194,13 @@ func TestMultiEditSequentialApplication(t *testing.T) {
 	lspClients := csync.NewMap[string, *lsp.Client]()
 	permissions := &mockPermissionService{Broker: pubsub.NewBroker[permission.PermissionRequest]()}
 	files := &mockHistoryService{Broker: pubsub.NewBroker[history.File]()}
+	tracker := &mockFileTracker{}
 
 	// Create multiedit tool.
-	_ = NewMultiEditTool(lspClients, permissions, files, tmpDir)
+	_ = NewMultiEditTool(lspClients, nil, permissions, files, tracker, nil, tmpDir)
 
 	// Simulate reading the file first.
-	recordFileRead(testFile)
+	require.NoError(t, tracker.RecordRead(t.Context(), "session", testFile))
 
 	// Manually test the sequential application logic.
 	currentContent := content
@@ -136,7 +217,7 @@ func TestMultiEditSequentialApplication(t *testing.T) {
 	successCount := 0
 
 	for i, edit := range edits {
-		newContent, err := applyEditToContent(currentContent, edit)
+		newContent, _, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			failedEdits = append(failedEdits, FailedEdit{
 				Index: i + 1,
@@ -180,7 +261,7 @@ func TestMultiEditAllEditsSucceed(t *testing.T) {
 	successCount := 0
 
 	for _, edit := range edits {
-		newContent, err := applyEditToContent(currentContent, edit)
+		newContent, _, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -208,7 +289,7 @@ func TestMultiEditAllEditsFail(t *testing.T) {
 	var failedEdits []FailedEdit
 
 	for i, edit := range edits {
-		newContent, err := applyEditToContent(currentContent, edit)
+		newContent, _, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			failedEdits = append(failedEdits, FailedEdit{
 				Index: i + 1,
@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxNotebookOutputLength is how much of each cell output is shown.
+const maxNotebookOutputLength = 2000
+
+// notebook is a Jupyter notebook. Writing it back encodes the whole notebook
+// again the way Jupyter does, with sorted keys, the indentation of the file
+// and non-ASCII characters unescaped, so notebooks saved by Jupyter only
+// change in the edited cells.
+type notebook struct {
+	raw   map[string]any
+	cells []map[string]any
+	// indent and newline are the format of the file, kept when writing it.
+	indent  string
+	newline bool
+}
+
+var notebookIndentRe = regexp.MustCompile(`\{\r?\n([ \t]+)"`)
+
+func parseNotebook(data []byte) (*notebook, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	list, ok := raw["cells"].([]any)
+	if !ok {
+		return nil, errors.New("invalid notebook: it has no cells")
+	}
+	nb := &notebook{
+		raw:     raw,
+		cells:   make([]map[string]any, len(list)),
+		indent:  " ",
+		newline: bytes.HasSuffix(data, []byte("\n")),
+	}
+	for i, c := range list {
+		cell, ok := c.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid notebook: cell %d is not an object", i)
+		}
+		nb.cells[i] = cell
+	}
+	if m := notebookIndentRe.FindSubmatch(data); m != nil {
+		nb.indent = string(m[1])
+	}
+	return nb, nil
+}
+
+func (nb *notebook) marshal() ([]byte, error) {
+	cells := make([]any, len(nb.cells))
+	for i, cell := range nb.cells {
+		cells[i] = cell
+	}
+	nb.raw["cells"] = cells
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", nb.indent)
+	if err := enc.Encode(nb.raw); err != nil {
+		return nil, err
+	}
+	if !nb.newline {
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// language returns the programming language of the code cells.
+func (nb *notebook) language() string {
+	metadata, _ := nb.raw["metadata"].(map[string]any)
+	if info, ok := metadata["language_info"].(map[string]any); ok {
+		if name, ok := info["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	if spec, ok := metadata["kernelspec"].(map[string]any); ok {
+		if lang, ok := spec["language"].(string); ok && lang != "" {
+			return lang
+		}
+	}
+	return "python"
+}
+
+// usesCellIDs reports whether the cells must have an ID, which is the case
+// from nbformat 4.5.
+func (nb *notebook) usesCellIDs() bool {
+	// The versions are missing, or not numbers, in broken notebooks.
+	majorNumber, _ := nb.raw["nbformat"].(json.Number)
+	minorNumber, _ := nb.raw["nbformat_minor"].(json.Number)
+	major, _ := majorNumber.Int64()
+	minor, _ := minorNumber.Int64()
+	if major > 4 || major == 4 && minor >= 5 {
+		return true
+	}
+	for _, cell := range nb.cells {
+		if cellID(cell) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findCell returns the index of the cell with the ID, or checks the index
+// when the ID is empty.
+func (nb *notebook) findCell(index int, id string) (int, error) {
+	if id != "" {
+		for i, cell := range nb.cells {
+			if cellID(cell) == id {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("cell %q not found", id)
+	}
+	if index < 0 || index >= len(nb.cells) {
+		return 0, fmt.Errorf("cell index %d is out of range, the notebook has %d cells", index, len(nb.cells))
+	}
+	return index, nil
+}
+
+func cellID(cell map[string]any) string {
+	id, _ := cell["id"].(string)
+	return id
+}
+
+func cellType(cell map[string]any) string {
+	t, _ := cell["cell_type"].(string)
+	return t
+}
+
+// multilineString joins a notebook string, which is either a string or a
+// list of lines.
+func multilineString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var sb strings.Builder
+		for _, line := range v {
+			s, _ := line.(string)
+			sb.WriteString(s)
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// sourceLines splits the source into lines the way Jupyter stores it.
+func sourceLines(source string) []any {
+	lines := []any{}
+	for line := range strings.SplitAfterSeq(source, "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// notebookImage is an image output of a cell, base64 encoded.
+type notebookImage struct {
+	data      string
+	mediaType string
+}
+
+// renderNotebook renders the cells from offset, up to limit, for the model.
+// When withImages is set, the first image output is returned to be attached
+// to the response, as only one can be.
+func renderNotebook(nb *notebook, offset, limit int, withImages bool) (string, *notebookImage) {
+	var (
+		sb    strings.Builder
+		image *notebookImage
+	)
+	fmt.Fprintf(&sb, "<notebook language=%q cells=\"%d\">\n", nb.language(), len(nb.cells))
+	end := min(offset+limit, len(nb.cells))
+	for i := offset; i < end; i++ {
+		cell := nb.cells[i]
+		fmt.Fprintf(&sb, "<cell index=\"%d\"", i)
+		if id := cellID(cell); id != "" {
+			fmt.Fprintf(&sb, " id=%q", id)
+		}
+		fmt.Fprintf(&sb, " type=%q", cellType(cell))
+		if count, ok := cell["execution_count"].(json.Number); ok {
+			fmt.Fprintf(&sb, " execution_count=\"%s\"", count)
+		}
+		sb.WriteString(">\n")
+		if source := multilineString(cell["source"]); source != "" {
+			sb.WriteString(strings.TrimSuffix(source, "\n"))
+			sb.WriteString("\n")
+		}
+		if outputs, _ := cell["outputs"].([]any); len(outputs) > 0 {
+			sb.WriteString("<outputs>\n")
+			for _, o := range outputs {
+				output, _ := o.(map[string]any)
+				renderCellOutput(&sb, output, withImages, &image)
+			}
+			sb.WriteString("</outputs>\n")
+		}
+		sb.WriteString("</cell>\n")
+	}
+	sb.WriteString("</notebook>")
+	if end < len(nb.cells) {
+		fmt.Fprintf(&sb, "\n\n(Notebook has more cells. Use 'offset' parameter to read beyond cell %d)", end-1)
+	}
+	return sb.String(), image
+}
+
+func renderCellOutput(sb *strings.Builder, output map[string]any, withImages bool, image **notebookImage) {
+	outputType, _ := output["output_type"].(string)
+	switch outputType {
+	case "stream":
+		name, _ := output["name"].(string)
+		fmt.Fprintf(sb, "<output type=\"stream\" name=%q>\n%s\n</output>\n", name, truncateCellOutput(multilineString(output["text"])))
+	case "error":
+		ename, _ := output["ename"].(string)
+		evalue, _ := output["evalue"].(string)
+		var traceback []string
+		if lines, ok := output["traceback"].([]any); ok {
+			for _, line := range lines {
+				s, _ := line.(string)
+				traceback = append(traceback, s)
+			}
+		}
+		fmt.Fprintf(sb, "<output type=\"error\" name=%q value=%q>\n%s\n</output>\n", ename, evalue, truncateCellOutput(ansi.Strip(strings.Join(traceback, "\n"))))
+	case "execute_result", "display_data":
+		data, _ := output["data"].(map[string]any)
+		for _, mediaType := range []string{"image/png", "image/jpeg", "image/gif", "image/webp"} {
+			encoded, ok := data[mediaType]
+			if !ok {
+				continue
+			}
+			switch {
+			case !withImages:
+				fmt.Fprintf(sb, "<output type=%q>(image not shown, the model doesn't support images)</output>\n", mediaType)
+			case *image == nil:
+				*image = &notebookImage{
+					data:      strings.ReplaceAll(multilineString(encoded), "\n", ""),
+					mediaType: mediaType,
+				}
+				fmt.Fprintf(sb, "<output type=%q>(image attached)</output>\n", mediaType)
+			default:
+				fmt.Fprintf(sb, "<output type=%q>(image not shown, only the first image is attached, use offset to view this cell alone)</output>\n", mediaType)
+			}
+			return
+		}
+		if text, ok := data["text/plain"]; ok {
+			fmt.Fprintf(sb, "<output type=\"text/plain\">\n%s\n</output>\n", truncateCellOutput(multilineString(text)))
+			return
+		}
+		for mediaType := range data {
+			fmt.Fprintf(sb, "<output type=%q>(not shown)</output>\n", mediaType)
+			return
+		}
+	}
+}
+
+func truncateCellOutput(text string) string {
+	text = strings.TrimSuffix(text, "\n")
+	if len(text) <= maxNotebookOutputLength {
+		return text
+	}
+	cut := maxNotebookOutputLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n... (%d more bytes)", text[:cut], len(text)-cut)
+}
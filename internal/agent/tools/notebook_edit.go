@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/google/uuid"
+)
+
+type NotebookEditParams struct {
+	FilePath  string `json:"file_path" description:"The absolute path to the notebook to modify"`
+	CellIndex int    `json:"cell_index,omitempty" description:"The 0-based index of the cell, ignored when cell_id is set"`
+	CellID    string `json:"cell_id,omitempty" description:"The ID of the cell"`
+	EditMode  string `json:"edit_mode,omitempty" description:"replace (default), insert or delete"`
+	CellType  string `json:"cell_type,omitempty" description:"code or markdown, required for insert. When replacing, changes the type of the cell"`
+	NewSource string `json:"new_source,omitempty" description:"The new source of the cell"`
+}
+
+const NotebookEditToolName = "notebook_edit"
+
+//go:embed notebook_edit.md
+var notebookEditDescription []byte
+
+func NewNotebookEditTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		NotebookEditToolName,
+		string(notebookEditDescription),
+		func(ctx context.Context, params NotebookEditParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.FilePath == "" {
+				return fantasy.NewTextErrorResponse("file_path is required"), nil
+			}
+			if params.EditMode == "" {
+				params.EditMode = "replace"
+			}
+			switch params.EditMode {
+			case "replace", "insert", "delete":
+			default:
+				return fantasy.NewTextErrorResponse("edit_mode must be replace, insert or delete"), nil
+			}
+			switch params.CellType {
+			case "", "code", "markdown", "raw":
+			default:
+				return fantasy.NewTextErrorResponse("cell_type must be code, markdown or raw"), nil
+			}
+			if params.EditMode == "insert" && params.CellType == "" {
+				return fantasy.NewTextErrorResponse("cell_type is required to insert a cell"), nil
+			}
+
+			params.FilePath = filepathext.SmartJoin(workingDir, params.FilePath)
+			// There is no formatter: the configured ones format source files,
+			// not the JSON of a notebook.
+			editCtx := editContext{ctx, permissions, files, tracker, nil, workingDir}
+			response, err := editNotebook(editCtx, params, call)
+			if err != nil || response.IsError {
+				return response, err
+			}
+
+			defer touchLSPs.use(ctx, params.FilePath)()
+			notifyLSPs(ctx, lspClients, params.FilePath)
+			return response, nil
+		})
+}
+
+func editNotebook(edit editContext, params NotebookEditParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	filePath := params.FilePath
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("file not found: %s", filePath)), nil
+		}
+		return fantasy.ToolResponse{}, fmt.Errorf("failed to access file: %w", err)
+	}
+	if fileInfo.IsDir() {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("path is a directory, not a file: %s", filePath)), nil
+	}
+
+	// Cell indexes can't be merged with changes made on disk, so the
+	// notebook must be read again when it changed.
+	status, err := fileStatus(edit.ctx, edit.tracker, filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	switch status {
+	case filetracker.StatusUnread:
+		return fantasy.NewTextErrorResponse("you must read the notebook before editing it. Use the View tool first"), nil
+	case filetracker.StatusChanged:
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("notebook %s has been modified since it was last read, read it again to get the current cells", filePath)), nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	nb, err := parseNotebook(data)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("%s: %s", filePath, err)), nil
+	}
+	summary, editErr := applyNotebookEdit(nb, params)
+	if editErr != "" {
+		return fantasy.NewTextErrorResponse(editErr), nil
+	}
+	newData, err := nb.marshal()
+	if err != nil {
+		return fantasy.ToolResponse{}, fmt.Errorf("failed to encode notebook: %w", err)
+	}
+	oldContent, newContent := string(data), string(newData)
+
+	sessionID := GetSessionFromContext(edit.ctx)
+	if sessionID == "" {
+		return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for editing a file")
+	}
+
+	_, additions, removals := diff.GenerateDiff(
+		oldContent,
+		newContent,
+		strings.TrimPrefix(filePath, edit.workingDir),
+	)
+
+	p := edit.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        fsext.PathOrPrefix(filePath, edit.workingDir),
+			ToolCallID:  call.ID,
+			ToolName:    NotebookEditToolName,
+			Action:      "write",
+			Description: fmt.Sprintf("%s in notebook %s", summary, filePath),
+			Params: EditPermissionsParams{
+				FilePath:   filePath,
+				OldContent: oldContent,
+				NewContent: newContent,
+			},
+		},
+	)
+	if !p {
+		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	defer edit.tracker.Writing(filePath)()
+	if err := os.WriteFile(filePath, newData, fileInfo.Mode().Perm()); err != nil {
+		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := updateFileHistory(edit.ctx, edit.files, sessionID, filePath, oldContent, newContent); err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+
+	recordFileWrite(edit.ctx, edit.tracker, filePath)
+
+	return fantasy.WithResponseMetadata(
+		fantasy.NewTextResponse(fmt.Sprintf("%s in notebook: %s", summary, filePath)),
+		EditResponseMetadata{
+			OldContent: oldContent,
+			NewContent: newContent,
+			Additions:  additions,
+			Removals:   removals,
+		}), nil
+}
+
+// applyNotebookEdit applies the edit of the params to the cells of the
+// notebook. It returns a summary of the change, or a message telling the
+// model why the edit can't be made.
+func applyNotebookEdit(nb *notebook, params NotebookEditParams) (summary, message string) {
+	if params.EditMode == "insert" {
+		// New cells go at the index, or after the cell with the ID.
+		index := params.CellIndex
+		if params.CellID != "" {
+			i, err := nb.findCell(0, params.CellID)
+			if err != nil {
+				return "", err.Error()
+			}
+			index = i + 1
+		} else if index < 0 || index > len(nb.cells) {
+			return "", fmt.Sprintf("cell index %d is out of range, it must be between 0 and %d", index, len(nb.cells))
+		}
+		cell := map[string]any{
+			"cell_type": params.CellType,
+			"metadata":  map[string]any{},
+			"source":    sourceLines(params.NewSource),
+		}
+		if params.CellType == "code" {
+			cell["execution_count"] = nil
+			cell["outputs"] = []any{}
+		}
+		if nb.usesCellIDs() {
+			cell["id"] = newCellID(nb)
+		}
+		nb.cells = append(nb.cells[:index], append([]map[string]any{cell}, nb.cells[index:]...)...)
+		return fmt.Sprintf("%s cell inserted at index %d", params.CellType, index), ""
+	}
+
+	index, err := nb.findCell(params.CellIndex, params.CellID)
+	if err != nil {
+		return "", err.Error()
+	}
+	if params.EditMode == "delete" {
+		nb.cells = append(nb.cells[:index], nb.cells[index+1:]...)
+		return fmt.Sprintf("Cell %d deleted", index), ""
+	}
+
+	cell := nb.cells[index]
+	if params.CellType != "" && params.CellType != cellType(cell) {
+		cell["cell_type"] = params.CellType
+		if params.CellType == "code" {
+			cell["execution_count"] = nil
+			cell["outputs"] = []any{}
+		} else {
+			delete(cell, "execution_count")
+			delete(cell, "outputs")
+		}
+	} else if multilineString(cell["source"]) == params.NewSource {
+		return "", "new_source is the same as the source of the cell. No changes made."
+	}
+	cell["source"] = sourceLines(params.NewSource)
+	if cellType(cell) == "code" {
+		// The outputs are of the old source.
+		cell["execution_count"] = nil
+		cell["outputs"] = []any{}
+	}
+	return fmt.Sprintf("Cell %d replaced", index), ""
+}
+
+// newCellID returns a cell ID that isn't used in the notebook, in the format
+// Jupyter uses.
+func newCellID(nb *notebook) string {
+	for {
+		id := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+		if _, err := nb.findCell(0, id); err != nil {
+			return id
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testNotebook = `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "id": "intro",
+   "metadata": {},
+   "source": [
+    "# Title"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 3,
+   "id": "plot",
+   "metadata": {
+    "tags": []
+   },
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": [
+      "a < b\n"
+     ]
+    },
+    {
+     "data": {
+      "image/png": "aGVsbG8=\n",
+      "text/plain": [
+       "<Figure>"
+      ]
+     },
+     "metadata": {},
+     "output_type": "display_data"
+    },
+    {
+     "ename": "ValueError",
+     "evalue": "bad",
+     "output_type": "error",
+     "traceback": [
+      "\u001b[31mValueError\u001b[0m: bad"
+     ]
+    }
+   ],
+   "source": [
+    "print('a < b')\n",
+    "plot()"
+   ]
+  }
+ ],
+ "metadata": {
+  "language_info": {
+   "name": "python",
+   "version": "3.12.1"
+  }
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`
+
+func TestNotebook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		nb, err := parseNotebook([]byte(testNotebook))
+		require.NoError(t, err)
+		data, err := nb.marshal()
+		require.NoError(t, err)
+		require.Equal(t, testNotebook, string(data))
+	})
+
+	t.Run("render", func(t *testing.T) {
+		t.Parallel()
+		nb, err := parseNotebook([]byte(testNotebook))
+		require.NoError(t, err)
+
+		output, image := renderNotebook(nb, 0, DefaultReadLimit, true)
+		require.Equal(t, `<notebook language="python" cells="2">
+<cell index="0" id="intro" type="markdown">
+# Title
+</cell>
+<cell index="1" id="plot" type="code" execution_count="3">
+print('a < b')
+plot()
+<outputs>
+<output type="stream" name="stdout">
+a < b
+</output>
+<output type="image/png">(image attached)</output>
+<output type="error" name="ValueError" value="bad">
+ValueError: bad
+</output>
+</outputs>
+</cell>
+</notebook>`, output)
+		require.Equal(t, &notebookImage{data: "aGVsbG8=", mediaType: "image/png"}, image)
+
+		output, image = renderNotebook(nb, 0, 1, false)
+		require.Nil(t, image)
+		require.True(t, strings.HasSuffix(output, "(Notebook has more cells. Use 'offset' parameter to read beyond cell 0)"))
+	})
+
+	t.Run("edit keeps the rest", func(t *testing.T) {
+		t.Parallel()
+		nb, err := parseNotebook([]byte(testNotebook))
+		require.NoError(t, err)
+		_, message := applyNotebookEdit(nb, NotebookEditParams{CellID: "intro", EditMode: "replace", NewSource: "# Título"})
+		require.Empty(t, message)
+		data, err := nb.marshal()
+		require.NoError(t, err)
+		require.Equal(t, strings.Replace(testNotebook, `"# Title"`, `"# Título"`, 1), string(data))
+	})
+
+	t.Run("cell ids", func(t *testing.T) {
+		t.Parallel()
+		for notebook, want := range map[string]bool{
+			`{"cells": [], "nbformat": 4, "nbformat_minor": 5}`:            true,
+			`{"cells": [], "nbformat": 4, "nbformat_minor": 4}`:            false,
+			`{"cells": [{"id": "a"}], "nbformat": 4, "nbformat_minor": 4}`: true,
+			`{"cells": []}`:                  false,
+			`{"cells": [], "nbformat": "4"}`: false,
+		} {
+			nb, err := parseNotebook([]byte(notebook))
+			require.NoError(t, err)
+			require.Equal(t, want, nb.usesCellIDs(), notebook)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := parseNotebook([]byte(`{"metadata": {}}`))
+		require.Error(t, err)
+	})
+}
+
+func TestApplyNotebookEdit(t *testing.T) {
+	t.Parallel()
+
+	edit := func(t *testing.T, params NotebookEditParams) (*notebook, string) {
+		t.Helper()
+		nb, err := parseNotebook([]byte(testNotebook))
+		require.NoError(t, err)
+		_, message := applyNotebookEdit(nb, params)
+		if message == "" {
+			// The notebook must stay valid JSON.
+			data, err := nb.marshal()
+			require.NoError(t, err)
+			require.True(t, json.Valid(data))
+		}
+		return nb, message
+	}
+
+	t.Run("replace clears outputs", func(t *testing.T) {
+		t.Parallel()
+		nb, message := edit(t, NotebookEditParams{CellID: "plot", EditMode: "replace", NewSource: "x = 1\nx"})
+		require.Empty(t, message)
+		require.Equal(t, []any{"x = 1\n", "x"}, nb.cells[1]["source"])
+		require.Equal(t, []any{}, nb.cells[1]["outputs"])
+		require.Nil(t, nb.cells[1]["execution_count"])
+		require.Equal(t, map[string]any{"tags": []any{}}, nb.cells[1]["metadata"])
+	})
+
+	t.Run("replace type", func(t *testing.T) {
+		t.Parallel()
+		nb, message := edit(t, NotebookEditParams{CellIndex: 1, EditMode: "replace", CellType: "markdown", NewSource: "Text"})
+		require.Empty(t, message)
+		require.Equal(t, "markdown", cellType(nb.cells[1]))
+		require.NotContains(t, nb.cells[1], "outputs")
+		require.NotContains(t, nb.cells[1], "execution_count")
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		t.Parallel()
+		_, message := edit(t, NotebookEditParams{CellIndex: 0, EditMode: "replace", NewSource: "# Title"})
+		require.Contains(t, message, "No changes made")
+	})
+
+	t.Run("insert after id", func(t *testing.T) {
+		t.Parallel()
+		nb, message := edit(t, NotebookEditParams{CellID: "intro", EditMode: "insert", CellType: "code", NewSource: "import os\n"})
+		require.Empty(t, message)
+		require.Len(t, nb.cells, 3)
+		cell := nb.cells[1]
+		require.Equal(t, "code", cellType(cell))
+		require.Len(t, cellID(cell), 8)
+		require.Equal(t, []any{}, cell["outputs"])
+		require.Equal(t, "import os\n", multilineString(cell["source"]))
+	})
+
+	t.Run("append", func(t *testing.T) {
+		t.Parallel()
+		nb, message := edit(t, NotebookEditParams{CellIndex: 2, EditMode: "insert", CellType: "markdown", NewSource: "End"})
+		require.Empty(t, message)
+		require.Equal(t, "End", multilineString(nb.cells[2]["source"]))
+		require.NotContains(t, nb.cells[2], "outputs")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		t.Parallel()
+		nb, message := edit(t, NotebookEditParams{CellID: "intro", EditMode: "delete"})
+		require.Empty(t, message)
+		require.Len(t, nb.cells, 1)
+		require.Equal(t, "plot", cellID(nb.cells[0]))
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		t.Parallel()
+		_, message := edit(t, NotebookEditParams{CellIndex: 5, EditMode: "replace", NewSource: "x"})
+		require.Contains(t, message, "out of range")
+		_, message = edit(t, NotebookEditParams{CellID: "missing", EditMode: "delete"})
+		require.Contains(t, message, "not found")
+	})
+}
@@ -0,0 +1,414 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// patchAction is what a patch does to a file.
+type patchAction string
+
+const (
+	patchAdd    patchAction = "add"
+	patchUpdate patchAction = "update"
+	patchDelete patchAction = "delete"
+)
+
+// filePatch is the change a patch makes to one file.
+type filePatch struct {
+	action patchAction
+	path   string
+	// movePath is where an updated file is moved to, if anywhere.
+	movePath string
+	// content is the content of an added file.
+	content string
+	hunks   []patchHunk
+}
+
+// patchHunk replaces lines of a file.
+type patchHunk struct {
+	// anchor is a line the hunk comes after, from the "@@" line of the
+	// "*** Begin Patch" format.
+	anchor string
+	// oldStart is the 1-based line the hunk starts at in unified diffs, 0
+	// when unknown.
+	oldStart  int
+	oldLines  []string
+	newLines  []string
+	endOfFile bool
+}
+
+// parsePatch parses a unified diff or a patch in the "*** Begin Patch"
+// format.
+func parsePatch(text string) ([]filePatch, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.HasPrefix(strings.TrimSpace(text), "*** Begin Patch") {
+		return parseEnvelopePatch(text)
+	}
+	return parseUnifiedDiff(text)
+}
+
+// parseEnvelopePatch parses the "*** Begin Patch" format:
+//
+//	*** Begin Patch
+//	*** Add File: path
+//	+line
+//	*** Update File: path
+//	*** Move to: new path
+//	@@ line the hunk comes after
+//	 context
+//	-removed
+//	+added
+//	*** Delete File: path
+//	*** End Patch
+func parseEnvelopePatch(text string) ([]filePatch, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var patches []filePatch
+	var current *filePatch
+	var hunk *patchHunk
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if hunk != nil {
+			current.hunks = append(current.hunks, *hunk)
+			hunk = nil
+		}
+		patches = append(patches, *current)
+		current = nil
+	}
+
+	for i, line := range lines[1:] {
+		lineNum := i + 2
+		switch {
+		case line == "*** End Patch":
+			flush()
+			if len(patches) == 0 {
+				return nil, errors.New("the patch changes no files")
+			}
+			return patches, nil
+		case strings.HasPrefix(line, "*** Add File: "):
+			flush()
+			current = &filePatch{action: patchAdd, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Add File: "))}
+		case strings.HasPrefix(line, "*** Delete File: "):
+			flush()
+			current = &filePatch{action: patchDelete, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Delete File: "))}
+		case strings.HasPrefix(line, "*** Update File: "):
+			flush()
+			current = &filePatch{action: patchUpdate, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Update File: "))}
+		case current == nil:
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: expected a file operation, got %q", lineNum, line)
+			}
+		case strings.HasPrefix(line, "*** Move to: "):
+			if current.action != patchUpdate {
+				return nil, fmt.Errorf("line %d: only updated files can be moved", lineNum)
+			}
+			current.movePath = strings.TrimSpace(strings.TrimPrefix(line, "*** Move to: "))
+		case current.action == patchAdd:
+			if !strings.HasPrefix(line, "+") {
+				return nil, fmt.Errorf("line %d: the lines of an added file must start with +", lineNum)
+			}
+			current.content += line[1:] + "\n"
+		case current.action == patchDelete:
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: unexpected content for a deleted file", lineNum)
+			}
+		case line == "*** End of File":
+			if hunk == nil {
+				return nil, fmt.Errorf("line %d: end of file marker outside of a hunk", lineNum)
+			}
+			hunk.endOfFile = true
+		case strings.HasPrefix(line, "@@"):
+			if hunk != nil {
+				current.hunks = append(current.hunks, *hunk)
+			}
+			hunk = &patchHunk{anchor: strings.TrimSpace(strings.TrimPrefix(line, "@@"))}
+		default:
+			if hunk == nil {
+				hunk = &patchHunk{}
+			}
+			if err := hunk.addLine(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		}
+	}
+	return nil, errors.New("the patch must end with *** End Patch")
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff parses the output of diff -u or git diff.
+func parseUnifiedDiff(text string) ([]filePatch, error) {
+	lines := strings.Split(text, "\n")
+	var patches []filePatch
+	var gitOld, gitNew string
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			gitOld, gitNew = "", ""
+			// A rename without changes has no --- and +++ lines.
+			var renameFrom, renameTo string
+			for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "diff --git ") && !strings.HasPrefix(lines[j], "--- "); j++ {
+				if from, ok := strings.CutPrefix(lines[j], "rename from "); ok {
+					renameFrom = from
+				}
+				if to, ok := strings.CutPrefix(lines[j], "rename to "); ok {
+					renameTo = to
+				}
+			}
+			if renameFrom != "" && renameTo != "" {
+				gitOld, gitNew = renameFrom, renameTo
+				if !hasHunksBefore(lines[i+1:]) {
+					patches = append(patches, filePatch{action: patchUpdate, path: renameFrom, movePath: renameTo})
+				}
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath := diffPath(strings.TrimPrefix(line, "--- "), "a/")
+			newPath := diffPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+			i++
+			patch := filePatch{action: patchUpdate, path: oldPath}
+			switch {
+			case oldPath == "" && newPath == "":
+				return nil, fmt.Errorf("line %d: missing file names", i)
+			case oldPath == "":
+				patch = filePatch{action: patchAdd, path: newPath}
+			case newPath == "":
+				patch.action = patchDelete
+			case newPath != oldPath:
+				patch.movePath = newPath
+			}
+			if gitOld != "" && patch.action == patchUpdate && patch.movePath == "" && gitNew != gitOld {
+				patch.movePath = gitNew
+			}
+
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "@@") {
+				i++
+				hunk, next, err := parseUnifiedHunk(lines, i)
+				if err != nil {
+					return nil, err
+				}
+				patch.hunks = append(patch.hunks, hunk)
+				i = next - 1
+			}
+			if patch.action == patchAdd {
+				for _, hunk := range patch.hunks {
+					for _, l := range hunk.newLines {
+						patch.content += l + "\n"
+					}
+				}
+				patch.hunks = nil
+			}
+			patches = append(patches, patch)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("no file changes found, the patch must be a unified diff or start with *** Begin Patch")
+	}
+	return patches, nil
+}
+
+// parseUnifiedHunk parses the hunk starting with the "@@" line at start. It
+// returns the index of the line after it.
+func parseUnifiedHunk(lines []string, start int) (patchHunk, int, error) {
+	var hunk patchHunk
+	oldCount, newCount := -1, -1
+	if m := hunkHeaderRe.FindStringSubmatch(lines[start]); m != nil {
+		hunk.oldStart, _ = strconv.Atoi(m[1])
+		oldCount, newCount = 1, 1
+		if m[2] != "" {
+			oldCount, _ = strconv.Atoi(m[2])
+		}
+		if m[4] != "" {
+			newCount, _ = strconv.Atoi(m[4])
+		}
+	}
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if oldCount >= 0 && len(hunk.oldLines) >= oldCount && len(hunk.newLines) >= newCount && !strings.HasPrefix(line, `\`) {
+			break
+		}
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff --git ") ||
+			(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")) {
+			break
+		}
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file"
+			continue
+		}
+		if line == "" && i == len(lines)-1 {
+			break
+		}
+		if err := hunk.addLine(line); err != nil {
+			return patchHunk{}, 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	if oldCount == 0 {
+		// Lines are added after the given line.
+		hunk.oldStart++
+	}
+	return hunk, i, nil
+}
+
+func hasHunksBefore(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			return false
+		}
+		if strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "@@") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffPath returns the path of a ---/+++ line, or "" for /dev/null.
+func diffPath(name, prefix string) string {
+	if before, _, ok := strings.Cut(name, "\t"); ok {
+		name = before
+	}
+	name = strings.TrimSpace(name)
+	if name == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(name, prefix)
+}
+
+func (h *patchHunk) addLine(line string) error {
+	switch {
+	case line == "":
+		// Editors and models often strip the space of empty context lines.
+		h.oldLines = append(h.oldLines, "")
+		h.newLines = append(h.newLines, "")
+	case line[0] == ' ':
+		h.oldLines = append(h.oldLines, line[1:])
+		h.newLines = append(h.newLines, line[1:])
+	case line[0] == '-':
+		h.oldLines = append(h.oldLines, line[1:])
+	case line[0] == '+':
+		h.newLines = append(h.newLines, line[1:])
+	default:
+		return fmt.Errorf("hunk lines must start with a space, - or +, got %q", line)
+	}
+	return nil
+}
+
+// applyHunks applies the hunks of an updated file to its content.
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	cursor := 0
+	for n, hunk := range hunks {
+		if hunk.anchor != "" {
+			for i := cursor; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == hunk.anchor {
+					cursor = i + 1
+					break
+				}
+			}
+		}
+		index, newLines, err := findHunk(lines, hunk, cursor)
+		if err != nil {
+			return "", fmt.Errorf("hunk %d: %w", n+1, err)
+		}
+		replaced := make([]string, 0, len(lines)-len(hunk.oldLines)+len(newLines))
+		replaced = append(replaced, lines[:index]...)
+		replaced = append(replaced, newLines...)
+		replaced = append(replaced, lines[index+len(hunk.oldLines):]...)
+		lines = replaced
+		cursor = index + len(newLines)
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findHunk returns where the lines the hunk replaces are, from cursor on,
+// and the lines to put in their place. Lines are compared exactly first, then
+// ignoring trailing whitespace and then indentation, in which case the new
+// lines are re-indented to match the file. When a hunk has a line number,
+// the closest match wins, otherwise tolerant matches must be unique.
+func findHunk(lines []string, hunk patchHunk, cursor int) (int, []string, error) {
+	if len(hunk.oldLines) == 0 {
+		switch {
+		case hunk.oldStart > 0:
+			return min(hunk.oldStart-1, len(lines)), hunk.newLines, nil
+		case hunk.endOfFile || hunk.anchor == "":
+			return len(lines), hunk.newLines, nil
+		default:
+			return cursor, hunk.newLines, nil
+		}
+	}
+
+	for _, strategy := range []matchStrategy{matchExact, matchTrailingWhitespace, matchIndentation} {
+		best, count := -1, 0
+		for i := cursor; i+len(hunk.oldLines) <= len(lines); i++ {
+			if hunk.endOfFile && i+len(hunk.oldLines) != len(lines) {
+				continue
+			}
+			if !hunkLinesMatch(lines[i:i+len(hunk.oldLines)], hunk.oldLines, strategy) {
+				continue
+			}
+			if hunk.oldStart == 0 && strategy == matchExact {
+				best = i
+				break
+			}
+			count++
+			if best == -1 || hunk.oldStart > 0 && abs(i-(hunk.oldStart-1)) < abs(best-(hunk.oldStart-1)) {
+				best = i
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		if hunk.oldStart == 0 && count > 1 {
+			return 0, nil, fmt.Errorf("the lines to change were not found exactly, and match %d places %s. Add more context lines or a line number", count, strategy)
+		}
+		newLines := hunk.newLines
+		if strategy == matchIndentation && len(newLines) > 0 {
+			matched := lines[best : best+len(hunk.oldLines)]
+			newLines = strings.Split(reindent(strings.Join(hunk.newLines, "\n"), hunk.oldLines, matched), "\n")
+		}
+		return best, newLines, nil
+	}
+
+	msg := "the lines to change were not found:\n" + strings.Join(hunk.oldLines, "\n")
+	if start, region := closestRegion(strings.Join(lines, "\n"), strings.Join(hunk.oldLines, "\n")); len(region) > 0 {
+		msg += fmt.Sprintf("\nThe closest match is at lines %d-%d:\n%s", start+1, start+len(region), addLineNumbers(strings.Join(region, "\n"), start+1))
+	}
+	return 0, nil, errors.New(msg)
+}
+
+func hunkLinesMatch(lines, oldLines []string, strategy matchStrategy) bool {
+	if strategy == matchExact {
+		for i, line := range lines {
+			if line != oldLines[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return linesMatch(lines, oldLines, strategy)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
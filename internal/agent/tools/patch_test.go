@@ -0,0 +1,306 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("envelope", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatch(`*** Begin Patch
+*** Add File: new.txt
++hello
++world
+*** Update File: main.go
+*** Move to: cmd/main.go
+@@ func main() {
+ 	a()
+-	b()
++	c()
+*** End of File
+*** Delete File: old.txt
+*** End Patch`)
+		require.NoError(t, err)
+		require.Len(t, patches, 3)
+
+		require.Equal(t, patchAdd, patches[0].action)
+		require.Equal(t, "new.txt", patches[0].path)
+		require.Equal(t, "hello\nworld\n", patches[0].content)
+
+		require.Equal(t, patchUpdate, patches[1].action)
+		require.Equal(t, "cmd/main.go", patches[1].movePath)
+		require.Len(t, patches[1].hunks, 1)
+		hunk := patches[1].hunks[0]
+		require.Equal(t, "func main() {", hunk.anchor)
+		require.Equal(t, []string{"\ta()", "\tb()"}, hunk.oldLines)
+		require.Equal(t, []string{"\ta()", "\tc()"}, hunk.newLines)
+		require.True(t, hunk.endOfFile)
+
+		require.Equal(t, patchDelete, patches[2].action)
+	})
+
+	t.Run("envelope without end", func(t *testing.T) {
+		t.Parallel()
+		_, err := parsePatch("*** Begin Patch\n*** Delete File: a.txt\n")
+		require.ErrorContains(t, err, "*** End Patch")
+	})
+
+	t.Run("unified diff", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatch(`diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,3 @@
+ package a
+-var x = 1
++var x = 2
+
+diff --git a/b.txt b/b.txt
+new file mode 100644
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1,2 @@
++one
++two
+diff --git a/c.txt b/c.txt
+deleted file mode 100644
+--- a/c.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/d.txt b/e.txt
+similarity index 100%
+rename from d.txt
+rename to e.txt
+`)
+		require.NoError(t, err)
+		require.Len(t, patches, 4)
+
+		require.Equal(t, patchUpdate, patches[0].action)
+		require.Equal(t, "a.go", patches[0].path)
+		require.Equal(t, 1, patches[0].hunks[0].oldStart)
+		require.Equal(t, []string{"package a", "var x = 1", ""}, patches[0].hunks[0].oldLines)
+
+		require.Equal(t, patchAdd, patches[1].action)
+		require.Equal(t, "one\ntwo\n", patches[1].content)
+
+		require.Equal(t, patchDelete, patches[2].action)
+		require.Equal(t, "c.txt", patches[2].path)
+
+		require.Equal(t, patchUpdate, patches[3].action)
+		require.Equal(t, "d.txt", patches[3].path)
+		require.Equal(t, "e.txt", patches[3].movePath)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		t.Parallel()
+		_, err := parsePatch("just some text")
+		require.ErrorContains(t, err, "no file changes found")
+	})
+}
+
+func TestApplyHunks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("uses the line number to pick between matches", func(t *testing.T) {
+		t.Parallel()
+		content := "x\ny\nx\ny\n"
+		result, err := applyHunks(content, []patchHunk{{oldStart: 3, oldLines: []string{"x"}, newLines: []string{"z"}}})
+		require.NoError(t, err)
+		require.Equal(t, "x\ny\nz\ny\n", result)
+	})
+
+	t.Run("uses the anchor to pick between matches", func(t *testing.T) {
+		t.Parallel()
+		content := "func a() {\n\treturn 1\n}\nfunc b() {\n\treturn 1\n}\n"
+		result, err := applyHunks(content, []patchHunk{{anchor: "func b() {", oldLines: []string{"\treturn 1"}, newLines: []string{"\treturn 2"}}})
+		require.NoError(t, err)
+		require.Equal(t, "func a() {\n\treturn 1\n}\nfunc b() {\n\treturn 2\n}\n", result)
+	})
+
+	t.Run("tolerates indentation", func(t *testing.T) {
+		t.Parallel()
+		result, err := applyHunks("\tfoo()\n", []patchHunk{{oldLines: []string{"    foo()"}, newLines: []string{"\tbar()"}}})
+		require.NoError(t, err)
+		require.Equal(t, "\tbar()\n", result)
+	})
+
+	t.Run("re-indents tolerant matches", func(t *testing.T) {
+		t.Parallel()
+		content := "def f():\n        if x:\n            return 1\n"
+		result, err := applyHunks(content, []patchHunk{{
+			oldLines: []string{"if x:", "    return 1"},
+			newLines: []string{"if x:", "    return 2", "return 3"},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, "def f():\n        if x:\n            return 2\n        return 3\n", result)
+	})
+
+	t.Run("tolerant matches must be unique", func(t *testing.T) {
+		t.Parallel()
+		content := "a:\n  b: 1\nc:\n    b: 1\n"
+		_, err := applyHunks(content, []patchHunk{{oldLines: []string{"b: 1"}, newLines: []string{"b: 2"}}})
+		require.ErrorContains(t, err, "match 2 places")
+
+		result, err := applyHunks(content, []patchHunk{{oldStart: 4, oldLines: []string{"b: 1"}, newLines: []string{"b: 2"}}})
+		require.NoError(t, err)
+		require.Equal(t, "a:\n  b: 1\nc:\n    b: 2\n", result)
+	})
+
+	t.Run("inserts at the end of the file", func(t *testing.T) {
+		t.Parallel()
+		result, err := applyHunks("a\n", []patchHunk{{newLines: []string{"b"}, endOfFile: true}})
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\n", result)
+	})
+
+	t.Run("missing lines show the closest match", func(t *testing.T) {
+		t.Parallel()
+		_, err := applyHunks("one\ntwo\nthree\n", []patchHunk{{oldLines: []string{"two", "thre"}, newLines: []string{"2"}}})
+		require.ErrorContains(t, err, "hunk 1: the lines to change were not found")
+		require.ErrorContains(t, err, "The closest match is at lines 2-3")
+	})
+}
+
+func TestPlanPatch(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (context.Context, filetracker.Service, string) {
+		ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+		tracker := &mockFileTracker{}
+		dir := t.TempDir()
+		for name, content := range map[string]string{"a.txt": "one\ntwo\n", "b.txt": "bye\n", "crlf.txt": "x\r\ny\r\n"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			recordFileRead(ctx, tracker, path)
+		}
+		return ctx, tracker, dir
+	}
+
+	t.Run("valid patch", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		patches, err := parsePatch(`*** Begin Patch
+*** Update File: a.txt
+@@
+ one
+-two
++2
+*** Update File: crlf.txt
+*** Move to: sub/crlf.txt
+@@
+-y
++z
+*** Delete File: b.txt
+*** Add File: c.txt
++new
+*** End Patch`)
+		require.NoError(t, err)
+
+		changes, err := planPatch(ctx, tracker, patches, dir)
+		require.NoError(t, err)
+		require.Len(t, changes, 4)
+		require.Equal(t, "one\n2\n", changes[0].NewContent)
+		require.Equal(t, "x\nz\n", changes[1].NewContent)
+		require.True(t, changes[1].crlf)
+		require.Equal(t, filepath.Join(dir, "sub", "crlf.txt"), changes[1].NewPath)
+
+		require.NoError(t, writePatchChanges(changes))
+		_, err = os.Stat(filepath.Join(dir, "b.txt"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "crlf.txt"))
+		require.True(t, os.IsNotExist(err))
+		content, err := os.ReadFile(filepath.Join(dir, "sub", "crlf.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "x\r\nz\r\n", string(content))
+		content, err = os.ReadFile(filepath.Join(dir, "c.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "new\n", string(content))
+	})
+
+	t.Run("encoded file", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		format := fsext.TextFormat{Encoding: fsext.UTF16LE, BOM: true}
+		path := filepath.Join(dir, "utf16.txt")
+		data, err := format.Encode("café\r\ntwo\r\n")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		recordFileRead(ctx, tracker, path)
+
+		patches, err := parsePatch("*** Begin Patch\n*** Update File: utf16.txt\n@@\n café\n-two\n+deux\n*** End Patch")
+		require.NoError(t, err)
+		changes, err := planPatch(ctx, tracker, patches, dir)
+		require.NoError(t, err)
+		require.Equal(t, "café\ntwo\n", changes[0].OldContent)
+		require.Equal(t, "café\ndeux\n", changes[0].NewContent)
+
+		require.NoError(t, writePatchChanges(changes))
+		content, got, err := fsext.ReadTextFile(path)
+		require.NoError(t, err)
+		require.Equal(t, format, got)
+		require.Equal(t, "café\r\ndeux\r\n", content)
+	})
+
+	t.Run("binary file", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		path := filepath.Join(dir, "bin.dat")
+		require.NoError(t, os.WriteFile(path, []byte{0x00, 0x01, 0x02, 'a', '\n'}, 0o644))
+		recordFileRead(ctx, tracker, path)
+
+		patches, err := parsePatch("*** Begin Patch\n*** Update File: bin.dat\n@@\n-a\n+b\n*** End Patch")
+		require.NoError(t, err)
+		_, err = planPatch(ctx, tracker, patches, dir)
+		require.ErrorContains(t, err, "binary")
+	})
+
+	t.Run("one failing hunk rejects the whole patch", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		patches, err := parsePatch("*** Begin Patch\n*** Add File: c.txt\n+new\n*** Update File: a.txt\n@@\n-three\n+3\n*** End Patch")
+		require.NoError(t, err)
+		_, err = planPatch(ctx, tracker, patches, dir)
+		require.ErrorContains(t, err, "cannot update")
+	})
+
+	t.Run("files must be read in their current state", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		patches, err := parsePatch("*** Begin Patch\n*** Delete File: b.txt\n*** End Patch")
+		require.NoError(t, err)
+
+		_, err = planPatch(context.WithValue(t.Context(), SessionIDContextKey, "other"), tracker, patches, dir)
+		require.ErrorContains(t, err, "you must read")
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed\n"), 0o644))
+		_, err = planPatch(ctx, tracker, patches, dir)
+		require.ErrorContains(t, err, "has been modified since it was last read")
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		t.Parallel()
+		ctx, tracker, dir := setup(t)
+		for patch, msg := range map[string]string{
+			"*** Begin Patch\n*** Add File: a.txt\n+x\n*** End Patch":                                  "already exists",
+			"*** Begin Patch\n*** Delete File: missing.txt\n*** End Patch":                             "does not exist",
+			"*** Begin Patch\n*** Delete File: ../outside.txt\n*** End Patch":                          "outside of the working directory",
+			"*** Begin Patch\n*** Delete File: a.txt\n*** Delete File: a.txt\n*** End Patch":           "changed more than once",
+			"*** Begin Patch\n*** Update File: a.txt\n*** Move to: b.txt\n@@\n-one\n+1\n*** End Patch": "already exists",
+		} {
+			patches, err := parsePatch(patch)
+			require.NoError(t, err)
+			_, err = planPatch(ctx, tracker, patches, dir)
+			require.ErrorContains(t, err, msg, patch)
+		}
+	})
+}
@@ -34,7 +34,7 @@ const ReferencesToolName = "lsp_references"
 //go:embed references.md
 var referencesDescription []byte
 
-func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client]) fantasy.AgentTool {
+func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		ReferencesToolName,
 		string(referencesDescription),
@@ -43,10 +43,6 @@ func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client]) fantasy.Agent
 				return fantasy.NewTextErrorResponse("symbol is required"), nil
 			}
 
-			if lspClients.Len() == 0 {
-				return fantasy.NewTextErrorResponse("no LSP clients available"), nil
-			}
-
 			workingDir := cmp.Or(params.Path, ".")
 
 			matches, _, err := searchFiles(ctx, regexp.QuoteMeta(params.Symbol), workingDir, "", 100)
@@ -61,7 +57,7 @@ func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client]) fantasy.Agent
 			var allLocations []protocol.Location
 			var allErrs error
 			for _, match := range matches {
-				locations, err := find(ctx, lspClients, params.Symbol, match)
+				locations, err := find(ctx, lspClients, touchLSPs, params.Symbol, match)
 				if err != nil {
 					if strings.Contains(err.Error(), "no identifier found") {
 						// grep probably matched a comment, string value, or something else that's irrelevant
@@ -83,6 +79,9 @@ func NewReferencesTool(lspClients *csync.Map[string, *lsp.Client]) fantasy.Agent
 			if allErrs != nil {
 				return fantasy.NewTextErrorResponse(allErrs.Error()), nil
 			}
+			if lspClients.Len() == 0 {
+				return fantasy.NewTextErrorResponse("no LSP clients available"), nil
+			}
 			return fantasy.NewTextResponse(fmt.Sprintf("No references found for symbol '%s'", params.Symbol)), nil
 		})
 }
@@ -91,12 +90,13 @@ func (r *referencesTool) Name() string {
 	return ReferencesToolName
 }
 
-func find(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], symbol string, match grepMatch) ([]protocol.Location, error) {
+func find(ctx context.Context, lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, symbol string, match grepMatch) ([]protocol.Location, error) {
 	absPath, err := filepath.Abs(match.path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %s", err)
 	}
 
+	defer touchLSPs.use(ctx, absPath)()
 	var client *lsp.Client
 	for c := range lspClients.Seq() {
 		if c.HandlesFile(absPath) {
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type RenameParams struct {
+	FilePath string `json:"file_path" description:"The path to the file containing the symbol"`
+	Line     int    `json:"line,omitempty" description:"The line of the symbol (1-based)"`
+	Column   int    `json:"column,omitempty" description:"The column of the symbol (1-based)"`
+	Symbol   string `json:"symbol,omitempty" description:"The current symbol name, used to find the position when line or column are not given"`
+	NewName  string `json:"new_name" description:"The new name of the symbol"`
+}
+
+type RenamePermissionsParams struct {
+	Symbol  string                `json:"symbol"`
+	NewName string                `json:"new_name"`
+	Files   []WorkspaceFileChange `json:"files"`
+}
+
+type RenameResponseMetadata struct {
+	Symbol    string   `json:"symbol"`
+	NewName   string   `json:"new_name"`
+	Files     []string `json:"files"`
+	Additions int      `json:"additions"`
+	Removals  int      `json:"removals"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
+}
+
+const RenameToolName = "rename"
+
+//go:embed rename.md
+var renameDescription []byte
+
+func NewRenameTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		RenameToolName,
+		string(renameDescription),
+		func(ctx context.Context, params RenameParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.NewName == "" {
+				return fantasy.NewTextErrorResponse("new_name is required"), nil
+			}
+
+			client, pos, done, err := resolveLSPPosition(ctx, lspClients, touchLSPs, workingDir, LSPPositionParams{
+				FilePath: params.FilePath,
+				Line:     params.Line,
+				Column:   params.Column,
+				Symbol:   params.Symbol,
+			})
+			if err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+			defer done()
+
+			target, err := client.PrepareRename(ctx, pos.path, pos.line, pos.column)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("cannot rename %s: %s", pos, err)), nil
+			}
+			if target == nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("cannot rename %s: no renameable symbol at this position", pos)), nil
+			}
+			oldName := renameOldName(target, pos)
+
+			edit, err := client.Rename(ctx, pos.path, pos.line, pos.column, params.NewName)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to rename %s: %s", pos, err)), nil
+			}
+			fileChanges, err := previewWorkspaceEdit(edit, workingDir)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to apply rename: %s", err)), nil
+			}
+			if len(fileChanges) == 0 {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("no changes made - renaming %s to %s changes nothing", oldName, params.NewName)), nil
+			}
+
+			sessionID := GetSessionFromContext(ctx)
+			if sessionID == "" {
+				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for renaming symbols")
+			}
+
+			paths := make([]string, 0, len(fileChanges))
+			for _, change := range fileChanges {
+				paths = append(paths, change.FilePath)
+			}
+			snapshot := snapshotDiagnostics(ctx, lspClients, paths...)
+
+			p := permissions.Request(permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    RenameToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Rename %s to %s in %d file(s)", oldName, params.NewName, len(fileChanges)),
+				Params: RenamePermissionsParams{
+					Symbol:  oldName,
+					NewName: params.NewName,
+					Files:   fileChanges,
+				},
+			})
+			if !p {
+				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+			}
+
+			changedPaths, err := applyWorkspaceEdit(ctx, files, tracker, sessionID, fileChanges)
+			if err != nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to apply rename: %s", err)), nil
+			}
+
+			notifyLSPsOfFiles(ctx, lspClients, changedPaths)
+
+			var totalAdditions, totalRemovals int
+			for _, change := range fileChanges {
+				totalAdditions += change.Additions
+				totalRemovals += change.Removals
+			}
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "<result>\nRenamed %s to %s in %d file(s):\n", oldName, params.NewName, len(fileChanges))
+			output.WriteString(formatWorkspaceChanges(fileChanges, workingDir))
+			output.WriteString("</result>\n")
+			diagnostics, delta := snapshot.compare(lspClients)
+			output.WriteString(diagnostics)
+
+			relPaths := make([]string, len(changedPaths))
+			for i, path := range changedPaths {
+				relPaths[i] = relativePath(workingDir, path)
+			}
+			return fantasy.WithResponseMetadata(
+				fantasy.NewTextResponse(output.String()),
+				RenameResponseMetadata{
+					Symbol:      oldName,
+					NewName:     params.NewName,
+					Files:       relPaths,
+					Additions:   totalAdditions,
+					Removals:    totalRemovals,
+					Diagnostics: delta,
+				},
+			), nil
+		})
+}
+
+// renameOldName returns the current name of the symbol being renamed.
+func renameOldName(target *lsp.RenameTarget, pos lspPosition) string {
+	if target.Placeholder != "" {
+		return target.Placeholder
+	}
+	if r := target.Range; r.Start.Line == r.End.Line && r.Start.Character < r.End.Character {
+		if content, err := os.ReadFile(pos.path); err == nil {
+			lines := strings.Split(string(content), "\n")
+			if line := int(r.Start.Line); line < len(lines) && int(r.End.Character) <= len(lines[line]) {
+				return lines[line][r.Start.Character:r.End.Character]
+			}
+		}
+	}
+	if pos.symbol != "" {
+		return pos.symbol
+	}
+	return pos.String()
+}
@@ -0,0 +1,282 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/lsp"
+)
+
+type DocumentSymbolsParams struct {
+	FilePath string `json:"file_path" description:"The path to the file to outline"`
+}
+
+type WorkspaceSymbolsParams struct {
+	Query string `json:"query" description:"The symbol name, or part of it, to search for"`
+	Kind  string `json:"kind,omitempty" description:"Only return symbols of this kind (e.g. function, method, struct, class, interface, variable)"`
+}
+
+const (
+	DocumentSymbolsToolName  = "lsp_document_symbols"
+	WorkspaceSymbolsToolName = "lsp_workspace_symbols"
+
+	// maxOutlineSymbols is how many symbols of a file outline are shown at
+	// most.
+	maxOutlineSymbols = 500
+	// maxSymbolMatches is how many workspace symbols are shown at most.
+	maxSymbolMatches = 50
+)
+
+//go:embed document_symbols.md
+var documentSymbolsDescription []byte
+
+//go:embed workspace_symbols.md
+var workspaceSymbolsDescription []byte
+
+func NewDocumentSymbolsTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		DocumentSymbolsToolName,
+		string(documentSymbolsDescription),
+		func(ctx context.Context, params DocumentSymbolsParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.FilePath == "" {
+				return fantasy.NewTextErrorResponse("file_path is required"), nil
+			}
+			path := params.FilePath
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workingDir, path)
+			}
+			relPath := relativePath(workingDir, path)
+
+			defer touchLSPs.use(ctx, path)()
+			var lists [][]lsp.Symbol
+			var errs error
+			for client := range lspClients.Seq() {
+				if !client.HandlesFile(path) {
+					continue
+				}
+				symbols, err := client.DocumentSymbols(ctx, path)
+				if err != nil {
+					errs = errors.Join(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+					continue
+				}
+				lists = append(lists, symbols)
+			}
+			if lists == nil && errs == nil {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("no LSP client available for %s", params.FilePath)), nil
+			}
+			symbols := mergeSymbols(lists...)
+			if len(symbols) == 0 {
+				if errs != nil {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get symbols: %s", errs)), nil
+				}
+				return fantasy.NewTextResponse(fmt.Sprintf("No symbols found in %s", relPath)), nil
+			}
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "Outline of %s:\n\n", relPath)
+			output.WriteString(formatOutline(symbols))
+			return fantasy.NewTextResponse(output.String()), nil
+		})
+}
+
+func NewWorkspaceSymbolsTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, workingDir string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(
+		WorkspaceSymbolsToolName,
+		string(workspaceSymbolsDescription),
+		func(ctx context.Context, params WorkspaceSymbolsParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			if params.Query == "" {
+				return fantasy.NewTextErrorResponse("query is required"), nil
+			}
+			defer touchLSPs.use(ctx)()
+			if lspClients.Len() == 0 {
+				return fantasy.NewTextErrorResponse("no LSP clients available"), nil
+			}
+
+			var all []lsp.Symbol
+			var errs error
+			for client := range lspClients.Seq() {
+				symbols, err := client.WorkspaceSymbols(ctx, params.Query)
+				if err != nil {
+					errs = errors.Join(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+					continue
+				}
+				all = append(all, symbols...)
+			}
+			if params.Kind != "" {
+				all = slices.DeleteFunc(all, func(s lsp.Symbol) bool {
+					return !strings.EqualFold(lsp.SymbolKindName(s.Kind), params.Kind)
+				})
+			}
+			matches := rankSymbols(all, params.Query, workingDir)
+			if len(matches) == 0 {
+				if errs != nil {
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to search symbols: %s", errs)), nil
+				}
+				return fantasy.NewTextResponse(fmt.Sprintf("No symbols matching '%s' found", params.Query)), nil
+			}
+
+			var output strings.Builder
+			fmt.Fprintf(&output, "Found %d symbol(s) matching '%s':\n\n", len(matches), params.Query)
+			for i, s := range matches {
+				if i == maxSymbolMatches {
+					fmt.Fprintf(&output, "\n... and %d more, refine the query to narrow them down\n", len(matches)-maxSymbolMatches)
+					break
+				}
+				output.WriteString(formatSymbolMatch(s, workingDir))
+			}
+			return fantasy.NewTextResponse(output.String()), nil
+		})
+}
+
+// symbolKey identifies a symbol across LSP servers.
+func symbolKey(s lsp.Symbol) string {
+	return fmt.Sprintf("%s:%d:%s", s.URI, s.Range.Start.Line, s.Name)
+}
+
+// mergeSymbols merges the outlines several LSP servers returned for the same
+// file. Symbols reported by more than one server are kept once, with the
+// most detailed children.
+func mergeSymbols(lists ...[]lsp.Symbol) []lsp.Symbol {
+	var merged []lsp.Symbol
+	seen := map[string]int{}
+	for _, list := range lists {
+		for _, s := range list {
+			key := symbolKey(s)
+			if i, ok := seen[key]; ok {
+				if len(s.Children) > len(merged[i].Children) {
+					merged[i] = s
+				}
+				continue
+			}
+			seen[key] = len(merged)
+			merged = append(merged, s)
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b lsp.Symbol) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	return merged
+}
+
+// formatOutline renders the symbol tree, one symbol per line, as kind, name
+// and 1-based range.
+func formatOutline(symbols []lsp.Symbol) string {
+	var output strings.Builder
+	count := 0
+	var walk func(symbols []lsp.Symbol, depth int)
+	walk = func(symbols []lsp.Symbol, depth int) {
+		for _, s := range symbols {
+			if count == maxOutlineSymbols {
+				return
+			}
+			count++
+			fmt.Fprintf(&output, "%s%s %s", strings.Repeat("  ", depth), lsp.SymbolKindName(s.Kind), s.Name)
+			if s.Detail != "" {
+				fmt.Fprintf(&output, " %s", s.Detail)
+			}
+			fmt.Fprintf(&output, " [%d:%d-%d:%d]\n",
+				s.Range.Start.Line+1, s.Range.Start.Character+1,
+				s.Range.End.Line+1, s.Range.End.Character+1)
+			walk(s.Children, depth+1)
+		}
+	}
+	walk(symbols, 0)
+	if total := countSymbols(symbols); total > count {
+		fmt.Fprintf(&output, "\n... and %d more\n", total-count)
+	}
+	return output.String()
+}
+
+func countSymbols(symbols []lsp.Symbol) int {
+	n := len(symbols)
+	for _, s := range symbols {
+		n += countSymbols(s.Children)
+	}
+	return n
+}
+
+// rankSymbols removes duplicates and orders the symbols by how well they
+// match the query: exact matches first, then prefix matches, then the rest.
+// Ties prefer symbols inside the working directory and shorter names.
+func rankSymbols(symbols []lsp.Symbol, query, workingDir string) []lsp.Symbol {
+	seen := map[string]bool{}
+	ranked := make([]lsp.Symbol, 0, len(symbols))
+	for _, s := range symbols {
+		key := symbolKey(s)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ranked = append(ranked, s)
+	}
+
+	outside := func(s lsp.Symbol) int {
+		path, err := s.URI.Path()
+		if err != nil || relativePath(workingDir, path) == path {
+			return 1
+		}
+		return 0
+	}
+	slices.SortStableFunc(ranked, func(a, b lsp.Symbol) int {
+		return cmp.Or(
+			cmp.Compare(symbolMatchScore(a, query), symbolMatchScore(b, query)),
+			cmp.Compare(outside(a), outside(b)),
+			cmp.Compare(len(a.Name), len(b.Name)),
+			strings.Compare(string(a.URI), string(b.URI)),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+		)
+	})
+	return ranked
+}
+
+// symbolMatchScore rates how well the symbol matches the query, lower is
+// better. Qualified queries such as "pkg.Func" are matched against the
+// container name too.
+func symbolMatchScore(s lsp.Symbol, query string) int {
+	score := func(name string) int {
+		lowerName, lowerQuery := strings.ToLower(name), strings.ToLower(query)
+		switch {
+		case name == query:
+			return 0
+		case lowerName == lowerQuery:
+			return 1
+		case strings.HasPrefix(name, query):
+			return 2
+		case strings.HasPrefix(lowerName, lowerQuery):
+			return 3
+		case strings.Contains(lowerName, lowerQuery):
+			return 4
+		default:
+			// The server matched it fuzzily.
+			return 5
+		}
+	}
+	best := score(s.Name)
+	if s.ContainerName != "" {
+		best = min(best, score(s.ContainerName+"."+s.Name))
+	}
+	return best
+}
+
+func formatSymbolMatch(s lsp.Symbol, workingDir string) string {
+	location := string(s.URI)
+	if path, err := s.URI.Path(); err == nil {
+		location = relativePath(workingDir, path)
+	}
+	name := s.Name
+	if s.ContainerName != "" {
+		name = s.ContainerName + "." + s.Name
+	}
+	return fmt.Sprintf("%s %s %s:%d:%d\n", lsp.SymbolKindName(s.Kind), name, location, s.Range.Start.Line+1, s.Range.Start.Character+1)
+}
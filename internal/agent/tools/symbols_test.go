@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func symbolAt(name, path string, line uint32) lsp.Symbol {
+	return lsp.Symbol{
+		Name:  name,
+		Kind:  protocol.Function,
+		URI:   protocol.URIFromPath(path),
+		Range: protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line + 2}},
+	}
+}
+
+func TestRankSymbols(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inside := filepath.Join(dir, "main.go")
+	outside := filepath.Join(filepath.Dir(dir), "dep", "dep.go")
+
+	symbols := []lsp.Symbol{
+		symbolAt("parseConfigFile", inside, 1),
+		symbolAt("ParseConfig", outside, 1),
+		symbolAt("mustParseConfig", inside, 5),
+		symbolAt("ParseConfig", inside, 10),
+		symbolAt("parseconfig", inside, 20),
+		symbolAt("ParseConfig", inside, 10), // duplicate from another server
+		symbolAt("pc", inside, 30),
+	}
+	ranked := rankSymbols(symbols, "ParseConfig", dir)
+
+	var got []string
+	for _, s := range ranked {
+		path, err := s.URI.Path()
+		require.NoError(t, err)
+		got = append(got, s.Name+"@"+relativePath(dir, path))
+	}
+	require.Equal(t, []string{
+		"ParseConfig@main.go",
+		"ParseConfig@" + outside,
+		"parseconfig@main.go",
+		"parseConfigFile@main.go",
+		"mustParseConfig@main.go",
+		"pc@main.go",
+	}, got)
+}
+
+func TestSymbolMatchScoreQualified(t *testing.T) {
+	t.Parallel()
+
+	s := lsp.Symbol{Name: "New", ContainerName: "config"}
+	require.Equal(t, 0, symbolMatchScore(s, "config.New"))
+	require.Equal(t, 0, symbolMatchScore(s, "New"))
+	require.Equal(t, 5, symbolMatchScore(s, "Load"))
+}
+
+func TestMergeSymbols(t *testing.T) {
+	t.Parallel()
+
+	a := symbolAt("A", "/tmp/a.go", 1)
+	b := symbolAt("B", "/tmp/a.go", 5)
+	withChildren := a
+	withChildren.Children = []lsp.Symbol{symbolAt("child", "/tmp/a.go", 2)}
+
+	merged := mergeSymbols([]lsp.Symbol{b, a}, []lsp.Symbol{withChildren})
+	require.Len(t, merged, 2)
+	require.Equal(t, "A", merged[0].Name)
+	require.Len(t, merged[0].Children, 1)
+	require.Equal(t, "B", merged[1].Name)
+}
+
+func TestFormatOutline(t *testing.T) {
+	t.Parallel()
+
+	symbols := []lsp.Symbol{{
+		Name:  "Server",
+		Kind:  protocol.Struct,
+		Range: protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 5, Character: 1}},
+		Children: []lsp.Symbol{{
+			Name:   "addr",
+			Detail: "string",
+			Kind:   protocol.Field,
+			Range:  protocol.Range{Start: protocol.Position{Line: 3, Character: 1}, End: protocol.Position{Line: 3, Character: 12}},
+		}},
+	}}
+	require.Equal(t, "struct Server [3:1-6:2]\n  field addr string [4:2-4:13]\n", formatOutline(symbols))
+}
@@ -5,16 +5,18 @@ import (
 	"context"
 	_ "embed"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"unicode/utf8"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/permission"
 )
@@ -26,12 +28,14 @@ type ViewParams struct {
 	FilePath string `json:"file_path" description:"The path to the file to read"`
 	Offset   int    `json:"offset,omitempty" description:"The line number to start reading from (0-based)"`
 	Limit    int    `json:"limit,omitempty" description:"The number of lines to read (defaults to 2000)"`
+	Pages    string `json:"pages,omitempty" description:"The pages of a PDF or presentation to read, as 3 or 3-7. Offset and limit then count the lines of these pages"`
 }
 
 type ViewPermissionsParams struct {
 	FilePath string `json:"file_path"`
 	Offset   int    `json:"offset"`
 	Limit    int    `json:"limit"`
+	Pages    string `json:"pages,omitempty"`
 }
 
 type viewTool struct {
@@ -52,7 +56,7 @@ const (
 	MaxLineLength    = 2000
 )
 
-func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, workingDir string) fantasy.AgentTool {
+func NewViewTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, tracker filetracker.Service, workingDir string) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		ViewToolName,
 		string(viewDescription),
@@ -137,17 +141,23 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 				return fantasy.NewTextErrorResponse(fmt.Sprintf("Path is a directory, not a file: %s", filePath)), nil
 			}
 
+			// Set default limit if not provided
+			if params.Limit <= 0 {
+				params.Limit = DefaultReadLimit
+			}
+
+			// The size of documents isn't checked, as they are compressed:
+			// the text extracted is limited instead.
+			if extract, ok := documentExtractors[strings.ToLower(filepath.Ext(filePath))]; ok {
+				return viewDocument(ctx, filePath, extract, params)
+			}
+
 			// Check file size
 			if fileInfo.Size() > MaxReadSize {
 				return fantasy.NewTextErrorResponse(fmt.Sprintf("File is too large (%d bytes). Maximum size is %d bytes",
 					fileInfo.Size(), MaxReadSize)), nil
 			}
 
-			// Set default limit if not provided
-			if params.Limit <= 0 {
-				params.Limit = DefaultReadLimit
-			}
-
 			isSupportedImage, mimeType := getImageMimeType(filePath)
 			if isSupportedImage {
 				if !GetSupportsImagesFromContext(ctx) {
@@ -164,16 +174,24 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 				return fantasy.NewImageResponse([]byte(encoded), mimeType), nil
 			}
 
+			if strings.EqualFold(filepath.Ext(filePath), ".ipynb") {
+				response, err := viewNotebook(ctx, filePath, params)
+				if err == nil && !response.IsError {
+					recordFileRead(ctx, tracker, filePath)
+				}
+				return response, err
+			}
+
 			// Read the file content
-			content, lineCount, err := readTextFile(filePath, params.Offset, params.Limit)
-			isValidUt8 := utf8.ValidString(content)
-			if !isValidUt8 {
-				return fantasy.NewTextErrorResponse("File content is not valid UTF-8"), nil
+			content, lineCount, format, err := readTextFile(filePath, params.Offset, params.Limit)
+			if errors.Is(err, fsext.ErrBinary) {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("File is binary, not text: %s", filePath)), nil
 			}
 			if err != nil {
 				return fantasy.ToolResponse{}, fmt.Errorf("error reading file: %w", err)
 			}
 
+			defer touchLSPs.use(ctx, filePath)()
 			notifyLSPs(ctx, lspClients, filePath)
 			output := "<file>\n"
 			// Format the output with line numbers
@@ -184,9 +202,12 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 				output += fmt.Sprintf("\n\n(File has more lines. Use 'offset' parameter to read beyond line %d)",
 					params.Offset+len(strings.Split(content, "\n")))
 			}
+			if !format.Plain() {
+				output += fmt.Sprintf("\n\n(File is encoded in %s, edits keep the encoding)", format)
+			}
 			output += "\n</file>\n"
 			output += getDiagnostics(filePath, lspClients)
-			recordFileRead(filePath)
+			recordFileRead(ctx, tracker, filePath)
 			return fantasy.WithResponseMetadata(
 				fantasy.NewTextResponse(output),
 				ViewResponseMetadata{
@@ -197,6 +218,104 @@ func NewViewTool(lspClients *csync.Map[string, *lsp.Client], permissions permiss
 		})
 }
 
+// viewNotebook renders the cells of a Jupyter notebook, with offset and limit
+// counted in cells. The first image output is attached when the model
+// supports images.
+func viewNotebook(ctx context.Context, filePath string, params ViewParams) (fantasy.ToolResponse, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fantasy.ToolResponse{}, fmt.Errorf("error reading file: %w", err)
+	}
+	nb, err := parseNotebook(data)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("%s: %s", filePath, err)), nil
+	}
+	if params.Offset > 0 && params.Offset >= len(nb.cells) {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("offset %d is out of range, the notebook has %d cells", params.Offset, len(nb.cells))), nil
+	}
+
+	output, image := renderNotebook(nb, max(params.Offset, 0), params.Limit, GetSupportsImagesFromContext(ctx))
+	response := fantasy.NewTextResponse(output)
+	if image != nil {
+		response = fantasy.NewImageResponse([]byte(image.data), image.mediaType)
+		response.Content = output
+	}
+	return fantasy.WithResponseMetadata(response, ViewResponseMetadata{
+		FilePath: filePath,
+		Content:  output,
+	}), nil
+}
+
+// viewDocument shows the text extracted from a PDF or office document, with
+// offset and limit counted in lines of the text. A page without text, as a
+// scanned one, is attached as an image when the model supports images.
+func viewDocument(ctx context.Context, filePath string, extract documentExtractor, params ViewParams) (fantasy.ToolResponse, error) {
+	pages, err := parsePageRange(params.Pages)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(err.Error()), nil
+	}
+	doc, err := extract(ctx, filePath, pages)
+	if errors.Is(err, errNoPDFToText) {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("Can't read %s: %s", filePath, err)), nil
+	}
+	if err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("Can't extract the text of %s: %s", filePath, err)), nil
+	}
+	if msg := doc.selectPages(pages); msg != "" {
+		return fantasy.NewTextErrorResponse(msg), nil
+	}
+
+	text, headers := doc.text()
+	content, lineCount, err := selectLines(text, params.Offset, params.Limit)
+	if err != nil {
+		return fantasy.ToolResponse{}, fmt.Errorf("error reading file: %w", err)
+	}
+	// The limit applies to the lines shown, so the rest of the text can be
+	// read with offset.
+	if len(content) > MaxReadSize {
+		content = content[:MaxReadSize]
+		if i := strings.LastIndexByte(content, '\n'); i > 0 {
+			content = content[:i]
+		}
+	}
+
+	output := "<file>\n"
+	output += addLineNumbers(content, params.Offset+1)
+	shown := params.Offset + len(strings.Split(content, "\n"))
+	if lineCount > shown {
+		output += fmt.Sprintf("\n\n(File has more lines. Use 'offset' parameter to read beyond line %d)", shown)
+	}
+	if doc.truncated {
+		output += fmt.Sprintf("\n\n(The text of the file is larger than %d bytes, the pages after %s %d are not shown. Use 'pages' parameter to read them)", maxDocumentPartSize, strings.ToLower(doc.pageName), doc.number(len(doc.pages)-1))
+	}
+
+	var image []byte
+	if page := doc.blankPage(headers, params.Offset, shown); page > 0 && doc.renderPage != nil {
+		switch {
+		case !GetSupportsImagesFromContext(ctx):
+			output += fmt.Sprintf("\n\n(%s %d has no text, it may be scanned. This model can't read it as an image)", doc.pageName, page)
+		default:
+			image, err = doc.renderPage(ctx, page)
+			if err != nil {
+				output += fmt.Sprintf("\n\n(%s %d has no text, it may be scanned, and can't be rendered as an image: %s)", doc.pageName, page, err)
+			} else {
+				output += fmt.Sprintf("\n\n(%s %d has no text, it may be scanned, so it is attached as an image. Use offset to view the other pages)", doc.pageName, page)
+			}
+		}
+	}
+	output += "\n</file>\n"
+
+	response := fantasy.NewTextResponse(output)
+	if image != nil {
+		response = fantasy.NewImageResponse([]byte(base64.StdEncoding.EncodeToString(image)), "image/png")
+		response.Content = output
+	}
+	return fantasy.WithResponseMetadata(response, ViewResponseMetadata{
+		FilePath: filePath,
+		Content:  content,
+	}), nil
+}
+
 func addLineNumbers(content string, startLine int) string {
 	if content == "" {
 		return ""
@@ -222,28 +341,28 @@ func addLineNumbers(content string, startLine int) string {
 	return strings.Join(result, "\n")
 }
 
-func readTextFile(filePath string, offset, limit int) (string, int, error) {
-	file, err := os.Open(filePath)
+// readTextFile reads the lines of the file from offset, up to limit, as
+// UTF-8 whatever the encoding of the file, and counts all its lines.
+func readTextFile(filePath string, offset, limit int) (string, int, fsext.TextFormat, error) {
+	text, format, err := fsext.ReadTextFile(filePath)
 	if err != nil {
-		return "", 0, err
+		return "", 0, format, err
 	}
-	defer file.Close()
+	content, lineCount, err := selectLines(text, offset, limit)
+	return content, lineCount, format, err
+}
 
+// selectLines returns the lines of the text from offset, up to limit, and
+// counts all its lines.
+func selectLines(text string, offset, limit int) (string, int, error) {
 	lineCount := 0
 
-	scanner := NewLineScanner(file)
+	scanner := NewLineScanner(strings.NewReader(text))
 	if offset > 0 {
 		for lineCount < offset && scanner.Scan() {
 			lineCount++
 		}
-		if err = scanner.Err(); err != nil {
-			return "", 0, err
-		}
-	}
-
-	if offset == 0 {
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
+		if err := scanner.Err(); err != nil {
 			return "", 0, err
 		}
 	}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp/util"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// WorkspaceFileChange is the change an LSP workspace edit makes to a single
+// file.
+type WorkspaceFileChange struct {
+	FilePath   string `json:"file_path"`
+	NewPath    string `json:"new_path,omitempty"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+	Additions  int    `json:"additions"`
+	Removals   int    `json:"removals"`
+	// created is set when the edit creates the file, and deleted when it
+	// deletes it.
+	created bool
+	deleted bool
+	mode    os.FileMode
+}
+
+// finalPath returns the path of the file after the change.
+func (c WorkspaceFileChange) finalPath() string {
+	if c.NewPath != "" {
+		return c.NewPath
+	}
+	return c.FilePath
+}
+
+// patchChange returns the change as apply_patch makes it, so workspace edits
+// are written and undone the same way.
+func (c WorkspaceFileChange) patchChange() patchChange {
+	change := patchChange{WorkspaceFileChange: c, action: patchUpdate, mode: c.mode}
+	switch {
+	case c.deleted:
+		change.action = patchDelete
+	case c.created:
+		change.action = patchAdd
+	}
+	if change.mode == 0 {
+		change.mode = 0o644
+	}
+	return change
+}
+
+// previewWorkspaceEdit returns the files the edit changes. Edits that would
+// change files outside of the working directory are refused.
+func previewWorkspaceEdit(edit protocol.WorkspaceEdit, workingDir string) ([]WorkspaceFileChange, error) {
+	changes, err := util.PreviewWorkspaceEdit(edit)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileChanges []WorkspaceFileChange
+	for _, change := range changes {
+		if change.OldContent == change.NewContent && change.NewPath == "" && !change.Created && !change.Deleted {
+			continue
+		}
+		for _, path := range []string{change.Path, change.NewPath} {
+			if path != "" && !fsext.HasPrefix(path, workingDir) {
+				return nil, fmt.Errorf("the edit would change %s, which is outside of the working directory", path)
+			}
+		}
+		_, additions, removals := diff.GenerateDiff(change.OldContent, change.NewContent, strings.TrimPrefix(change.FinalPath(), workingDir))
+		fileChange := WorkspaceFileChange{
+			FilePath:   change.Path,
+			NewPath:    change.NewPath,
+			OldContent: change.OldContent,
+			NewContent: change.NewContent,
+			Additions:  additions,
+			Removals:   removals,
+			deleted:    change.Deleted,
+			mode:       0o644,
+		}
+		if info, err := os.Stat(change.Path); err == nil {
+			fileChange.mode = info.Mode().Perm()
+		} else if change.Created {
+			fileChange.created = true
+			// A created file is only ever written at its final path.
+			fileChange.FilePath, fileChange.NewPath = change.FinalPath(), ""
+		}
+		fileChanges = append(fileChanges, fileChange)
+	}
+	return fileChanges, nil
+}
+
+// applyWorkspaceEdit writes the changes of a previewed edit, undoing the ones
+// already written if one of them fails, and records the changed files in the
+// history. It returns the paths of the changed files.
+func applyWorkspaceEdit(ctx context.Context, files history.Service, tracker filetracker.Service, sessionID string, changes []WorkspaceFileChange) ([]string, error) {
+	patchChanges := make([]patchChange, len(changes))
+	for i, change := range changes {
+		if !change.created {
+			// The edit was computed from the content of the preview.
+			data, err := os.ReadFile(change.FilePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			if string(data) != change.OldContent {
+				return nil, fmt.Errorf("%s was modified since the edit was computed", change.FilePath)
+			}
+		}
+		patchChanges[i] = change.patchChange()
+		if !change.deleted {
+			defer tracker.Writing(change.finalPath())()
+		}
+	}
+	if err := writePatchChanges(patchChanges); err != nil {
+		return nil, err
+	}
+
+	var historyChanges []history.FileChange
+	paths := make([]string, 0, len(changes))
+	for _, change := range patchChanges {
+		historyChanges = append(historyChanges, change.historyChanges()...)
+		if change.action != patchDelete {
+			recordFileWrite(ctx, tracker, change.finalPath())
+		}
+		paths = append(paths, change.finalPath())
+	}
+	if _, err := files.RecordChanges(ctx, sessionID, historyChanges); err != nil {
+		slog.Error("Error recording file history", "error", err)
+	}
+	return paths, nil
+}
+
+// formatWorkspaceChanges lists the changed files with their line counts.
+func formatWorkspaceChanges(changes []WorkspaceFileChange, workingDir string) string {
+	var output strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&output, "- %s", relativePath(workingDir, change.FilePath))
+		if change.NewPath != "" {
+			fmt.Fprintf(&output, " -> %s", relativePath(workingDir, change.NewPath))
+		}
+		fmt.Fprintf(&output, " (+%d -%d)\n", change.Additions, change.Removals)
+	}
+	return output.String()
+}
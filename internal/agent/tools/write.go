@@ -4,16 +4,15 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/filepathext"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
 
@@ -46,11 +45,13 @@ type WriteResponseMetadata struct {
 	Diff      string `json:"diff"`
 	Additions int    `json:"additions"`
 	Removals  int    `json:"removals"`
+
+	Diagnostics *DiagnosticsDelta `json:"diagnostics,omitempty"`
 }
 
 const WriteToolName = "write"
 
-func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permission.Service, files history.Service, workingDir string) fantasy.AgentTool {
+func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], touchLSPs LSPToucher, permissions permission.Service, files history.Service, tracker filetracker.Service, formatter *Formatter, workingDir string) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		WriteToolName,
 		string(writeDescription),
@@ -65,21 +66,50 @@ func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permis
 
 			filePath := filepathext.SmartJoin(workingDir, params.FilePath)
 
+			newContent := params.Content
+			oldContent := ""
+			var (
+				format    fsext.TextFormat
+				isCrlf    bool
+				mergeNote string
+			)
 			fileInfo, err := os.Stat(filePath)
 			if err == nil {
 				if fileInfo.IsDir() {
 					return fantasy.NewTextErrorResponse(fmt.Sprintf("Path is a directory, not a file: %s", filePath)), nil
 				}
 
-				modTime := fileInfo.ModTime()
-				lastRead := getLastReadTime(filePath)
-				if modTime.After(lastRead) {
-					return fantasy.NewTextErrorResponse(fmt.Sprintf("File %s has been modified since it was last read.\nLast modification: %s\nLast read: %s\n\nPlease read the file again before modifying it.",
-						filePath, modTime.Format(time.RFC3339), lastRead.Format(time.RFC3339))), nil
+				base, stale, readErr, err := staleBase(ctx, tracker, filePath)
+				if err != nil {
+					return fantasy.ToolResponse{}, err
+				}
+				if readErr != "" {
+					return fantasy.NewTextErrorResponse(readErr), nil
 				}
 
-				oldContent, readErr := os.ReadFile(filePath)
-				if readErr == nil && string(oldContent) == params.Content {
+				oldContent, format, readErr, err = readText(filePath)
+				if err != nil {
+					return fantasy.ToolResponse{}, err
+				}
+				if readErr != "" {
+					return fantasy.NewTextErrorResponse(readErr), nil
+				}
+				// Compare with LF line endings, the ones of the file are
+				// restored when writing it.
+				oldContent, isCrlf = fsext.ToUnixLineEndings(oldContent)
+				if isCrlf {
+					newContent, _ = fsext.ToUnixLineEndings(newContent)
+				}
+				if stale {
+					// Merge the content with the changes made on disk since
+					// the file was read.
+					var conflict *mergeConflict
+					newContent, mergeNote, conflict = mergeDiskChanges(filePath, base, newContent, oldContent)
+					if conflict != nil {
+						return conflict.response(conflict.Error()), nil
+					}
+				}
+				if oldContent == newContent {
 					return fantasy.NewTextErrorResponse(fmt.Sprintf("File %s already contains the exact content. No changes made.", filePath)), nil
 				}
 			} else if !os.IsNotExist(err) {
@@ -91,25 +121,20 @@ func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permis
 				return fantasy.ToolResponse{}, fmt.Errorf("error creating directory: %w", err)
 			}
 
-			oldContent := ""
-			if fileInfo != nil && !fileInfo.IsDir() {
-				oldBytes, readErr := os.ReadFile(filePath)
-				if readErr == nil {
-					oldContent = string(oldBytes)
-				}
-			}
-
 			sessionID := GetSessionFromContext(ctx)
 			if sessionID == "" {
 				return fantasy.ToolResponse{}, fmt.Errorf("session_id is required")
 			}
 
-			diff, additions, removals := diff.GenerateDiff(
+			patch, additions, removals := diff.GenerateDiff(
 				oldContent,
-				params.Content,
+				newContent,
 				strings.TrimPrefix(filePath, workingDir),
 			)
 
+			defer touchLSPs.use(ctx, filePath)()
+			snapshot := snapshotDiagnostics(ctx, lspClients, filePath)
+
 			p := permissions.Request(
 				permission.CreatePermissionRequest{
 					SessionID:   sessionID,
@@ -121,7 +146,7 @@ func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permis
 					Params: WritePermissionsParams{
 						FilePath:   filePath,
 						OldContent: oldContent,
-						NewContent: params.Content,
+						NewContent: newContent,
 					},
 				},
 			)
@@ -129,46 +154,35 @@ func NewWriteTool(lspClients *csync.Map[string, *lsp.Client], permissions permis
 				return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
 			}
 
-			err = os.WriteFile(filePath, []byte(params.Content), 0o644)
-			if err != nil {
-				return fantasy.ToolResponse{}, fmt.Errorf("error writing file: %w", err)
-			}
-
-			// Check if file exists in history
-			file, err := files.GetByPathAndSession(ctx, filePath, sessionID)
+			defer tracker.Writing(filePath)()
+			writeErr, err := writeText(filePath, withLineEndings(newContent, isCrlf), format)
 			if err != nil {
-				_, err = files.Create(ctx, sessionID, filePath, oldContent)
-				if err != nil {
-					// Log error but don't fail the operation
-					return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
-				}
+				return fantasy.ToolResponse{}, err
 			}
-			if file.Content != oldContent {
-				// User Manually changed the content store an intermediate version
-				_, err = files.CreateVersion(ctx, sessionID, filePath, oldContent)
-				if err != nil {
-					slog.Error("Error creating file history version", "error", err)
-				}
+			if writeErr != "" {
+				return fantasy.NewTextErrorResponse(writeErr), nil
 			}
-			// Store the new version
-			_, err = files.CreateVersion(ctx, sessionID, filePath, params.Content)
-			if err != nil {
-				slog.Error("Error creating file history version", "error", err)
+
+			content, note := formatter.formatChange(ctx, filePath, oldContent, newContent, format, isCrlf, &patch, &additions, &removals)
+
+			if err := updateFileHistory(ctx, files, sessionID, filePath, oldContent, content); err != nil {
+				return fantasy.ToolResponse{}, err
 			}
 
-			recordFileWrite(filePath)
-			recordFileRead(filePath)
+			recordFileWrite(ctx, tracker, filePath)
 
 			notifyLSPs(ctx, lspClients, params.FilePath)
 
-			result := fmt.Sprintf("File successfully written: %s", filePath)
+			result := fmt.Sprintf("File successfully written: %s%s%s", filePath, mergeNote, note)
 			result = fmt.Sprintf("<result>\n%s\n</result>", result)
-			result += getDiagnostics(filePath, lspClients)
+			diagnostics, delta := snapshot.compare(lspClients)
+			result += diagnostics
 			return fantasy.WithResponseMetadata(fantasy.NewTextResponse(result),
 				WriteResponseMetadata{
-					Diff:      diff,
-					Additions: additions,
-					Removals:  removals,
+					Diff:        patch,
+					Additions:   additions,
+					Removals:    removals,
+					Diagnostics: delta,
 				},
 			), nil
 		})
@@ -22,6 +22,7 @@ import (
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/format"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/log"
@@ -35,6 +36,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/update"
 	"github.com/charmbracelet/crush/internal/version"
+	"github.com/charmbracelet/crush/internal/watcher"
 	"github.com/charmbracelet/x/ansi"
 	"github.com/charmbracelet/x/exp/charmtone"
 	"github.com/charmbracelet/x/term"
@@ -44,11 +46,17 @@ type App struct {
 	Sessions    session.Service
 	Messages    message.Service
 	History     history.Service
+	FileTracker filetracker.Service
 	Permissions permission.Service
+	// PermissionLog is the persisted audit trail of permission decisions.
+	PermissionLog permission.Log
+	// Watcher publishes the changes to the files of the workspace.
+	Watcher watcher.Service
 
 	AgentCoordinator agent.Coordinator
 
 	LSPClients *csync.Map[string, *lsp.Client]
+	lspServers *csync.Map[string, *lspServer]
 
 	config *config.Config
 
@@ -73,13 +81,23 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
 		allowedTools = cfg.Permissions.AllowedTools
 	}
+	var permissionRules []permission.Rule
+	if cfg.Permissions != nil {
+		permissionRules = cfg.Permissions.Rules
+	}
+	permissionLog := permission.NewLog(q)
+	workspace := watcher.NewService(cfg.WorkingDir())
 
 	app := &App{
-		Sessions:    sessions,
-		Messages:    messages,
-		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
-		LSPClients:  csync.NewMap[string, *lsp.Client](),
+		Sessions:      sessions,
+		Messages:      messages,
+		History:       files,
+		FileTracker:   filetracker.NewService(q, workspace),
+		Permissions:   permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, permissionRules, permissionLog),
+		PermissionLog: permissionLog,
+		Watcher:       workspace,
+		LSPClients:    csync.NewMap[string, *lsp.Client](),
+		lspServers:    csync.NewMap[string, *lspServer](),
 
 		globalCtx: ctx,
 
@@ -293,6 +311,20 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", mcp.SubscribeEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "jobs", shell.SubscribeBackgroundJobs, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "filetracker", app.FileTracker.Subscribe, app.events)
+	app.watchBackgroundJobs(ctx)
+	app.serviceEventsWG.Go(func() {
+		if err := app.FileTracker.Watch(ctx); err != nil {
+			slog.Error("Failed to watch files", "error", err)
+		}
+	})
+	app.serviceEventsWG.Go(func() {
+		if err := app.Watcher.Watch(ctx); err != nil {
+			slog.Error("Failed to watch workspace", "error", err)
+		}
+	})
+	app.forwardWatchedFiles(ctx)
 	cleanupFunc := func() error {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -347,7 +379,9 @@ func (app *App) InitCoderAgent(ctx context.Context) error {
 		app.Messages,
 		app.Permissions,
 		app.History,
+		app.FileTracker,
 		app.LSPClients,
+		app.touchLSPs,
 	)
 	if err != nil {
 		slog.Error("Failed to create coder agent", "err", err)
@@ -405,13 +439,11 @@ func (app *App) Shutdown() {
 	})
 
 	// Shutdown all LSP clients.
-	for name, client := range app.LSPClients.Seq2() {
+	for server := range app.lspServers.Seq() {
 		wg.Go(func() {
 			shutdownCtx, cancel := context.WithTimeout(app.globalCtx, 5*time.Second)
 			defer cancel()
-			if err := client.Close(shutdownCtx); err != nil {
-				slog.Error("Failed to shutdown LSP client", "name", name, "error", err)
-			}
+			app.stopLSP(shutdownCtx, server, lsp.StateStopped)
 		})
 	}
 
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+// watchBackgroundJobs tells the agent about background jobs it started once
+// they finish, so it doesn't have to poll them.
+func (app *App) watchBackgroundJobs(ctx context.Context) {
+	app.serviceEventsWG.Go(func() {
+		for event := range shell.SubscribeBackgroundJobs(ctx) {
+			job := event.Payload
+			if event.Type != pubsub.UpdatedEvent || job.SessionID == "" || job.Killed {
+				continue
+			}
+			app.notifyJobFinished(job)
+		}
+	})
+}
+
+// notifyJobFinished saves a notice in the session, with the next step of the
+// running turn if the agent is working on it, or right away otherwise.
+func (app *App) notifyJobFinished(job shell.BackgroundJob) {
+	if app.AgentCoordinator == nil {
+		return
+	}
+	app.AgentCoordinator.QueueNotice(job.SessionID, jobFinishedMessage(job))
+}
+
+func jobFinishedMessage(job shell.BackgroundJob) string {
+	status := fmt.Sprintf("finished with exit code %d", job.ExitCode)
+	if job.ExitCode == 0 {
+		status = "finished successfully"
+	}
+	runtime := job.CompletedAt.Sub(job.StartedAt).Round(time.Second)
+	return fmt.Sprintf(
+		"Background job %s (`%s`) %s after %s. Use the job_output tool with shell_id %s to read its output.",
+		job.ID, job.Command, status, runtime, job.ID,
+	)
+}
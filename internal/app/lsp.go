@@ -2,13 +2,52 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/watcher"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
 )
 
+const (
+	// lspMaxCrashRestarts is how many times in a row a crashing server is
+	// restarted before it's left in the error state.
+	lspMaxCrashRestarts = 5
+	// lspStableAfter is how long a server has to run before a crash no
+	// longer counts towards the restarts in a row.
+	lspStableAfter = time.Minute
+	lspMinBackoff  = time.Second
+	lspMaxBackoff  = 30 * time.Second
+)
+
+// lspServer tracks the lifecycle of the server of one LSP configuration.
+type lspServer struct {
+	name   string
+	config config.LSPConfig
+
+	mu sync.Mutex
+	// client is the running client, nil while the server is stopped.
+	client *lsp.Client
+	// starting is closed when the start in progress, if any, is done.
+	starting chan struct{}
+	// cancel stops watching the running client, or the pending restart of
+	// a crashed one.
+	cancel context.CancelFunc
+	// startOnUse is set while the server is idle, so it starts as soon as a
+	// file it handles is used.
+	startOnUse bool
+	// inUse counts the tool calls using the server, which isn't shut down
+	// for being idle while they're in flight.
+	inUse     int
+	lastUsed  time.Time
+	startedAt time.Time
+	crashes   int
+}
+
 // initLSPClients initializes LSP clients.
 func (app *App) initLSPClients(ctx context.Context) {
 	for name, clientConfig := range app.config.LSP {
@@ -16,31 +55,301 @@ func (app *App) initLSPClients(ctx context.Context) {
 			slog.Info("Skipping disabled LSP client", "name", name)
 			continue
 		}
-		go app.createAndStartLSPClient(ctx, name, clientConfig)
+		// Check if any root markers exist in the working directory (config now has defaults)
+		if !lsp.HasRootMarkers(app.config.WorkingDir(), clientConfig.RootMarkers) {
+			slog.Debug("Skipping LSP client: no root markers found", "name", name, "rootMarkers", clientConfig.RootMarkers)
+			updateLSPState(name, lsp.StateDisabled, nil, nil, 0)
+			continue
+		}
+		server := &lspServer{name: name, config: clientConfig}
+		app.lspServers.Set(name, server)
+		if clientConfig.Lazy {
+			slog.Debug("Deferring LSP client start until a file it handles is used", "name", name)
+			server.startOnUse = true
+			updateLSPState(name, lsp.StateIdle, nil, nil, 0)
+			continue
+		}
+		go func() {
+			if err := app.startLSP(ctx, server); err != nil {
+				slog.Error("Failed to start LSP client", "name", name, "error", err)
+			}
+		}()
 	}
 	slog.Info("LSP clients initialization started in background")
 }
 
-// createAndStartLSPClient creates a new LSP client, initializes it, and starts its workspace watcher
-func (app *App) createAndStartLSPClient(ctx context.Context, name string, config config.LSPConfig) {
-	slog.Debug("Creating LSP client", "name", name, "command", config.Command, "fileTypes", config.FileTypes, "args", config.Args)
+// StartLSP starts the LSP server with the given name if it isn't running.
+func (app *App) StartLSP(ctx context.Context, name string) error {
+	server, err := app.lspServer(name)
+	if err != nil {
+		return err
+	}
+	return app.startLSP(ctx, server)
+}
+
+// StopLSP stops the LSP server with the given name. It stays stopped until
+// it's started again.
+func (app *App) StopLSP(ctx context.Context, name string) error {
+	server, err := app.lspServer(name)
+	if err != nil {
+		return err
+	}
+	app.stopLSP(ctx, server, lsp.StateStopped)
+	return nil
+}
+
+// RestartLSP stops the LSP server with the given name, if it's running, and
+// starts it again.
+func (app *App) RestartLSP(ctx context.Context, name string) error {
+	server, err := app.lspServer(name)
+	if err != nil {
+		return err
+	}
+	app.stopLSP(ctx, server, lsp.StateStopped)
+	server.mu.Lock()
+	server.crashes = 0
+	server.mu.Unlock()
+	return app.startLSP(ctx, server)
+}
+
+func (app *App) lspServer(name string) (*lspServer, error) {
+	server, ok := app.lspServers.Get(name)
+	if !ok {
+		if cfg, exists := app.config.LSP[name]; exists && !cfg.Disabled {
+			return nil, fmt.Errorf("LSP server %q is not used in this project", name)
+		}
+		return nil, fmt.Errorf("LSP server %q is not configured or is disabled", name)
+	}
+	return server, nil
+}
+
+// touchLSPs records that the tools use the files, starting the idle servers
+// handling them. Without files, all running servers are marked as used. The
+// servers are kept in use until the returned function is called.
+func (app *App) touchLSPs(ctx context.Context, paths ...string) (done func()) {
+	var used []*lspServer
+	for server := range app.lspServers.Seq() {
+		if len(paths) > 0 && !server.handlesAny(paths) {
+			continue
+		}
+		server.mu.Lock()
+		server.inUse++
+		server.lastUsed = time.Now()
+		start := len(paths) > 0 && server.startOnUse && server.client == nil
+		server.mu.Unlock()
+		used = append(used, server)
+		if !start {
+			continue
+		}
+		if err := app.startLSP(ctx, server); err != nil {
+			slog.Error("Failed to start LSP client on use", "name", server.name, "error", err)
+		}
+	}
+	return sync.OnceFunc(func() {
+		for _, server := range used {
+			server.mu.Lock()
+			server.inUse--
+			server.lastUsed = time.Now()
+			server.mu.Unlock()
+		}
+	})
+}
+
+// idle reports whether no tool call uses the server and none did for the
+// timeout.
+func (s *lspServer) idle(timeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inUse == 0 && time.Since(s.lastUsed) >= timeout
+}
+
+func (s *lspServer) handlesAny(paths []string) bool {
+	if len(s.config.FileTypes) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		if lsp.MatchesFileTypes(s.config.FileTypes, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// startLSP starts the server and waits until it's ready, or until the start
+// already in progress is done.
+func (app *App) startLSP(ctx context.Context, server *lspServer) error {
+	server.mu.Lock()
+	if server.client != nil {
+		server.mu.Unlock()
+		return nil
+	}
+	if starting := server.starting; starting != nil {
+		server.mu.Unlock()
+		select {
+		case <-starting:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if server.cancel != nil {
+		// Starting it now replaces the pending restart after a crash.
+		server.cancel()
+		server.cancel = nil
+	}
+	starting := make(chan struct{})
+	server.starting = starting
+	server.startOnUse = false
+	server.mu.Unlock()
+
+	// The server outlives the request that started it.
+	client, err := app.createAndStartLSPClient(app.globalCtx, server.name, server.config)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.starting = nil
+	close(starting)
+	if err != nil {
+		return err
+	}
+	watchCtx, cancel := context.WithCancel(app.globalCtx)
+	server.client = client
+	server.cancel = cancel
+	server.startedAt = time.Now()
+	server.lastUsed = server.startedAt
+	app.LSPClients.Set(server.name, client)
+	go app.watchLSP(watchCtx, server, client)
+	return nil
+}
+
+// stopLSP shuts the server down, and cancels its pending restart if it
+// crashed, leaving it in the given state.
+func (app *App) stopLSP(ctx context.Context, server *lspServer, state lsp.ServerState) {
+	server.mu.Lock()
+	if starting := server.starting; starting != nil {
+		server.mu.Unlock()
+		select {
+		case <-starting:
+		case <-ctx.Done():
+			return
+		}
+		server.mu.Lock()
+	}
+	client := server.client
+	if server.cancel != nil {
+		server.cancel()
+	}
+	server.client = nil
+	server.cancel = nil
+	server.startOnUse = state == lsp.StateIdle
+	server.mu.Unlock()
+
+	if client != nil {
+		app.LSPClients.Del(server.name)
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := client.Close(closeCtx); err != nil {
+			slog.Warn("Failed to stop LSP client", "name", server.name, "error", err)
+		}
+	}
+	updateLSPState(server.name, state, nil, nil, 0)
+}
+
+// watchLSP restarts the server when it crashes and shuts it down when it's
+// idle for longer than its idle timeout, until ctx is cancelled.
+func (app *App) watchLSP(ctx context.Context, server *lspServer, client *lsp.Client) {
+	var idleCheck <-chan time.Time
+	idleTimeout := time.Duration(server.config.IdleTimeout) * time.Second
+	if idleTimeout > 0 {
+		ticker := time.NewTicker(min(max(idleTimeout/4, time.Second), 30*time.Second))
+		defer ticker.Stop()
+		idleCheck = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-idleCheck:
+			if server.idle(idleTimeout) {
+				slog.Info("Stopping idle LSP client", "name", server.name, "idleTimeout", idleTimeout)
+				go app.stopLSP(app.globalCtx, server, lsp.StateIdle)
+				return
+			}
+		case <-client.Disconnected():
+			if ctx.Err() != nil {
+				return
+			}
+			app.restartCrashedLSP(ctx, server, client)
+			return
+		}
+	}
+}
+
+// restartCrashedLSP restarts a server that exited on its own, waiting longer
+// each time it crashes again shortly after starting.
+func (app *App) restartCrashedLSP(ctx context.Context, server *lspServer, client *lsp.Client) {
+	server.mu.Lock()
+	if server.client != client {
+		server.mu.Unlock()
+		return
+	}
+	server.client = nil
+	if time.Since(server.startedAt) >= lspStableAfter {
+		server.crashes = 0
+	}
+	server.crashes++
+	crashes := server.crashes
+	server.mu.Unlock()
+	app.LSPClients.Del(server.name)
 
-	// Check if any root markers exist in the working directory (config now has defaults)
-	if !lsp.HasRootMarkers(app.config.WorkingDir(), config.RootMarkers) {
-		slog.Debug("Skipping LSP client: no root markers found", "name", name, "rootMarkers", config.RootMarkers)
-		updateLSPState(name, lsp.StateDisabled, nil, nil, 0)
+	if crashes > lspMaxCrashRestarts {
+		err := fmt.Errorf("server crashed %d times in a row, restart it manually", crashes)
+		slog.Error("LSP server keeps crashing, giving up", "name", server.name, "crashes", crashes)
+		updateLSPState(server.name, lsp.StateError, err, nil, 0)
 		return
 	}
 
+	backoff := lspBackoff(crashes)
+	slog.Warn("LSP server exited unexpectedly, restarting", "name", server.name, "backoff", backoff, "attempt", crashes)
+	updateLSPState(server.name, lsp.StateError, fmt.Errorf("server exited unexpectedly, restarting in %s", backoff), nil, 0)
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(backoff):
+	}
+	if err := app.startLSP(app.globalCtx, server); err != nil {
+		slog.Error("Failed to restart LSP client", "name", server.name, "error", err)
+	}
+}
+
+// lspBackoff returns how long to wait before restarting a server that
+// crashed the given number of times in a row.
+func lspBackoff(crashes int) time.Duration {
+	backoff := lspMinBackoff
+	for range crashes - 1 {
+		backoff *= 2
+		if backoff >= lspMaxBackoff {
+			return lspMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// createAndStartLSPClient creates a new LSP client and initializes it.
+func (app *App) createAndStartLSPClient(ctx context.Context, name string, config config.LSPConfig) (*lsp.Client, error) {
+	slog.Debug("Creating LSP client", "name", name, "command", config.Command, "fileTypes", config.FileTypes, "args", config.Args)
+
 	// Update state to starting
 	updateLSPState(name, lsp.StateStarting, nil, nil, 0)
 
 	// Create LSP client.
-	lspClient, err := lsp.New(ctx, name, config, app.config.Resolver())
+	lspClient, err := lsp.New(ctx, name, app.config.WorkingDir(), config, app.config.Resolver())
 	if err != nil {
 		slog.Error("Failed to create LSP client for", name, err)
 		updateLSPState(name, lsp.StateError, err, nil, 0)
-		return
+		return nil, err
 	}
 
 	// Set diagnostics callback
@@ -56,7 +365,7 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, config
 		slog.Error("LSP client initialization failed", "name", name, "error", err)
 		updateLSPState(name, lsp.StateError, err, lspClient, 0)
 		lspClient.Close(ctx)
-		return
+		return nil, err
 	}
 
 	// Wait for the server to be ready.
@@ -74,7 +383,37 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, config
 	}
 
 	slog.Info("LSP client initialized", "name", name)
+	return lspClient, nil
+}
 
-	// Add to map with mutex protection before starting goroutine
-	app.LSPClients.Set(name, lspClient)
+// forwardWatchedFiles sends the changes to the files of the workspace to the
+// LSP servers watching them.
+func (app *App) forwardWatchedFiles(ctx context.Context) {
+	app.serviceEventsWG.Go(func() {
+		for event := range app.Watcher.Subscribe(ctx) {
+			changes := make([]protocol.FileEvent, len(event.Payload.Changes))
+			for i, change := range event.Payload.Changes {
+				changes[i] = protocol.FileEvent{
+					URI:  protocol.URIFromPath(change.Path),
+					Type: fileChangeType(change.Op),
+				}
+			}
+			for name, client := range app.LSPClients.Seq2() {
+				if err := client.NotifyWatchedFiles(ctx, changes); err != nil {
+					slog.Warn("Failed to notify LSP of watched file changes", "name", name, "error", err)
+				}
+			}
+		}
+	})
+}
+
+func fileChangeType(op watcher.Op) protocol.FileChangeType {
+	switch op {
+	case watcher.Created:
+		return protocol.Created
+	case watcher.Deleted:
+		return protocol.Deleted
+	default:
+		return protocol.Changed
+	}
 }
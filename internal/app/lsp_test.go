@@ -0,0 +1,60 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLSPBackoff(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, time.Second, lspBackoff(1))
+	require.Equal(t, 2*time.Second, lspBackoff(2))
+	require.Equal(t, 8*time.Second, lspBackoff(4))
+	require.Equal(t, lspMaxBackoff, lspBackoff(6))
+	require.Equal(t, lspMaxBackoff, lspBackoff(50))
+}
+
+func TestLSPServerHandlesAny(t *testing.T) {
+	t.Parallel()
+
+	gopls := &lspServer{name: "gopls", config: config.LSPConfig{FileTypes: []string{"go", ".mod"}}}
+	require.True(t, gopls.handlesAny([]string{"README.md", "/src/main.go"}))
+	require.True(t, gopls.handlesAny([]string{"go.mod"}))
+	require.False(t, gopls.handlesAny([]string{"main.ts", "Makefile"}))
+
+	anything := &lspServer{name: "any"}
+	require.True(t, anything.handlesAny([]string{"notes.txt"}))
+}
+
+func TestTouchLSPsHoldsServersInUse(t *testing.T) {
+	t.Parallel()
+
+	gopls := &lspServer{name: "gopls", config: config.LSPConfig{FileTypes: []string{"go"}}}
+	tsserver := &lspServer{name: "tsserver", config: config.LSPConfig{FileTypes: []string{"ts"}}}
+	app := &App{lspServers: csync.NewMap[string, *lspServer]()}
+	app.lspServers.Set(gopls.name, gopls)
+	app.lspServers.Set(tsserver.name, tsserver)
+
+	done := app.touchLSPs(t.Context(), "main.go")
+	require.Equal(t, 1, gopls.inUse)
+	require.Zero(t, tsserver.inUse)
+	require.False(t, gopls.idle(0))
+	require.True(t, tsserver.idle(0))
+
+	all := app.touchLSPs(t.Context())
+	require.Equal(t, 2, gopls.inUse)
+	require.Equal(t, 1, tsserver.inUse)
+
+	done()
+	done()
+	all()
+	require.Zero(t, gopls.inUse)
+	require.Zero(t, tsserver.inUse)
+	require.True(t, gopls.idle(0))
+	require.False(t, gopls.idle(time.Hour))
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+const defaultPermissionLogLimit = 100
+
+var permissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "Inspect tool permissions",
+	Long:  "Inspect the permission decisions Crush made for tool calls in this project",
+}
+
+var permissionsLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the permission audit log",
+	Long:  "Show every permission request made by tools and how it was resolved, whether by a rule, the allowlist, or the user",
+	Example: `
+# Show the most recent permission decisions
+crush permissions log
+
+# Show every decision of a session
+crush permissions log --session <session-id>
+
+# Output the log as JSON
+crush permissions log --json
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := cmd.Flags().GetString("cwd")
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %v", err)
+		}
+		dataDir, err := cmd.Flags().GetString("data-dir")
+		if err != nil {
+			return fmt.Errorf("failed to get data directory: %v", err)
+		}
+		sessionID, _ := cmd.Flags().GetString("session")
+		limit, _ := cmd.Flags().GetInt("limit")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		if cwd == "" {
+			cwd, err = os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+		}
+
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+
+		conn, err := db.Connect(cmd.Context(), cfg.Options.DataDirectory)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		log := permission.NewLog(db.New(conn))
+		var entries []permission.LogEntry
+		if sessionID != "" {
+			entries, err = log.ListBySession(cmd.Context(), sessionID)
+		} else {
+			entries, err = log.List(cmd.Context(), limit)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read permission log: %v", err)
+		}
+
+		if jsonOutput {
+			output := struct {
+				Entries []permission.LogEntry `json:"entries"`
+			}{Entries: entries}
+
+			data, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(entries) == 0 {
+			cmd.Println("No permission decisions recorded yet.")
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("Time", "Session", "Tool", "Action", "Path", "Decision")
+
+			for _, e := range entries {
+				t.Row(
+					time.UnixMilli(e.RequestedAt).Local().Format("2006-01-02 15:04:05"),
+					shortID(e.SessionID),
+					e.ToolName,
+					e.Action,
+					e.Path,
+					string(e.Decision),
+				)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, e := range entries {
+			cmd.Printf(
+				"%s\t%s\t%s\t%s\t%s\t%s\n",
+				time.UnixMilli(e.RequestedAt).Format(time.RFC3339),
+				e.SessionID,
+				e.ToolName,
+				e.Action,
+				e.Path,
+				e.Decision,
+			)
+		}
+		return nil
+	},
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func init() {
+	permissionsLogCmd.Flags().StringP("session", "s", "", "Only show decisions of the given session")
+	permissionsLogCmd.Flags().IntP("limit", "n", defaultPermissionLogLimit, "Maximum number of entries to show")
+	permissionsLogCmd.Flags().Bool("json", false, "Output as JSON")
+	permissionsCmd.AddCommand(permissionsLogCmd)
+}
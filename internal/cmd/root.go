@@ -44,6 +44,7 @@ func init() {
 		projectsCmd,
 		updateProvidersCmd,
 		logsCmd,
+		permissionsCmd,
 		schemaCmd,
 		loginCmd,
 	)
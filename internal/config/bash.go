@@ -0,0 +1,138 @@
+package config
+
+import "slices"
+
+// defaultBannedCommands are the commands the bash tool refuses to run,
+// matched against the command name.
+var defaultBannedCommands = []string{
+	// Network/Download tools
+	"alias",
+	"aria2c",
+	"axel",
+	"chrome",
+	"curl",
+	"curlie",
+	"firefox",
+	"http-prompt",
+	"httpie",
+	"links",
+	"lynx",
+	"nc",
+	"safari",
+	"scp",
+	"ssh",
+	"telnet",
+	"w3m",
+	"wget",
+	"xh",
+
+	// System administration
+	"doas",
+	"su",
+	"sudo",
+
+	// Package managers
+	"apk",
+	"apt",
+	"apt-cache",
+	"apt-get",
+	"dnf",
+	"dpkg",
+	"emerge",
+	"home-manager",
+	"makepkg",
+	"opkg",
+	"pacman",
+	"paru",
+	"pkg",
+	"pkg_add",
+	"pkg_delete",
+	"portage",
+	"rpm",
+	"yay",
+	"yum",
+	"zypper",
+
+	// System modification
+	"at",
+	"batch",
+	"chkconfig",
+	"crontab",
+	"fdisk",
+	"mkfs",
+	"mount",
+	"parted",
+	"service",
+	"systemctl",
+	"umount",
+
+	// Network configuration
+	"firewall-cmd",
+	"ifconfig",
+	"ip",
+	"iptables",
+	"netstat",
+	"pfctl",
+	"route",
+	"ufw",
+}
+
+// defaultBlockedArguments are the argument patterns the bash tool refuses to
+// run. See [shell.PatternBlocker] for the pattern syntax.
+var defaultBlockedArguments = []string{
+	// System package managers
+	"apk add",
+	"apt install",
+	"apt-get install",
+	"dnf install",
+	"pacman -S",
+	"pkg install",
+	"yum install",
+	"zypper install",
+
+	// Language-specific package managers
+	"brew install",
+	"cargo install",
+	"gem install",
+	"go install",
+	"npm install --global",
+	"npm install -g",
+	"pip install --user",
+	"pip3 install --user",
+	"pnpm add --global",
+	"pnpm add -g",
+	"yarn global add",
+
+	// `go test -exec` can run arbitrary commands
+	"go test -exec",
+}
+
+// ListOverrides changes a built-in list: entries in Extend are added to it
+// and entries in Remove are taken out of it.
+type ListOverrides struct {
+	Extend []string `json:"extend,omitempty" jsonschema:"description=Entries to add to the built-in list"`
+	Remove []string `json:"remove,omitempty" jsonschema:"description=Entries to remove from the built-in list"`
+}
+
+func (l ListOverrides) apply(defaults []string) []string {
+	result := make([]string, 0, len(defaults)+len(l.Extend))
+	for _, item := range slices.Concat(defaults, l.Extend) {
+		if item == "" || slices.Contains(l.Remove, item) || slices.Contains(result, item) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// EffectiveBannedCommands returns the built-in banned commands with the
+// configured overrides applied.
+func (t ToolBash) EffectiveBannedCommands() []string {
+	return t.BannedCommands.apply(defaultBannedCommands)
+}
+
+// EffectiveBlockedArguments returns the built-in blocked argument patterns
+// with the configured overrides applied.
+func (t ToolBash) EffectiveBlockedArguments() []string {
+	return t.BlockedArguments.apply(defaultBlockedArguments)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolBash_EffectiveLists(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		var bash ToolBash
+		require.Equal(t, defaultBannedCommands, bash.EffectiveBannedCommands())
+		require.Equal(t, defaultBlockedArguments, bash.EffectiveBlockedArguments())
+	})
+
+	t.Run("extend and remove", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := LoadReader(strings.NewReader(`{
+			"tools": {
+				"bash": {
+					"banned_commands": {"extend": ["kubectl", "curl"], "remove": ["curl", "ssh"]},
+					"blocked_arguments": {"extend": ["git push --force*"], "remove": ["go install"]}
+				}
+			}
+		}`))
+		require.NoError(t, err)
+
+		banned := cfg.Tools.Bash.EffectiveBannedCommands()
+		require.Contains(t, banned, "kubectl")
+		require.Contains(t, banned, "wget")
+		require.NotContains(t, banned, "curl")
+		require.NotContains(t, banned, "ssh")
+
+		blocked := cfg.Tools.Bash.EffectiveBlockedArguments()
+		require.Contains(t, blocked, "git push --force*")
+		require.Contains(t, blocked, "go test -exec")
+		require.NotContains(t, blocked, "go install")
+	})
+}
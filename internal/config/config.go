@@ -18,10 +18,13 @@ import (
 	hyperp "github.com/charmbracelet/crush/internal/agent/hyper"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/env"
+	"github.com/charmbracelet/crush/internal/home"
 	"github.com/charmbracelet/crush/internal/oauth"
 	"github.com/charmbracelet/crush/internal/oauth/claude"
 	"github.com/charmbracelet/crush/internal/oauth/copilot"
 	"github.com/charmbracelet/crush/internal/oauth/hyper"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/invopop/jsonschema"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -205,6 +208,17 @@ type LSPConfig struct {
 	RootMarkers []string          `json:"root_markers,omitempty" jsonschema:"description=Files or directories that indicate the project root,example=go.mod,example=package.json,example=Cargo.toml"`
 	InitOptions map[string]any    `json:"init_options,omitempty" jsonschema:"description=Initialization options passed to the LSP server during initialize request"`
 	Options     map[string]any    `json:"options,omitempty" jsonschema:"description=LSP server-specific settings passed during initialization"`
+	Lazy        bool              `json:"lazy,omitempty" jsonschema:"description=Start the LSP server only when a file it handles is first used,default=false"`
+	IdleTimeout int               `json:"idle_timeout,omitempty" jsonschema:"description=Seconds without use after which the LSP server is shut down until a file it handles is used again. 0 keeps it running,default=0,example=600"`
+}
+
+type FormatterConfig struct {
+	Disabled  bool     `json:"disabled,omitempty" jsonschema:"description=Whether this formatter is disabled,default=false"`
+	FileTypes []string `json:"filetypes" jsonschema:"required,description=File types this formatter handles,example=go,example=ts,example=tsx"`
+	LSP       bool     `json:"lsp,omitempty" jsonschema:"description=Format with the LSP servers handling the file instead of a command,default=false"`
+	Command   string   `json:"command,omitempty" jsonschema:"description=Command reading the file on stdin and writing it formatted to stdout,example=gofmt,example=prettier"`
+	Args      []string `json:"args,omitempty" jsonschema:"description=Arguments to pass to the command. {file} is replaced with the path of the file,example=--stdin-filepath,example={file}"`
+	Timeout   int      `json:"timeout,omitempty" jsonschema:"description=Timeout in seconds for formatting a file,default=10,example=30"`
 }
 
 type TUIOptions struct {
@@ -227,8 +241,9 @@ func (c Completions) Limits() (depth, items int) {
 }
 
 type Permissions struct {
-	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
-	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	AllowedTools []string          `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
+	Rules        []permission.Rule `json:"rules,omitempty" jsonschema:"description=Ordered allow/deny/ask rules matched against commands, file paths and URL domains"`     // First matching rule wins
+	SkipRequests bool              `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
 }
 
 type TrailerStyle string
@@ -291,6 +306,8 @@ func (m MCPs) Sorted() []MCP {
 
 type LSPs map[string]LSPConfig
 
+type Formatters map[string]FormatterConfig
+
 type LSP struct {
 	Name string    `json:"name"`
 	LSP  LSPConfig `json:"lsp"`
@@ -355,7 +372,8 @@ type Agent struct {
 }
 
 type Tools struct {
-	Ls ToolLs `json:"ls,omitzero"`
+	Ls   ToolLs   `json:"ls,omitzero"`
+	Bash ToolBash `json:"bash,omitzero"`
 }
 
 type ToolLs struct {
@@ -367,6 +385,45 @@ func (t ToolLs) Limits() (depth, items int) {
 	return ptrValOr(t.MaxDepth, 0), ptrValOr(t.MaxItems, 0)
 }
 
+type ToolBash struct {
+	PersistentShell  bool          `json:"persistent_shell,omitempty" jsonschema:"description=Keep the working directory, environment variables and functions of the bash tool between commands of a session,default=false"`
+	Sandbox          *BashSandbox  `json:"sandbox,omitempty" jsonschema:"description=Run bash commands in a kernel-enforced sandbox (Linux only)"`
+	BannedCommands   ListOverrides `json:"banned_commands,omitzero" jsonschema:"description=Changes to the list of commands the bash tool refuses to run,example={\"remove\":[\"curl\"]}"`
+	BlockedArguments ListOverrides `json:"blocked_arguments,omitzero" jsonschema:"description=Changes to the list of argument patterns the bash tool refuses to run. Patterns are the command followed by arguments and flags to match and may use * wildcards,example={\"extend\":[\"git push --force*\"]}"`
+}
+
+// BashSandbox restricts what commands run by the bash tool can do. Commands
+// can always write to the working directory, the temporary directory and the
+// user cache directory.
+type BashSandbox struct {
+	Enabled       bool     `json:"enabled,omitempty" jsonschema:"description=Enable the sandbox,default=false"`
+	WritablePaths []string `json:"writable_paths,omitempty" jsonschema:"description=Additional files or directories commands may write to. Relative paths are resolved against the working directory,example=~/.npm,example=../shared"`
+	AllowNetwork  bool     `json:"allow_network,omitempty" jsonschema:"description=Allow commands to open network connections,default=false"`
+}
+
+// ShellSandbox returns the sandbox bash commands should run in, or nil if
+// the sandbox is disabled.
+func (t ToolBash) ShellSandbox(workingDir string) *shell.Sandbox {
+	if t.Sandbox == nil || !t.Sandbox.Enabled {
+		return nil
+	}
+	paths := []string{workingDir, os.TempDir()}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		paths = append(paths, cacheDir)
+	}
+	for _, p := range t.Sandbox.WritablePaths {
+		p = home.Long(p)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(workingDir, p)
+		}
+		paths = append(paths, p)
+	}
+	return &shell.Sandbox{
+		WritablePaths: paths,
+		AllowNetwork:  t.Sandbox.AllowNetwork,
+	}
+}
+
 // Config holds the configuration for crush.
 type Config struct {
 	Schema string `json:"$schema,omitempty"`
@@ -383,6 +440,8 @@ type Config struct {
 
 	LSP LSPs `json:"lsp,omitempty" jsonschema:"description=Language Server Protocol configurations"`
 
+	Format Formatters `json:"format,omitempty" jsonschema:"description=Formatters applied to files written by the edit tools, by language"`
+
 	Options *Options `json:"options,omitempty" jsonschema:"description=General application options"`
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
@@ -499,7 +558,43 @@ func (c *Config) HasConfigField(key string) bool {
 }
 
 func (c *Config) SetConfigField(key string, value any) error {
-	data, err := os.ReadFile(c.dataConfigDir)
+	return setConfigFieldIn(c.dataConfigDir, key, value)
+}
+
+// ProjectConfigPath returns the path of the configuration file in the
+// working directory, preferring an existing `.crush.json` over
+// `crush.json`. The file may not exist yet.
+func (c *Config) ProjectConfigPath() string {
+	for _, name := range []string{"." + appName + ".json", appName + ".json"} {
+		path := filepath.Join(c.workingDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(c.workingDir, appName+".json")
+}
+
+// AddPermissionRules appends the given rules to the project configuration so
+// they persist across sessions.
+func (c *Config) AddPermissionRules(rules ...permission.Rule) error {
+	if c.Permissions == nil {
+		c.Permissions = &Permissions{}
+	}
+	path := c.ProjectConfigPath()
+	for _, rule := range rules {
+		if slices.Contains(c.Permissions.Rules, rule) {
+			continue
+		}
+		if err := setConfigFieldIn(path, "permissions.rules.-1", rule); err != nil {
+			return fmt.Errorf("failed to save permission rule: %w", err)
+		}
+		c.Permissions.Rules = append(c.Permissions.Rules, rule)
+	}
+	return nil
+}
+
+func setConfigFieldIn(path, key string, value any) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			data = []byte("{}")
@@ -512,10 +607,10 @@ func (c *Config) SetConfigField(key string, value any) error {
 	if err != nil {
 		return fmt.Errorf("failed to set config field %s: %w", key, err)
 	}
-	if err := os.MkdirAll(filepath.Dir(c.dataConfigDir), 0o755); err != nil {
-		return fmt.Errorf("failed to create config directory %q: %w", c.dataConfigDir, err)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory %q: %w", path, err)
 	}
-	if err := os.WriteFile(c.dataConfigDir, []byte(newValue), 0o600); err != nil {
+	if err := os.WriteFile(path, []byte(newValue), 0o600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	return nil
@@ -689,8 +784,24 @@ func allToolNames() []string {
 		"download",
 		"edit",
 		"multiedit",
+		"edit_lines",
+		"notebook_edit",
+		"apply_patch",
+		"move",
+		"copy",
+		"delete",
 		"lsp_diagnostics",
 		"lsp_references",
+		"lsp_definition",
+		"lsp_type_definition",
+		"lsp_implementation",
+		"lsp_hover",
+		"lsp_document_symbols",
+		"lsp_workspace_symbols",
+		"rename",
+		"lsp_code_action",
+		"lsp_call_hierarchy",
+		"lsp_type_hierarchy",
 		"fetch",
 		"agentic_fetch",
 		"glob",
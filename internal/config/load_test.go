@@ -487,7 +487,7 @@ func TestConfig_setupAgentsWithDisabledTools(t *testing.T) {
 	coderAgent, ok := cfg.Agents[AgentCoder]
 	require.True(t, ok)
 
-	assert.Equal(t, []string{"agent", "bash", "job_output", "job_kill", "multiedit", "lsp_diagnostics", "lsp_references", "fetch", "agentic_fetch", "glob", "ls", "sourcegraph", "todos", "view", "write"}, coderAgent.AllowedTools)
+	assert.Equal(t, []string{"agent", "bash", "job_output", "job_kill", "multiedit", "edit_lines", "notebook_edit", "apply_patch", "move", "copy", "delete", "lsp_diagnostics", "lsp_references", "lsp_definition", "lsp_type_definition", "lsp_implementation", "lsp_hover", "lsp_document_symbols", "lsp_workspace_symbols", "rename", "lsp_code_action", "lsp_call_hierarchy", "lsp_type_hierarchy", "fetch", "agentic_fetch", "glob", "ls", "sourcegraph", "todos", "view", "write"}, coderAgent.AllowedTools)
 
 	taskAgent, ok := cfg.Agents[AgentTask]
 	require.True(t, ok)
@@ -510,7 +510,7 @@ func TestConfig_setupAgentsWithEveryReadOnlyToolDisabled(t *testing.T) {
 	cfg.SetupAgents()
 	coderAgent, ok := cfg.Agents[AgentCoder]
 	require.True(t, ok)
-	assert.Equal(t, []string{"agent", "bash", "job_output", "job_kill", "download", "edit", "multiedit", "lsp_diagnostics", "lsp_references", "fetch", "agentic_fetch", "todos", "write"}, coderAgent.AllowedTools)
+	assert.Equal(t, []string{"agent", "bash", "job_output", "job_kill", "download", "edit", "multiedit", "edit_lines", "notebook_edit", "apply_patch", "move", "copy", "delete", "lsp_diagnostics", "lsp_references", "lsp_definition", "lsp_type_definition", "lsp_implementation", "lsp_hover", "lsp_document_symbols", "lsp_workspace_symbols", "rename", "lsp_code_action", "lsp_call_hierarchy", "lsp_type_hierarchy", "fetch", "agentic_fetch", "todos", "write"}, coderAgent.AllowedTools)
 
 	taskAgent, ok := cfg.Agents[AgentTask]
 	require.True(t, ok)
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_AddPermissionRules(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := &Config{workingDir: dir}
+
+	rule := permission.Rule{Action: permission.RuleAllow, Tool: "bash", Pattern: "go test *"}
+	require.NoError(t, cfg.AddPermissionRules(rule, rule))
+	require.Equal(t, []permission.Rule{rule}, cfg.Permissions.Rules)
+
+	data, err := os.ReadFile(filepath.Join(dir, "crush.json"))
+	require.NoError(t, err)
+
+	loaded, err := LoadReader(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Equal(t, []permission.Rule{rule}, loaded.Permissions.Rules)
+}
+
+func TestConfig_ProjectConfigPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := &Config{workingDir: dir}
+	require.Equal(t, filepath.Join(dir, "crush.json"), cfg.ProjectConfigPath())
+
+	hidden := filepath.Join(dir, ".crush.json")
+	require.NoError(t, os.WriteFile(hidden, []byte("{}"), 0o644))
+	require.Equal(t, hidden, cfg.ProjectConfigPath())
+}
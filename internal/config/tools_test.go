@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/home"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolBash_ShellSandbox(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		require.Nil(t, ToolBash{}.ShellSandbox("/work"))
+		require.Nil(t, ToolBash{Sandbox: &BashSandbox{}}.ShellSandbox("/work"))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := LoadReader(strings.NewReader(`{
+			"tools": {
+				"bash": {
+					"sandbox": {
+						"enabled": true,
+						"writable_paths": ["build", "~/.npm", "/opt/shared"]
+					}
+				}
+			}
+		}`))
+		require.NoError(t, err)
+
+		sb := cfg.Tools.Bash.ShellSandbox("/work")
+		require.NotNil(t, sb)
+		require.False(t, sb.AllowNetwork)
+		require.Contains(t, sb.WritablePaths, "/work")
+		require.Contains(t, sb.WritablePaths, os.TempDir())
+		require.Contains(t, sb.WritablePaths, filepath.Join("/work", "build"))
+		require.Contains(t, sb.WritablePaths, filepath.Join(home.Dir(), ".npm"))
+		require.Contains(t, sb.WritablePaths, "/opt/shared")
+	})
+}
@@ -30,12 +30,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createMessageStmt, err = db.PrepareContext(ctx, createMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateMessage: %w", err)
 	}
+	if q.createPermissionLogEntryStmt, err = db.PrepareContext(ctx, createPermissionLogEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query CreatePermissionLogEntry: %w", err)
+	}
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
+	if q.deleteFileRecordStmt, err = db.PrepareContext(ctx, deleteFileRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteFileRecord: %w", err)
+	}
 	if q.deleteMessageStmt, err = db.PrepareContext(ctx, deleteMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteMessage: %w", err)
 	}
@@ -54,12 +60,21 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getFileByPathAndSessionStmt, err = db.PrepareContext(ctx, getFileByPathAndSession); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFileByPathAndSession: %w", err)
 	}
+	if q.getFileRecordStmt, err = db.PrepareContext(ctx, getFileRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query GetFileRecord: %w", err)
+	}
 	if q.getMessageStmt, err = db.PrepareContext(ctx, getMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query GetMessage: %w", err)
 	}
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.listFileRecordsByPathStmt, err = db.PrepareContext(ctx, listFileRecordsByPath); err != nil {
+		return nil, fmt.Errorf("error preparing query ListFileRecordsByPath: %w", err)
+	}
+	if q.listFileRecordsBySessionStmt, err = db.PrepareContext(ctx, listFileRecordsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListFileRecordsBySession: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -75,6 +90,12 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
+	if q.listPermissionLogStmt, err = db.PrepareContext(ctx, listPermissionLog); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionLog: %w", err)
+	}
+	if q.listPermissionLogBySessionStmt, err = db.PrepareContext(ctx, listPermissionLogBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionLogBySession: %w", err)
+	}
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
@@ -87,6 +108,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.updateSessionTitleAndUsageStmt, err = db.PrepareContext(ctx, updateSessionTitleAndUsage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateSessionTitleAndUsage: %w", err)
 	}
+	if q.upsertFileRecordStmt, err = db.PrepareContext(ctx, upsertFileRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertFileRecord: %w", err)
+	}
 	return &q, nil
 }
 
@@ -102,6 +126,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createMessageStmt: %w", cerr)
 		}
 	}
+	if q.createPermissionLogEntryStmt != nil {
+		if cerr := q.createPermissionLogEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createPermissionLogEntryStmt: %w", cerr)
+		}
+	}
 	if q.createSessionStmt != nil {
 		if cerr := q.createSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
@@ -112,6 +141,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
 		}
 	}
+	if q.deleteFileRecordStmt != nil {
+		if cerr := q.deleteFileRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteFileRecordStmt: %w", cerr)
+		}
+	}
 	if q.deleteMessageStmt != nil {
 		if cerr := q.deleteMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteMessageStmt: %w", cerr)
@@ -142,6 +176,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getFileByPathAndSessionStmt: %w", cerr)
 		}
 	}
+	if q.getFileRecordStmt != nil {
+		if cerr := q.getFileRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getFileRecordStmt: %w", cerr)
+		}
+	}
 	if q.getMessageStmt != nil {
 		if cerr := q.getMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getMessageStmt: %w", cerr)
@@ -152,6 +191,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.listFileRecordsByPathStmt != nil {
+		if cerr := q.listFileRecordsByPathStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listFileRecordsByPathStmt: %w", cerr)
+		}
+	}
+	if q.listFileRecordsBySessionStmt != nil {
+		if cerr := q.listFileRecordsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listFileRecordsBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -177,6 +226,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
 		}
 	}
+	if q.listPermissionLogStmt != nil {
+		if cerr := q.listPermissionLogStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionLogStmt: %w", cerr)
+		}
+	}
+	if q.listPermissionLogBySessionStmt != nil {
+		if cerr := q.listPermissionLogBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionLogBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listSessionsStmt != nil {
 		if cerr := q.listSessionsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
@@ -197,6 +256,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateSessionTitleAndUsageStmt: %w", cerr)
 		}
 	}
+	if q.upsertFileRecordStmt != nil {
+		if cerr := q.upsertFileRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertFileRecordStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -238,25 +302,33 @@ type Queries struct {
 	tx                             *sql.Tx
 	createFileStmt                 *sql.Stmt
 	createMessageStmt              *sql.Stmt
+	createPermissionLogEntryStmt   *sql.Stmt
 	createSessionStmt              *sql.Stmt
 	deleteFileStmt                 *sql.Stmt
+	deleteFileRecordStmt           *sql.Stmt
 	deleteMessageStmt              *sql.Stmt
 	deleteSessionStmt              *sql.Stmt
 	deleteSessionFilesStmt         *sql.Stmt
 	deleteSessionMessagesStmt      *sql.Stmt
 	getFileStmt                    *sql.Stmt
 	getFileByPathAndSessionStmt    *sql.Stmt
+	getFileRecordStmt              *sql.Stmt
 	getMessageStmt                 *sql.Stmt
 	getSessionByIDStmt             *sql.Stmt
+	listFileRecordsByPathStmt      *sql.Stmt
+	listFileRecordsBySessionStmt   *sql.Stmt
 	listFilesByPathStmt            *sql.Stmt
 	listFilesBySessionStmt         *sql.Stmt
 	listLatestSessionFilesStmt     *sql.Stmt
 	listMessagesBySessionStmt      *sql.Stmt
 	listNewFilesStmt               *sql.Stmt
+	listPermissionLogStmt          *sql.Stmt
+	listPermissionLogBySessionStmt *sql.Stmt
 	listSessionsStmt               *sql.Stmt
 	updateMessageStmt              *sql.Stmt
 	updateSessionStmt              *sql.Stmt
 	updateSessionTitleAndUsageStmt *sql.Stmt
+	upsertFileRecordStmt           *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
@@ -265,24 +337,32 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		tx:                             tx,
 		createFileStmt:                 q.createFileStmt,
 		createMessageStmt:              q.createMessageStmt,
+		createPermissionLogEntryStmt:   q.createPermissionLogEntryStmt,
 		createSessionStmt:              q.createSessionStmt,
 		deleteFileStmt:                 q.deleteFileStmt,
+		deleteFileRecordStmt:           q.deleteFileRecordStmt,
 		deleteMessageStmt:              q.deleteMessageStmt,
 		deleteSessionStmt:              q.deleteSessionStmt,
 		deleteSessionFilesStmt:         q.deleteSessionFilesStmt,
 		deleteSessionMessagesStmt:      q.deleteSessionMessagesStmt,
 		getFileStmt:                    q.getFileStmt,
 		getFileByPathAndSessionStmt:    q.getFileByPathAndSessionStmt,
+		getFileRecordStmt:              q.getFileRecordStmt,
 		getMessageStmt:                 q.getMessageStmt,
 		getSessionByIDStmt:             q.getSessionByIDStmt,
+		listFileRecordsByPathStmt:      q.listFileRecordsByPathStmt,
+		listFileRecordsBySessionStmt:   q.listFileRecordsBySessionStmt,
 		listFilesByPathStmt:            q.listFilesByPathStmt,
 		listFilesBySessionStmt:         q.listFilesBySessionStmt,
 		listLatestSessionFilesStmt:     q.listLatestSessionFilesStmt,
 		listMessagesBySessionStmt:      q.listMessagesBySessionStmt,
 		listNewFilesStmt:               q.listNewFilesStmt,
+		listPermissionLogStmt:          q.listPermissionLogStmt,
+		listPermissionLogBySessionStmt: q.listPermissionLogBySessionStmt,
 		listSessionsStmt:               q.listSessionsStmt,
 		updateMessageStmt:              q.updateMessageStmt,
 		updateSessionStmt:              q.updateSessionStmt,
 		updateSessionTitleAndUsageStmt: q.updateSessionTitleAndUsageStmt,
+		upsertFileRecordStmt:           q.upsertFileRecordStmt,
 	}
 }
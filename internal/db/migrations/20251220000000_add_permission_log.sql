@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS permission_log (
+    id TEXT PRIMARY KEY,
+    session_id TEXT,  -- NULL once the session is deleted, the log is kept
+    tool_call_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    path TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    params TEXT NOT NULL DEFAULT '',
+    decision TEXT NOT NULL,
+    granted INTEGER NOT NULL DEFAULT 0,
+    requested_at INTEGER NOT NULL,  -- Unix timestamp in milliseconds
+    decided_at INTEGER NOT NULL,  -- Unix timestamp in milliseconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_permission_log_session_id ON permission_log (session_id);
+CREATE INDEX IF NOT EXISTS idx_permission_log_requested_at ON permission_log (requested_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_permission_log_requested_at;
+DROP INDEX IF EXISTS idx_permission_log_session_id;
+DROP TABLE IF EXISTS permission_log;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS file_records (
+    session_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    hash TEXT NOT NULL,  -- SHA-256 of the content last read or written
+    read_at INTEGER NOT NULL DEFAULT 0,  -- Unix timestamp in milliseconds
+    written_at INTEGER NOT NULL DEFAULT 0,  -- Unix timestamp in milliseconds
+    content BLOB,  -- content last read or written, NULL when too large to keep
+    PRIMARY KEY (session_id, path),
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_records_path ON file_records (path);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_file_records_path;
+DROP TABLE IF EXISTS file_records;
+-- +goose StatementEnd
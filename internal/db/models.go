@@ -18,6 +18,15 @@ type File struct {
 	UpdatedAt int64  `json:"updated_at"`
 }
 
+type FileRecord struct {
+	SessionID string `json:"session_id"`
+	Path      string `json:"path"`
+	Hash      string `json:"hash"`
+	ReadAt    int64  `json:"read_at"`
+	WrittenAt int64  `json:"written_at"`
+	Content   []byte `json:"content"`
+}
+
 type Message struct {
 	ID               string         `json:"id"`
 	SessionID        string         `json:"session_id"`
@@ -31,6 +40,21 @@ type Message struct {
 	IsSummaryMessage int64          `json:"is_summary_message"`
 }
 
+type PermissionLog struct {
+	ID          string         `json:"id"`
+	SessionID   sql.NullString `json:"session_id"`
+	ToolCallID  string         `json:"tool_call_id"`
+	ToolName    string         `json:"tool_name"`
+	Action      string         `json:"action"`
+	Path        string         `json:"path"`
+	Description string         `json:"description"`
+	Params      string         `json:"params"`
+	Decision    string         `json:"decision"`
+	Granted     int64          `json:"granted"`
+	RequestedAt int64          `json:"requested_at"`
+	DecidedAt   int64          `json:"decided_at"`
+}
+
 type Session struct {
 	ID               string         `json:"id"`
 	ParentSessionID  sql.NullString `json:"parent_session_id"`
@@ -0,0 +1,38 @@
+-- name: UpsertFileRecord :exec
+INSERT INTO file_records (
+    session_id,
+    path,
+    hash,
+    read_at,
+    written_at,
+    content
+) VALUES (
+    ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (session_id, path) DO UPDATE SET
+    hash = excluded.hash,
+    read_at = excluded.read_at,
+    written_at = excluded.written_at,
+    content = excluded.content;
+
+-- name: GetFileRecord :one
+SELECT *
+FROM file_records
+WHERE session_id = ? AND path = ?
+LIMIT 1;
+
+-- name: ListFileRecordsBySession :many
+SELECT *
+FROM file_records
+WHERE session_id = ?
+ORDER BY path ASC;
+
+-- name: ListFileRecordsByPath :many
+SELECT *
+FROM file_records
+WHERE path = ?
+ORDER BY session_id ASC;
+
+-- name: DeleteFileRecord :exec
+DELETE FROM file_records
+WHERE session_id = ? AND path = ?;
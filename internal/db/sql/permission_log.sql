@@ -0,0 +1,29 @@
+-- name: CreatePermissionLogEntry :exec
+INSERT INTO permission_log (
+    id,
+    session_id,
+    tool_call_id,
+    tool_name,
+    action,
+    path,
+    description,
+    params,
+    decision,
+    granted,
+    requested_at,
+    decided_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListPermissionLogBySession :many
+SELECT *
+FROM permission_log
+WHERE session_id = ?
+ORDER BY requested_at ASC;
+
+-- name: ListPermissionLog :many
+SELECT *
+FROM permission_log
+ORDER BY requested_at DESC
+LIMIT ?;
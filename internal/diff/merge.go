@@ -0,0 +1,148 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aymanbagabas/go-udiff/lcs"
+)
+
+// Conflict is a region of the base that both sides of a merge changed
+// differently.
+type Conflict struct {
+	// Line is the 1-based line of the merged content where the region
+	// starts.
+	Line   int
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// MergeResult is the result of a three-way merge. Conflicting regions take
+// their side in Content.
+type MergeResult struct {
+	Content   string
+	Conflicts []Conflict
+}
+
+// lineHunk replaces the base lines [start, end) with lines.
+type lineHunk struct {
+	start, end int
+	lines      []string
+}
+
+// Merge3 merges the changes from base to ours and from base to theirs, line
+// by line. Changes to the same or adjacent lines conflict unless both sides
+// made the same change.
+func Merge3(base, ours, theirs string) MergeResult {
+	baseLines := splitLines(base)
+	oursHunks := lineHunks(baseLines, splitLines(ours))
+	theirsHunks := lineHunks(baseLines, splitLines(theirs))
+
+	var (
+		merged    strings.Builder
+		conflicts []Conflict
+		line      = 1
+		pos       int
+	)
+	write := func(lines []string) {
+		for _, l := range lines {
+			merged.WriteString(l)
+		}
+		line += len(lines)
+	}
+
+	var oi, ti int
+	for oi < len(oursHunks) || ti < len(theirsHunks) {
+		// Start a region with the first hunk of either side, then extend it
+		// with every hunk that touches it, so it is resolved as a whole.
+		var start, end int
+		if ti == len(theirsHunks) || oi < len(oursHunks) && oursHunks[oi].start <= theirsHunks[ti].start {
+			start, end = oursHunks[oi].start, oursHunks[oi].end
+		} else {
+			start, end = theirsHunks[ti].start, theirsHunks[ti].end
+		}
+		oursFrom, theirsFrom := oi, ti
+		for {
+			if oi < len(oursHunks) && oursHunks[oi].start <= end {
+				end = max(end, oursHunks[oi].end)
+				oi++
+			} else if ti < len(theirsHunks) && theirsHunks[ti].start <= end {
+				end = max(end, theirsHunks[ti].end)
+				ti++
+			} else {
+				break
+			}
+		}
+		regionOurs, regionTheirs := oursHunks[oursFrom:oi], theirsHunks[theirsFrom:ti]
+
+		write(baseLines[pos:start])
+		pos = end
+		oursLines := applyLineHunks(baseLines, start, end, regionOurs)
+		theirsLines := applyLineHunks(baseLines, start, end, regionTheirs)
+		switch {
+		case len(regionTheirs) == 0:
+			write(oursLines)
+		case len(regionOurs) == 0 || slices.Equal(oursLines, theirsLines):
+			write(theirsLines)
+		default:
+			conflicts = append(conflicts, Conflict{
+				Line:   line,
+				Base:   strings.Join(baseLines[start:end], ""),
+				Ours:   strings.Join(oursLines, ""),
+				Theirs: strings.Join(theirsLines, ""),
+			})
+			write(theirsLines)
+		}
+	}
+	write(baseLines[pos:])
+
+	return MergeResult{Content: merged.String(), Conflicts: conflicts}
+}
+
+// lineHunks returns the changes from the before lines to the after lines.
+func lineHunks(before, after []string) []lineHunk {
+	// Map every distinct line to a rune so the lines can be compared by
+	// the LCS algorithm.
+	ids := make(map[string]rune)
+	toRunes := func(lines []string) []rune {
+		runes := make([]rune, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = rune(len(ids))
+				ids[l] = id
+			}
+			runes[i] = id
+		}
+		return runes
+	}
+	diffs := lcs.DiffRunes(toRunes(before), toRunes(after))
+	hunks := make([]lineHunk, len(diffs))
+	for i, d := range diffs {
+		hunks[i] = lineHunk{start: d.Start, end: d.End, lines: after[d.ReplStart:d.ReplEnd]}
+	}
+	return hunks
+}
+
+// applyLineHunks returns the base lines [start, end) with the hunks, which
+// are within the range, applied.
+func applyLineHunks(base []string, start, end int, hunks []lineHunk) []string {
+	var lines []string
+	pos := start
+	for _, h := range hunks {
+		lines = append(lines, base[pos:h.start]...)
+		lines = append(lines, h.lines...)
+		pos = h.end
+	}
+	return append(lines, base[pos:end]...)
+}
+
+// splitLines splits the content after each newline, keeping the newlines.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
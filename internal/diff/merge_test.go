@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3(t *testing.T) {
+	t.Parallel()
+
+	base := "one\ntwo\nthree\nfour\nfive\nsix\n"
+
+	t.Run("changes to different lines", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3(base,
+			"ONE\ntwo\nthree\nfour\nfive\nsix\n",
+			"one\ntwo\nthree\nfour\nFIVE\nsix\nseven\n",
+		)
+		require.Empty(t, result.Conflicts)
+		require.Equal(t, "ONE\ntwo\nthree\nfour\nFIVE\nsix\nseven\n", result.Content)
+	})
+
+	t.Run("same change on both sides", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3(base,
+			"one\nTWO\nthree\nfour\nfive\nsix\n",
+			"one\nTWO\nthree\nfour\nfive\n",
+		)
+		require.Empty(t, result.Conflicts)
+		require.Equal(t, "one\nTWO\nthree\nfour\nfive\n", result.Content)
+	})
+
+	t.Run("only one side changed", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3(base, base, "one\nthree\n")
+		require.Empty(t, result.Conflicts)
+		require.Equal(t, "one\nthree\n", result.Content)
+	})
+
+	t.Run("conflicting changes keep their side", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3(base,
+			"one\ntwo\nTHREE\nfour\nfive\nsix\n",
+			"ONE\ntwo\nthree!\nfour\nfive\nsix\n",
+		)
+		require.Equal(t, "ONE\ntwo\nthree!\nfour\nfive\nsix\n", result.Content)
+		require.Equal(t, []Conflict{{
+			Line:   3,
+			Base:   "three\n",
+			Ours:   "THREE\n",
+			Theirs: "three!\n",
+		}}, result.Conflicts)
+	})
+
+	t.Run("adjacent changes conflict", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3(base,
+			"one\ntwo\nTHREE\nfour\nfive\nsix\n",
+			"one\ntwo\nthree\nFOUR\nfive\nsix\n",
+		)
+		require.Len(t, result.Conflicts, 1)
+		require.Equal(t, "three\nfour\n", result.Conflicts[0].Base)
+		require.Equal(t, "THREE\nfour\n", result.Conflicts[0].Ours)
+		require.Equal(t, "three\nFOUR\n", result.Conflicts[0].Theirs)
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3("a\nb\nc\nd", "A\nb\nc\nd", "a\nb\nc\nD")
+		require.Empty(t, result.Conflicts)
+		require.Equal(t, "A\nb\nc\nD", result.Content)
+	})
+
+	t.Run("empty base", func(t *testing.T) {
+		t.Parallel()
+		result := Merge3("", "ours\n", "")
+		require.Empty(t, result.Conflicts)
+		require.Equal(t, "ours\n", result.Content)
+	})
+}
@@ -0,0 +1,271 @@
+// Package filetracker records which files a session has read or written, and
+// the content it saw, so tools can refuse to change a file the session hasn't
+// seen in its current state.
+package filetracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/watcher"
+)
+
+// Status is what a session knows about the current content of a file.
+type Status int
+
+const (
+	// StatusUnread means the session never read or wrote the file.
+	StatusUnread Status = iota
+	// StatusChanged means the file changed since the session last read or
+	// wrote it.
+	StatusChanged
+	// StatusCurrent means the session last saw the current content of the
+	// file.
+	StatusCurrent
+)
+
+// Record is the last content of a file a session read or wrote.
+type Record struct {
+	SessionID string
+	Path      string
+	// Hash is the SHA-256 of the content, see [Hash].
+	Hash string
+	// ReadAt and WrittenAt are Unix timestamps in milliseconds, zero when
+	// the session never read or wrote the file.
+	ReadAt    int64
+	WrittenAt int64
+}
+
+type Service interface {
+	pubsub.Subscriber[StaleFile]
+	// RecordRead records that the session read the current content of the
+	// file, and keeps the content so it can be merged with later changes,
+	// see [Service.Base].
+	RecordRead(ctx context.Context, sessionID, path string) error
+	// RecordWrite records that the session wrote the current content of the
+	// file.
+	RecordWrite(ctx context.Context, sessionID, path string) error
+	// Status compares the current content of the file with the content the
+	// session last read or wrote.
+	Status(ctx context.Context, sessionID, path string) (Status, error)
+	// Base returns the content the session last read or wrote, as it was on
+	// disk, when it was small enough to be kept.
+	Base(ctx context.Context, sessionID, path string) (string, bool, error)
+	Get(ctx context.Context, sessionID, path string) (Record, error)
+	ListBySession(ctx context.Context, sessionID string) ([]Record, error)
+	// Move moves the records of the files at or under oldPath to newPath,
+	// after the files were moved.
+	Move(ctx context.Context, sessionID, oldPath, newPath string) error
+	// Writing marks the file as being written until done is called, so its
+	// changes meanwhile aren't reported stale: the file can be written
+	// several times, by a formatter for example, before the session records
+	// the write.
+	Writing(path string) (done func())
+	// Forget removes the records of the files at or under the path, after
+	// the files were deleted.
+	Forget(ctx context.Context, sessionID, path string) error
+	// Watch follows the changes of the workspace until the context is done,
+	// publishing a [StaleFile] when a file recorded since the service was
+	// created changes. Only the files the workspace watcher reports, which
+	// aren't ignored, are followed.
+	Watch(ctx context.Context) error
+}
+
+type service struct {
+	*pubsub.Broker[StaleFile]
+	q         db.Querier
+	workspace watcher.Service
+	tracked   *tracked
+}
+
+// maxBaseSize is the size of the largest content kept as the base of later
+// merges, see [Service.Base].
+const maxBaseSize = 1024 * 1024
+
+func NewService(q db.Querier, workspace watcher.Service) Service {
+	return &service{
+		Broker:    pubsub.NewBroker[StaleFile](),
+		q:         q,
+		workspace: workspace,
+		tracked:   newTracked(),
+	}
+}
+
+func (s *service) RecordRead(ctx context.Context, sessionID, path string) error {
+	return s.record(ctx, sessionID, path, func(r *db.UpsertFileRecordParams, now int64) {
+		r.ReadAt = now
+	})
+}
+
+func (s *service) RecordWrite(ctx context.Context, sessionID, path string) error {
+	return s.record(ctx, sessionID, path, func(r *db.UpsertFileRecordParams, now int64) {
+		r.WrittenAt = now
+	})
+}
+
+func (s *service) record(ctx context.Context, sessionID, path string, update func(*db.UpsertFileRecordParams, int64)) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	params := db.UpsertFileRecordParams{
+		SessionID: sessionID,
+		Path:      path,
+		Hash:      Hash(content),
+	}
+	if len(content) <= maxBaseSize {
+		params.Content = content
+	}
+	existing, err := s.q.GetFileRecord(ctx, db.GetFileRecordParams{SessionID: sessionID, Path: path})
+	switch {
+	case err == nil:
+		params.ReadAt = existing.ReadAt
+		params.WrittenAt = existing.WrittenAt
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	update(&params, time.Now().UnixMilli())
+	if err := s.q.UpsertFileRecord(ctx, params); err != nil {
+		return err
+	}
+	s.tracked.add(sessionID, path)
+	return nil
+}
+
+func (s *service) Status(ctx context.Context, sessionID, path string) (Status, error) {
+	record, err := s.q.GetFileRecord(ctx, db.GetFileRecordParams{SessionID: sessionID, Path: path})
+	if errors.Is(err, sql.ErrNoRows) {
+		return StatusUnread, nil
+	}
+	if err != nil {
+		return StatusUnread, err
+	}
+	hash, err := HashFile(path)
+	if os.IsNotExist(err) {
+		return StatusChanged, nil
+	}
+	if err != nil {
+		return StatusUnread, err
+	}
+	if hash != record.Hash {
+		return StatusChanged, nil
+	}
+	return StatusCurrent, nil
+}
+
+func (s *service) Base(ctx context.Context, sessionID, path string) (string, bool, error) {
+	record, err := s.q.GetFileRecord(ctx, db.GetFileRecordParams{SessionID: sessionID, Path: path})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	// The content is NULL when the file was too large to keep, which only
+	// matches the hash of an empty file.
+	if Hash(record.Content) != record.Hash {
+		return "", false, nil
+	}
+	return string(record.Content), true, nil
+}
+
+func (s *service) Get(ctx context.Context, sessionID, path string) (Record, error) {
+	record, err := s.q.GetFileRecord(ctx, db.GetFileRecordParams{SessionID: sessionID, Path: path})
+	if err != nil {
+		return Record{}, err
+	}
+	return fromDBItem(record), nil
+}
+
+func (s *service) ListBySession(ctx context.Context, sessionID string) ([]Record, error) {
+	dbRecords, err := s.q.ListFileRecordsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(dbRecords))
+	for i, record := range dbRecords {
+		records[i] = fromDBItem(record)
+	}
+	return records, nil
+}
+
+func (s *service) Move(ctx context.Context, sessionID, oldPath, newPath string) error {
+	records, err := s.q.ListFileRecordsBySession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		rel, ok := fsext.UnderPath(oldPath, record.Path)
+		if !ok {
+			continue
+		}
+		if err := s.q.DeleteFileRecord(ctx, db.DeleteFileRecordParams{SessionID: sessionID, Path: record.Path}); err != nil {
+			return err
+		}
+		s.tracked.remove(sessionID, record.Path)
+		path := filepath.Join(newPath, rel)
+		if err := s.q.UpsertFileRecord(ctx, db.UpsertFileRecordParams{
+			SessionID: sessionID,
+			Path:      path,
+			Hash:      record.Hash,
+			ReadAt:    record.ReadAt,
+			WrittenAt: record.WrittenAt,
+			Content:   record.Content,
+		}); err != nil {
+			return err
+		}
+		s.tracked.add(sessionID, path)
+	}
+	return nil
+}
+
+func (s *service) Forget(ctx context.Context, sessionID, path string) error {
+	records, err := s.q.ListFileRecordsBySession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, ok := fsext.UnderPath(path, record.Path); !ok {
+			continue
+		}
+		if err := s.q.DeleteFileRecord(ctx, db.DeleteFileRecordParams{SessionID: sessionID, Path: record.Path}); err != nil {
+			return err
+		}
+		s.tracked.remove(sessionID, record.Path)
+	}
+	return nil
+}
+
+// Hash returns the hex encoded SHA-256 of the content.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile returns the [Hash] of the content of the file.
+func HashFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Hash(content), nil
+}
+
+func fromDBItem(item db.FileRecord) Record {
+	return Record{
+		SessionID: item.SessionID,
+		Path:      item.Path,
+		Hash:      item.Hash,
+		ReadAt:    item.ReadAt,
+		WrittenAt: item.WrittenAt,
+	}
+}
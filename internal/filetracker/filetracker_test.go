@@ -0,0 +1,187 @@
+package filetracker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/watcher"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (Service, session.Service) {
+	t.Helper()
+	tracker, sessions, _ := setupWorkspace(t, t.TempDir())
+	return tracker, sessions
+}
+
+// setupWorkspace returns services for the workspace at root, with the
+// workspace watcher, which isn't started.
+func setupWorkspace(t *testing.T, root string) (Service, session.Service, watcher.Service) {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	workspace := watcher.NewService(root)
+	return NewService(q, workspace), session.NewService(q), workspace
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	tracker, sessions := setup(t)
+	parent, err := sessions.Create(t.Context(), "parent")
+	require.NoError(t, err)
+	child, err := sessions.CreateTaskSession(t.Context(), "call", parent.ID, "child")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o644))
+
+	status, err := tracker.Status(t.Context(), parent.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusUnread, status)
+
+	require.NoError(t, tracker.RecordRead(t.Context(), child.ID, path))
+	status, err = tracker.Status(t.Context(), parent.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusUnread, status, "a sub-agent's read must not count for the parent")
+	status, err = tracker.Status(t.Context(), child.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusCurrent, status)
+
+	// Touching the file without changing it keeps it current.
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o644))
+	status, err = tracker.Status(t.Context(), child.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusCurrent, status)
+
+	require.NoError(t, os.WriteFile(path, []byte("two\n"), 0o644))
+	status, err = tracker.Status(t.Context(), child.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusChanged, status)
+
+	require.NoError(t, tracker.RecordWrite(t.Context(), child.ID, path))
+	record, err := tracker.Get(t.Context(), child.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, Hash([]byte("two\n")), record.Hash)
+	require.NotZero(t, record.ReadAt)
+	require.NotZero(t, record.WrittenAt)
+
+	require.NoError(t, os.Remove(path))
+	status, err = tracker.Status(t.Context(), child.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusChanged, status)
+}
+
+func TestMoveAndForget(t *testing.T) {
+	t.Parallel()
+
+	tracker, sessions := setup(t)
+	sess, err := sessions.Create(t.Context(), "session")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	moved := filepath.Join(dir, "pkg", "a.go")
+	other := filepath.Join(dir, "pkgx", "b.go")
+	for _, path := range []string{moved, other} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("package x\n"), 0o644))
+		require.NoError(t, tracker.RecordRead(t.Context(), sess.ID, path))
+	}
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "pkg"), filepath.Join(dir, "lib")))
+	require.NoError(t, tracker.Move(t.Context(), sess.ID, filepath.Join(dir, "pkg"), filepath.Join(dir, "lib")))
+	status, err := tracker.Status(t.Context(), sess.ID, filepath.Join(dir, "lib", "a.go"))
+	require.NoError(t, err)
+	require.Equal(t, StatusCurrent, status)
+
+	records, err := tracker.ListBySession(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, filepath.Join(dir, "lib", "a.go"), records[0].Path)
+	require.Equal(t, other, records[1].Path)
+
+	require.NoError(t, tracker.Forget(t.Context(), sess.ID, filepath.Join(dir, "lib")))
+	records, err = tracker.ListBySession(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, other, records[0].Path)
+}
+
+func TestBase(t *testing.T) {
+	t.Parallel()
+
+	tracker, sessions := setup(t)
+	sess, err := sessions.Create(t.Context(), "session")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	_, ok, err := tracker.Base(t.Context(), sess.ID, path)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o644))
+	require.NoError(t, tracker.RecordRead(t.Context(), sess.ID, path))
+	require.NoError(t, os.WriteFile(path, []byte("two\n"), 0o644))
+
+	base, ok, err := tracker.Base(t.Context(), sess.ID, path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "one\n", base)
+
+	// Large files are tracked, but their content isn't kept.
+	large := strings.Repeat("x", maxBaseSize+1)
+	require.NoError(t, os.WriteFile(path, []byte(large), 0o644))
+	require.NoError(t, tracker.RecordRead(t.Context(), sess.ID, path))
+	status, err := tracker.Status(t.Context(), sess.ID, path)
+	require.NoError(t, err)
+	require.Equal(t, StatusCurrent, status)
+	_, ok, err = tracker.Base(t.Context(), sess.ID, path)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+// settleDelay is long enough for the workspace watcher to report a change.
+const settleDelay = 200 * time.Millisecond
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	tracker, sessions, workspace := setupWorkspace(t, root)
+	sess, err := sessions.Create(t.Context(), "session")
+	require.NoError(t, err)
+
+	path := filepath.Join(root, "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("one\n"), 0o644))
+	require.NoError(t, tracker.RecordRead(t.Context(), sess.ID, path))
+
+	events := tracker.Subscribe(t.Context())
+	go tracker.Watch(t.Context())
+	go workspace.Watch(t.Context())
+
+	// Writes before the watcher started are missed, so keep writing.
+	var stale StaleFile
+	require.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(path, []byte("two\n"), 0o644))
+		select {
+		case event := <-events:
+			stale = event.Payload
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 2*settleDelay)
+	require.Equal(t, StaleFile{SessionID: sess.ID, Path: path}, stale)
+
+	// The file is reported once until the session sees it again.
+	require.NoError(t, os.WriteFile(path, []byte("two\n"), 0o644))
+	time.Sleep(2 * settleDelay)
+	require.Empty(t, events)
+}
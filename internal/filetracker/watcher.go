@@ -0,0 +1,133 @@
+package filetracker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// StaleFile is a file that changed on disk since a session last read or
+// wrote it.
+type StaleFile struct {
+	SessionID string
+	Path      string
+}
+
+// tracked keeps the files the sessions read or wrote, to know which changes
+// of the workspace matter to them.
+type tracked struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]bool
+	// stale are the files already reported stale to a session, with the
+	// hash they had.
+	stale map[StaleFile]string
+	// writing counts the writes in progress of each file.
+	writing map[string]int
+}
+
+func newTracked() *tracked {
+	return &tracked{
+		sessions: make(map[string]map[string]bool),
+		stale:    make(map[StaleFile]string),
+		writing:  make(map[string]int),
+	}
+}
+
+// add tracks the file for the session, and forgets it was reported stale,
+// as the session just saw its content.
+func (t *tracked) add(sessionID, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sessions[path] == nil {
+		t.sessions[path] = make(map[string]bool)
+	}
+	t.sessions[path][sessionID] = true
+	delete(t.stale, StaleFile{SessionID: sessionID, Path: path})
+}
+
+// remove stops tracking the file for the session.
+func (t *tracked) remove(sessionID, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.sessions[path], sessionID)
+	if len(t.sessions[path]) == 0 {
+		delete(t.sessions, path)
+	}
+	delete(t.stale, StaleFile{SessionID: sessionID, Path: path})
+}
+
+// has reports whether a session tracks the file and no write of it is in
+// progress.
+func (t *tracked) has(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sessions[path]) > 0 && t.writing[path] == 0
+}
+
+func (s *service) Writing(path string) func() {
+	t := s.tracked
+	t.mu.Lock()
+	t.writing[path]++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.writing[path]--; t.writing[path] == 0 {
+				delete(t.writing, path)
+			}
+		})
+	}
+}
+
+func (s *service) Watch(ctx context.Context) error {
+	for event := range s.workspace.Subscribe(ctx) {
+		for _, change := range event.Payload.Changes {
+			if s.tracked.has(change.Path) {
+				s.checkStale(ctx, change.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// checkStale publishes a [StaleFile] for every session that last saw other
+// content than the file has now.
+func (s *service) checkStale(ctx context.Context, path string) {
+	if ctx.Err() != nil {
+		return
+	}
+	records, err := s.q.ListFileRecordsByPath(ctx, path)
+	if err != nil {
+		slog.Error("Error listing file records", "path", path, "error", err)
+		return
+	}
+	hash, err := HashFile(path)
+	if err != nil {
+		// The file was removed.
+		hash = ""
+	}
+
+	t := s.tracked
+	for _, record := range records {
+		if record.Hash == hash {
+			continue
+		}
+		file := StaleFile{SessionID: record.SessionID, Path: path}
+		t.mu.Lock()
+		reported, ok := t.stale[file]
+		if !t.sessions[path][record.SessionID] || ok && reported == hash {
+			t.mu.Unlock()
+			continue
+		}
+		t.stale[file] = hash
+		t.mu.Unlock()
+		s.Publish(pubsub.UpdatedEvent, file)
+	}
+}
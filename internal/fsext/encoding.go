@@ -0,0 +1,183 @@
+package fsext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding is the character encoding of a text file.
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF16LE
+	UTF16BE
+	// Latin1 is ISO-8859-1, assumed for files that aren't valid UTF-8.
+	Latin1
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	case Latin1:
+		return "ISO-8859-1"
+	default:
+		return "UTF-8"
+	}
+}
+
+func (e Encoding) encoding() encoding.Encoding {
+	switch e {
+	case UTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case UTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case Latin1:
+		return charmap.ISO8859_1
+	default:
+		return unicode.UTF8
+	}
+}
+
+// TextFormat is how the text of a file is stored.
+type TextFormat struct {
+	Encoding Encoding
+	// BOM is set when the file starts with a byte order mark.
+	BOM bool
+}
+
+// Plain reports whether the text is stored as UTF-8 without a byte order
+// mark, as most tools expect it.
+func (f TextFormat) Plain() bool {
+	return f.Encoding == UTF8 && !f.BOM
+}
+
+func (f TextFormat) String() string {
+	if f.BOM {
+		return f.Encoding.String() + " with BOM"
+	}
+	return f.Encoding.String()
+}
+
+// ErrBinary is returned when decoding content that isn't text.
+var ErrBinary = errors.New("content is binary")
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// DecodeText detects the format of the text and returns it as UTF-8, without
+// the byte order mark. Line endings are kept. It returns [ErrBinary] when the
+// data doesn't look like text.
+func DecodeText(data []byte) (string, TextFormat, error) {
+	var format TextFormat
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		format = TextFormat{Encoding: UTF8, BOM: true}
+		data = data[len(utf8BOM):]
+		if !utf8.Valid(data) {
+			return "", format, ErrBinary
+		}
+		return string(data), format, nil
+	case bytes.HasPrefix(data, utf16LEBOM):
+		format = TextFormat{Encoding: UTF16LE, BOM: true}
+		data = data[len(utf16LEBOM):]
+	case bytes.HasPrefix(data, utf16BEBOM):
+		format = TextFormat{Encoding: UTF16BE, BOM: true}
+		data = data[len(utf16BEBOM):]
+	case utf8.Valid(data) && bytes.IndexByte(data, 0) == -1:
+		return string(data), format, nil
+	default:
+		if encoding, ok := guessUTF16(data); ok {
+			format = TextFormat{Encoding: encoding}
+		} else if isLatin1Text(data) {
+			format = TextFormat{Encoding: Latin1}
+		} else {
+			return "", format, ErrBinary
+		}
+	}
+
+	decoded, err := format.Encoding.encoding().NewDecoder().Bytes(data)
+	if err != nil {
+		return "", format, ErrBinary
+	}
+	return string(decoded), format, nil
+}
+
+// Encode returns the text, in UTF-8, stored in the format.
+func (f TextFormat) Encode(text string) ([]byte, error) {
+	data, err := f.Encoding.encoding().NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("the content can't be encoded in %s: %w", f.Encoding, err)
+	}
+	if !f.BOM {
+		return data, nil
+	}
+	var bom []byte
+	switch f.Encoding {
+	case UTF16LE:
+		bom = utf16LEBOM
+	case UTF16BE:
+		bom = utf16BEBOM
+	default:
+		bom = utf8BOM
+	}
+	return append(bytes.Clone(bom), data...), nil
+}
+
+// ReadTextFile reads the file and decodes it with [DecodeText].
+func ReadTextFile(path string) (string, TextFormat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", TextFormat{}, err
+	}
+	return DecodeText(data)
+}
+
+// guessUTF16 detects UTF-16 text without a byte order mark, which is mostly
+// ASCII, so every other byte is zero.
+func guessUTF16(data []byte) (Encoding, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return UTF8, false
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(data) / 2
+	switch {
+	case oddZeros > pairs/2 && evenZeros == 0:
+		return UTF16LE, true
+	case evenZeros > pairs/2 && oddZeros == 0:
+		return UTF16BE, true
+	}
+	return UTF8, false
+}
+
+// isLatin1Text reports whether the data has no control characters other than
+// the usual whitespace and escape, as binary data has.
+func isLatin1Text(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\v' && b != 0x1b {
+			return false
+		}
+	}
+	return true
+}
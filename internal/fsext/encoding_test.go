@@ -0,0 +1,56 @@
+package fsext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeText(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name   string
+		data   []byte
+		text   string
+		format TextFormat
+	}{
+		{"utf-8", []byte("héllo\r\nworld\r\n"), "héllo\r\nworld\r\n", TextFormat{Encoding: UTF8}},
+		{"utf-8 with bom", []byte("\xef\xbb\xbfhéllo\n"), "héllo\n", TextFormat{Encoding: UTF8, BOM: true}},
+		{"utf-16le with bom", []byte("\xff\xfeh\x00\xe9\x00\n\x00"), "hé\n", TextFormat{Encoding: UTF16LE, BOM: true}},
+		{"utf-16be with bom", []byte("\xfe\xff\x00h\x00\xe9\x00\n"), "hé\n", TextFormat{Encoding: UTF16BE, BOM: true}},
+		{"utf-16le", []byte("h\x00i\x00\n\x00"), "hi\n", TextFormat{Encoding: UTF16LE}},
+		{"latin-1", []byte("caf\xe9\n"), "café\n", TextFormat{Encoding: Latin1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			text, format, err := DecodeText(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.text, text)
+			require.Equal(t, tt.format, format)
+
+			// Writing the text back in its format gives the same content.
+			data, err := format.Encode(text)
+			require.NoError(t, err)
+			require.Equal(t, tt.data, data)
+		})
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		t.Parallel()
+		for _, data := range [][]byte{
+			{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0x0d},
+			[]byte("text\x00with a nul"),
+			{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02},
+		} {
+			_, _, err := DecodeText(data)
+			require.ErrorIs(t, err, ErrBinary)
+		}
+	})
+
+	t.Run("unrepresentable", func(t *testing.T) {
+		t.Parallel()
+		_, err := TextFormat{Encoding: Latin1}.Encode("smile 🙂")
+		require.ErrorContains(t, err, "ISO-8859-1")
+	})
+}
@@ -81,6 +81,13 @@ func GlobWithDoubleStar(pattern, searchPath string, limit int) ([]string, bool,
 	// backslashes
 	pattern = filepath.ToSlash(pattern)
 
+	key := listingKey{glob: true, root: searchPath, pattern: pattern, limit: limit}
+	return cachedListing(key, func() ([]string, bool, error) {
+		return globWithDoubleStar(pattern, searchPath, limit)
+	})
+}
+
+func globWithDoubleStar(pattern, searchPath string, limit int) ([]string, bool, error) {
 	walker := NewFastGlobWalker(searchPath)
 	found := csync.NewSlice[FileInfo]()
 	conf := fastwalk.Config{
@@ -199,6 +206,15 @@ func HasPrefix(path, prefix string) bool {
 	return !strings.HasPrefix(rel, "..")
 }
 
+// UnderPath returns the path relative to root if it is root or under it.
+func UnderPath(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
 // ToUnixLineEndings converts Windows line endings (CRLF) to Unix line endings (LF).
 func ToUnixLineEndings(content string) (string, bool) {
 	if strings.Contains(content, "\r\n") {
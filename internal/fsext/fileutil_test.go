@@ -267,3 +267,22 @@ func TestGlobWithDoubleStar(t *testing.T) {
 		require.Equal(t, []string{oldestFile, middleDir, newestFile}, matches)
 	})
 }
+
+func TestUnderPath(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("/", "work", "pkg")
+	for path, want := range map[string]string{
+		root:                                ".",
+		filepath.Join(root, "a.go"):         "a.go",
+		filepath.Join(root, "sub", "b.go"):  filepath.Join("sub", "b.go"),
+		filepath.Join(root, "..", "pkgx"):   "",
+		filepath.Join("/", "work", "..pkg"): "",
+		filepath.Join("/", "work", "pkgx"):  "",
+		filepath.Join("/", "work"):          "",
+	} {
+		rel, ok := UnderPath(root, path)
+		require.Equal(t, want != "", ok, path)
+		require.Equal(t, want, rel, path)
+	}
+}
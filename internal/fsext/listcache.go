@@ -0,0 +1,115 @@
+package fsext
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// listingKey identifies the result of a [ListDirectory] or
+// [GlobWithDoubleStar] call.
+type listingKey struct {
+	glob    bool
+	root    string
+	pattern string
+	ignore  string
+	depth   int
+	limit   int
+}
+
+type listing struct {
+	// dir is the absolute path of the listed directory.
+	dir       string
+	paths     []string
+	truncated bool
+}
+
+// listings caches directory listings and glob results while a watcher keeps
+// them up to date, see [CacheListings].
+var listings struct {
+	mu      sync.Mutex
+	watched func(dir string) bool
+	entries map[listingKey]listing
+	// generation changes with every invalidation, so a listing computed
+	// while files changed isn't cached.
+	generation uint64
+}
+
+// CacheListings caches the directory listings and glob results of the
+// directories for which watched returns true, until [StopCachingListings] is
+// called. The caller must call [InvalidateListings] for every change in
+// them.
+func CacheListings(watched func(dir string) bool) {
+	listings.mu.Lock()
+	defer listings.mu.Unlock()
+	listings.watched = watched
+	listings.entries = make(map[listingKey]listing)
+	listings.generation++
+}
+
+// StopCachingListings stops caching and drops the cached listings.
+func StopCachingListings() {
+	listings.mu.Lock()
+	defer listings.mu.Unlock()
+	listings.watched = nil
+	listings.entries = nil
+	listings.generation++
+}
+
+// InvalidateListings drops the cached listings the file or directory at the
+// path could be part of. A change to an ignore file drops all of them, as it
+// can change what the listings of other directories contain.
+func InvalidateListings(path string) {
+	listings.mu.Lock()
+	defer listings.mu.Unlock()
+	listings.generation++
+	if base := filepath.Base(path); base == ".gitignore" || base == ".crushignore" {
+		clear(listings.entries)
+		return
+	}
+	for key, entry := range listings.entries {
+		if isUnder(entry.dir, path) {
+			delete(listings.entries, key)
+		}
+	}
+}
+
+// cachedListing returns the cached listing for the key, or computes and
+// caches it when the directory is watched.
+func cachedListing(key listingKey, compute func() ([]string, bool, error)) ([]string, bool, error) {
+	dir, err := filepath.Abs(key.root)
+	if err != nil {
+		return compute()
+	}
+
+	listings.mu.Lock()
+	if listings.watched == nil || !listings.watched(dir) {
+		listings.mu.Unlock()
+		return compute()
+	}
+	if entry, ok := listings.entries[key]; ok {
+		listings.mu.Unlock()
+		return slices.Clone(entry.paths), entry.truncated, nil
+	}
+	generation := listings.generation
+	listings.mu.Unlock()
+
+	paths, truncated, err := compute()
+	if err != nil {
+		return paths, truncated, err
+	}
+
+	listings.mu.Lock()
+	if listings.generation == generation && listings.entries != nil {
+		listings.entries[key] = listing{dir: dir, paths: slices.Clone(paths), truncated: truncated}
+	}
+	listings.mu.Unlock()
+	return paths, truncated, nil
+}
+
+// isUnder reports whether the path is dir or under it.
+func isUnder(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
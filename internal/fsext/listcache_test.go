@@ -0,0 +1,50 @@
+package fsext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedListings(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "a.go"), []byte("package a"), 0o644))
+
+	CacheListings(func(dir string) bool { return dir == tmp })
+	t.Cleanup(StopCachingListings)
+
+	list := func() []string {
+		files, _, err := ListDirectory(tmp, nil, -1, -1)
+		require.NoError(t, err)
+		return relPaths(t, files, tmp)
+	}
+	glob := func() []string {
+		files, _, err := GlobWithDoubleStar("*.go", tmp, 0)
+		require.NoError(t, err)
+		return relPaths(t, files, tmp)
+	}
+	require.Equal(t, []string{"a.go"}, list())
+	require.Equal(t, []string{"a.go"}, glob())
+
+	// Changes aren't seen until the listings are invalidated.
+	path := filepath.Join(tmp, "b.go")
+	require.NoError(t, os.WriteFile(path, []byte("package b"), 0o644))
+	require.Equal(t, []string{"a.go"}, list())
+	require.Equal(t, []string{"a.go"}, glob())
+
+	InvalidateListings(path)
+	require.ElementsMatch(t, []string{"a.go", "b.go"}, list())
+	require.ElementsMatch(t, []string{"a.go", "b.go"}, glob())
+
+	// Other directories aren't cached.
+	other := t.TempDir()
+	files, _, err := ListDirectory(other, nil, -1, -1)
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.NoError(t, os.WriteFile(filepath.Join(other, "c.go"), []byte("package c"), 0o644))
+	files, _, err = ListDirectory(other, nil, -1, -1)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -207,6 +207,18 @@ func (dl *directoryLister) getIgnore(path string) ignore.IgnoreParser {
 
 // ListDirectory lists files and directories in the specified path,
 func ListDirectory(initialPath string, ignorePatterns []string, depth, limit int) ([]string, bool, error) {
+	key := listingKey{
+		root:   initialPath,
+		ignore: strings.Join(ignorePatterns, "\x00"),
+		depth:  depth,
+		limit:  limit,
+	}
+	return cachedListing(key, func() ([]string, bool, error) {
+		return listDirectory(initialPath, ignorePatterns, depth, limit)
+	})
+}
+
+func listDirectory(initialPath string, ignorePatterns []string, depth, limit int) ([]string, bool, error) {
 	found := csync.NewSlice[string]()
 	dl := NewDirectoryLister(initialPath)
 
@@ -3,10 +3,12 @@ package history
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/google/uuid"
 )
@@ -19,16 +21,28 @@ type File struct {
 	ID        string
 	SessionID string
 	Path      string
+	// Content is the text of the file, see [Text].
 	Content   string
 	Version   int64
 	CreatedAt int64
 	UpdatedAt int64
 }
 
+// FileChange is a change of the content of a file.
+type FileChange struct {
+	Path       string
+	OldContent string
+	NewContent string
+}
+
 type Service interface {
 	pubsub.Subscriber[File]
 	Create(ctx context.Context, sessionID, path, content string) (File, error)
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
+	// RecordChanges records the new content of several files at once, either
+	// all of them or none. The old content is recorded first when it isn't
+	// the latest version of the file in the session.
+	RecordChanges(ctx context.Context, sessionID string, changes []FileChange) ([]File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
 	ListBySession(ctx context.Context, sessionID string) ([]File, error)
@@ -75,6 +89,7 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 }
 
 func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+	content = Text(content)
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
@@ -127,6 +142,73 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 	return file, err
 }
 
+// Text returns the content as the history keeps it: as UTF-8 text, the way
+// the edit tools show it, whatever the encoding of the file. Content that
+// isn't text is kept as it is.
+func Text(content string) string {
+	text, _, err := fsext.DecodeText([]byte(content))
+	if err != nil {
+		return content
+	}
+	return text
+}
+
+func (s *service) RecordChanges(ctx context.Context, sessionID string, changes []FileChange) ([]File, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	var created []File
+	for _, change := range changes {
+		change.OldContent, change.NewContent = Text(change.OldContent), Text(change.NewContent)
+		contents := []string{change.NewContent}
+		latest, err := qtx.GetFileByPathAndSession(ctx, db.GetFileByPathAndSessionParams{
+			Path:      change.Path,
+			SessionID: sessionID,
+		})
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			contents = []string{change.OldContent, change.NewContent}
+		case err != nil:
+			return nil, fmt.Errorf("failed to get file history: %w", err)
+		case latest.Content != change.OldContent:
+			contents = []string{change.OldContent, change.NewContent}
+		}
+		for _, content := range contents {
+			version := int64(InitialVersion)
+			files, err := qtx.ListFilesByPath(ctx, change.Path)
+			if err != nil {
+				return nil, err
+			}
+			if len(files) > 0 {
+				version = files[0].Version + 1
+			}
+			dbFile, err := qtx.CreateFile(ctx, db.CreateFileParams{
+				ID:        uuid.New().String(),
+				SessionID: sessionID,
+				Path:      change.Path,
+				Content:   content,
+				Version:   version,
+			})
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, s.fromDBItem(dbFile))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, file := range created {
+		s.Publish(pubsub.CreatedEvent, file)
+	}
+	return created, nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (File, error) {
 	dbFile, err := s.q.GetFile(ctx, id)
 	if err != nil {
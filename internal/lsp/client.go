@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,15 +17,17 @@ import (
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/home"
-	powernap "github.com/charmbracelet/x/powernap/pkg/lsp"
 	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
 	"github.com/charmbracelet/x/powernap/pkg/transport"
 )
 
 type Client struct {
-	client *powernap.Client
+	client *server
 	name   string
 
+	// Root directory of the workspace the server was started for
+	workDir string
+
 	// File types this LSP server handles (e.g., .go, .rs, .py)
 	fileTypes []string
 
@@ -40,14 +43,23 @@ type Client struct {
 	// Files are currently opened by the LSP
 	openFiles *csync.Map[string, *OpenFileInfo]
 
+	// File watchers registered by the server, by registration ID
+	fileWatchers *csync.Map[string, []protocol.FileSystemWatcher]
+
 	// Server state
 	serverState atomic.Value
+
+	// Commands run one at a time, their workspace edits go to editHandler.
+	commandMu   sync.Mutex
+	editMu      sync.Mutex
+	editHandler EditHandler
 }
 
-// New creates a new LSP client using the powernap implementation.
-func New(ctx context.Context, name string, config config.LSPConfig, resolver config.VariableResolver) (*Client, error) {
+// New starts the LSP server and creates a client for it, for the workspace
+// at workDir.
+func New(ctx context.Context, name, workDir string, config config.LSPConfig, resolver config.VariableResolver) (*Client, error) {
 	// Convert working directory to file URI
-	workDir, err := os.Getwd()
+	workDir, err := filepath.Abs(workDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
@@ -59,39 +71,35 @@ func New(ctx context.Context, name string, config config.LSPConfig, resolver con
 		return nil, fmt.Errorf("invalid lsp command: %w", err)
 	}
 
-	// Create powernap client config
-	clientConfig := powernap.ClientConfig{
-		Command: home.Long(command),
-		Args:    config.Args,
-		RootURI: rootURI,
-		Environment: func() map[string]string {
-			env := make(map[string]string)
-			maps.Copy(env, config.Env)
-			return env
-		}(),
-		Settings:    config.Options,
-		InitOptions: config.InitOptions,
-		WorkspaceFolders: []protocol.WorkspaceFolder{
+	env := make(map[string]string)
+	maps.Copy(env, config.Env)
+	server, err := startServer(serverConfig{
+		command:  home.Long(command),
+		args:     config.Args,
+		env:      env,
+		rootURI:  rootURI,
+		settings: config.Options,
+		workspaceFolders: []protocol.WorkspaceFolder{
 			{
 				URI:  rootURI,
 				Name: filepath.Base(workDir),
 			},
 		},
-	}
-
-	// Create the powernap client
-	powernapClient, err := powernap.NewClient(clientConfig)
+		initOptions: config.InitOptions,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create lsp client: %w", err)
 	}
 
 	client := &Client{
-		client:      powernapClient,
-		name:        name,
-		fileTypes:   config.FileTypes,
-		diagnostics: csync.NewVersionedMap[protocol.DocumentURI, []protocol.Diagnostic](),
-		openFiles:   csync.NewMap[string, *OpenFileInfo](),
-		config:      config,
+		client:       server,
+		name:         name,
+		workDir:      workDir,
+		fileTypes:    config.FileTypes,
+		diagnostics:  csync.NewVersionedMap[protocol.DocumentURI, []protocol.Diagnostic](),
+		openFiles:    csync.NewMap[string, *OpenFileInfo](),
+		fileWatchers: csync.NewMap[string, []protocol.FileSystemWatcher](),
+		config:       config,
 	}
 
 	// Initialize server state
@@ -102,7 +110,7 @@ func New(ctx context.Context, name string, config config.LSPConfig, resolver con
 
 // Initialize initializes the LSP client and returns the server capabilities.
 func (c *Client) Initialize(ctx context.Context, workspaceDir string) (*protocol.InitializeResult, error) {
-	if err := c.client.Initialize(ctx, false); err != nil {
+	if err := c.client.Initialize(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize the lsp client: %w", err)
 	}
 
@@ -126,9 +134,16 @@ func (c *Client) Initialize(ctx context.Context, workspaceDir string) (*protocol
 		Capabilities: protocolCaps,
 	}
 
-	c.RegisterServerRequestHandler("workspace/applyEdit", HandleApplyEdit)
+	c.RegisterServerRequestHandler("workspace/applyEdit", func(ctx context.Context, _ string, params json.RawMessage) (any, error) {
+		return HandleApplyEdit(ctx, c, params)
+	})
 	c.RegisterServerRequestHandler("workspace/configuration", HandleWorkspaceConfiguration)
-	c.RegisterServerRequestHandler("client/registerCapability", HandleRegisterCapability)
+	c.RegisterServerRequestHandler("client/registerCapability", func(ctx context.Context, _ string, params json.RawMessage) (any, error) {
+		return HandleRegisterCapability(ctx, c, params)
+	})
+	c.RegisterServerRequestHandler("client/unregisterCapability", func(ctx context.Context, _ string, params json.RawMessage) (any, error) {
+		return HandleUnregisterCapability(ctx, c, params)
+	})
 	c.RegisterNotificationHandler("window/showMessage", HandleServerMessage)
 	c.RegisterNotificationHandler("textDocument/publishDiagnostics", func(_ context.Context, _ string, params json.RawMessage) {
 		HandleDiagnostics(c, params)
@@ -161,8 +176,21 @@ const (
 	StateReady
 	StateError
 	StateDisabled
+	// StateStopped is a server that was stopped by the user.
+	StateStopped
+	// StateIdle is a server that isn't running but starts as soon as a file
+	// it handles is used, because it's lazily started or was shut down after
+	// being idle.
+	StateIdle
 )
 
+// Disconnected returns a channel that is closed when the connection to the
+// server is lost, either because the client was closed or because the server
+// exited.
+func (c *Client) Disconnected() <-chan struct{} {
+	return c.client.DisconnectNotify()
+}
+
 // GetServerState returns the current state of the LSP server
 func (c *Client) GetServerState() ServerState {
 	if val := c.serverState.Load(); val != nil {
@@ -244,18 +272,27 @@ func (c *Client) HandlesFile(path string) bool {
 		return true
 	}
 
+	if MatchesFileTypes(c.fileTypes, path) {
+		slog.Debug("handles file", "name", c.name, "file", path)
+		return true
+	}
+	slog.Debug("doesn't handle file", "name", c.name, "file", path)
+	return false
+}
+
+// MatchesFileTypes reports whether the file has one of the file types, which
+// are extensions given with or without the leading dot.
+func MatchesFileTypes(fileTypes []string, path string) bool {
 	name := strings.ToLower(filepath.Base(path))
-	for _, filetype := range c.fileTypes {
+	for _, filetype := range fileTypes {
 		suffix := strings.ToLower(filetype)
 		if !strings.HasPrefix(suffix, ".") {
 			suffix = "." + suffix
 		}
 		if strings.HasSuffix(name, suffix) {
-			slog.Debug("handles file", "name", c.name, "file", name, "filetype", filetype)
 			return true
 		}
 	}
-	slog.Debug("doesn't handle file", "name", c.name, "file", name)
 	return false
 }
 
@@ -406,14 +443,9 @@ func (c *Client) DidChangeWatchedFiles(ctx context.Context, params protocol.DidC
 
 // openKeyConfigFiles opens important configuration files that help initialize the server.
 func (c *Client) openKeyConfigFiles(ctx context.Context) {
-	wd, err := os.Getwd()
-	if err != nil {
-		return
-	}
-
 	// Try to open each file, ignoring errors if they don't exist
 	for _, file := range c.config.RootMarkers {
-		file = filepath.Join(wd, file)
+		file = filepath.Join(c.workDir, file)
 		if _, err := os.Stat(file); err == nil {
 			// File exists, try to open it
 			if err := c.OpenFile(ctx, file); err != nil {
@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// ErrCodeActionsNotSupported is returned when the server has no code
+// actions.
+var ErrCodeActionsNotSupported = errors.New("the LSP server does not support code actions")
+
+// EditHandler applies a workspace edit the server asked for. It returns an
+// error to reject the edit.
+type EditHandler func(edit protocol.WorkspaceEdit) error
+
+// CodeActions returns the code actions available for the range of the file.
+// diagnostics are the diagnostics the actions should fix, and only limits
+// the kinds of the returned actions. Bare commands are returned as actions
+// with only a command.
+func (c *Client) CodeActions(ctx context.Context, filepath string, rng protocol.Range, diagnostics []protocol.Diagnostic, only []protocol.CodeActionKind) ([]protocol.CodeAction, error) {
+	if supported, _ := codeActionSupport(c.client.GetCapabilities().CodeActionProvider); !supported {
+		return nil, ErrCodeActionsNotSupported
+	}
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return nil, err
+	}
+	if diagnostics == nil {
+		diagnostics = []protocol.Diagnostic{}
+	}
+	params := protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filepath)},
+		Range:        rng,
+		Context: protocol.CodeActionContext{
+			Diagnostics: diagnostics,
+			Only:        only,
+		},
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, "textDocument/codeAction", params, &result); err != nil {
+		return nil, fmt.Errorf("code action request failed: %w", err)
+	}
+	return parseCodeActions(result)
+}
+
+// ResolveCodeAction fills in the edit of a code action the server left out
+// to compute lazily. Actions that need no resolving are returned as is.
+func (c *Client) ResolveCodeAction(ctx context.Context, action protocol.CodeAction) (protocol.CodeAction, error) {
+	if action.Edit != nil || action.Command != nil {
+		return action, nil
+	}
+	if _, resolve := codeActionSupport(c.client.GetCapabilities().CodeActionProvider); !resolve {
+		return action, nil
+	}
+	var resolved protocol.CodeAction
+	if err := c.client.Call(ctx, "codeAction/resolve", action, &resolved); err != nil {
+		return action, fmt.Errorf("code action resolve request failed: %w", err)
+	}
+	return resolved, nil
+}
+
+// ExecuteCommand runs a command on the server. Workspace edits the server
+// asks for while running it go to onEdit instead of being applied directly.
+// Commands run one at a time, so edits can't be attributed to the wrong
+// command.
+func (c *Client) ExecuteCommand(ctx context.Context, command protocol.Command, onEdit EditHandler) (json.RawMessage, error) {
+	c.commandMu.Lock()
+	defer c.commandMu.Unlock()
+
+	c.setEditHandler(onEdit)
+	defer c.setEditHandler(nil)
+
+	params := protocol.ExecuteCommandParams{
+		Command:   command.Command,
+		Arguments: command.Arguments,
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, "workspace/executeCommand", params, &result); err != nil {
+		return nil, fmt.Errorf("execute command request failed: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) setEditHandler(handler EditHandler) {
+	c.editMu.Lock()
+	defer c.editMu.Unlock()
+	c.editHandler = handler
+}
+
+func (c *Client) getEditHandler() EditHandler {
+	c.editMu.Lock()
+	defer c.editMu.Unlock()
+	return c.editHandler
+}
+
+// codeActionSupport reads the codeActionProvider capability, which is
+// either a boolean or code action options.
+func codeActionSupport(provider any) (supported, resolve bool) {
+	switch p := provider.(type) {
+	case bool:
+		return p, false
+	case map[string]any:
+		resolve, _ := p["resolveProvider"].(bool)
+		return true, resolve
+	case protocol.CodeActionOptions:
+		return true, p.ResolveProvider
+	case *protocol.CodeActionOptions:
+		return p != nil, p != nil && p.ResolveProvider
+	}
+	return false, false
+}
+
+// parseCodeActions decodes a list of code actions and commands.
+func parseCodeActions(raw json.RawMessage) ([]protocol.CodeAction, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid code actions: %w", err)
+	}
+
+	actions := make([]protocol.CodeAction, 0, len(items))
+	for _, item := range items {
+		var probe struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(item, &probe); err != nil {
+			return nil, fmt.Errorf("invalid code action: %w", err)
+		}
+		if cmd := strings.TrimSpace(string(probe.Command)); strings.HasPrefix(cmd, `"`) {
+			var command protocol.Command
+			if err := json.Unmarshal(item, &command); err != nil {
+				return nil, fmt.Errorf("invalid command: %w", err)
+			}
+			actions = append(actions, protocol.CodeAction{Title: command.Title, Command: &command})
+			continue
+		}
+		var action protocol.CodeAction
+		if err := json.Unmarshal(item, &action); err != nil {
+			return nil, fmt.Errorf("invalid code action: %w", err)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCodeActions(t *testing.T) {
+	t.Parallel()
+
+	raw := `[
+		{"title":"Add import: \"fmt\"","kind":"quickfix","isPreferred":true,"edit":{"changes":{"file:///tmp/a.go":[]}}},
+		{"title":"Organize Imports","command":"gopls.organize_imports","arguments":[{"uri":"file:///tmp/a.go"}]},
+		{"title":"Extract function","kind":"refactor.extract","command":{"title":"Extract","command":"gopls.extract"}}
+	]`
+	actions, err := parseCodeActions(json.RawMessage(raw))
+	require.NoError(t, err)
+	require.Len(t, actions, 3)
+
+	require.Equal(t, protocol.CodeActionKind("quickfix"), actions[0].Kind)
+	require.True(t, actions[0].IsPreferred)
+	require.NotNil(t, actions[0].Edit)
+	require.Nil(t, actions[0].Command)
+
+	require.Equal(t, "Organize Imports", actions[1].Title)
+	require.NotNil(t, actions[1].Command)
+	require.Equal(t, "gopls.organize_imports", actions[1].Command.Command)
+	require.Len(t, actions[1].Command.Arguments, 1)
+
+	require.Equal(t, "gopls.extract", actions[2].Command.Command)
+
+	actions, err = parseCodeActions(json.RawMessage("null"))
+	require.NoError(t, err)
+	require.Empty(t, actions)
+}
+
+func TestCodeActionSupport(t *testing.T) {
+	t.Parallel()
+
+	supported, resolve := codeActionSupport(nil)
+	require.False(t, supported)
+	require.False(t, resolve)
+
+	supported, resolve = codeActionSupport(true)
+	require.True(t, supported)
+	require.False(t, resolve)
+
+	supported, resolve = codeActionSupport(map[string]any{"resolveProvider": true})
+	require.True(t, supported)
+	require.True(t, resolve)
+}
+
+func TestHandleApplyEdit(t *testing.T) {
+	t.Parallel()
+
+	newParams := func(t *testing.T, path string) json.RawMessage {
+		params, err := json.Marshal(protocol.ApplyWorkspaceEditParams{
+			Edit: protocol.WorkspaceEdit{
+				Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+					protocol.URIFromPath(path): {{
+						Range:   protocol.Range{End: protocol.Position{Character: 3}},
+						NewText: "bar",
+					}},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return params
+	}
+
+	t.Run("applies directly", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "a.go")
+		require.NoError(t, os.WriteFile(path, []byte("foo\n"), 0o644))
+		client := &Client{openFiles: csync.NewMap[string, *OpenFileInfo]()}
+
+		result, err := HandleApplyEdit(context.Background(), client, newParams(t, path))
+		require.NoError(t, err)
+		require.Equal(t, protocol.ApplyWorkspaceEditResult{Applied: true}, result)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "bar\n", string(content))
+	})
+
+	t.Run("goes to the command handler", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "a.go")
+		require.NoError(t, os.WriteFile(path, []byte("foo\n"), 0o644))
+		client := &Client{openFiles: csync.NewMap[string, *OpenFileInfo]()}
+
+		var got []protocol.WorkspaceEdit
+		client.setEditHandler(func(edit protocol.WorkspaceEdit) error {
+			got = append(got, edit)
+			return nil
+		})
+		result, err := HandleApplyEdit(context.Background(), client, newParams(t, path))
+		require.NoError(t, err)
+		require.Equal(t, protocol.ApplyWorkspaceEditResult{Applied: true}, result)
+		require.Len(t, got, 1)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "foo\n", string(content))
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		t.Parallel()
+		client := &Client{openFiles: csync.NewMap[string, *OpenFileInfo]()}
+		result, err := HandleApplyEdit(context.Background(), client, newParams(t, filepath.Join(t.TempDir(), "missing.go")))
+		require.NoError(t, err)
+		require.False(t, result.(protocol.ApplyWorkspaceEditResult).Applied)
+	})
+}
@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// fileOperations returns the file operations the server is interested in.
+func (c *Client) fileOperations() protocol.FileOperationOptions {
+	if c.client == nil {
+		return protocol.FileOperationOptions{}
+	}
+	workspace := c.client.GetCapabilities().Workspace
+	if workspace == nil || workspace.FileOperations == nil {
+		return protocol.FileOperationOptions{}
+	}
+	return *workspace.FileOperations
+}
+
+// WillRenameFile asks the server for the edit to make before a file or
+// directory is moved, e.g. to update the imports referring to it. The edit
+// is not applied. It is empty when the server isn't interested.
+func (c *Client) WillRenameFile(ctx context.Context, oldPath, newPath string) (protocol.WorkspaceEdit, error) {
+	var edit protocol.WorkspaceEdit
+	if c.fileOperations().WillRename == nil {
+		return edit, nil
+	}
+	if err := c.client.Call(ctx, "workspace/willRenameFiles", renameFilesParams(oldPath, newPath), &edit); err != nil {
+		return protocol.WorkspaceEdit{}, fmt.Errorf("will rename files request failed: %w", err)
+	}
+	return edit, nil
+}
+
+// DidRenameFile tells the server a file or directory was moved. The
+// documents open under the old path are closed.
+func (c *Client) DidRenameFile(ctx context.Context, oldPath, newPath string) error {
+	c.closeFilesUnder(ctx, oldPath)
+	if c.fileOperations().DidRename == nil {
+		return nil
+	}
+	return c.client.Notify(ctx, "workspace/didRenameFiles", renameFilesParams(oldPath, newPath))
+}
+
+// WillDeleteFile asks the server for the edit to make before a file or
+// directory is deleted. The edit is not applied. It is empty when the server
+// isn't interested.
+func (c *Client) WillDeleteFile(ctx context.Context, path string) (protocol.WorkspaceEdit, error) {
+	var edit protocol.WorkspaceEdit
+	if c.fileOperations().WillDelete == nil {
+		return edit, nil
+	}
+	if err := c.client.Call(ctx, "workspace/willDeleteFiles", deleteFilesParams(path), &edit); err != nil {
+		return protocol.WorkspaceEdit{}, fmt.Errorf("will delete files request failed: %w", err)
+	}
+	return edit, nil
+}
+
+// DidDeleteFile tells the server a file or directory was deleted. The
+// documents open under the path are closed.
+func (c *Client) DidDeleteFile(ctx context.Context, path string) error {
+	c.closeFilesUnder(ctx, path)
+	if c.fileOperations().DidDelete == nil {
+		return nil
+	}
+	return c.client.Notify(ctx, "workspace/didDeleteFiles", deleteFilesParams(path))
+}
+
+// DidCreateFile tells the server a file or directory was created.
+func (c *Client) DidCreateFile(ctx context.Context, path string) error {
+	if c.fileOperations().DidCreate == nil {
+		return nil
+	}
+	return c.client.Notify(ctx, "workspace/didCreateFiles", protocol.CreateFilesParams{
+		Files: []protocol.FileCreate{{URI: string(protocol.URIFromPath(path))}},
+	})
+}
+
+// closeFilesUnder closes the open documents at the path or under it, and
+// forgets their diagnostics.
+func (c *Client) closeFilesUnder(ctx context.Context, path string) {
+	uri := string(protocol.URIFromPath(path))
+	for openURI := range c.openFiles.Seq2() {
+		if openURI != uri && !strings.HasPrefix(openURI, uri+"/") {
+			continue
+		}
+		_ = c.client.NotifyDidCloseTextDocument(ctx, openURI)
+		c.openFiles.Del(openURI)
+		c.ClearDiagnosticsForURI(protocol.DocumentURI(openURI))
+	}
+}
+
+func renameFilesParams(oldPath, newPath string) protocol.RenameFilesParams {
+	return protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: string(protocol.URIFromPath(oldPath)),
+			NewURI: string(protocol.URIFromPath(newPath)),
+		}},
+	}
+}
+
+func deleteFilesParams(path string) protocol.DeleteFilesParams {
+	return protocol.DeleteFilesParams{
+		Files: []protocol.FileDelete{{URI: string(protocol.URIFromPath(path))}},
+	}
+}
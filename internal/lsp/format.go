@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp/util"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// ErrFormattingNotSupported is returned when the server can't format
+// documents.
+var ErrFormattingNotSupported = errors.New("the LSP server does not support formatting")
+
+// Format returns the content of the file formatted by the server. The
+// server is told about the content on disk first, so it formats what was
+// last written.
+func (c *Client) Format(ctx context.Context, filepath string) (string, error) {
+	if !formattingSupport(c.client.GetCapabilities().DocumentFormattingProvider) {
+		return "", ErrFormattingNotSupported
+	}
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return "", err
+	}
+	if err := c.NotifyChange(ctx, filepath); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+	content := string(data)
+
+	params := protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filepath)},
+		Options:      formattingOptions(content),
+	}
+	var edits []protocol.TextEdit
+	if err := c.client.Call(ctx, "textDocument/formatting", params, &edits); err != nil {
+		return "", fmt.Errorf("formatting request failed: %w", err)
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+	formatted, err := util.ApplyTextEditsToContent([]byte(content), edits)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply formatting: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// formattingSupport reads the documentFormattingProvider capability, which
+// is either a boolean or formatting options.
+func formattingSupport(provider *protocol.Or_ServerCapabilities_documentFormattingProvider) bool {
+	if provider == nil {
+		return false
+	}
+	switch p := provider.Value.(type) {
+	case bool:
+		return p
+	case nil:
+		return false
+	}
+	return true
+}
+
+// formattingOptions guesses the indentation of the content, servers that
+// don't have their own style use it.
+func formattingOptions(content string) protocol.FormattingOptions {
+	options := protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}
+	for line := range strings.SplitSeq(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			options.InsertSpaces = false
+			return options
+		case strings.HasPrefix(line, "  "):
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if indent == 2 || indent == 4 || indent == 8 {
+				options.TabSize = uint32(indent)
+			}
+			return options
+		}
+	}
+	return options
+}
@@ -0,0 +1,26 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormattingSupport(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, formattingSupport(nil))
+	require.False(t, formattingSupport(&protocol.Or_ServerCapabilities_documentFormattingProvider{}))
+	require.False(t, formattingSupport(&protocol.Or_ServerCapabilities_documentFormattingProvider{Value: false}))
+	require.True(t, formattingSupport(&protocol.Or_ServerCapabilities_documentFormattingProvider{Value: true}))
+	require.True(t, formattingSupport(&protocol.Or_ServerCapabilities_documentFormattingProvider{Value: protocol.DocumentFormattingOptions{}}))
+}
+
+func TestFormattingOptions(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, protocol.FormattingOptions{TabSize: 4}, formattingOptions("func main() {\n\tprintln()\n}\n"))
+	require.Equal(t, protocol.FormattingOptions{TabSize: 2, InsertSpaces: true}, formattingOptions("a:\n  b: 1\n"))
+	require.Equal(t, protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, formattingOptions("x = 1\n"))
+}
@@ -16,7 +16,7 @@ func HandleWorkspaceConfiguration(_ context.Context, _ string, params json.RawMe
 }
 
 // HandleRegisterCapability handles capability registration requests
-func HandleRegisterCapability(_ context.Context, _ string, params json.RawMessage) (any, error) {
+func HandleRegisterCapability(_ context.Context, client *Client, params json.RawMessage) (any, error) {
 	var registerParams protocol.RegistrationParams
 	if err := json.Unmarshal(params, &registerParams); err != nil {
 		slog.Error("Error unmarshaling registration params", "error", err)
@@ -38,25 +38,65 @@ func HandleRegisterCapability(_ context.Context, _ string, params json.RawMessag
 				continue
 			}
 			// Store the file watchers registrations
+			client.fileWatchers.Set(reg.ID, options.Watchers)
 			notifyFileWatchRegistration(reg.ID, options.Watchers)
 		}
 	}
 	return nil, nil
 }
 
-// HandleApplyEdit handles workspace edit requests
-func HandleApplyEdit(_ context.Context, _ string, params json.RawMessage) (any, error) {
+// HandleUnregisterCapability handles capability unregistration requests
+func HandleUnregisterCapability(_ context.Context, client *Client, params json.RawMessage) (any, error) {
+	var unregisterParams protocol.UnregistrationParams
+	if err := json.Unmarshal(params, &unregisterParams); err != nil {
+		slog.Error("Error unmarshaling unregistration params", "error", err)
+		return nil, err
+	}
+
+	for _, unreg := range unregisterParams.Unregisterations {
+		if unreg.Method == "workspace/didChangeWatchedFiles" {
+			client.fileWatchers.Del(unreg.ID)
+		}
+	}
+	return nil, nil
+}
+
+// HandleApplyEdit handles workspace edit requests. Edits made while running
+// a command go to the handler of the command, other edits are applied
+// directly. Open files are synced with the server afterwards.
+func HandleApplyEdit(ctx context.Context, client *Client, params json.RawMessage) (any, error) {
 	var edit protocol.ApplyWorkspaceEditParams
 	if err := json.Unmarshal(params, &edit); err != nil {
 		return nil, err
 	}
 
-	err := util.ApplyWorkspaceEdit(edit.Edit)
+	// Find the files before applying the edit, renames move them.
+	changes, err := util.PreviewWorkspaceEdit(edit.Edit)
+	if err != nil {
+		slog.Error("Error applying workspace edit", "error", err)
+		return protocol.ApplyWorkspaceEditResult{Applied: false, FailureReason: err.Error()}, nil
+	}
+
+	if handler := client.getEditHandler(); handler != nil {
+		err = handler(edit.Edit)
+	} else {
+		err = util.ApplyWorkspaceEdit(edit.Edit)
+	}
 	if err != nil {
 		slog.Error("Error applying workspace edit", "error", err)
 		return protocol.ApplyWorkspaceEditResult{Applied: false, FailureReason: err.Error()}, nil
 	}
 
+	for _, change := range changes {
+		path := change.FinalPath()
+		if change.Deleted || !client.IsFileOpen(path) {
+			continue
+		}
+		if err := client.NotifyChange(ctx, path); err != nil {
+			slog.Warn("Error notifying change after workspace edit", "path", path, "error", err)
+		}
+	}
+
 	return protocol.ApplyWorkspaceEditResult{Applied: true}, nil
 }
 
@@ -0,0 +1,158 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+var (
+	// ErrCallHierarchyNotSupported is returned when the server has no call
+	// hierarchy.
+	ErrCallHierarchyNotSupported = errors.New("the LSP server does not support call hierarchies")
+	// ErrTypeHierarchyNotSupported is returned when the server has no type
+	// hierarchy.
+	ErrTypeHierarchyNotSupported = errors.New("the LSP server does not support type hierarchies")
+)
+
+// HierarchyItem is an item of a call or type hierarchy, which share the same
+// shape. Items are sent back to the server as received, including the data
+// it attached to them.
+type HierarchyItem struct {
+	Name           string               `json:"name"`
+	Kind           protocol.SymbolKind  `json:"kind"`
+	Tags           []protocol.SymbolTag `json:"tags,omitempty"`
+	Detail         string               `json:"detail,omitempty"`
+	URI            protocol.DocumentURI `json:"uri"`
+	Range          protocol.Range       `json:"range"`
+	SelectionRange protocol.Range       `json:"selectionRange"`
+	Data           json.RawMessage      `json:"data,omitempty"`
+}
+
+// HierarchyCall is a call between an item of a call hierarchy and the item
+// it was asked for.
+type HierarchyCall struct {
+	Item HierarchyItem
+	// Ranges are where the calls are: in Item for incoming calls, in the
+	// item the calls were asked for for outgoing calls.
+	Ranges []protocol.Range
+}
+
+// PrepareCallHierarchy returns the call hierarchy items of the symbol at the
+// given position.
+func (c *Client) PrepareCallHierarchy(ctx context.Context, filepath string, line, character int) ([]HierarchyItem, error) {
+	if !hierarchySupport(c.client.GetCapabilities().CallHierarchyProvider) {
+		return nil, ErrCallHierarchyNotSupported
+	}
+	return c.prepareHierarchy(ctx, "textDocument/prepareCallHierarchy", filepath, line, character)
+}
+
+// PrepareTypeHierarchy returns the type hierarchy items of the symbol at the
+// given position.
+func (c *Client) PrepareTypeHierarchy(ctx context.Context, filepath string, line, character int) ([]HierarchyItem, error) {
+	if !hierarchySupport((*protocol.Or_ServerCapabilities_callHierarchyProvider)(c.client.GetCapabilities().TypeHierarchyProvider)) {
+		return nil, ErrTypeHierarchyNotSupported
+	}
+	return c.prepareHierarchy(ctx, "textDocument/prepareTypeHierarchy", filepath, line, character)
+}
+
+// IncomingCalls returns the callers of a call hierarchy item.
+func (c *Client) IncomingCalls(ctx context.Context, item HierarchyItem) ([]HierarchyCall, error) {
+	var result []struct {
+		From       HierarchyItem    `json:"from"`
+		FromRanges []protocol.Range `json:"fromRanges"`
+	}
+	if err := c.client.Call(ctx, "callHierarchy/incomingCalls", hierarchyParams{item}, &result); err != nil {
+		return nil, fmt.Errorf("incoming calls request failed: %w", err)
+	}
+	calls := make([]HierarchyCall, 0, len(result))
+	for _, call := range result {
+		calls = append(calls, HierarchyCall{Item: call.From, Ranges: call.FromRanges})
+	}
+	return calls, nil
+}
+
+// OutgoingCalls returns the items a call hierarchy item calls.
+func (c *Client) OutgoingCalls(ctx context.Context, item HierarchyItem) ([]HierarchyCall, error) {
+	var result []struct {
+		To         HierarchyItem    `json:"to"`
+		FromRanges []protocol.Range `json:"fromRanges"`
+	}
+	if err := c.client.Call(ctx, "callHierarchy/outgoingCalls", hierarchyParams{item}, &result); err != nil {
+		return nil, fmt.Errorf("outgoing calls request failed: %w", err)
+	}
+	calls := make([]HierarchyCall, 0, len(result))
+	for _, call := range result {
+		calls = append(calls, HierarchyCall{Item: call.To, Ranges: call.FromRanges})
+	}
+	return calls, nil
+}
+
+// Supertypes returns the direct supertypes of a type hierarchy item.
+func (c *Client) Supertypes(ctx context.Context, item HierarchyItem) ([]HierarchyItem, error) {
+	var result []HierarchyItem
+	if err := c.client.Call(ctx, "typeHierarchy/supertypes", hierarchyParams{item}, &result); err != nil {
+		return nil, fmt.Errorf("supertypes request failed: %w", err)
+	}
+	return result, nil
+}
+
+// Subtypes returns the direct subtypes of a type hierarchy item.
+func (c *Client) Subtypes(ctx context.Context, item HierarchyItem) ([]HierarchyItem, error) {
+	var result []HierarchyItem
+	if err := c.client.Call(ctx, "typeHierarchy/subtypes", hierarchyParams{item}, &result); err != nil {
+		return nil, fmt.Errorf("subtypes request failed: %w", err)
+	}
+	return result, nil
+}
+
+// hierarchyParams are the parameters of the requests following a hierarchy
+// from an item.
+type hierarchyParams struct {
+	Item HierarchyItem `json:"item"`
+}
+
+func (c *Client) prepareHierarchy(ctx context.Context, method, filepath string, line, character int) ([]HierarchyItem, error) {
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return nil, err
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, method, positionParams(filepath, line, character), &result); err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", method, err)
+	}
+	return parseHierarchyItems(result)
+}
+
+// parseHierarchyItems decodes the result of a prepare hierarchy request,
+// which is a list of items or null.
+func parseHierarchyItems(raw json.RawMessage) ([]HierarchyItem, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var items []HierarchyItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid hierarchy items: %w", err)
+	}
+	return items, nil
+}
+
+// hierarchySupport reads the callHierarchyProvider or typeHierarchyProvider
+// capability, which is either a boolean or hierarchy options. Both have the
+// same underlying type, the type hierarchy one is converted to read it.
+func hierarchySupport(provider *protocol.Or_ServerCapabilities_callHierarchyProvider) bool {
+	if provider == nil {
+		return false
+	}
+	switch p := provider.Value.(type) {
+	case bool:
+		return p
+	case nil:
+		return false
+	}
+	return true
+}
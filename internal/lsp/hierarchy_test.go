@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHierarchyItems(t *testing.T) {
+	t.Parallel()
+
+	raw := `[{
+		"name": "Run",
+		"kind": 12,
+		"detail": "internal/app",
+		"uri": "file:///tmp/app.go",
+		"range": {"start": {"line": 9, "character": 0}, "end": {"line": 20, "character": 1}},
+		"selectionRange": {"start": {"line": 9, "character": 5}, "end": {"line": 9, "character": 8}},
+		"data": {"id": 12345678901234567890}
+	}]`
+	items, err := parseHierarchyItems(json.RawMessage(raw))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "Run", items[0].Name)
+	require.Equal(t, protocol.Function, items[0].Kind)
+	require.Equal(t, uint32(5), items[0].SelectionRange.Start.Character)
+
+	// The data is sent back untouched.
+	params, err := json.Marshal(hierarchyParams{items[0]})
+	require.NoError(t, err)
+	require.Contains(t, string(params), `"data":{"id":12345678901234567890}`)
+
+	items, err = parseHierarchyItems(json.RawMessage("null"))
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func TestHierarchySupport(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, hierarchySupport(nil))
+	require.False(t, hierarchySupport(&protocol.Or_ServerCapabilities_callHierarchyProvider{}))
+	require.False(t, hierarchySupport(&protocol.Or_ServerCapabilities_callHierarchyProvider{Value: false}))
+	require.True(t, hierarchySupport(&protocol.Or_ServerCapabilities_callHierarchyProvider{Value: true}))
+	require.True(t, hierarchySupport(&protocol.Or_ServerCapabilities_callHierarchyProvider{Value: protocol.CallHierarchyOptions{}}))
+	require.True(t, hierarchySupport((*protocol.Or_ServerCapabilities_callHierarchyProvider)(&protocol.Or_ServerCapabilities_typeHierarchyProvider{Value: true})))
+}
@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// Definition returns the locations where the symbol at the given position is
+// defined.
+func (c *Client) Definition(ctx context.Context, filepath string, line, character int) ([]protocol.Location, error) {
+	return c.locations(ctx, "textDocument/definition", filepath, line, character)
+}
+
+// TypeDefinition returns the locations where the type of the symbol at the
+// given position is defined.
+func (c *Client) TypeDefinition(ctx context.Context, filepath string, line, character int) ([]protocol.Location, error) {
+	return c.locations(ctx, "textDocument/typeDefinition", filepath, line, character)
+}
+
+// Implementation returns the locations of the implementations of the
+// interface or abstract method at the given position.
+func (c *Client) Implementation(ctx context.Context, filepath string, line, character int) ([]protocol.Location, error) {
+	return c.locations(ctx, "textDocument/implementation", filepath, line, character)
+}
+
+// Hover returns the hover information of the symbol at the given position as
+// markdown or plain text, or an empty string if there is none.
+func (c *Client) Hover(ctx context.Context, filepath string, line, character int) (string, error) {
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return "", err
+	}
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.client.Call(ctx, "textDocument/hover", positionParams(filepath, line, character), &result); err != nil {
+		return "", fmt.Errorf("hover request failed: %w", err)
+	}
+	return hoverText(result.Contents), nil
+}
+
+func (c *Client) locations(ctx context.Context, method, filepath string, line, character int) ([]protocol.Location, error) {
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return nil, err
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, method, positionParams(filepath, line, character), &result); err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", method, err)
+	}
+	return parseLocations(result)
+}
+
+// positionParams builds the parameters of a position request. line and
+// character are 1-based, like everywhere else in crush.
+func positionParams(filepath string, line, character int) protocol.TextDocumentPositionParams {
+	return protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: protocol.URIFromPath(filepath),
+		},
+		Position: protocol.Position{
+			Line:      uint32(max(line-1, 0)),
+			Character: uint32(max(character-1, 0)),
+		},
+	}
+}
+
+// parseLocations decodes the result of a definition-like request, which can
+// be a location, a list of locations or a list of location links.
+func parseLocations(raw json.RawMessage) ([]protocol.Location, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] != '[' {
+		raw = json.RawMessage("[" + string(raw) + "]")
+	}
+
+	var items []struct {
+		protocol.Location
+		TargetURI            protocol.DocumentURI `json:"targetUri"`
+		TargetSelectionRange *protocol.Range      `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid locations: %w", err)
+	}
+
+	locations := make([]protocol.Location, 0, len(items))
+	for _, item := range items {
+		if item.TargetURI != "" && item.TargetSelectionRange != nil {
+			locations = append(locations, protocol.Location{URI: item.TargetURI, Range: *item.TargetSelectionRange})
+			continue
+		}
+		if item.URI != "" {
+			locations = append(locations, item.Location)
+		}
+	}
+	return locations, nil
+}
+
+// hoverText flattens the contents of a hover, which can be a string, a
+// marked string, markup content or a list of those.
+func hoverText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+
+	var content struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &content); err == nil {
+		if content.Language != "" {
+			return fmt.Sprintf("```%s\n%s\n```", content.Language, strings.TrimSpace(content.Value))
+		}
+		return strings.TrimSpace(content.Value)
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if part := hoverText(item); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
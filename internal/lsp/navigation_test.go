@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocations(t *testing.T) {
+	t.Parallel()
+
+	location := `{"uri":"file:///tmp/a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}`
+	link := `{"targetUri":"file:///tmp/b.go","targetRange":{"start":{"line":3,"character":0},"end":{"line":9,"character":1}},"targetSelectionRange":{"start":{"line":3,"character":5},"end":{"line":3,"character":8}}}`
+
+	tests := []struct {
+		name string
+		raw  string
+		want []protocol.Location
+	}{
+		{name: "null", raw: "null"},
+		{name: "empty", raw: ""},
+		{
+			name: "single location",
+			raw:  location,
+			want: []protocol.Location{{
+				URI:   "file:///tmp/a.go",
+				Range: protocol.Range{Start: protocol.Position{Line: 1, Character: 2}, End: protocol.Position{Line: 1, Character: 5}},
+			}},
+		},
+		{
+			name: "location list",
+			raw:  "[" + location + "," + location + "]",
+			want: []protocol.Location{
+				{URI: "file:///tmp/a.go", Range: protocol.Range{Start: protocol.Position{Line: 1, Character: 2}, End: protocol.Position{Line: 1, Character: 5}}},
+				{URI: "file:///tmp/a.go", Range: protocol.Range{Start: protocol.Position{Line: 1, Character: 2}, End: protocol.Position{Line: 1, Character: 5}}},
+			},
+		},
+		{
+			name: "location links",
+			raw:  "[" + link + "]",
+			want: []protocol.Location{{
+				URI:   "file:///tmp/b.go",
+				Range: protocol.Range{Start: protocol.Position{Line: 3, Character: 5}, End: protocol.Position{Line: 3, Character: 8}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseLocations(json.RawMessage(tt.raw))
+			require.NoError(t, err)
+			if len(tt.want) == 0 {
+				require.Empty(t, got)
+				return
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHoverText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "string", raw: `" plain text "`, want: "plain text"},
+		{name: "markup content", raw: `{"kind":"markdown","value":"**bold**"}`, want: "**bold**"},
+		{name: "marked string", raw: `{"language":"go","value":"func Foo()"}`, want: "```go\nfunc Foo()\n```"},
+		{name: "list", raw: `[{"language":"go","value":"var x int"},"docs",""]`, want: "```go\nvar x int\n```\n\ndocs"},
+		{name: "null", raw: `null`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, hoverText(json.RawMessage(tt.raw)))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// ErrRenameNotSupported is returned when the server can't rename symbols.
+var ErrRenameNotSupported = errors.New("the LSP server does not support renaming")
+
+// RenameTarget is the symbol a rename applies to.
+type RenameTarget struct {
+	// Range of the symbol name. Zero when the server can't tell it.
+	Range protocol.Range
+	// Placeholder is the current name of the symbol, if the server tells it.
+	Placeholder string
+}
+
+// PrepareRename checks whether the symbol at the given position can be
+// renamed. It returns nil when it can't, e.g. when the position is not on a
+// symbol or the symbol is defined outside the workspace. Servers that don't
+// support preparing a rename get an empty target.
+func (c *Client) PrepareRename(ctx context.Context, filepath string, line, character int) (*RenameTarget, error) {
+	rename, prepare := renameSupport(c.client.GetCapabilities().RenameProvider)
+	if !rename {
+		return nil, ErrRenameNotSupported
+	}
+	if !prepare {
+		return &RenameTarget{}, nil
+	}
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return nil, err
+	}
+	params := protocol.PrepareRenameParams{
+		TextDocumentPositionParams: positionParams(filepath, line, character),
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, "textDocument/prepareRename", params, &result); err != nil {
+		return nil, fmt.Errorf("prepare rename request failed: %w", err)
+	}
+	return parseRenameTarget(result)
+}
+
+// Rename returns the edit renaming the symbol at the given position to
+// newName. The edit is not applied.
+func (c *Client) Rename(ctx context.Context, filepath string, line, character int, newName string) (protocol.WorkspaceEdit, error) {
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	pos := positionParams(filepath, line, character)
+	params := protocol.RenameParams{
+		TextDocument: pos.TextDocument,
+		Position:     pos.Position,
+		NewName:      newName,
+	}
+	var edit protocol.WorkspaceEdit
+	if err := c.client.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return protocol.WorkspaceEdit{}, fmt.Errorf("rename request failed: %w", err)
+	}
+	return edit, nil
+}
+
+// renameSupport reads the renameProvider capability, which is either a
+// boolean or rename options.
+func renameSupport(provider any) (rename, prepare bool) {
+	switch p := provider.(type) {
+	case bool:
+		return p, false
+	case map[string]any:
+		prepare, _ := p["prepareProvider"].(bool)
+		return true, prepare
+	case protocol.RenameOptions:
+		return true, p.PrepareProvider
+	case *protocol.RenameOptions:
+		return p != nil, p != nil && p.PrepareProvider
+	}
+	return false, false
+}
+
+// parseRenameTarget decodes the result of a prepare rename request: a range,
+// a range with a placeholder, a default behavior flag or null.
+func parseRenameTarget(raw json.RawMessage) (*RenameTarget, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var result struct {
+		protocol.Range
+		Placeholder string          `json:"placeholder"`
+		InnerRange  *protocol.Range `json:"range"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid prepare rename result: %w", err)
+	}
+	// {defaultBehavior: true} decodes to an empty target.
+	if result.InnerRange != nil {
+		return &RenameTarget{Range: *result.InnerRange, Placeholder: result.Placeholder}, nil
+	}
+	return &RenameTarget{Range: result.Range}, nil
+}
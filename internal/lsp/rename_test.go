@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRenameTarget(t *testing.T) {
+	t.Parallel()
+
+	nameRange := protocol.Range{
+		Start: protocol.Position{Line: 3, Character: 5},
+		End:   protocol.Position{Line: 3, Character: 8},
+	}
+
+	target, err := parseRenameTarget(json.RawMessage(`{"start":{"line":3,"character":5},"end":{"line":3,"character":8}}`))
+	require.NoError(t, err)
+	require.Equal(t, &RenameTarget{Range: nameRange}, target)
+
+	target, err = parseRenameTarget(json.RawMessage(`{"range":{"start":{"line":3,"character":5},"end":{"line":3,"character":8}},"placeholder":"foo"}`))
+	require.NoError(t, err)
+	require.Equal(t, &RenameTarget{Range: nameRange, Placeholder: "foo"}, target)
+
+	target, err = parseRenameTarget(json.RawMessage(`{"defaultBehavior":true}`))
+	require.NoError(t, err)
+	require.Equal(t, &RenameTarget{}, target)
+
+	target, err = parseRenameTarget(json.RawMessage(`null`))
+	require.NoError(t, err)
+	require.Nil(t, target)
+}
+
+func TestRenameSupport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		provider any
+		rename   bool
+		prepare  bool
+	}{
+		{name: "missing", provider: nil},
+		{name: "false", provider: false},
+		{name: "true", provider: true, rename: true},
+		{name: "options", provider: map[string]any{"prepareProvider": true}, rename: true, prepare: true},
+		{name: "options without prepare", provider: map[string]any{}, rename: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rename, prepare := renameSupport(tt.provider)
+			require.Equal(t, tt.rename, rename)
+			require.Equal(t, tt.prepare, prepare)
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/version"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/charmbracelet/x/powernap/pkg/transport"
+)
+
+// serverConfig is how to start a language server and the workspace it's
+// started for.
+type serverConfig struct {
+	command          string
+	args             []string
+	env              map[string]string
+	rootURI          string
+	workspaceFolders []protocol.WorkspaceFolder
+	settings         map[string]any
+	initOptions      map[string]any
+}
+
+// server is the connection to a language server process, made with the
+// powernap transport. Requests the client has no dedicated method for are
+// sent with Call and Notify.
+type server struct {
+	conn   *transport.Connection
+	config serverConfig
+	cancel context.CancelFunc
+	// exited is closed when the process is gone.
+	exited chan struct{}
+
+	initialized  atomic.Bool
+	shutdown     atomic.Bool
+	capabilities protocol.ServerCapabilities
+}
+
+// startServer starts the language server process and connects to it.
+func startServer(config serverConfig) (*server, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, config.command, config.args...)
+	if len(config.env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range config.env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start process: %w", err)
+	}
+
+	s := &server{
+		config: config,
+		cancel: cancel,
+		exited: make(chan struct{}),
+	}
+	go logServerStderr(config.command, stderr)
+	go func() {
+		defer close(s.exited)
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			slog.Error("Language server process exited with error", "command", config.command, "error", err)
+			return
+		}
+		slog.Info("Language server process exited", "command", config.command)
+	}()
+
+	stream := transport.NewStreamTransport(stdout, stdin, closerFunc(func() error {
+		cancel()
+		return nil
+	}))
+	conn, err := transport.NewConnection(ctx, stream, slog.Default())
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create connection: %w", err)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func logServerStderr(command string, stderr io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := stderr.Read(buf)
+		if n > 0 {
+			slog.Error("Language server stderr", "command", command, "output", string(buf[:n]))
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+				slog.Error("Error reading stderr", "error", err)
+			}
+			return
+		}
+	}
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// Initialize sends the initialize request and the initialized notification.
+func (s *server) Initialize(ctx context.Context) error {
+	if s.initialized.Load() {
+		return errors.New("client already initialized")
+	}
+
+	// Some servers don't like nil workspace folders.
+	workspaceFolders := s.config.workspaceFolders
+	if workspaceFolders == nil {
+		workspaceFolders = []protocol.WorkspaceFolder{}
+	}
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"clientInfo": map[string]any{
+			"name":    "crush",
+			"version": version.Version,
+		},
+		"locale":                "en-us",
+		"rootPath":              strings.TrimPrefix(s.config.rootURI, "file://"), // Deprecated but some servers still use it
+		"rootUri":               s.config.rootURI,
+		"capabilities":          clientCapabilities(),
+		"workspaceFolders":      workspaceFolders,
+		"initializationOptions": s.config.initOptions,
+		"trace":                 "off",
+	}
+
+	var result protocol.InitializeResult
+	if err := s.conn.Call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize request failed: %w", err)
+	}
+	s.capabilities = result.Capabilities
+	if err := s.conn.Notify(ctx, "initialized", map[string]any{}); err != nil {
+		return fmt.Errorf("initialized notification failed: %w", err)
+	}
+	s.initialized.Store(true)
+
+	// gopls sets up its workspace views once it has its configuration and
+	// knows about the workspace files.
+	if strings.Contains(s.config.command, "gopls") {
+		_ = s.conn.Notify(ctx, "workspace/didChangeConfiguration", map[string]any{"settings": s.config.settings})
+		if s.config.rootURI != "" {
+			_ = s.NotifyDidChangeWatchedFiles(ctx, []protocol.FileEvent{{
+				URI:  protocol.DocumentURI(s.config.rootURI),
+				Type: protocol.Created,
+			}})
+		}
+	}
+	return nil
+}
+
+// Shutdown sends the shutdown request.
+func (s *server) Shutdown(ctx context.Context) error {
+	if s.shutdown.Load() {
+		return nil
+	}
+	if err := s.conn.Call(ctx, "shutdown", nil, nil); err != nil {
+		return fmt.Errorf("shutdown request failed: %w", err)
+	}
+	s.shutdown.Store(true)
+	return nil
+}
+
+// Exit sends the exit notification, then stops the process if it's still
+// running after a while.
+func (s *server) Exit() error {
+	err := s.conn.Notify(context.Background(), "exit", nil)
+	select {
+	case <-s.exited:
+	case <-time.After(5 * time.Second):
+	}
+	s.cancel()
+	_ = s.conn.Close()
+	if err != nil {
+		return fmt.Errorf("exit notification failed: %w", err)
+	}
+	return nil
+}
+
+// GetCapabilities returns the capabilities the server reported.
+func (s *server) GetCapabilities() protocol.ServerCapabilities {
+	return s.capabilities
+}
+
+// IsRunning reports whether the server is initialized, not shut down and
+// its process is still there.
+func (s *server) IsRunning() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+	}
+	return s.conn.IsConnected() && s.initialized.Load() && !s.shutdown.Load()
+}
+
+// DisconnectNotify returns a channel that is closed when the process is
+// gone, either because the client was closed or because the server exited.
+func (s *server) DisconnectNotify() <-chan struct{} {
+	return s.exited
+}
+
+// Call sends a request and decodes its result.
+func (s *server) Call(ctx context.Context, method string, params, result any) error {
+	return s.conn.Call(ctx, method, params, result)
+}
+
+// Notify sends a notification.
+func (s *server) Notify(ctx context.Context, method string, params any) error {
+	return s.conn.Notify(ctx, method, params)
+}
+
+// RegisterNotificationHandler handles the notifications of the server.
+func (s *server) RegisterNotificationHandler(method string, handler transport.NotificationHandler) {
+	s.conn.RegisterNotificationHandler(method, handler)
+}
+
+// RegisterHandler handles the requests of the server.
+func (s *server) RegisterHandler(method string, handler transport.Handler) {
+	s.conn.RegisterHandler(method, handler)
+}
+
+func (s *server) notifyInitialized(ctx context.Context, method string, params any) error {
+	if !s.initialized.Load() {
+		return errors.New("client not initialized")
+	}
+	return s.conn.Notify(ctx, method, params)
+}
+
+// NotifyDidOpenTextDocument tells the server a document was opened.
+func (s *server) NotifyDidOpenTextDocument(ctx context.Context, uri, languageID string, version int, text string) error {
+	return s.notifyInitialized(ctx, "textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        protocol.DocumentURI(uri),
+			LanguageID: protocol.LanguageKind(languageID),
+			Version:    int32(version),
+			Text:       text,
+		},
+	})
+}
+
+// NotifyDidCloseTextDocument tells the server a document was closed.
+func (s *server) NotifyDidCloseTextDocument(ctx context.Context, uri string) error {
+	return s.notifyInitialized(ctx, "textDocument/didClose", protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+	})
+}
+
+// NotifyDidChangeTextDocument tells the server a document changed.
+func (s *server) NotifyDidChangeTextDocument(ctx context.Context, uri string, version int, changes []protocol.TextDocumentContentChangeEvent) error {
+	return s.notifyInitialized(ctx, "textDocument/didChange", protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			Version:                int32(version),
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: protocol.DocumentURI(uri)},
+		},
+		ContentChanges: changes,
+	})
+}
+
+// NotifyDidChangeWatchedFiles tells the server watched files changed.
+func (s *server) NotifyDidChangeWatchedFiles(ctx context.Context, changes []protocol.FileEvent) error {
+	return s.notifyInitialized(ctx, "workspace/didChangeWatchedFiles", protocol.DidChangeWatchedFilesParams{
+		Changes: changes,
+	})
+}
+
+// FindReferences finds the references to the symbol at the 0-based
+// position.
+func (s *server) FindReferences(ctx context.Context, filepath string, line, character int, includeDeclaration bool) ([]protocol.Location, error) {
+	params := protocol.ReferenceParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filepath)},
+			Position: protocol.Position{
+				Line:      uint32(line),
+				Character: uint32(character),
+			},
+		},
+		Context: protocol.ReferenceContext{IncludeDeclaration: includeDeclaration},
+	}
+	var result []protocol.Location
+	if err := s.conn.Call(ctx, "textDocument/references", params, &result); err != nil {
+		return nil, fmt.Errorf("find references request failed: %w", err)
+	}
+	return result, nil
+}
+
+// clientCapabilities are the capabilities crush tells the servers it has.
+func clientCapabilities() map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{
+			"synchronization": map[string]any{
+				"dynamicRegistration": true,
+				"didSave":             true,
+			},
+			"hover": map[string]any{
+				"dynamicRegistration": true,
+				"contentFormat":       []string{"markdown", "plaintext"},
+			},
+			"definition":     map[string]any{"dynamicRegistration": true, "linkSupport": true},
+			"typeDefinition": map[string]any{"dynamicRegistration": true, "linkSupport": true},
+			"implementation": map[string]any{"dynamicRegistration": true, "linkSupport": true},
+			"references":     map[string]any{"dynamicRegistration": true},
+			"documentSymbol": map[string]any{
+				"dynamicRegistration":               true,
+				"hierarchicalDocumentSymbolSupport": true,
+			},
+			"formatting":    map[string]any{"dynamicRegistration": true},
+			"callHierarchy": map[string]any{"dynamicRegistration": true},
+			"typeHierarchy": map[string]any{"dynamicRegistration": true},
+			"rename": map[string]any{
+				"dynamicRegistration": true,
+				"prepareSupport":      true,
+			},
+			"publishDiagnostics": map[string]any{
+				"relatedInformation":     true,
+				"versionSupport":         true,
+				"tagSupport":             map[string]any{"valueSet": []int{1, 2}},
+				"codeDescriptionSupport": true,
+				"dataSupport":            true,
+			},
+			"codeAction": map[string]any{
+				"dynamicRegistration": true,
+				"codeActionLiteralSupport": map[string]any{
+					"codeActionKind": map[string]any{
+						"valueSet": []string{
+							"quickfix",
+							"refactor",
+							"refactor.extract",
+							"refactor.inline",
+							"refactor.rewrite",
+							"source",
+							"source.organizeImports",
+						},
+					},
+				},
+				"isPreferredSupport": true,
+				"disabledSupport":    true,
+				"dataSupport":        true,
+				"resolveSupport": map[string]any{
+					"properties": []string{"edit"},
+				},
+			},
+		},
+		"workspace": map[string]any{
+			"applyEdit": true,
+			"workspaceEdit": map[string]any{
+				"documentChanges":       true,
+				"resourceOperations":    []string{"create", "rename", "delete"},
+				"failureHandling":       "textOnlyTransactional",
+				"normalizesLineEndings": true,
+			},
+			"didChangeConfiguration": map[string]any{"dynamicRegistration": true},
+			"didChangeWatchedFiles": map[string]any{
+				"dynamicRegistration":    true,
+				"relativePatternSupport": true,
+			},
+			"symbol":           map[string]any{"dynamicRegistration": true},
+			"executeCommand":   map[string]any{"dynamicRegistration": true},
+			"configuration":    true,
+			"workspaceFolders": true,
+			"fileOperations": map[string]any{
+				"dynamicRegistration": true,
+				"didCreate":           true,
+				"willRename":          true,
+				"didRename":           true,
+				"willDelete":          true,
+				"didDelete":           true,
+			},
+		},
+		"window": map[string]any{
+			"workDoneProgress": true,
+			"showMessage": map[string]any{
+				"messageActionItem": map[string]any{
+					"additionalPropertiesSupport": true,
+				},
+			},
+		},
+		"general": map[string]any{
+			"positionEncodings": []string{"utf-16"},
+		},
+	}
+}
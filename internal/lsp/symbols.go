@@ -0,0 +1,207 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// Symbol is a symbol of a document or of the workspace.
+type Symbol struct {
+	Name          string
+	Detail        string
+	Kind          protocol.SymbolKind
+	ContainerName string
+	URI           protocol.DocumentURI
+	// Range spans the whole symbol, e.g. a function including its body.
+	// Workspace symbols of some servers only have a start position.
+	Range    protocol.Range
+	Children []Symbol
+}
+
+// DocumentSymbols returns the symbols of the file as a tree.
+func (c *Client) DocumentSymbols(ctx context.Context, filepath string) ([]Symbol, error) {
+	if err := c.OpenFileOnDemand(ctx, filepath); err != nil {
+		return nil, err
+	}
+	uri := protocol.URIFromPath(filepath)
+	params := protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, "textDocument/documentSymbol", params, &result); err != nil {
+		return nil, fmt.Errorf("document symbol request failed: %w", err)
+	}
+	symbols, err := parseSymbols(result, uri)
+	if err != nil {
+		return nil, err
+	}
+	return nestSymbols(symbols), nil
+}
+
+// WorkspaceSymbols returns the symbols of the workspace matching the query.
+// How the query is matched is up to the server.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]Symbol, error) {
+	params := protocol.WorkspaceSymbolParams{Query: query}
+	var result json.RawMessage
+	if err := c.client.Call(ctx, "workspace/symbol", params, &result); err != nil {
+		return nil, fmt.Errorf("workspace symbol request failed: %w", err)
+	}
+	return parseSymbols(result, "")
+}
+
+// rawSymbol covers DocumentSymbol, SymbolInformation and WorkspaceSymbol.
+type rawSymbol struct {
+	Name          string              `json:"name"`
+	Detail        string              `json:"detail"`
+	Kind          protocol.SymbolKind `json:"kind"`
+	ContainerName string              `json:"containerName"`
+	Range         *protocol.Range     `json:"range"`
+	Location      *struct {
+		URI   protocol.DocumentURI `json:"uri"`
+		Range *protocol.Range      `json:"range"`
+	} `json:"location"`
+	Children []rawSymbol `json:"children"`
+}
+
+// parseSymbols decodes a list of symbols. uri is the document the symbols
+// belong to when they don't carry a location themselves.
+func parseSymbols(raw json.RawMessage, uri protocol.DocumentURI) ([]Symbol, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var items []rawSymbol
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid symbols: %w", err)
+	}
+	return convertSymbols(items, uri), nil
+}
+
+func convertSymbols(items []rawSymbol, uri protocol.DocumentURI) []Symbol {
+	symbols := make([]Symbol, 0, len(items))
+	for _, item := range items {
+		symbol := Symbol{
+			Name:          item.Name,
+			Detail:        item.Detail,
+			Kind:          item.Kind,
+			ContainerName: item.ContainerName,
+			URI:           uri,
+		}
+		switch {
+		case item.Location != nil:
+			symbol.URI = item.Location.URI
+			if item.Location.Range != nil {
+				symbol.Range = *item.Location.Range
+			}
+		case item.Range != nil:
+			symbol.Range = *item.Range
+		}
+		if symbol.URI == "" {
+			continue
+		}
+		symbol.Children = convertSymbols(item.Children, symbol.URI)
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// nestSymbols turns a flat list of symbols into a tree by the containment of
+// their ranges. Servers answering with SymbolInformation instead of
+// DocumentSymbol only give us a flat list. Lists that are already a tree are
+// returned as is.
+func nestSymbols(symbols []Symbol) []Symbol {
+	for _, symbol := range symbols {
+		if len(symbol.Children) > 0 {
+			return symbols
+		}
+	}
+
+	sorted := slices.Clone(symbols)
+	slices.SortStableFunc(sorted, func(a, b Symbol) int {
+		if c := comparePosition(a.Range.Start, b.Range.Start); c != 0 {
+			return c
+		}
+		// Outer symbols first.
+		return comparePosition(b.Range.End, a.Range.End)
+	})
+
+	var roots []Symbol
+	// path holds the chain of open symbols as indexes into their parent's
+	// children, starting at roots.
+	var path []int
+	at := func(depth int) *Symbol {
+		s := &roots[path[0]]
+		for _, i := range path[1:depth] {
+			s = &s.Children[i]
+		}
+		return s
+	}
+	for _, symbol := range sorted {
+		for len(path) > 0 && !rangeContains(at(len(path)).Range, symbol.Range) {
+			path = path[:len(path)-1]
+		}
+		if len(path) == 0 {
+			roots = append(roots, symbol)
+			path = append(path, len(roots)-1)
+			continue
+		}
+		parent := at(len(path))
+		parent.Children = append(parent.Children, symbol)
+		path = append(path, len(parent.Children)-1)
+	}
+	return roots
+}
+
+func comparePosition(a, b protocol.Position) int {
+	if a.Line != b.Line {
+		return int(a.Line) - int(b.Line)
+	}
+	return int(a.Character) - int(b.Character)
+}
+
+// rangeContains reports whether inner lies within outer.
+func rangeContains(outer, inner protocol.Range) bool {
+	return comparePosition(outer.Start, inner.Start) <= 0 && comparePosition(inner.End, outer.End) <= 0
+}
+
+var symbolKindNames = map[protocol.SymbolKind]string{
+	protocol.File:          "file",
+	protocol.Module:        "module",
+	protocol.Namespace:     "namespace",
+	protocol.Package:       "package",
+	protocol.Class:         "class",
+	protocol.Method:        "method",
+	protocol.Property:      "property",
+	protocol.Field:         "field",
+	protocol.Constructor:   "constructor",
+	protocol.Enum:          "enum",
+	protocol.Interface:     "interface",
+	protocol.Function:      "function",
+	protocol.Variable:      "variable",
+	protocol.Constant:      "constant",
+	protocol.String:        "string",
+	protocol.Number:        "number",
+	protocol.Boolean:       "boolean",
+	protocol.Array:         "array",
+	protocol.Object:        "object",
+	protocol.Key:           "key",
+	protocol.Null:          "null",
+	protocol.EnumMember:    "enum member",
+	protocol.Struct:        "struct",
+	protocol.Event:         "event",
+	protocol.Operator:      "operator",
+	protocol.TypeParameter: "type parameter",
+}
+
+// SymbolKindName returns the human readable name of a symbol kind.
+func SymbolKindName(kind protocol.SymbolKind) string {
+	if name, ok := symbolKindNames[kind]; ok {
+		return name
+	}
+	return "symbol"
+}
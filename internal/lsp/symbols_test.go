@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func lines(start, end uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: start},
+		End:   protocol.Position{Line: end},
+	}
+}
+
+func TestParseSymbols(t *testing.T) {
+	t.Parallel()
+
+	t.Run("document symbols", func(t *testing.T) {
+		t.Parallel()
+		raw := `[{"name":"Server","kind":23,"range":{"start":{"line":0,"character":0},"end":{"line":9,"character":0}},"selectionRange":{"start":{"line":0,"character":5},"end":{"line":0,"character":11}},
+			"children":[{"name":"addr","detail":"string","kind":8,"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":12}},"selectionRange":{"start":{"line":1,"character":0},"end":{"line":1,"character":4}}}]}]`
+		symbols, err := parseSymbols(json.RawMessage(raw), "file:///tmp/a.go")
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		require.Equal(t, "Server", symbols[0].Name)
+		require.Equal(t, protocol.Struct, symbols[0].Kind)
+		require.Equal(t, protocol.DocumentURI("file:///tmp/a.go"), symbols[0].URI)
+		require.Len(t, symbols[0].Children, 1)
+		require.Equal(t, "addr", symbols[0].Children[0].Name)
+		require.Equal(t, "string", symbols[0].Children[0].Detail)
+		require.Equal(t, protocol.DocumentURI("file:///tmp/a.go"), symbols[0].Children[0].URI)
+	})
+
+	t.Run("symbol information", func(t *testing.T) {
+		t.Parallel()
+		raw := `[{"name":"Run","kind":12,"containerName":"app","location":{"uri":"file:///tmp/b.go","range":{"start":{"line":4,"character":0},"end":{"line":8,"character":1}}}},
+			{"name":"Other","kind":5,"location":{"uri":"file:///tmp/c.ts"}}]`
+		symbols, err := parseSymbols(json.RawMessage(raw), "")
+		require.NoError(t, err)
+		require.Len(t, symbols, 2)
+		require.Equal(t, "app", symbols[0].ContainerName)
+		require.Equal(t, protocol.DocumentURI("file:///tmp/b.go"), symbols[0].URI)
+		require.Equal(t, lines(4, 8).Start, symbols[0].Range.Start)
+		require.Equal(t, protocol.DocumentURI("file:///tmp/c.ts"), symbols[1].URI)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		t.Parallel()
+		symbols, err := parseSymbols(json.RawMessage("null"), "")
+		require.NoError(t, err)
+		require.Empty(t, symbols)
+	})
+}
+
+func TestNestSymbols(t *testing.T) {
+	t.Parallel()
+
+	flat := []Symbol{
+		{Name: "method", Range: lines(2, 4)},
+		{Name: "Class", Range: lines(1, 10)},
+		{Name: "other", Range: lines(5, 6)},
+		{Name: "after", Range: lines(12, 14)},
+	}
+	nested := nestSymbols(flat)
+	require.Len(t, nested, 2)
+	require.Equal(t, "Class", nested[0].Name)
+	require.Len(t, nested[0].Children, 2)
+	require.Equal(t, "method", nested[0].Children[0].Name)
+	require.Equal(t, "other", nested[0].Children[1].Name)
+	require.Equal(t, "after", nested[1].Name)
+
+	tree := []Symbol{{Name: "a", Range: lines(0, 9), Children: []Symbol{{Name: "b", Range: lines(1, 2)}}}}
+	require.Equal(t, tree, nestSymbols(tree))
+}
@@ -0,0 +1,160 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// FileChange is the effect of a workspace edit on a single file.
+type FileChange struct {
+	Path string
+	// NewPath is set when the file is renamed.
+	NewPath    string
+	OldContent string
+	NewContent string
+	Created    bool
+	Deleted    bool
+}
+
+// FinalPath returns the path of the file after the edit is applied.
+func (c FileChange) FinalPath() string {
+	if c.NewPath != "" {
+		return c.NewPath
+	}
+	return c.Path
+}
+
+// PreviewWorkspaceEdit computes what ApplyWorkspaceEdit would do to each
+// file, without touching the filesystem. Files are returned in the order
+// they are first changed.
+func PreviewWorkspaceEdit(edit protocol.WorkspaceEdit) ([]*FileChange, error) {
+	p := &preview{byPath: map[string]*FileChange{}}
+
+	uris := make([]protocol.DocumentURI, 0, len(edit.Changes))
+	for uri := range edit.Changes {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+	for _, uri := range uris {
+		if err := p.textEdits(uri, edit.Changes[uri]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range edit.DocumentChanges {
+		if err := p.documentChange(change); err != nil {
+			return nil, err
+		}
+	}
+	return p.changes, nil
+}
+
+type preview struct {
+	changes []*FileChange
+	// byPath maps the current path of each file to its change.
+	byPath map[string]*FileChange
+}
+
+// file returns the change of the file at path, reading it on first use.
+func (p *preview) file(path string, mustExist bool) (*FileChange, error) {
+	if change, ok := p.byPath[path]; ok {
+		if change.Deleted {
+			return nil, fmt.Errorf("file was deleted by the edit: %s", path)
+		}
+		return change, nil
+	}
+	change := &FileChange{Path: path}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		change.OldContent = string(content)
+		change.NewContent = string(content)
+	case errors.Is(err, os.ErrNotExist) && !mustExist:
+	default:
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	p.changes = append(p.changes, change)
+	p.byPath[path] = change
+	return change, nil
+}
+
+func (p *preview) textEdits(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
+	path, err := uri.Path()
+	if err != nil {
+		return fmt.Errorf("invalid URI: %w", err)
+	}
+	change, err := p.file(path, true)
+	if err != nil {
+		return err
+	}
+	content, err := ApplyTextEditsToContent([]byte(change.NewContent), edits)
+	if err != nil {
+		return fmt.Errorf("failed to apply text edits to %s: %w", path, err)
+	}
+	change.NewContent = string(content)
+	return nil
+}
+
+func (p *preview) documentChange(change protocol.DocumentChange) error {
+	switch {
+	case change.CreateFile != nil:
+		path, err := change.CreateFile.URI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		file, err := p.file(path, false)
+		if err != nil {
+			return err
+		}
+		if opts := change.CreateFile.Options; opts != nil && opts.IgnoreIfExists && !opts.Overwrite && file.OldContent != "" {
+			return nil
+		}
+		file.Created = true
+		file.NewContent = ""
+
+	case change.DeleteFile != nil:
+		path, err := change.DeleteFile.URI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		file, err := p.file(path, true)
+		if err != nil {
+			return err
+		}
+		file.Deleted = true
+		file.NewContent = ""
+
+	case change.RenameFile != nil:
+		oldPath, err := change.RenameFile.OldURI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		newPath, err := change.RenameFile.NewURI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		file, err := p.file(oldPath, true)
+		if err != nil {
+			return err
+		}
+		file.NewPath = newPath
+		delete(p.byPath, oldPath)
+		p.byPath[newPath] = file
+
+	case change.TextDocumentEdit != nil:
+		edits := make([]protocol.TextEdit, len(change.TextDocumentEdit.Edits))
+		for i, edit := range change.TextDocumentEdit.Edits {
+			var err error
+			edits[i], err = edit.AsTextEdit()
+			if err != nil {
+				return fmt.Errorf("invalid edit type: %w", err)
+			}
+		}
+		return p.textEdits(change.TextDocumentEdit.TextDocument.URI, edits)
+	}
+	return nil
+}
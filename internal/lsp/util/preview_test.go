@@ -0,0 +1,90 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func replace(line, start, end uint32, text string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		},
+		NewText: text,
+	}
+}
+
+func TestPreviewWorkspaceEdit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	renamed := filepath.Join(dir, "renamed.go")
+	require.NoError(t, os.WriteFile(a, []byte("func foo() {}\nfoo()\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("x := foo()\n"), 0o644))
+
+	edit := protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(a): {replace(0, 5, 8, "bar"), replace(1, 0, 3, "bar")},
+		},
+		DocumentChanges: []protocol.DocumentChange{
+			{TextDocumentEdit: &protocol.TextDocumentEdit{
+				TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(b)},
+				},
+				Edits: []protocol.Or_TextDocumentEdit_edits_Elem{{Value: replace(0, 5, 8, "bar")}},
+			}},
+			{RenameFile: &protocol.RenameFile{
+				Kind:   "rename",
+				OldURI: protocol.URIFromPath(b),
+				NewURI: protocol.URIFromPath(renamed),
+			}},
+		},
+	}
+
+	changes, err := PreviewWorkspaceEdit(edit)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	require.Equal(t, a, changes[0].Path)
+	require.Equal(t, "func foo() {}\nfoo()\n", changes[0].OldContent)
+	require.Equal(t, "func bar() {}\nbar()\n", changes[0].NewContent)
+	require.Equal(t, a, changes[0].FinalPath())
+
+	require.Equal(t, b, changes[1].Path)
+	require.Equal(t, renamed, changes[1].NewPath)
+	require.Equal(t, "x := bar()\n", changes[1].NewContent)
+	require.Equal(t, renamed, changes[1].FinalPath())
+
+	// Nothing is written by the preview.
+	content, err := os.ReadFile(a)
+	require.NoError(t, err)
+	require.Equal(t, "func foo() {}\nfoo()\n", string(content))
+	require.NoFileExists(t, renamed)
+
+	// Applying the edit gives the previewed result.
+	require.NoError(t, ApplyWorkspaceEdit(edit))
+	for _, change := range changes {
+		content, err := os.ReadFile(change.FinalPath())
+		require.NoError(t, err)
+		require.Equal(t, change.NewContent, string(content))
+	}
+}
+
+func TestPreviewWorkspaceEditMissingFile(t *testing.T) {
+	t.Parallel()
+
+	edit := protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(filepath.Join(t.TempDir(), "missing.go")): {replace(0, 0, 0, "x")},
+		},
+	}
+	_, err := PreviewWorkspaceEdit(edit)
+	require.Error(t, err)
+}
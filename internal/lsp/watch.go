@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+)
+
+// NotifyWatchedFiles sends the changes that match the file watchers
+// registered by the server, if any, in a workspace/didChangeWatchedFiles
+// notification.
+func (c *Client) NotifyWatchedFiles(ctx context.Context, changes []protocol.FileEvent) error {
+	var watched []protocol.FileEvent
+	for _, change := range changes {
+		path, err := change.URI.Path()
+		if err != nil {
+			continue
+		}
+		if c.watchesFile(path, change.Type) {
+			watched = append(watched, change)
+		}
+	}
+	if len(watched) == 0 {
+		return nil
+	}
+	return c.client.NotifyDidChangeWatchedFiles(ctx, watched)
+}
+
+// watchesFile reports whether a file watcher registered by the server is
+// interested in the change of the file.
+func (c *Client) watchesFile(path string, change protocol.FileChangeType) bool {
+	kind := protocol.WatchKind(1 << (change - 1))
+	for watchers := range c.fileWatchers.Seq() {
+		for _, watcher := range watchers {
+			if watcher.Kind != nil && *watcher.Kind&kind == 0 {
+				continue
+			}
+			if matchGlobPattern(watcher.GlobPattern, c.workDir, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGlobPattern reports whether the path matches the pattern. Plain
+// patterns are matched against the path relative to the workspace root and
+// the absolute path, relative ones against the path relative to their base.
+func matchGlobPattern(pattern protocol.GlobPattern, root, path string) bool {
+	switch p := pattern.Value.(type) {
+	case protocol.Pattern:
+		if matchGlob(p, filepath.ToSlash(path)) {
+			return true
+		}
+		return root != "" && matchRelativeGlob(p, root, path)
+	case protocol.RelativePattern:
+		var base protocol.DocumentURI
+		switch uri := p.BaseURI.Value.(type) {
+		case protocol.URI:
+			base = protocol.DocumentURI(uri)
+		case protocol.WorkspaceFolder:
+			base = protocol.DocumentURI(uri.URI)
+		default:
+			return false
+		}
+		dir, err := base.Path()
+		if err != nil {
+			return false
+		}
+		return matchRelativeGlob(p.Pattern, dir, path)
+	}
+	return false
+}
+
+func matchRelativeGlob(pattern, dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return false
+	}
+	return matchGlob(pattern, filepath.ToSlash(rel))
+}
+
+func matchGlob(pattern, path string) bool {
+	matched, err := doublestar.Match(pattern, path)
+	return err == nil && matched
+}
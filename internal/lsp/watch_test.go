@@ -0,0 +1,50 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchesFile(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	deleteOnly := protocol.WatchDelete
+	client := &Client{workDir: root, fileWatchers: csync.NewMap[string, []protocol.FileSystemWatcher]()}
+	client.fileWatchers.Set("go", []protocol.FileSystemWatcher{
+		{GlobPattern: protocol.GlobPattern{Value: "**/*.go"}},
+		{GlobPattern: protocol.GlobPattern{Value: "**/go.{mod,sum}"}},
+		{GlobPattern: protocol.GlobPattern{Value: "docs/*.md"}},
+	})
+	client.fileWatchers.Set("generated", []protocol.FileSystemWatcher{{
+		GlobPattern: protocol.GlobPattern{Value: protocol.RelativePattern{
+			BaseURI: protocol.Or_RelativePattern_baseUri{Value: protocol.WorkspaceFolder{URI: string(protocol.URIFromPath(root))}},
+			Pattern: "gen/*.pb",
+		}},
+		Kind: &deleteOnly,
+	}})
+
+	for _, tt := range []struct {
+		path   string
+		change protocol.FileChangeType
+		want   bool
+	}{
+		{"main.go", protocol.Created, true},
+		{"pkg/a/a.go", protocol.Changed, true},
+		{"go.sum", protocol.Deleted, true},
+		{"README.md", protocol.Created, false},
+		{"docs/guide.md", protocol.Changed, true},
+		{"gen/a.pb", protocol.Deleted, true},
+		{"gen/a.pb", protocol.Created, false},
+		{"gen/sub/a.pb", protocol.Deleted, false},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, client.watchesFile(filepath.Join(root, tt.path), tt.change))
+		})
+	}
+}
@@ -541,6 +541,7 @@ func (m *Message) ToAIMessage() []fantasy.Message {
 				content = fantasy.ToolResultOutputContentMedia{
 					Data:      result.Data,
 					MediaType: result.MIMEType,
+					Text:      result.Content,
 				}
 			} else {
 				content = fantasy.ToolResultOutputContentText{
@@ -0,0 +1,214 @@
+package permission
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// Decision describes how a permission request was resolved.
+type Decision string
+
+const (
+	DecisionRuleAllow           Decision = "rule_allow"
+	DecisionRuleDeny            Decision = "rule_deny"
+	DecisionYolo                Decision = "yolo"
+	DecisionAllowlist           Decision = "allowlist"
+	DecisionSessionAutoApprove  Decision = "session_auto_approve"
+	DecisionSessionGrant        Decision = "session_grant"
+	DecisionUserGrant           Decision = "user_grant"
+	DecisionUserGrantPersistent Decision = "user_grant_persistent"
+	DecisionUserDeny            Decision = "user_deny"
+)
+
+// Granted reports whether the decision lets the tool call proceed.
+func (d Decision) Granted() bool {
+	return d != DecisionRuleDeny && d != DecisionUserDeny
+}
+
+// ByUser reports whether the decision was made interactively by the user,
+// as opposed to automatically by configuration or session state.
+func (d Decision) ByUser() bool {
+	return d == DecisionUserGrant || d == DecisionUserGrantPersistent || d == DecisionUserDeny
+}
+
+// LogEntry is a persisted permission request and its outcome. Entries are
+// kept when their session is deleted, with an empty SessionID.
+type LogEntry struct {
+	ID          string   `json:"id"`
+	SessionID   string   `json:"session_id"`
+	ToolCallID  string   `json:"tool_call_id"`
+	ToolName    string   `json:"tool_name"`
+	Action      string   `json:"action"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Params      string   `json:"params"` // command, URL and paths of the call, as JSON
+	Decision    Decision `json:"decision"`
+	Granted     bool     `json:"granted"`
+	RequestedAt int64    `json:"requested_at"`
+	DecidedAt   int64    `json:"decided_at"`
+}
+
+// Log is the audit trail of permission requests.
+type Log interface {
+	Record(ctx context.Context, entry LogEntry) error
+	ListBySession(ctx context.Context, sessionID string) ([]LogEntry, error)
+	List(ctx context.Context, limit int) ([]LogEntry, error)
+}
+
+type permissionLog struct {
+	q db.Querier
+}
+
+// NewLog returns a [Log] persisted in the database.
+func NewLog(q db.Querier) Log {
+	return &permissionLog{q: q}
+}
+
+func (l *permissionLog) Record(ctx context.Context, entry LogEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	var granted int64
+	if entry.Granted {
+		granted = 1
+	}
+	return l.q.CreatePermissionLogEntry(ctx, db.CreatePermissionLogEntryParams{
+		ID:          entry.ID,
+		SessionID:   sql.NullString{String: entry.SessionID, Valid: entry.SessionID != ""},
+		ToolCallID:  entry.ToolCallID,
+		ToolName:    entry.ToolName,
+		Action:      entry.Action,
+		Path:        entry.Path,
+		Description: entry.Description,
+		Params:      entry.Params,
+		Decision:    string(entry.Decision),
+		Granted:     granted,
+		RequestedAt: entry.RequestedAt,
+		DecidedAt:   entry.DecidedAt,
+	})
+}
+
+func (l *permissionLog) ListBySession(ctx context.Context, sessionID string) ([]LogEntry, error) {
+	items, err := l.q.ListPermissionLogBySession(ctx, sql.NullString{String: sessionID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	return fromDBItems(items), nil
+}
+
+func (l *permissionLog) List(ctx context.Context, limit int) ([]LogEntry, error) {
+	items, err := l.q.ListPermissionLog(ctx, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	return fromDBItems(items), nil
+}
+
+func fromDBItems(items []db.PermissionLog) []LogEntry {
+	entries := make([]LogEntry, len(items))
+	for i, item := range items {
+		entries[i] = LogEntry{
+			ID:          item.ID,
+			SessionID:   item.SessionID.String,
+			ToolCallID:  item.ToolCallID,
+			ToolName:    item.ToolName,
+			Action:      item.Action,
+			Path:        item.Path,
+			Description: item.Description,
+			Params:      item.Params,
+			Decision:    Decision(item.Decision),
+			Granted:     item.Granted != 0,
+			RequestedAt: item.RequestedAt,
+			DecidedAt:   item.DecidedAt,
+		}
+	}
+	return entries
+}
+
+// record persists the outcome of a request. Failures are logged but never
+// affect the permission decision itself.
+func (s *permissionService) record(opts CreatePermissionRequest, requestedAt time.Time, decision Decision) {
+	if s.log == nil {
+		return
+	}
+	now := time.Now()
+	if err := s.log.Record(context.Background(), LogEntry{
+		SessionID:   opts.SessionID,
+		ToolCallID:  opts.ToolCallID,
+		ToolName:    opts.ToolName,
+		Action:      opts.Action,
+		Path:        opts.Path,
+		Description: opts.Description,
+		Params:      summarizeParams(opts.Params),
+		Decision:    decision,
+		Granted:     decision.Granted(),
+		RequestedAt: requestedAt.UnixMilli(),
+		DecidedAt:   now.UnixMilli(),
+	}); err != nil {
+		slog.Error("Failed to record permission decision", "tool", opts.ToolName, "error", err)
+	}
+}
+
+// summaryParams are the params the log keeps: the command, URL and paths of
+// the call, never the content it writes, which can be large or secret.
+var summaryParams = []string{"command", "url", "source_path", "destination_path", "file_path", "path", "new_path"}
+
+// maxParamLength caps each logged param, as commands can embed file contents
+// in heredocs.
+const maxParamLength = 1024
+
+// summarizeParams returns the JSON summary of the tool params the log keeps,
+// including the paths of the workspace edits tools list in `files`.
+func summarizeParams(params any) string {
+	fields := paramFields(params)
+	summary := summarizeFields(fields)
+	if files, ok := fields["files"].([]any); ok {
+		var paths []map[string]any
+		for _, f := range files {
+			if file, ok := f.(map[string]any); ok {
+				if fileSummary := summarizeFields(file); len(fileSummary) > 0 {
+					paths = append(paths, fileSummary)
+				}
+			}
+		}
+		if len(paths) > 0 {
+			summary["files"] = paths
+		}
+	}
+	if len(summary) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func summarizeFields(fields map[string]any) map[string]any {
+	summary := make(map[string]any)
+	for _, key := range summaryParams {
+		if value, ok := fields[key].(string); ok && value != "" {
+			summary[key] = truncateParam(value)
+		}
+	}
+	return summary
+}
+
+func truncateParam(value string) string {
+	if len(value) <= maxParamLength {
+		return value
+	}
+	cut := maxParamLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + "…"
+}
@@ -0,0 +1,204 @@
+package permission
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryLog struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func (l *memoryLog) Record(_ context.Context, entry LogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryLog) ListBySession(_ context.Context, sessionID string) ([]LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []LogEntry
+	for _, e := range l.entries {
+		if e.SessionID == sessionID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (l *memoryLog) List(_ context.Context, limit int) ([]LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries[:min(limit, len(l.entries))], nil
+}
+
+func (l *memoryLog) decisions() []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decisions := make([]Decision, len(l.entries))
+	for i, e := range l.entries {
+		decisions[i] = e.Decision
+	}
+	return decisions
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	for _, id := range []string{"s1", "s2"} {
+		_, err := q.CreateSession(t.Context(), db.CreateSessionParams{ID: id, Title: id})
+		require.NoError(t, err)
+	}
+
+	log := NewLog(q)
+	require.NoError(t, log.Record(t.Context(), LogEntry{
+		SessionID:   "s1",
+		ToolName:    "bash",
+		Action:      "execute",
+		Params:      `{"command":"ls"}`,
+		Decision:    DecisionUserGrant,
+		Granted:     true,
+		RequestedAt: 1000,
+		DecidedAt:   1500,
+	}))
+	require.NoError(t, log.Record(t.Context(), LogEntry{
+		SessionID:   "s1",
+		ToolName:    "edit",
+		Action:      "write",
+		Path:        "/work",
+		Decision:    DecisionRuleDeny,
+		RequestedAt: 2000,
+		DecidedAt:   2000,
+	}))
+	require.NoError(t, log.Record(t.Context(), LogEntry{
+		SessionID:   "s2",
+		ToolName:    "view",
+		Decision:    DecisionYolo,
+		Granted:     true,
+		RequestedAt: 3000,
+		DecidedAt:   3000,
+	}))
+
+	entries, err := log.ListBySession(t.Context(), "s1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "bash", entries[0].ToolName)
+	require.Equal(t, `{"command":"ls"}`, entries[0].Params)
+	require.True(t, entries[0].Granted)
+	require.NotEmpty(t, entries[0].ID)
+	require.Equal(t, DecisionRuleDeny, entries[1].Decision)
+	require.False(t, entries[1].Granted)
+
+	recent, err := log.List(t.Context(), 2)
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	require.Equal(t, "s2", recent[0].SessionID, "most recent entries come first")
+
+	// The log outlives the sessions.
+	require.NoError(t, q.DeleteSession(t.Context(), "s1"))
+	entries, err = log.ListBySession(t.Context(), "s1")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	all, err := log.List(t.Context(), 10)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Empty(t, all[2].SessionID)
+}
+
+func TestPermissionService_RecordsDecisions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("automatic decisions", func(t *testing.T) {
+		t.Parallel()
+		log := &memoryLog{}
+		rules := []Rule{
+			{Action: RuleDeny, Tool: "bash", Pattern: "git push*"},
+			{Action: RuleAllow, Tool: "bash", Pattern: "go test *"},
+		}
+		service := NewPermissionService("/tmp", false, []string{"view"}, rules, log)
+		service.AutoApproveSession("auto")
+
+		require.False(t, service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Params: testBashParams{"git push"}}))
+		require.True(t, service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Params: testBashParams{"go test ./..."}}))
+		require.True(t, service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "view", Path: "/tmp/a"}))
+		require.True(t, service.Request(CreatePermissionRequest{SessionID: "auto", ToolName: "edit", Path: "/tmp/a"}))
+
+		require.Equal(t, []Decision{
+			DecisionRuleDeny,
+			DecisionRuleAllow,
+			DecisionAllowlist,
+			DecisionSessionAutoApprove,
+		}, log.decisions())
+	})
+
+	t.Run("user decisions", func(t *testing.T) {
+		t.Parallel()
+		log := &memoryLog{}
+		service := NewPermissionService("/tmp", false, nil, nil, log)
+		events := service.Subscribe(t.Context())
+
+		req := CreatePermissionRequest{SessionID: "s", ToolName: "edit", Action: "write", Path: "/tmp/a.txt"}
+		request := func(respond func(PermissionRequest)) bool {
+			result := make(chan bool, 1)
+			go func() { result <- service.Request(req) }()
+			respond((<-events).Payload)
+			return <-result
+		}
+
+		require.False(t, request(service.Deny))
+		require.True(t, request(service.GrantPersistent))
+		require.True(t, service.Request(req))
+
+		require.Equal(t, []Decision{
+			DecisionUserDeny,
+			DecisionUserGrantPersistent,
+			DecisionSessionGrant,
+		}, log.decisions())
+
+		entries, err := log.ListBySession(t.Context(), "s")
+		require.NoError(t, err)
+		require.Equal(t, "/tmp/a.txt", entries[0].Path)
+		require.Equal(t, "edit", entries[0].ToolName)
+		require.LessOrEqual(t, entries[0].RequestedAt, entries[0].DecidedAt)
+	})
+}
+
+func TestSummarizeParams(t *testing.T) {
+	t.Parallel()
+
+	type writeParams struct {
+		FilePath string `json:"file_path"`
+		Content  string `json:"content"`
+	}
+	type patchParams struct {
+		Patch string           `json:"patch"`
+		Files []testFileChange `json:"files"`
+	}
+
+	require.Empty(t, summarizeParams(nil))
+	require.Equal(t, `{"command":"ls"}`, summarizeParams(testBashParams{"ls"}))
+	require.Equal(t, `{"file_path":"a.txt"}`, summarizeParams(writeParams{"a.txt", "SECRET=1"}))
+	require.Equal(t, `{"files":[{"file_path":"a.go","new_path":"b.go"}]}`, summarizeParams(patchParams{
+		Patch: "*** Begin Patch",
+		Files: []testFileChange{{FilePath: "a.go", NewPath: "b.go"}},
+	}))
+	require.Empty(t, summarizeParams(map[string]string{"content": "SECRET=1"}))
+
+	long := summarizeParams(testBashParams{strings.Repeat("é", maxParamLength)})
+	require.Less(t, len(long), maxParamLength+32)
+	require.True(t, utf8.ValidString(long))
+}
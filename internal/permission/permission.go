@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -48,6 +50,7 @@ type Service interface {
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
+	AddRules(rules ...Rule)
 	AutoApproveSession(sessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
@@ -61,11 +64,14 @@ type permissionService struct {
 	workingDir            string
 	sessionPermissions    []PermissionRequest
 	sessionPermissionsMu  sync.RWMutex
-	pendingRequests       *csync.Map[string, chan bool]
+	pendingRequests       *csync.Map[string, chan Decision]
 	autoApproveSessions   map[string]bool
 	autoApproveSessionsMu sync.RWMutex
 	skip                  bool
 	allowedTools          []string
+	rules                 []Rule
+	rulesMu               sync.RWMutex
+	log                   Log
 
 	// used to make sure we only process one request at a time
 	requestMu     sync.Mutex
@@ -79,7 +85,7 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
 	if ok {
-		respCh <- true
+		respCh <- DecisionUserGrantPersistent
 	}
 
 	s.sessionPermissionsMu.Lock()
@@ -98,7 +104,7 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
 	if ok {
-		respCh <- true
+		respCh <- DecisionUserGrant
 	}
 
 	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
@@ -114,7 +120,7 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
 	if ok {
-		respCh <- false
+		respCh <- DecisionUserDeny
 	}
 
 	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
@@ -123,7 +129,28 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
+	requestedAt := time.Now()
+
+	s.rulesMu.RLock()
+	ruleAction := evaluateRules(s.rules, s.workingDir, opts)
+	s.rulesMu.RUnlock()
+
+	// Deny rules win over everything, including YOLO mode.
+	if ruleAction == RuleDeny {
+		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+			ToolCallID: opts.ToolCallID,
+			Denied:     true,
+		})
+		s.record(opts, requestedAt, DecisionRuleDeny)
+		return false
+	}
+
 	if s.skip {
+		s.record(opts, requestedAt, DecisionYolo)
+		return true
+	}
+	if ruleAction == RuleAllow {
+		s.record(opts, requestedAt, DecisionRuleAllow)
 		return true
 	}
 
@@ -134,9 +161,13 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
 
+	// Ask rules override the allowlist and previous grants.
+	askRule := ruleAction == RuleAsk
+
 	// Check if the tool/action combination is in the allowlist
 	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
+	if !askRule && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		s.record(opts, requestedAt, DecisionAllowlist)
 		return true
 	}
 
@@ -145,6 +176,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
+		s.record(opts, requestedAt, DecisionSessionAutoApprove)
 		return true
 	}
 
@@ -172,34 +204,54 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		Params:      opts.Params,
 	}
 
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
+	if !askRule {
+		s.sessionPermissionsMu.RLock()
+		for _, p := range s.sessionPermissions {
+			if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
+				s.sessionPermissionsMu.RUnlock()
+				s.record(opts, requestedAt, DecisionSessionGrant)
+				return true
+			}
 		}
+		s.sessionPermissionsMu.RUnlock()
 	}
-	s.sessionPermissionsMu.RUnlock()
-
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
-		}
-	}
-	s.sessionPermissionsMu.RUnlock()
 
 	s.activeRequest = &permission
 
-	respCh := make(chan bool, 1)
+	respCh := make(chan Decision, 1)
 	s.pendingRequests.Set(permission.ID, respCh)
 	defer s.pendingRequests.Del(permission.ID)
 
 	// Publish the request
 	s.Publish(pubsub.CreatedEvent, permission)
 
-	return <-respCh
+	decision := <-respCh
+	s.record(opts, requestedAt, decision)
+	return decision.Granted()
+}
+
+// AddRules adds rules the user chose to always apply. A rule is inserted
+// ahead of the first ask rule of its tool covering its pattern, which would
+// otherwise keep prompting for it, and appended to the end of the rule list
+// otherwise, so the rules that were already configured keep precedence.
+func (s *permissionService) AddRules(rules ...Rule) {
+	s.rulesMu.Lock()
+	defer s.rulesMu.Unlock()
+	for _, rule := range rules {
+		if slices.Contains(s.rules, rule) {
+			continue
+		}
+		i := slices.IndexFunc(s.rules, func(r Rule) bool {
+			return r.Action == RuleAsk && rule.Action != RuleAsk &&
+				(r.Tool == rule.Tool || strings.HasPrefix(r.Tool, rule.Tool+":")) &&
+				r.covers(rule.Pattern)
+		})
+		if i < 0 {
+			s.rules = append(s.rules, rule)
+			continue
+		}
+		s.rules = slices.Insert(s.rules, i, rule)
+	}
 }
 
 func (s *permissionService) AutoApproveSession(sessionID string) {
@@ -220,7 +272,7 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, rules []Rule, log Log) Service {
 	return &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
 		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
@@ -229,6 +281,8 @@ func NewPermissionService(workingDir string, skip bool, allowedTools []string) S
 		autoApproveSessions: make(map[string]bool),
 		skip:                skip,
 		allowedTools:        allowedTools,
-		pendingRequests:     csync.NewMap[string, chan bool](),
+		rules:               slices.Clone(rules),
+		log:                 log,
+		pendingRequests:     csync.NewMap[string, chan Decision](),
 	}
 }
@@ -0,0 +1,414 @@
+package permission
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// RuleAction is the outcome of a matching permission rule.
+type RuleAction string
+
+const (
+	RuleAllow RuleAction = "allow"
+	RuleDeny  RuleAction = "deny"
+	RuleAsk   RuleAction = "ask"
+)
+
+// Rule is a pattern based permission rule. Rules are evaluated in order and
+// the first rule matching a given subject wins.
+//
+// What the pattern is matched against depends on the tool parameters:
+//   - commands (bash): each simple command of the script, so `a && b` is
+//     checked as `a` and `b`, with quotes and escapes removed. `*` matches
+//     any sequence of characters. Commands with words only known when the
+//     script runs, as `$x` or `$(...)`, and redirections writing to files,
+//     as `> out.txt`, are never allowed by a rule.
+//   - file paths (edit, write, view...): the path relative to the working
+//     directory, using doublestar globs (`internal/**/*.go`).
+//   - URLs (fetch, download...): the host name (`*.github.com`).
+//
+// An empty pattern matches every call of the tool.
+type Rule struct {
+	Action  RuleAction `json:"action" jsonschema:"required,description=What to do when the rule matches,enum=allow,enum=deny,enum=ask"`
+	Tool    string     `json:"tool" jsonschema:"required,description=Tool name the rule applies to,example=bash,example=edit,example=fetch"`
+	Pattern string     `json:"pattern,omitempty" jsonschema:"description=Glob matched against the command, file path or URL domain,example=go test *,example=internal/**,example=*.github.com"`
+}
+
+type subjectKind int
+
+const (
+	subjectCommand subjectKind = iota
+	subjectPath
+	subjectDomain
+)
+
+type subject struct {
+	kind  subjectKind
+	value string
+	// dynamic is set for commands with words only known when the script
+	// runs, as `$x` or `$(...)`, and for redirections writing to files.
+	// Allow rules never match them.
+	dynamic bool
+}
+
+// evaluateRules returns the action the rules decide for the given request,
+// or an empty action if no rule has an opinion.
+//
+// A deny on any subject denies the whole request, an ask on any subject
+// forces a prompt, and the request is only allowed when every subject is
+// allowed.
+func evaluateRules(rules []Rule, workingDir string, opts CreatePermissionRequest) RuleAction {
+	var toolRules []Rule
+	for _, r := range rules {
+		if r.Tool == opts.ToolName || r.Tool == opts.ToolName+":"+opts.Action {
+			toolRules = append(toolRules, r)
+		}
+	}
+	if len(toolRules) == 0 {
+		return ""
+	}
+
+	subjects := requestSubjects(workingDir, opts.Params)
+	if len(subjects) == 0 {
+		// Nothing to match patterns against, only catch-all rules apply.
+		for _, r := range toolRules {
+			if r.Pattern == "" {
+				return r.Action
+			}
+		}
+		return ""
+	}
+
+	allowed := 0
+	ask := false
+	for _, s := range subjects {
+		if s.dynamic {
+			// What runs can't be known, so it must be asked unless denied.
+			ask = true
+		}
+		for _, r := range toolRules {
+			if !r.matches(s) || s.dynamic && r.Action == RuleAllow {
+				continue
+			}
+			switch r.Action {
+			case RuleDeny:
+				return RuleDeny
+			case RuleAsk:
+				ask = true
+			case RuleAllow:
+				allowed++
+			}
+			break
+		}
+	}
+
+	switch {
+	case ask:
+		return RuleAsk
+	case allowed == len(subjects):
+		return RuleAllow
+	default:
+		return ""
+	}
+}
+
+// covers reports whether the rule matches everything the given pattern does,
+// comparing command, path and domain patterns alike as plain text.
+func (r Rule) covers(pattern string) bool {
+	return r.Pattern == "" || globToRegexp(r.Pattern).MatchString(pattern)
+}
+
+func (r Rule) matches(s subject) bool {
+	if r.Pattern == "" {
+		return true
+	}
+	switch s.kind {
+	case subjectCommand:
+		return globToRegexp(r.Pattern).MatchString(s.value)
+	case subjectPath:
+		ok, _ := doublestar.Match(filepath.ToSlash(r.Pattern), s.value)
+		return ok
+	case subjectDomain:
+		ok, _ := path.Match(strings.ToLower(r.Pattern), s.value)
+		return ok
+	}
+	return false
+}
+
+// globToRegexp converts a command glob into an anchored regexp where `*`
+// matches any sequence of characters, spaces and slashes included.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return regexp.MustCompile("$^")
+	}
+	return re
+}
+
+// requestSubjects extracts the values rules are matched against from the
+// tool parameters. Parameters are inspected by their JSON field names so
+// this package does not need to know about the concrete tool types.
+func requestSubjects(workingDir string, params any) []subject {
+	fields := paramFields(params)
+	if fields == nil {
+		return nil
+	}
+
+	if cmd, ok := fields["command"].(string); ok && cmd != "" {
+		return commandSubjects(cmd)
+	}
+	if u, ok := fields["url"].(string); ok && u != "" {
+		if host := urlHost(u); host != "" {
+			return []subject{{kind: subjectDomain, value: host}}
+		}
+		return nil
+	}
+	// Every file the request touches is a subject: the ones of the tool
+	// params, and the ones of the workspace edits tools list in `files`.
+	var paths []subject
+	addPaths := func(fields map[string]any, keys ...string) {
+		for _, key := range keys {
+			p, ok := fields[key].(string)
+			if !ok || p == "" {
+				continue
+			}
+			s := subject{kind: subjectPath, value: relativePath(workingDir, p)}
+			if !slices.Contains(paths, s) {
+				paths = append(paths, s)
+			}
+		}
+	}
+	addPaths(fields, "source_path", "destination_path", "file_path", "path")
+	if files, ok := fields["files"].([]any); ok {
+		for _, f := range files {
+			if file, ok := f.(map[string]any); ok {
+				addPaths(file, "file_path", "new_path")
+			}
+		}
+	}
+	return paths
+}
+
+func paramFields(params any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	var data []byte
+	switch p := params.(type) {
+	case string:
+		data = []byte(p)
+	case []byte:
+		data = p
+	default:
+		var err error
+		data, err = json.Marshal(p)
+		if err != nil {
+			return nil
+		}
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// commandSubjects parses a shell script and returns every simple command in
+// it, as the values of its words separated by single spaces: quotes and
+// escapes are removed, so `"git" 'push'` is matched as `git push`. Commands
+// nested in pipelines, lists, subshells and command substitutions are all
+// included. Commands with words that can't be resolved without running the
+// script, and scripts that fail to parse, are dynamic. Redirections writing
+// to files are subjects of their own, as `> out.txt`, and always dynamic:
+// `cat *` must not allow `cat a > ~/.bashrc`.
+func commandSubjects(script string) []subject {
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		return []subject{{kind: subjectCommand, value: strings.TrimSpace(script), dynamic: true}}
+	}
+
+	printer := syntax.NewPrinter()
+	var subjects []subject
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if redir, ok := node.(*syntax.Redirect); ok {
+			if writesFile(redir) {
+				var sb strings.Builder
+				sb.WriteString(redir.Op.String() + " ")
+				_ = printer.Print(&sb, redir.Word)
+				subjects = append(subjects, subject{kind: subjectCommand, value: sb.String(), dynamic: true})
+			}
+			return true
+		}
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		cmd := subject{kind: subjectCommand}
+		words := make([]string, 0, len(call.Args))
+		for _, word := range call.Args {
+			if value, ok := literalWord(word); ok {
+				words = append(words, value)
+				continue
+			}
+			// Keep the word as written, deny rules can still match it.
+			cmd.dynamic = true
+			var sb strings.Builder
+			if err := printer.Print(&sb, word); err == nil {
+				words = append(words, sb.String())
+			}
+		}
+		cmd.value = strings.Join(words, " ")
+		subjects = append(subjects, cmd)
+		return true
+	})
+	if len(subjects) == 0 {
+		return []subject{{kind: subjectCommand, value: strings.TrimSpace(script)}}
+	}
+	return subjects
+}
+
+// writesFile reports whether the redirection can write to a file. Writing to
+// /dev/null and duplicating file descriptors, as `2>&1`, are harmless.
+func writesFile(redir *syntax.Redirect) bool {
+	switch redir.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+	case syntax.DplOut:
+		// `>&file` is the same as `&>file` in Bash.
+		if target, ok := literalWord(redir.Word); ok && (target == "-" || isFileDescriptor(target)) {
+			return false
+		}
+	default:
+		return false
+	}
+	target, ok := literalWord(redir.Word)
+	return !ok || target != "/dev/null"
+}
+
+func isFileDescriptor(word string) bool {
+	return word != "" && strings.Trim(word, "0123456789") == ""
+}
+
+// literalWord returns the value of the word once quotes and escapes are
+// removed, or false if it has expansions only known when the script runs.
+func literalWord(word *syntax.Word) (string, bool) {
+	// SplitBraces changes the word, which is still being walked.
+	split := syntax.Word{Parts: slices.Clone(word.Parts)}
+	if syntax.SplitBraces(&split) {
+		// `git pu{sh,ll}` runs `git push pull`.
+		return "", false
+	}
+	static := true
+	syntax.Walk(word, func(node syntax.Node) bool {
+		switch node.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst, *syntax.ArithmExp, *syntax.ProcSubst, *syntax.ExtGlob:
+			static = false
+		}
+		return static
+	})
+	if !static {
+		return "", false
+	}
+	fields, err := expand.Fields(nil, word)
+	if err != nil || len(fields) != 1 {
+		return "", false
+	}
+	return fields[0], true
+}
+
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func relativePath(workingDir, p string) string {
+	if workingDir != "" && filepath.IsAbs(p) {
+		if rel, err := filepath.Rel(workingDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// SuggestRules returns allow rules that would cover the given request, used
+// to offer "always allow this pattern" to the user. Commands are generalized
+// to their leading subcommand, files to their directory and URLs to their
+// host. Requests without a command, path or URL get no suggestion, as the
+// only rule covering them would allow every call of the tool.
+func SuggestRules(req PermissionRequest, workingDir string) []Rule {
+	var rules []Rule
+	subjects := requestSubjects(workingDir, req.Params)
+	for _, s := range subjects {
+		if s.dynamic {
+			// Allow rules never match it.
+			continue
+		}
+		rule := Rule{Action: RuleAllow, Tool: req.ToolName}
+		switch s.kind {
+		case subjectCommand:
+			rule.Pattern = commandPattern(s.value)
+		case subjectPath:
+			rule.Pattern = pathPattern(s.value)
+		case subjectDomain:
+			rule.Pattern = s.value
+		}
+		if !slices.Contains(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// commandPattern keeps the command name and up to one subcommand, replacing
+// the remaining arguments with a wildcard: `go test ./...` -> `go test *`.
+func commandPattern(command string) string {
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return command
+	}
+	keep := 1
+	if len(words) > 1 && isSubcommand(words[1]) {
+		keep = 2
+	}
+	if len(words) == keep {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:keep], " ") + " *"
+}
+
+func isSubcommand(word string) bool {
+	return !strings.HasPrefix(word, "-") &&
+		!strings.ContainsAny(word, "/.=$'\"*")
+}
+
+func pathPattern(p string) string {
+	dir := path.Dir(p)
+	if dir == "." || dir == "/" {
+		return p
+	}
+	return dir + "/**"
+}
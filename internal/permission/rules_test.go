@@ -0,0 +1,262 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBashParams struct {
+	Command string `json:"command"`
+}
+
+type testFileParams struct {
+	FilePath string `json:"file_path"`
+}
+
+type testMoveParams struct {
+	SourcePath      string `json:"source_path"`
+	DestinationPath string `json:"destination_path"`
+}
+
+type testFileChange struct {
+	FilePath string `json:"file_path"`
+	NewPath  string `json:"new_path,omitempty"`
+}
+
+type testFilesParams struct {
+	Files []testFileChange `json:"files"`
+}
+
+type testURLParams struct {
+	URL string `json:"url"`
+}
+
+func TestEvaluateRules(t *testing.T) {
+	t.Parallel()
+
+	rules := []Rule{
+		{Action: RuleDeny, Tool: "bash", Pattern: "git push*"},
+		{Action: RuleAllow, Tool: "bash", Pattern: "go test *"},
+		{Action: RuleAllow, Tool: "bash", Pattern: "git status"},
+		{Action: RuleAsk, Tool: "bash", Pattern: "rm *"},
+		{Action: RuleAllow, Tool: "edit", Pattern: "internal/**"},
+		{Action: RuleDeny, Tool: "edit", Pattern: "**/*.env"},
+		{Action: RuleDeny, Tool: "apply_patch", Pattern: "secrets/**"},
+		{Action: RuleAllow, Tool: "apply_patch", Pattern: "internal/**"},
+		{Action: RuleAllow, Tool: "fetch", Pattern: "*.github.com"},
+		{Action: RuleDeny, Tool: "download"},
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		params   any
+		expected RuleAction
+	}{
+		{"allowed command", "bash", testBashParams{"go test ./..."}, RuleAllow},
+		{"exact command", "bash", testBashParams{"git status"}, RuleAllow},
+		{"denied command", "bash", testBashParams{"git push --force origin main"}, RuleDeny},
+		{"chain all allowed", "bash", testBashParams{"git status && go test ./..."}, RuleAllow},
+		{"chain with denied", "bash", testBashParams{"go test ./... && git push"}, RuleDeny},
+		{"chain partially matched", "bash", testBashParams{"go test ./... && make"}, ""},
+		{"denied in substitution", "bash", testBashParams{"echo $(git push)"}, RuleDeny},
+		{"ask command", "bash", testBashParams{"go test ./... ; rm -rf build"}, RuleAsk},
+		{"unmatched command", "bash", testBashParams{"make build"}, ""},
+		{"allowed path", "edit", testFileParams{"/work/internal/config/config.go"}, RuleAllow},
+		{"first match wins", "edit", testFileParams{"/work/internal/.env"}, RuleAllow},
+		{"denied path", "edit", testFileParams{"/work/.env"}, RuleDeny},
+		{"path outside dir", "edit", testFileParams{"/etc/hosts"}, ""},
+		{"move within allowed dir", "edit", testMoveParams{"/work/internal/a.go", "/work/internal/b.go"}, RuleAllow},
+		{"move out of allowed dir", "edit", testMoveParams{"/work/internal/a.go", "/work/a.go"}, ""},
+		{"move to denied path", "edit", testMoveParams{"/work/internal/a.go", "/work/.env"}, RuleDeny},
+		{"patch touching denied file", "apply_patch", testFilesParams{[]testFileChange{{FilePath: "/work/internal/a.go"}, {FilePath: "/work/secrets/key"}}}, RuleDeny},
+		{"patch moving to denied file", "apply_patch", testFilesParams{[]testFileChange{{FilePath: "/work/internal/a.go", NewPath: "/work/secrets/a.go"}}}, RuleDeny},
+		{"patch within allowed dir", "apply_patch", testFilesParams{[]testFileChange{{FilePath: "/work/internal/a.go"}, {FilePath: "/work/internal/b/c.go"}}}, RuleAllow},
+		{"patch partially allowed", "apply_patch", testFilesParams{[]testFileChange{{FilePath: "/work/internal/a.go"}, {FilePath: "/work/main.go"}}}, ""},
+		{"allowed domain", "fetch", testURLParams{"https://api.github.com/repos"}, RuleAllow},
+		{"unmatched domain", "fetch", testURLParams{"https://github.com"}, ""},
+		{"catch-all rule", "download", testURLParams{"https://example.com/file"}, RuleDeny},
+		{"no rules for tool", "view", testFileParams{"/work/main.go"}, ""},
+		{"string params", "bash", `{"command":"git push"}`, RuleDeny},
+		{"single quoted word", "bash", testBashParams{"git 'push' --force"}, RuleDeny},
+		{"double quoted word", "bash", testBashParams{`"git" push`}, RuleDeny},
+		{"escaped word", "bash", testBashParams{`g\it push`}, RuleDeny},
+		{"quoted allowed command", "bash", testBashParams{`go test "./..."`}, RuleAllow},
+		{"variable command", "bash", testBashParams{"$cmd push"}, RuleAsk},
+		{"variable argument", "bash", testBashParams{"go test $pkg"}, RuleAsk},
+		{"substituted argument", "bash", testBashParams{"go test $(go list ./...)"}, RuleAsk},
+		{"variable in denied command", "bash", testBashParams{"git push $remote"}, RuleDeny},
+		{"brace expansion", "bash", testBashParams{"git pu{sh,ll}"}, RuleAsk},
+		{"unparsable script", "bash", testBashParams{"go test ./... &&"}, RuleAsk},
+		{"redirect to file", "bash", testBashParams{"go test ./... > ~/.bashrc"}, RuleAsk},
+		{"appending redirect", "bash", testBashParams{"go test ./... >> out.txt"}, RuleAsk},
+		{"redirect of block", "bash", testBashParams{"{ go test ./...; } &> out.txt"}, RuleAsk},
+		{"redirect to null", "bash", testBashParams{"go test ./... 2>/dev/null"}, RuleAllow},
+		{"duplicated descriptor", "bash", testBashParams{"go test ./... 2>&1"}, RuleAllow},
+		{"input redirect", "bash", testBashParams{"go test ./... < input.txt"}, RuleAllow},
+		{"redirect in denied command", "bash", testBashParams{"git push > out.txt"}, RuleDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := evaluateRules(rules, "/work", CreatePermissionRequest{
+				ToolName: tt.tool,
+				Params:   tt.params,
+			})
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestPermissionService_Rules(t *testing.T) {
+	t.Parallel()
+
+	rules := []Rule{
+		{Action: RuleDeny, Tool: "bash", Pattern: "git push*"},
+		{Action: RuleAllow, Tool: "bash", Pattern: "go test *"},
+	}
+
+	t.Run("deny wins over skip mode", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", true, nil, rules, nil)
+		require.False(t, service.Request(CreatePermissionRequest{
+			SessionID: "s",
+			ToolName:  "bash",
+			Params:    testBashParams{"git push origin"},
+		}))
+	})
+
+	t.Run("allow skips the prompt", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, nil, rules, nil)
+		require.True(t, service.Request(CreatePermissionRequest{
+			SessionID: "s",
+			ToolName:  "bash",
+			Params:    testBashParams{"go test ./..."},
+		}))
+	})
+
+	t.Run("added rules apply", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, nil, nil, nil)
+		service.AddRules(Rule{Action: RuleAllow, Tool: "bash", Pattern: "ls *"})
+		require.True(t, service.Request(CreatePermissionRequest{
+			SessionID: "s",
+			ToolName:  "bash",
+			Params:    testBashParams{"ls -la"},
+		}))
+	})
+
+	t.Run("added rules override ask rules", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, nil, []Rule{
+			{Action: RuleDeny, Tool: "bash", Pattern: "ls /root*"},
+			{Action: RuleAsk, Tool: "bash", Pattern: "ls *"},
+		}, nil)
+		service.AddRules(Rule{Action: RuleAllow, Tool: "bash", Pattern: "ls -la *"})
+		require.Equal(t, []Rule{
+			{Action: RuleDeny, Tool: "bash", Pattern: "ls /root*"},
+			{Action: RuleAllow, Tool: "bash", Pattern: "ls -la *"},
+			{Action: RuleAsk, Tool: "bash", Pattern: "ls *"},
+		}, service.(*permissionService).rules)
+		require.True(t, service.Request(CreatePermissionRequest{
+			SessionID: "s",
+			ToolName:  "bash",
+			Params:    testBashParams{"ls -la internal"},
+		}))
+		require.False(t, service.Request(CreatePermissionRequest{
+			SessionID: "s",
+			ToolName:  "bash",
+			Params:    testBashParams{"ls /root"},
+		}))
+	})
+
+	t.Run("added rules keep unrelated ask rules first", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, nil, []Rule{
+			{Action: RuleAsk, Tool: "bash", Pattern: "rm *"},
+			{Action: RuleAsk, Tool: "edit"},
+		}, nil)
+		service.AddRules(Rule{Action: RuleAllow, Tool: "bash", Pattern: "ls *"})
+		require.Equal(t, []Rule{
+			{Action: RuleAsk, Tool: "bash", Pattern: "rm *"},
+			{Action: RuleAsk, Tool: "edit"},
+			{Action: RuleAllow, Tool: "bash", Pattern: "ls *"},
+		}, service.(*permissionService).rules)
+	})
+}
+
+func TestSuggestRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tool     string
+		params   any
+		expected []Rule
+	}{
+		{
+			name:   "subcommand",
+			tool:   "bash",
+			params: testBashParams{"go test ./..."},
+			expected: []Rule{
+				{Action: RuleAllow, Tool: "bash", Pattern: "go test *"},
+			},
+		},
+		{
+			name:   "flags only",
+			tool:   "bash",
+			params: testBashParams{"ls -la && git status"},
+			expected: []Rule{
+				{Action: RuleAllow, Tool: "bash", Pattern: "ls *"},
+				{Action: RuleAllow, Tool: "bash", Pattern: "git status"},
+			},
+		},
+		{
+			name:   "file",
+			tool:   "edit",
+			params: testFileParams{"/work/internal/config/config.go"},
+			expected: []Rule{
+				{Action: RuleAllow, Tool: "edit", Pattern: "internal/config/**"},
+			},
+		},
+		{
+			name:   "url",
+			tool:   "fetch",
+			params: testURLParams{"https://Example.com/page"},
+			expected: []Rule{
+				{Action: RuleAllow, Tool: "fetch", Pattern: "example.com"},
+			},
+		},
+		{
+			name:     "no subject",
+			tool:     "mcp_tool",
+			params:   nil,
+			expected: nil,
+		},
+		{
+			name:     "dynamic command",
+			tool:     "bash",
+			params:   testBashParams{"go test $pkg"},
+			expected: nil,
+		},
+		{
+			name:   "redirect",
+			tool:   "bash",
+			params: testBashParams{"go test ./... > out.txt"},
+			expected: []Rule{
+				{Action: RuleAllow, Tool: "bash", Pattern: "go test *"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := SuggestRules(PermissionRequest{ToolName: tt.tool, Params: tt.params}, "/work")
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package shell
 
 import (
-	"bytes"
+	"cmp"
 	"context"
 	"fmt"
+	"io"
+	"regexp"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/pubsub"
 )
 
 const (
@@ -16,22 +20,50 @@ const (
 	MaxBackgroundJobs = 50
 	// CompletedJobRetentionMinutes is how long to keep completed jobs before auto-cleanup (8 hours)
 	CompletedJobRetentionMinutes = 8 * 60
+	// MaxBackgroundOutputBytes is how much of each output stream is kept, older output is dropped
+	MaxBackgroundOutputBytes = 1024 * 1024
 )
 
 // BackgroundShell represents a shell running in the background.
 type BackgroundShell struct {
 	ID          string
+	SessionID   string
 	Command     string
 	Description string
 	Shell       *Shell
 	WorkingDir  string
+	StartedAt   time.Time
 	ctx         context.Context
 	cancel      context.CancelFunc
-	stdout      *bytes.Buffer
-	stderr      *bytes.Buffer
+	stdout      *ringBuffer
+	stderr      *ringBuffer
+	output      *ringBuffer // stdout and stderr interleaved
 	done        chan struct{}
 	exitErr     error
-	completedAt int64 // Unix timestamp when job completed (0 if still running)
+	completedAt int64 // Unix timestamp in milliseconds when job completed (0 if still running)
+
+	mu       sync.Mutex
+	detached bool // Whether the shell was handed over to the background
+	killed   bool
+}
+
+// BackgroundJob is a snapshot of a background shell, as published in
+// background job events.
+type BackgroundJob struct {
+	ID          string
+	SessionID   string
+	Command     string
+	Description string
+	WorkingDir  string
+	StartedAt   time.Time
+	CompletedAt time.Time // Zero while the job is running
+	ExitCode    int
+	Killed      bool
+}
+
+// Done reports whether the job has finished.
+func (j BackgroundJob) Done() bool {
+	return !j.CompletedAt.IsZero()
 }
 
 // BackgroundShellManager manages background shell instances.
@@ -43,8 +75,16 @@ var (
 	backgroundManager     *BackgroundShellManager
 	backgroundManagerOnce sync.Once
 	idCounter             atomic.Uint64
+	backgroundBroker      = pubsub.NewBroker[BackgroundJob]()
 )
 
+// SubscribeBackgroundJobs returns a channel for background job events. A
+// created event is sent when a shell is detached, an updated event when its
+// command finishes and a deleted event when it is killed or removed.
+func SubscribeBackgroundJobs(ctx context.Context) <-chan pubsub.Event[BackgroundJob] {
+	return backgroundBroker.Subscribe(ctx)
+}
+
 // GetBackgroundShellManager returns the singleton background shell manager.
 func GetBackgroundShellManager() *BackgroundShellManager {
 	backgroundManagerOnce.Do(func() {
@@ -56,7 +96,19 @@ func GetBackgroundShellManager() *BackgroundShellManager {
 }
 
 // Start creates and starts a new background shell with the given command.
-func (m *BackgroundShellManager) Start(ctx context.Context, workingDir string, blockFuncs []BlockFunc, command string, description string) (*BackgroundShell, error) {
+func (m *BackgroundShellManager) Start(ctx context.Context, workingDir string, blockFuncs []BlockFunc, sandbox *Sandbox, command string, description string) (*BackgroundShell, error) {
+	shell := NewShell(&Options{
+		WorkingDir: workingDir,
+		BlockFuncs: blockFuncs,
+		Sandbox:    sandbox,
+	})
+	return m.StartShell(ctx, "", shell, command, description)
+}
+
+// StartShell starts the given command in the background using an existing
+// shell, so the command sees and updates that shell's state. The session ID
+// identifies the session that started the command, if any.
+func (m *BackgroundShellManager) StartShell(ctx context.Context, sessionID string, shell *Shell, command string, description string) (*BackgroundShell, error) {
 	// Check job limit
 	if m.shells.Len() >= MaxBackgroundJobs {
 		return nil, fmt.Errorf("maximum number of background jobs (%d) reached. Please terminate or wait for some jobs to complete", MaxBackgroundJobs)
@@ -64,35 +116,44 @@ func (m *BackgroundShellManager) Start(ctx context.Context, workingDir string, b
 
 	id := fmt.Sprintf("%03X", idCounter.Add(1))
 
-	shell := NewShell(&Options{
-		WorkingDir: workingDir,
-		BlockFuncs: blockFuncs,
-	})
-
 	shellCtx, cancel := context.WithCancel(ctx)
 
 	bgShell := &BackgroundShell{
 		ID:          id,
+		SessionID:   sessionID,
 		Command:     command,
 		Description: description,
-		WorkingDir:  workingDir,
+		WorkingDir:  shell.GetWorkingDir(),
+		StartedAt:   time.Now(),
 		Shell:       shell,
 		ctx:         shellCtx,
 		cancel:      cancel,
-		stdout:      &bytes.Buffer{},
-		stderr:      &bytes.Buffer{},
+		stdout:      newRingBuffer(MaxBackgroundOutputBytes),
+		stderr:      newRingBuffer(MaxBackgroundOutputBytes),
+		output:      newRingBuffer(MaxBackgroundOutputBytes),
 		done:        make(chan struct{}),
 	}
 
 	m.shells.Set(id, bgShell)
 
 	go func() {
-		defer close(bgShell.done)
-
-		err := shell.ExecStream(shellCtx, command, bgShell.stdout, bgShell.stderr)
-
+		err := shell.ExecStream(
+			shellCtx,
+			command,
+			io.MultiWriter(bgShell.stdout, bgShell.output),
+			io.MultiWriter(bgShell.stderr, bgShell.output),
+		)
+
+		bgShell.mu.Lock()
 		bgShell.exitErr = err
-		atomic.StoreInt64(&bgShell.completedAt, time.Now().Unix())
+		atomic.StoreInt64(&bgShell.completedAt, time.Now().UnixMilli())
+		close(bgShell.done)
+		detached := bgShell.detached
+		bgShell.mu.Unlock()
+
+		if detached {
+			backgroundBroker.Publish(pubsub.UpdatedEvent, bgShell.Job())
+		}
 	}()
 
 	return bgShell, nil
@@ -106,10 +167,11 @@ func (m *BackgroundShellManager) Get(id string) (*BackgroundShell, bool) {
 // Remove removes a background shell from the manager without terminating it.
 // This is useful when a shell has already completed and you just want to clean up tracking.
 func (m *BackgroundShellManager) Remove(id string) error {
-	_, ok := m.shells.Take(id)
+	shell, ok := m.shells.Take(id)
 	if !ok {
 		return fmt.Errorf("background shell not found: %s", id)
 	}
+	shell.publishRemoved()
 	return nil
 }
 
@@ -120,11 +182,26 @@ func (m *BackgroundShellManager) Kill(id string) error {
 		return fmt.Errorf("background shell not found: %s", id)
 	}
 
-	shell.cancel()
-	<-shell.done
+	shell.kill()
+	shell.publishRemoved()
 	return nil
 }
 
+// Jobs returns the shells that were detached to the background, oldest
+// first.
+func (m *BackgroundShellManager) Jobs() []BackgroundJob {
+	var jobs []BackgroundJob
+	for shell := range m.shells.Seq() {
+		if shell.IsDetached() {
+			jobs = append(jobs, shell.Job())
+		}
+	}
+	slices.SortFunc(jobs, func(a, b BackgroundJob) int {
+		return cmp.Or(a.StartedAt.Compare(b.StartedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return jobs
+}
+
 // BackgroundShellInfo contains information about a background shell.
 type BackgroundShellInfo struct {
 	ID          string
@@ -143,13 +220,13 @@ func (m *BackgroundShellManager) List() []string {
 
 // Cleanup removes completed jobs that have been finished for more than the retention period
 func (m *BackgroundShellManager) Cleanup() int {
-	now := time.Now().Unix()
-	retentionSeconds := int64(CompletedJobRetentionMinutes * 60)
+	now := time.Now().UnixMilli()
+	retentionMillis := int64(CompletedJobRetentionMinutes * 60 * 1000)
 
 	var toRemove []string
 	for shell := range m.shells.Seq() {
 		completedAt := atomic.LoadInt64(&shell.completedAt)
-		if completedAt > 0 && now-completedAt > retentionSeconds {
+		if completedAt > 0 && now-completedAt > retentionMillis {
 			toRemove = append(toRemove, shell.ID)
 		}
 	}
@@ -170,8 +247,7 @@ func (m *BackgroundShellManager) KillAll() {
 	m.shells.Reset(map[string]*BackgroundShell{})
 
 	for _, shell := range shells {
-		shell.cancel()
-		<-shell.done
+		shell.kill()
 	}
 }
 
@@ -185,6 +261,35 @@ func (bs *BackgroundShell) GetOutput() (stdout string, stderr string, done bool,
 	}
 }
 
+// ReadOutput returns the interleaved stdout and stderr output written from
+// the given offset on. start is the offset of the returned output, which is
+// past offset if older output was dropped, and next is the offset to pass to
+// only read output written after this call.
+func (bs *BackgroundShell) ReadOutput(offset int64) (output string, start, next int64) {
+	return bs.output.ReadSince(offset)
+}
+
+// WaitForOutput blocks until the output written from the given offset on
+// matches re, the command finishes or ctx is done. It reports whether the
+// output matched.
+func (bs *BackgroundShell) WaitForOutput(ctx context.Context, offset int64, re *regexp.Regexp) bool {
+	for {
+		// Get the channel before reading, so no write is missed.
+		changed := bs.output.Changed()
+		if output, _, _ := bs.output.ReadSince(offset); re.MatchString(output) {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-bs.done:
+			output, _, _ := bs.output.ReadSince(offset)
+			return re.MatchString(output)
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
 // IsDone checks if the background shell has finished execution.
 func (bs *BackgroundShell) IsDone() bool {
 	select {
@@ -199,3 +304,67 @@ func (bs *BackgroundShell) IsDone() bool {
 func (bs *BackgroundShell) Wait() {
 	<-bs.done
 }
+
+// Detach hands the shell over to the background: it is listed by
+// [BackgroundShellManager.Jobs] and events are published about it from now
+// on. If the command already finished, the updated event is published right
+// away so that its completion is never missed.
+func (bs *BackgroundShell) Detach() {
+	bs.mu.Lock()
+	if bs.detached {
+		bs.mu.Unlock()
+		return
+	}
+	bs.detached = true
+	done := bs.IsDone()
+	bs.mu.Unlock()
+
+	job := bs.Job()
+	backgroundBroker.Publish(pubsub.CreatedEvent, job)
+	if done {
+		backgroundBroker.Publish(pubsub.UpdatedEvent, job)
+	}
+}
+
+// IsDetached reports whether the shell was handed over to the background.
+func (bs *BackgroundShell) IsDetached() bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.detached
+}
+
+// Job returns a snapshot of the shell's state.
+func (bs *BackgroundShell) Job() BackgroundJob {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	job := BackgroundJob{
+		ID:          bs.ID,
+		SessionID:   bs.SessionID,
+		Command:     bs.Command,
+		Description: bs.Description,
+		WorkingDir:  bs.WorkingDir,
+		StartedAt:   bs.StartedAt,
+		Killed:      bs.killed,
+	}
+	if completedAt := atomic.LoadInt64(&bs.completedAt); completedAt > 0 {
+		job.CompletedAt = time.UnixMilli(completedAt)
+		job.ExitCode = ExitCode(bs.exitErr)
+	}
+	return job
+}
+
+func (bs *BackgroundShell) kill() {
+	bs.mu.Lock()
+	bs.killed = true
+	bs.mu.Unlock()
+
+	bs.cancel()
+	<-bs.done
+}
+
+func (bs *BackgroundShell) publishRemoved() {
+	if bs.IsDetached() {
+		backgroundBroker.Publish(pubsub.DeletedEvent, bs.Job())
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
 )
 
 func TestBackgroundShellManager_Start(t *testing.T) {
@@ -16,7 +18,7 @@ func TestBackgroundShellManager_Start(t *testing.T) {
 	workingDir := t.TempDir()
 	manager := GetBackgroundShellManager()
 
-	bgShell, err := manager.Start(ctx, workingDir, nil, "echo 'hello world'", "")
+	bgShell, err := manager.Start(ctx, workingDir, nil, nil, "echo 'hello world'", "")
 	if err != nil {
 		t.Fatalf("failed to start background shell: %v", err)
 	}
@@ -53,7 +55,7 @@ func TestBackgroundShellManager_Get(t *testing.T) {
 	workingDir := t.TempDir()
 	manager := GetBackgroundShellManager()
 
-	bgShell, err := manager.Start(ctx, workingDir, nil, "echo 'test'", "")
+	bgShell, err := manager.Start(ctx, workingDir, nil, nil, "echo 'test'", "")
 	if err != nil {
 		t.Fatalf("failed to start background shell: %v", err)
 	}
@@ -80,7 +82,7 @@ func TestBackgroundShellManager_Kill(t *testing.T) {
 	manager := GetBackgroundShellManager()
 
 	// Start a long-running command
-	bgShell, err := manager.Start(ctx, workingDir, nil, "sleep 10", "")
+	bgShell, err := manager.Start(ctx, workingDir, nil, nil, "sleep 10", "")
 	if err != nil {
 		t.Fatalf("failed to start background shell: %v", err)
 	}
@@ -121,7 +123,7 @@ func TestBackgroundShell_IsDone(t *testing.T) {
 	workingDir := t.TempDir()
 	manager := GetBackgroundShellManager()
 
-	bgShell, err := manager.Start(ctx, workingDir, nil, "echo 'quick'", "")
+	bgShell, err := manager.Start(ctx, workingDir, nil, nil, "echo 'quick'", "")
 	if err != nil {
 		t.Fatalf("failed to start background shell: %v", err)
 	}
@@ -148,7 +150,7 @@ func TestBackgroundShell_WithBlockFuncs(t *testing.T) {
 		CommandsBlocker([]string{"curl", "wget"}),
 	}
 
-	bgShell, err := manager.Start(ctx, workingDir, blockFuncs, "curl example.com", "")
+	bgShell, err := manager.Start(ctx, workingDir, blockFuncs, nil, "curl example.com", "")
 	if err != nil {
 		t.Fatalf("failed to start background shell: %v", err)
 	}
@@ -183,12 +185,12 @@ func TestBackgroundShellManager_List(t *testing.T) {
 	manager := GetBackgroundShellManager()
 
 	// Start two shells
-	bgShell1, err := manager.Start(ctx, workingDir, nil, "sleep 1", "")
+	bgShell1, err := manager.Start(ctx, workingDir, nil, nil, "sleep 1", "")
 	if err != nil {
 		t.Fatalf("failed to start first background shell: %v", err)
 	}
 
-	bgShell2, err := manager.Start(ctx, workingDir, nil, "sleep 1", "")
+	bgShell2, err := manager.Start(ctx, workingDir, nil, nil, "sleep 1", "")
 	if err != nil {
 		t.Fatalf("failed to start second background shell: %v", err)
 	}
@@ -227,17 +229,17 @@ func TestBackgroundShellManager_KillAll(t *testing.T) {
 	manager := GetBackgroundShellManager()
 
 	// Start multiple long-running shells
-	shell1, err := manager.Start(ctx, workingDir, nil, "sleep 10", "")
+	shell1, err := manager.Start(ctx, workingDir, nil, nil, "sleep 10", "")
 	if err != nil {
 		t.Fatalf("failed to start shell 1: %v", err)
 	}
 
-	shell2, err := manager.Start(ctx, workingDir, nil, "sleep 10", "")
+	shell2, err := manager.Start(ctx, workingDir, nil, nil, "sleep 10", "")
 	if err != nil {
 		t.Fatalf("failed to start shell 2: %v", err)
 	}
 
-	shell3, err := manager.Start(ctx, workingDir, nil, "sleep 10", "")
+	shell3, err := manager.Start(ctx, workingDir, nil, nil, "sleep 10", "")
 	if err != nil {
 		t.Fatalf("failed to start shell 3: %v", err)
 	}
@@ -280,3 +282,88 @@ func TestBackgroundShellManager_KillAll(t *testing.T) {
 		}
 	}
 }
+
+func TestBackgroundShellManager_Detach(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	events := SubscribeBackgroundJobs(ctx)
+
+	manager := GetBackgroundShellManager()
+	bgShell, err := manager.StartShell(ctx, "session", NewShell(&Options{WorkingDir: t.TempDir()}), "sleep 0.2; exit 3", "")
+	if err != nil {
+		t.Fatalf("failed to start background shell: %v", err)
+	}
+	defer manager.Kill(bgShell.ID)
+
+	for _, job := range manager.Jobs() {
+		if job.ID == bgShell.ID {
+			t.Fatal("expected shell not to be listed before it is detached")
+		}
+	}
+
+	bgShell.Detach()
+
+	listed := false
+	for _, job := range manager.Jobs() {
+		listed = listed || job.ID == bgShell.ID
+	}
+	if !listed {
+		t.Fatal("expected detached shell to be listed")
+	}
+
+	var got []pubsub.Event[BackgroundJob]
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case event := <-events:
+			if event.Payload.ID == bgShell.ID {
+				got = append(got, event)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got: %v", got)
+		}
+	}
+
+	if got[0].Type != pubsub.CreatedEvent || got[0].Payload.Done() {
+		t.Errorf("expected a created event for a running job, got: %+v", got[0])
+	}
+	if got[1].Type != pubsub.UpdatedEvent || !got[1].Payload.Done() {
+		t.Errorf("expected an updated event for a finished job, got: %+v", got[1])
+	}
+	if got[1].Payload.ExitCode != 3 || got[1].Payload.SessionID != "session" {
+		t.Errorf("expected exit code 3 in session, got: %+v", got[1].Payload)
+	}
+}
+
+func TestBackgroundShellManager_DetachFinished(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	events := SubscribeBackgroundJobs(ctx)
+
+	manager := GetBackgroundShellManager()
+	bgShell, err := manager.StartShell(ctx, "session", NewShell(&Options{WorkingDir: t.TempDir()}), "true", "")
+	if err != nil {
+		t.Fatalf("failed to start background shell: %v", err)
+	}
+	defer manager.Remove(bgShell.ID)
+	bgShell.Wait()
+
+	// A job that finished before it was detached must still be reported.
+	bgShell.Detach()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Payload.ID == bgShell.ID && event.Type == pubsub.UpdatedEvent {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the updated event")
+		}
+	}
+}
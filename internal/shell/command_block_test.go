@@ -374,3 +374,34 @@ func TestSplitArgsFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestPatternBlocker(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		args     []string
+		expected bool
+	}{
+		{"command only", "kubectl", []string{"kubectl", "get", "pods"}, true},
+		{"argument prefix", "kubectl delete", []string{"kubectl", "delete", "pod", "x"}, true},
+		{"different argument", "kubectl delete", []string{"kubectl", "get", "pods"}, false},
+		{"flag anywhere", "npm install -g", []string{"npm", "install", "typescript", "-g"}, true},
+		{"missing flag", "npm install -g", []string{"npm", "install", "typescript"}, false},
+		{"wildcard flag", "git push --force*", []string{"git", "push", "--force-with-lease", "origin"}, true},
+		{"wildcard flag exact", "git push --force*", []string{"git", "push", "--force"}, true},
+		{"wildcard flag no match", "git push --force*", []string{"git", "push", "origin", "main"}, false},
+		{"flag value ignored", "go test -exec", []string{"go", "test", "-exec=bash", "./..."}, true},
+		{"root path", "rm -rf /", []string{"rm", "-rf", "/"}, true},
+		{"other path", "rm -rf /", []string{"rm", "-rf", "/tmp/build"}, false},
+		{"wildcard argument", "kubectl delete *", []string{"kubectl", "delete", "pods/web"}, true},
+		{"wildcard command", "pip*", []string{"pip3", "install"}, true},
+		{"empty pattern", "", []string{"ls"}, false},
+		{"empty args", "ls", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, PatternBlocker(tt.pattern)(tt.args))
+		})
+	}
+}
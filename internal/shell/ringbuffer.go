@@ -0,0 +1,85 @@
+package shell
+
+import "sync"
+
+// ringBuffer is a concurrency safe [io.Writer] that keeps the last size bytes
+// written to it. Bytes are addressed by their offset in everything ever
+// written, so readers can pick up where they left off.
+type ringBuffer struct {
+	mu      sync.Mutex
+	size    int
+	buf     []byte // grows up to size, then wraps around
+	written int64
+	changed chan struct{}
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+// Write implements [io.Writer].
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	if n == 0 {
+		return 0, nil
+	}
+	for len(p) > 0 && len(r.buf) < r.size {
+		chunk := min(len(p), r.size-len(r.buf))
+		r.buf = append(r.buf, p[:chunk]...)
+		p = p[chunk:]
+		r.written += int64(chunk)
+	}
+	if skip := len(p) - r.size; skip > 0 {
+		// These bytes would be overwritten right away.
+		p = p[skip:]
+		r.written += int64(skip)
+	}
+	for len(p) > 0 {
+		chunk := copy(r.buf[r.written%int64(r.size):], p)
+		p = p[chunk:]
+		r.written += int64(chunk)
+	}
+
+	if r.changed != nil {
+		close(r.changed)
+		r.changed = nil
+	}
+	return n, nil
+}
+
+// ReadSince returns the retained bytes from offset on. start is the offset of
+// the first returned byte, which is past offset if older bytes were already
+// dropped, and next is the offset to read new bytes from.
+func (r *ringBuffer) ReadSince(offset int64) (data string, start, next int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start = min(max(offset, r.written-int64(len(r.buf))), r.written)
+	out := make([]byte, 0, r.written-start)
+	for o := start; o < r.written; {
+		pos := int(o % int64(r.size))
+		end := min(len(r.buf), pos+int(r.written-o))
+		out = append(out, r.buf[pos:end]...)
+		o += int64(end - pos)
+	}
+	return string(out), start, r.written
+}
+
+// String returns all retained bytes.
+func (r *ringBuffer) String() string {
+	data, _, _ := r.ReadSince(0)
+	return data
+}
+
+// Changed returns a channel that is closed on the next write.
+func (r *ringBuffer) Changed() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.changed == nil {
+		r.changed = make(chan struct{})
+	}
+	return r.changed
+}
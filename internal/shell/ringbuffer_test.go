@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBuffer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps everything below the size", func(t *testing.T) {
+		t.Parallel()
+		r := newRingBuffer(10)
+		r.Write([]byte("hello "))
+		r.Write([]byte("you"))
+		require.Equal(t, "hello you", r.String())
+
+		data, start, next := r.ReadSince(6)
+		require.Equal(t, "you", data)
+		require.EqualValues(t, 6, start)
+		require.EqualValues(t, 9, next)
+	})
+
+	t.Run("drops the oldest bytes", func(t *testing.T) {
+		t.Parallel()
+		r := newRingBuffer(10)
+		r.Write([]byte("0123456789"))
+		r.Write([]byte("abcd"))
+		require.Equal(t, "456789abcd", r.String())
+
+		data, start, next := r.ReadSince(2)
+		require.Equal(t, "456789abcd", data)
+		require.EqualValues(t, 4, start)
+		require.EqualValues(t, 14, next)
+
+		data, start, _ = r.ReadSince(12)
+		require.Equal(t, "cd", data)
+		require.EqualValues(t, 12, start)
+	})
+
+	t.Run("writes larger than the size", func(t *testing.T) {
+		t.Parallel()
+		r := newRingBuffer(4)
+		r.Write([]byte("ab"))
+		n, err := r.Write([]byte(strings.Repeat("x", 7) + "wxyz"))
+		require.NoError(t, err)
+		require.Equal(t, 11, n)
+		require.Equal(t, "wxyz", r.String())
+
+		data, start, next := r.ReadSince(20)
+		require.Empty(t, data)
+		require.EqualValues(t, 13, start)
+		require.EqualValues(t, 13, next)
+	})
+
+	t.Run("signals writes", func(t *testing.T) {
+		t.Parallel()
+		r := newRingBuffer(4)
+		changed := r.Changed()
+		select {
+		case <-changed:
+			t.Fatal("expected no change before writing")
+		default:
+		}
+		r.Write([]byte("a"))
+		<-changed
+	})
+}
@@ -0,0 +1,203 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// SandboxHelperCommand is the hidden argument the crush binary is re-executed
+// with to apply the sandbox before running a command. The main package must
+// hand control to [RunSandboxHelper] when it sees it.
+const SandboxHelperCommand = "__crush_sandbox_exec"
+
+// sandboxExitCode is the exit code used when a command could not be started
+// inside the sandbox, mirroring the shell's "cannot execute" status.
+const sandboxExitCode = 126
+
+// Sandbox restricts what external commands run by a [Shell] can do. It is
+// enforced by the kernel where supported (Landlock on Linux), so it also
+// applies to scripts and interpreters started by the command.
+type Sandbox struct {
+	// WritablePaths are the files and directories commands may write to.
+	// Everything else on the filesystem is read-only.
+	WritablePaths []string
+	// AllowNetwork lets commands open TCP connections. Landlock does not
+	// filter UDP, so this only covers TCP, and it can only block them from
+	// Linux 6.7, see [SandboxBlocksNetwork]. On older kernels commands are
+	// refused unless this is set.
+	AllowNetwork bool
+}
+
+// alwaysWritable are device files programs commonly write to which never
+// need to be restricted.
+var alwaysWritable = []string{"/dev/null", "/dev/tty"}
+
+func (sb *Sandbox) writablePaths() []string {
+	paths := make([]string, 0, len(sb.WritablePaths)+len(alwaysWritable))
+	for _, p := range sb.WritablePaths {
+		if p == "" {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			p = resolved
+		}
+		paths = append(paths, filepath.Clean(p))
+	}
+	return append(paths, alwaysWritable...)
+}
+
+// allowsWrite reports whether path is one of the writable paths or lives
+// under one of them.
+func (sb *Sandbox) allowsWrite(path string) bool {
+	path = resolvePath(path)
+	for _, p := range sb.writablePaths() {
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath resolves symlinks in the longest existing prefix of path, so
+// paths to files that do not exist yet can still be checked.
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir, file := filepath.Split(path)
+	if dir == "" || filepath.Clean(dir) == path {
+		return path
+	}
+	return filepath.Join(resolvePath(filepath.Clean(dir)), file)
+}
+
+// helperArgs builds the arguments to re-execute crush as a sandbox helper
+// that will apply the sandbox and then run path with args.
+func (sb *Sandbox) helperArgs(exe, path string, args []string) []string {
+	helper := []string{exe, SandboxHelperCommand}
+	for _, p := range sb.writablePaths() {
+		helper = append(helper, "--write="+p)
+	}
+	if sb.AllowNetwork {
+		helper = append(helper, "--net")
+	}
+	helper = append(helper, "--", path)
+	return append(helper, args...)
+}
+
+var (
+	sandboxCheck        = sync.OnceValue(sandboxAvailable)
+	sandboxNetworkCheck = sync.OnceValue(sandboxBlocksNetwork)
+)
+
+var errSandboxNetwork = errors.New("blocking network access requires Landlock ABI 4 (Linux 6.7 or newer), set allow_network to run commands on this kernel")
+
+// SandboxAvailable returns an error describing why commands cannot be
+// sandboxed on this system, or nil if they can.
+func SandboxAvailable() error {
+	return sandboxCheck()
+}
+
+// SandboxBlocksNetwork reports whether the sandbox can block network access
+// on this system.
+func SandboxBlocksNetwork() bool {
+	return sandboxNetworkCheck()
+}
+
+// Check returns an error describing why commands can't run in the sandbox on
+// this system, or nil if they can. Commands are never run with less
+// restrictions than configured.
+func (sb *Sandbox) Check() error {
+	if err := SandboxAvailable(); err != nil {
+		return fmt.Errorf("sandbox is enabled but not available: %w", err)
+	}
+	if !sb.AllowNetwork && !SandboxBlocksNetwork() {
+		return fmt.Errorf("sandbox is enabled but can't be applied: %w", errSandboxNetwork)
+	}
+	return nil
+}
+
+func (s *Shell) sandboxHandler() func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return next(ctx, args)
+			}
+			if err := s.sandbox.Check(); err != nil {
+				return err
+			}
+
+			hc := interp.HandlerCtx(ctx)
+			path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+			if err != nil {
+				// Let the next handler report the lookup error as usual.
+				return next(ctx, args)
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("sandbox: could not find the crush executable: %w", err)
+			}
+			return next(ctx, s.sandbox.helperArgs(exe, path, args))
+		}
+	}
+}
+
+// sandboxOpenHandler enforces the writable paths for redirections, which the
+// interpreter performs in-process and are not covered by the kernel sandbox.
+func (s *Shell) sandboxOpenHandler() interp.OpenHandlerFunc {
+	open := interp.DefaultOpenHandler()
+	return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+			abs := path
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(interp.HandlerCtx(ctx).Dir, abs)
+			}
+			if !s.sandbox.allowsWrite(abs) {
+				return nil, fmt.Errorf("sandbox: writing to %s is not allowed, writable paths are: %s", path, strings.Join(s.sandbox.writablePaths(), ", "))
+			}
+		}
+		return open(ctx, path, flag, perm)
+	}
+}
+
+// RunSandboxHelper applies the sandbox described by args and replaces the
+// current process with the sandboxed command. It never returns.
+func RunSandboxHelper(args []string) {
+	var (
+		writable     []string
+		allowNetwork bool
+	)
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		switch {
+		case strings.HasPrefix(arg, "--write="):
+			writable = append(writable, strings.TrimPrefix(arg, "--write="))
+		case arg == "--net":
+			allowNetwork = true
+		default:
+			sandboxFatal(fmt.Errorf("unknown argument %q", arg))
+		}
+	}
+	if len(args) < 2 {
+		sandboxFatal(fmt.Errorf("missing command"))
+	}
+	sandboxFatal(sandboxExec(writable, allowNetwork, args[0], args[1:]))
+}
+
+func sandboxFatal(err error) {
+	fmt.Fprintf(os.Stderr, "crush sandbox: %v\n", err)
+	os.Exit(sandboxExitCode)
+}
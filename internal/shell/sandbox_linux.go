@@ -0,0 +1,138 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockWriteAccess are the filesystem rights that modify the filesystem,
+// which are the only ones the sandbox restricts. Reading and executing is
+// always allowed.
+const landlockWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+	unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+	unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+func landlockABI() int {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(v)
+}
+
+func sandboxAvailable() error {
+	if landlockABI() < 1 {
+		return errors.New("the kernel does not support Landlock (Linux 5.13 or newer with Landlock enabled is required)")
+	}
+	return nil
+}
+
+func sandboxBlocksNetwork() bool {
+	return landlockABI() >= 4
+}
+
+func sandboxExec(writable []string, allowNetwork bool, path string, args []string) error {
+	// Landlock and no_new_privs apply to the calling thread only, and are
+	// inherited by the process started with execve from that thread.
+	runtime.LockOSThread()
+
+	if err := landlockRestrict(writable, allowNetwork); err != nil {
+		return err
+	}
+	// The environment is the one the shell built for the command, as the
+	// helper runs before crush loads anything into its own.
+	if err := syscall.Exec(path, args, os.Environ()); err != nil {
+		return fmt.Errorf("could not execute %s: %w", path, err)
+	}
+	return nil
+}
+
+func landlockRestrict(writable []string, allowNetwork bool) error {
+	abi := landlockABI()
+	if abi < 1 {
+		return sandboxAvailable()
+	}
+
+	fsAccess := uint64(landlockWriteAccess)
+	// Only these rights can be granted on a file rather than a directory.
+	fileAccess := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE)
+	if abi >= 2 {
+		fsAccess |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		fsAccess |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+		fileAccess |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: fsAccess}
+	if !allowNetwork {
+		if abi < 4 {
+			return errSandboxNetwork
+		}
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("could not create Landlock ruleset: %w", errno)
+	}
+	rulesetFd := int(fd)
+	defer unix.Close(rulesetFd)
+
+	for _, p := range writable {
+		if err := landlockAllow(rulesetFd, p, fsAccess, fileAccess); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not set no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0); errno != 0 {
+		return fmt.Errorf("could not enforce Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+func landlockAllow(rulesetFd int, path string, dirAccess, fileAccess uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			// Writable paths that do not exist yet cannot be created anyway.
+			return nil
+		}
+		return fmt.Errorf("could not open writable path %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("could not stat writable path %s: %w", path, err)
+	}
+	access := dirAccess
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access = fileAccess
+	}
+
+	rule := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("could not allow writes to %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package shell
+
+import "errors"
+
+var errSandboxUnsupported = errors.New("the sandbox is only supported on Linux")
+
+func sandboxAvailable() error {
+	return errSandboxUnsupported
+}
+
+func sandboxBlocksNetwork() bool {
+	return false
+}
+
+func sandboxExec([]string, bool, string, []string) error {
+	return errSandboxUnsupported
+}
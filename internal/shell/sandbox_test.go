@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the sandbox helper, the same way the
+// crush binary does.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == SandboxHelperCommand {
+		RunSandboxHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+func TestSandbox_AllowsWrite(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sb := &Sandbox{WritablePaths: []string{dir}}
+
+	require.True(t, sb.allowsWrite(dir))
+	require.True(t, sb.allowsWrite(filepath.Join(dir, "new", "file.txt")))
+	require.True(t, sb.allowsWrite("/dev/null"))
+	require.False(t, sb.allowsWrite(dir+"-sibling"))
+	require.False(t, sb.allowsWrite(filepath.Join(dir, "..", "escape")))
+}
+
+func TestSandbox_Redirections(t *testing.T) {
+	t.Parallel()
+
+	work := t.TempDir()
+	outside := t.TempDir()
+	sh := NewShell(&Options{
+		WorkingDir: work,
+		Sandbox:    &Sandbox{WritablePaths: []string{work}},
+	})
+
+	_, _, err := sh.Exec(t.Context(), "echo ok > inside.txt")
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(work, "inside.txt"))
+
+	_, _, err = sh.Exec(t.Context(), "echo nope > "+filepath.Join(outside, "outside.txt"))
+	require.ErrorContains(t, err, "sandbox: writing to")
+	require.NoFileExists(t, filepath.Join(outside, "outside.txt"))
+}
+
+func TestSandbox_Commands(t *testing.T) {
+	t.Parallel()
+
+	if err := SandboxAvailable(); err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+
+	work := t.TempDir()
+	outside := t.TempDir()
+	sh := NewShell(&Options{
+		WorkingDir: work,
+		Sandbox:    &Sandbox{WritablePaths: []string{work}, AllowNetwork: true},
+	})
+
+	_, stderr, err := sh.Exec(t.Context(), "touch inside.txt")
+	require.NoError(t, err, stderr)
+	require.FileExists(t, filepath.Join(work, "inside.txt"))
+
+	_, _, err = sh.Exec(t.Context(), "touch "+filepath.Join(outside, "outside.txt"))
+	require.Error(t, err)
+	require.NotZero(t, ExitCode(err))
+	require.NoFileExists(t, filepath.Join(outside, "outside.txt"))
+
+	// Scripts started by the command are confined too.
+	_, _, err = sh.Exec(t.Context(), "sh -c 'echo nope > "+filepath.Join(outside, "script.txt")+"'")
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(outside, "script.txt"))
+}
+
+func TestSandbox_Check(t *testing.T) {
+	t.Parallel()
+
+	if err := SandboxAvailable(); err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+
+	require.NoError(t, (&Sandbox{AllowNetwork: true}).Check())
+	// Commands are refused rather than run with network access.
+	if SandboxBlocksNetwork() {
+		require.NoError(t, (&Sandbox{}).Check())
+	} else {
+		require.ErrorIs(t, (&Sandbox{}).Check(), errSandboxNetwork)
+	}
+}
+
+func TestSandbox_Environment(t *testing.T) {
+	t.Parallel()
+
+	if err := (&Sandbox{}).Check(); err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+
+	work := t.TempDir()
+	sh := NewShell(&Options{
+		WorkingDir: work,
+		Env:        []string{"PATH=" + os.Getenv("PATH"), "CRUSH_SANDBOX_TEST=shell"},
+		Sandbox:    &Sandbox{WritablePaths: []string{work}},
+	})
+
+	// Commands get the environment of the shell, not the one of crush.
+	stdout, stderr, err := sh.Exec(t.Context(), "env")
+	require.NoError(t, err, stderr)
+	require.Contains(t, stdout, "CRUSH_SANDBOX_TEST=shell\n")
+	require.NotContains(t, stdout, "HOME=")
+}
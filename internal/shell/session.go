@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/csync"
+)
+
+// SessionShellManager keeps a shell per session so that the working
+// directory, environment variables and functions carry over between
+// commands.
+type SessionShellManager struct {
+	shells *csync.Map[string, *Shell]
+}
+
+var (
+	sessionManager     *SessionShellManager
+	sessionManagerOnce sync.Once
+)
+
+// GetSessionShellManager returns the singleton session shell manager.
+func GetSessionShellManager() *SessionShellManager {
+	sessionManagerOnce.Do(func() {
+		sessionManager = &SessionShellManager{shells: newShellMap()}
+	})
+	return sessionManager
+}
+
+// Checkout returns a new shell created from opts that carries the state of
+// the session's shell. Commands run in it only affect the session once the
+// shell is passed to [SessionShellManager.Save], so commands running
+// concurrently or in the background never interfere with each other.
+func (m *SessionShellManager) Checkout(sessionID string, opts *Options) *Shell {
+	sh := NewShell(opts)
+	saved, ok := m.shells.Get(sessionID)
+	if !ok {
+		return sh
+	}
+
+	saved.mu.Lock()
+	defer saved.mu.Unlock()
+	if info, err := os.Stat(saved.cwd); err == nil && info.IsDir() {
+		sh.cwd = saved.cwd
+	}
+	sh.env = slices.Clone(saved.env)
+	sh.funcs = maps.Clone(saved.funcs)
+	return sh
+}
+
+// Save makes sh the session's shell.
+func (m *SessionShellManager) Save(sessionID string, sh *Shell) {
+	m.shells.Set(sessionID, sh)
+}
+
+// Reset drops the session's shell, so the next command starts from scratch.
+func (m *SessionShellManager) Reset(sessionID string) {
+	m.shells.Del(sessionID)
+}
+
+func newShellMap() *csync.Map[string, *Shell] {
+	return csync.NewMap[string, *Shell]()
+}
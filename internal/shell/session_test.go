@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionShellManager(t *testing.T) {
+	t.Parallel()
+
+	work := t.TempDir()
+	sub := filepath.Join(work, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+
+	m := &SessionShellManager{shells: newShellMap()}
+	opts := &Options{WorkingDir: work}
+
+	sh := m.Checkout("session", opts)
+	_, _, err := sh.Exec(t.Context(), "cd sub && export GREETING=hello && greet() { echo \"$GREETING $1\"; }")
+	require.NoError(t, err)
+	m.Save("session", sh)
+
+	t.Run("state carries over", func(t *testing.T) {
+		sh := m.Checkout("session", opts)
+		require.Equal(t, sub, sh.GetWorkingDir())
+
+		stdout, _, err := sh.Exec(t.Context(), "greet world")
+		require.NoError(t, err)
+		require.Equal(t, "hello world", strings.TrimSpace(stdout))
+	})
+
+	t.Run("other sessions are not affected", func(t *testing.T) {
+		sh := m.Checkout("other", opts)
+		require.Equal(t, work, sh.GetWorkingDir())
+
+		stdout, _, err := sh.Exec(t.Context(), "echo \"[$GREETING]\"")
+		require.NoError(t, err)
+		require.Equal(t, "[]", strings.TrimSpace(stdout))
+	})
+
+	t.Run("unsaved commands are discarded", func(t *testing.T) {
+		sh := m.Checkout("session", opts)
+		_, _, err := sh.Exec(t.Context(), "cd .. && export GREETING=bye")
+		require.NoError(t, err)
+
+		sh = m.Checkout("session", opts)
+		require.Equal(t, sub, sh.GetWorkingDir())
+	})
+
+	t.Run("customized", func(t *testing.T) {
+		sh := m.Checkout("other", opts)
+		_, _, err := sh.Exec(t.Context(), "cd sub && export GREETING=hi")
+		require.NoError(t, err)
+		require.False(t, sh.Customized())
+
+		// Functions and PATH change what a command name runs.
+		require.True(t, m.Checkout("session", opts).Customized())
+		sh = m.Checkout("other", opts)
+		_, _, err = sh.Exec(t.Context(), "export PATH="+work+":$PATH")
+		require.NoError(t, err)
+		require.True(t, sh.Customized())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		m := &SessionShellManager{shells: newShellMap()}
+		sh := m.Checkout("session", opts)
+		_, _, err := sh.Exec(t.Context(), "cd sub")
+		require.NoError(t, err)
+		m.Save("session", sh)
+
+		m.Reset("session")
+		require.Equal(t, work, m.Checkout("session", opts).GetWorkingDir())
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"slices"
 	"strings"
@@ -52,10 +53,13 @@ type BlockFunc func(args []string) bool
 // Shell provides cross-platform shell execution with optional state persistence
 type Shell struct {
 	env        []string
+	baseEnv    []string
 	cwd        string
 	mu         sync.Mutex
 	logger     Logger
 	blockFuncs []BlockFunc
+	sandbox    *Sandbox
+	funcs      map[string]*syntax.Stmt
 }
 
 // Options for creating a new shell
@@ -64,6 +68,8 @@ type Options struct {
 	Env        []string
 	Logger     Logger
 	BlockFuncs []BlockFunc
+	// Sandbox, when set, runs external commands in a sandbox.
+	Sandbox *Sandbox
 }
 
 // NewShell creates a new shell instance with the given options
@@ -90,8 +96,10 @@ func NewShell(opts *Options) *Shell {
 	return &Shell{
 		cwd:        cwd,
 		env:        env,
+		baseEnv:    env,
 		logger:     logger,
 		blockFuncs: opts.BlockFuncs,
+		sandbox:    opts.Sandbox,
 	}
 }
 
@@ -158,6 +166,37 @@ func (s *Shell) SetEnv(key, value string) {
 	s.env = append(s.env, keyPrefix+value)
 }
 
+// commandEnv are the environment variables that change which program a
+// command name runs, or what it loads.
+var commandEnv = []string{"PATH", "LD_PRELOAD", "LD_LIBRARY_PATH"}
+
+// Customized reports whether command names may no longer run the programs
+// they usually stand for, because functions were defined in the shell or
+// its PATH changed since it was created.
+func (s *Shell) Customized() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.funcs) > 0 {
+		return true
+	}
+	for _, name := range commandEnv {
+		if envValue(s.env, name) != envValue(s.baseEnv, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func envValue(env []string, name string) string {
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
 // SetBlockFuncs sets the command block functions for the shell
 func (s *Shell) SetBlockFuncs(blockFuncs []BlockFunc) {
 	s.mu.Lock()
@@ -200,6 +239,70 @@ func ArgumentsBlocker(cmd string, args []string, flags []string) BlockFunc {
 	}
 }
 
+// PatternBlocker creates a BlockFunc from a pattern made of a command name
+// followed by the arguments and flags to block, e.g. "git push --force*".
+// Arguments must match the leading arguments of the command in order, while
+// flags may appear anywhere. Flag values after '=' are ignored. Every word
+// may use '*' to match any sequence of characters.
+func PatternBlocker(pattern string) BlockFunc {
+	words := strings.Fields(pattern)
+	if len(words) == 0 {
+		return func([]string) bool { return false }
+	}
+	cmd := words[0]
+	args, flags := splitArgsFlags(words[1:])
+
+	return func(parts []string) bool {
+		if len(parts) == 0 || !wildcardMatch(cmd, parts[0]) {
+			return false
+		}
+
+		argParts, flagParts := splitArgsFlags(parts[1:])
+		if len(argParts) < len(args) {
+			return false
+		}
+		for i, arg := range args {
+			if !wildcardMatch(arg, argParts[i]) {
+				return false
+			}
+		}
+		for _, flag := range flags {
+			if !slices.ContainsFunc(flagParts, func(f string) bool {
+				return wildcardMatch(flag, f)
+			}) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// wildcardMatch reports whether s matches pattern, where '*' matches any
+// sequence of characters, including none.
+func wildcardMatch(pattern, s string) bool {
+	star, match := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == s[i] && pattern[p] != '*':
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, i
+			p++
+		case star >= 0:
+			match++
+			p, i = star+1, match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
 func splitArgsFlags(parts []string) (args []string, flags []string) {
 	args = make([]string, 0, len(parts))
 	flags = make([]string, 0, len(parts))
@@ -238,18 +341,33 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 
 // newInterp creates a new interpreter with the current shell state
 func (s *Shell) newInterp(stdout, stderr io.Writer) (*interp.Runner, error) {
-	return interp.New(
+	opts := []interp.RunnerOption{
 		interp.StdIO(nil, stdout, stderr),
 		interp.Interactive(false),
 		interp.Env(expand.ListEnviron(s.env...)),
 		interp.Dir(s.cwd),
 		interp.ExecHandlers(s.execHandlers()...),
-	)
+	}
+	if s.sandbox != nil {
+		opts = append(opts, interp.OpenHandler(s.sandboxOpenHandler()))
+	}
+	runner, err := interp.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.funcs) > 0 {
+		// Functions are cleared on reset, so reset now and restore them
+		// before the runner is used.
+		runner.Reset()
+		runner.Funcs = maps.Clone(s.funcs)
+	}
+	return runner, nil
 }
 
 // updateShellFromRunner updates the shell from the interpreter after execution
 func (s *Shell) updateShellFromRunner(runner *interp.Runner) {
 	s.cwd = runner.Dir
+	s.funcs = runner.Funcs
 	s.env = nil
 	for name, vr := range runner.Vars {
 		s.env = append(s.env, fmt.Sprintf("%s=%s", name, vr.Str))
@@ -290,6 +408,11 @@ func (s *Shell) execHandlers() []func(next interp.ExecHandlerFunc) interp.ExecHa
 	handlers := []func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc{
 		s.blockHandler(),
 	}
+	if s.sandbox != nil {
+		// The Go core utils run in-process and would escape the sandbox, so
+		// every command goes through the sandboxed executables instead.
+		return append(handlers, s.sandboxHandler())
+	}
 	if useGoCoreUtils {
 		handlers = append(handlers, coreutils.ExecHandler)
 	}
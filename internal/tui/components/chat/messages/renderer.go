@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/crush/internal/agent"
 	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/ansiext"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/todos"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
@@ -188,6 +189,8 @@ func init() {
 	registry.register(tools.ViewToolName, func() renderer { return viewRenderer{} })
 	registry.register(tools.EditToolName, func() renderer { return editRenderer{} })
 	registry.register(tools.MultiEditToolName, func() renderer { return multiEditRenderer{} })
+	registry.register(tools.EditLinesToolName, func() renderer { return editLinesRenderer{} })
+	registry.register(tools.NotebookEditToolName, func() renderer { return notebookEditRenderer{} })
 	registry.register(tools.WriteToolName, func() renderer { return writeRenderer{} })
 	registry.register(tools.FetchToolName, func() renderer { return simpleFetchRenderer{} })
 	registry.register(tools.AgenticFetchToolName, func() renderer { return agenticFetchRenderer{} })
@@ -198,6 +201,11 @@ func init() {
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
+	registry.register(tools.RenameToolName, func() renderer { return renameRenderer{} })
+	registry.register(tools.ApplyPatchToolName, func() renderer { return applyPatchRenderer{} })
+	registry.register(tools.MoveToolName, func() renderer { return moveRenderer{} })
+	registry.register(tools.CopyToolName, func() renderer { return copyRenderer{} })
+	registry.register(tools.DeleteToolName, func() renderer { return deleteRenderer{} })
 	registry.register(tools.TodosToolName, func() renderer { return todosRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
 }
@@ -246,13 +254,18 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 
 	cmd := strings.ReplaceAll(params.Command, "\n", " ")
 	cmd = strings.ReplaceAll(cmd, "\t", "    ")
-	args := newParamBuilder().
+	pb := newParamBuilder().
 		addMain(cmd).
 		addFlag("background", params.RunInBackground).
-		build()
+		addFlag("reset", params.ResetShell)
 	if v.call.Finished {
 		var meta tools.BashResponseMetadata
 		_ = br.unmarshalParams(v.result.Metadata, &meta)
+		// Show where the command ended up when it is not the project
+		// directory, e.g. after a cd in a persistent shell.
+		if meta.WorkingDirectory != "" && meta.WorkingDirectory != config.Get().WorkingDir() {
+			pb.addKeyValue("cwd", fsext.PrettyPath(meta.WorkingDirectory))
+		}
 		if meta.Background {
 			description := cmp.Or(meta.Description, params.Command)
 			width := v.textWidth()
@@ -272,7 +285,7 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 		}
 	}
 
-	return br.renderWithParams(v, "Bash", args, func() string {
+	return br.renderWithParams(v, "Bash", pb.build(), func() string {
 		var meta tools.BashResponseMetadata
 		if err := br.unmarshalParams(v.result.Metadata, &meta); err != nil {
 			return renderPlainContent(v, v.result.Content)
@@ -435,6 +448,7 @@ func (vr viewRenderer) Render(v *toolCallCmp) string {
 		addMain(file).
 		addKeyValue("limit", formatNonZero(params.Limit)).
 		addKeyValue("offset", formatNonZero(params.Offset)).
+		addKeyValue("pages", params.Pages).
 		build()
 
 	return vr.renderWithParams(v, "View", args, func() string {
@@ -458,6 +472,32 @@ func formatNonZero(value int) string {
 	return fmt.Sprintf("%d", value)
 }
 
+// renderDiagnosticsDelta adds a line below the content of a tool that
+// changed files, with the diagnostics the change introduced and resolved.
+func renderDiagnosticsDelta(v *toolCallCmp, content string, delta *tools.DiagnosticsDelta) string {
+	if delta == nil {
+		return content
+	}
+	t := styles.CurrentTheme()
+	tag := t.S().Base.Padding(0, 2).Background(t.Info).Foreground(t.White).Render("Diagnostics")
+	newStyle, resolvedStyle := t.S().Muted, t.S().Muted
+	if delta.New > 0 {
+		newStyle = t.S().Error
+	}
+	if delta.Resolved > 0 {
+		resolvedStyle = t.S().Success
+	}
+	counts := strings.Join([]string{
+		newStyle.Render(fmt.Sprintf("%d new", delta.New)),
+		resolvedStyle.Render(fmt.Sprintf("%d resolved", delta.Resolved)),
+		t.S().Muted.Render(fmt.Sprintf("%d unchanged", delta.Unchanged)),
+	}, t.S().Muted.Render(" · "))
+	line := t.S().Base.
+		Width(v.textWidth() - 2).
+		Render(fmt.Sprintf("%s %s", tag, counts))
+	return lipgloss.JoinVertical(lipgloss.Left, content, "", line)
+}
+
 // -----------------------------------------------------------------------------
 //  Edit renderer
 // -----------------------------------------------------------------------------
@@ -469,7 +509,6 @@ type editRenderer struct {
 
 // Render displays the edited file with a formatted diff of changes
 func (er editRenderer) Render(v *toolCallCmp) string {
-	t := styles.CurrentTheme()
 	var params tools.EditParams
 	var args []string
 	if err := er.unmarshalParams(v.call.Input, &params); err == nil {
@@ -478,30 +517,97 @@ func (er editRenderer) Render(v *toolCallCmp) string {
 	}
 
 	return er.renderWithParams(v, "Edit", args, func() string {
-		var meta tools.EditResponseMetadata
-		if err := er.unmarshalParams(v.result.Metadata, &meta); err != nil {
-			return renderPlainContent(v, v.result.Content)
-		}
+		return er.renderDiff(v, params.FilePath)
+	})
+}
 
-		formatter := core.DiffFormatter().
-			Before(fsext.PrettyPath(params.FilePath), meta.OldContent).
-			After(fsext.PrettyPath(params.FilePath), meta.NewContent).
-			Width(v.textWidth() - 2) // -2 for padding
-		if v.textWidth() > 120 {
-			formatter = formatter.Split()
+// renderDiff renders the diff of the edit response metadata of the file
+func (er editRenderer) renderDiff(v *toolCallCmp, filePath string) string {
+	t := styles.CurrentTheme()
+	var meta tools.EditResponseMetadata
+	if err := er.unmarshalParams(v.result.Metadata, &meta); err != nil {
+		return renderPlainContent(v, v.result.Content)
+	}
+
+	formatter := core.DiffFormatter().
+		Before(fsext.PrettyPath(filePath), meta.OldContent).
+		After(fsext.PrettyPath(filePath), meta.NewContent).
+		Width(v.textWidth() - 2) // -2 for padding
+	if v.textWidth() > 120 {
+		formatter = formatter.Split()
+	}
+	// add a message to the bottom if the content was truncated
+	formatted := formatter.String()
+	if lipgloss.Height(formatted) > responseContextHeight {
+		contentLines := strings.Split(formatted, "\n")
+		truncateMessage := t.S().Muted.
+			Background(t.BgBaseLighter).
+			PaddingLeft(2).
+			Width(v.textWidth() - 2).
+			Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
+		formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+	}
+	return renderDiagnosticsDelta(v, formatted, meta.Diagnostics)
+}
+
+// -----------------------------------------------------------------------------
+//  Edit lines renderer
+// -----------------------------------------------------------------------------
+
+// editLinesRenderer handles line range edits, showing the range and the diff
+type editLinesRenderer struct {
+	editRenderer
+}
+
+// Render displays the edited file and line range with a formatted diff
+func (er editLinesRenderer) Render(v *toolCallCmp) string {
+	var params tools.EditLinesParams
+	var args []string
+	if err := er.unmarshalParams(v.call.Input, &params); err == nil {
+		file := fsext.PrettyPath(params.FilePath)
+		args = newParamBuilder().
+			addMain(file).
+			addKeyValue("lines", fmt.Sprintf("%d-%d", params.StartLine, params.EndLine)).
+			build()
+	}
+
+	return er.renderWithParams(v, "Edit Lines", args, func() string {
+		return er.renderDiff(v, params.FilePath)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Notebook edit renderer
+// -----------------------------------------------------------------------------
+
+// notebookEditRenderer handles notebook cell edits, showing the cell and the diff
+type notebookEditRenderer struct {
+	editRenderer
+}
+
+// Render displays the edited notebook and cell with a formatted diff
+func (nr notebookEditRenderer) Render(v *toolCallCmp) string {
+	var params tools.NotebookEditParams
+	var args []string
+	if err := nr.unmarshalParams(v.call.Input, &params); err == nil {
+		file := fsext.PrettyPath(params.FilePath)
+		cell := params.CellID
+		if cell == "" {
+			cell = fmt.Sprintf("%d", params.CellIndex)
 		}
-		// add a message to the bottom if the content was truncated
-		formatted := formatter.String()
-		if lipgloss.Height(formatted) > responseContextHeight {
-			contentLines := strings.Split(formatted, "\n")
-			truncateMessage := t.S().Muted.
-				Background(t.BgBaseLighter).
-				PaddingLeft(2).
-				Width(v.textWidth() - 2).
-				Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
-			formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+		mode := params.EditMode
+		if mode == "" {
+			mode = "replace"
 		}
-		return formatted
+		args = newParamBuilder().
+			addMain(file).
+			addKeyValue("cell", cell).
+			addKeyValue("mode", mode).
+			build()
+	}
+
+	return nr.renderWithParams(v, "Notebook Edit", args, func() string {
+		return nr.renderDiff(v, params.FilePath)
 	})
 }
 
@@ -563,7 +669,7 @@ func (mer multiEditRenderer) Render(v *toolCallCmp) string {
 			formatted = lipgloss.JoinVertical(lipgloss.Left, formatted, "", note)
 		}
 
-		return formatted
+		return renderDiagnosticsDelta(v, formatted, meta.Diagnostics)
 	})
 }
 
@@ -587,7 +693,9 @@ func (wr writeRenderer) Render(v *toolCallCmp) string {
 	}
 
 	return wr.renderWithParams(v, "Write", args, func() string {
-		return renderCodeContent(v, file, params.Content, 0)
+		var meta tools.WriteResponseMetadata
+		_ = wr.unmarshalParams(v.result.Metadata, &meta)
+		return renderDiagnosticsDelta(v, renderCodeContent(v, file, params.Content, 0), meta.Diagnostics)
 	})
 }
 
@@ -910,6 +1018,150 @@ func (dr diagnosticsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Rename renderer
+// -----------------------------------------------------------------------------
+
+// renameRenderer handles LSP renames, listing the changed files
+type renameRenderer struct {
+	baseRenderer
+}
+
+// Render displays the renamed symbol and the files the rename changed
+func (rr renameRenderer) Render(v *toolCallCmp) string {
+	var params tools.RenameParams
+	var args []string
+	if err := rr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Symbol
+		if main == "" {
+			main = fsext.PrettyPath(params.FilePath)
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("to", params.NewName).
+			build()
+	}
+
+	return rr.renderWithParams(v, "Rename", args, func() string {
+		var meta tools.RenameResponseMetadata
+		if err := rr.unmarshalParams(v.result.Metadata, &meta); err != nil || len(meta.Files) == 0 {
+			return renderPlainContent(v, v.result.Content)
+		}
+		summary := fmt.Sprintf("%s → %s in %d file(s) (+%d -%d)",
+			meta.Symbol, meta.NewName, len(meta.Files), meta.Additions, meta.Removals)
+		return renderDiagnosticsDelta(v, renderPlainContent(v, summary+"\n"+strings.Join(meta.Files, "\n")), meta.Diagnostics)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Apply patch renderer
+// -----------------------------------------------------------------------------
+
+// applyPatchRenderer handles multi-file patches, listing the changed files
+type applyPatchRenderer struct {
+	baseRenderer
+}
+
+// Render displays the files the patch changed
+func (ar applyPatchRenderer) Render(v *toolCallCmp) string {
+	return ar.renderWithParams(v, "Apply Patch", nil, func() string {
+		var meta tools.ApplyPatchResponseMetadata
+		if err := ar.unmarshalParams(v.result.Metadata, &meta); err != nil || len(meta.Files) == 0 {
+			return renderPlainContent(v, v.result.Content)
+		}
+		summary := fmt.Sprintf("%d file(s) (+%d -%d)", len(meta.Files), meta.Additions, meta.Removals)
+		return renderDiagnosticsDelta(v, renderPlainContent(v, summary+"\n"+strings.Join(meta.Files, "\n")), meta.Diagnostics)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  File operation renderers
+// -----------------------------------------------------------------------------
+
+// moveRenderer handles moved files, listing the files whose references
+// were updated
+type moveRenderer struct {
+	baseRenderer
+}
+
+// Render displays the moved path and its destination
+func (mr moveRenderer) Render(v *toolCallCmp) string {
+	var params tools.MoveParams
+	var args []string
+	if err := mr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fsext.PrettyPath(params.SourcePath)).
+			addKeyValue("to", fsext.PrettyPath(params.DestinationPath)).
+			build()
+	}
+
+	return mr.renderWithParams(v, "Move", args, func() string {
+		var meta tools.MoveResponseMetadata
+		if err := mr.unmarshalParams(v.result.Metadata, &meta); err != nil {
+			return renderPlainContent(v, v.result.Content)
+		}
+		if len(meta.Files) == 0 {
+			return renderDiagnosticsDelta(v, "", meta.Diagnostics)
+		}
+		content := fmt.Sprintf("Updated references in %d file(s)\n%s", len(meta.Files), strings.Join(meta.Files, "\n"))
+		return renderDiagnosticsDelta(v, renderPlainContent(v, content), meta.Diagnostics)
+	})
+}
+
+// copyRenderer handles copied files
+type copyRenderer struct {
+	baseRenderer
+}
+
+// Render displays the copied path and its destination
+func (cr copyRenderer) Render(v *toolCallCmp) string {
+	var params tools.CopyParams
+	var args []string
+	if err := cr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fsext.PrettyPath(params.SourcePath)).
+			addKeyValue("to", fsext.PrettyPath(params.DestinationPath)).
+			build()
+	}
+
+	return cr.renderWithParams(v, "Copy", args, func() string {
+		if v.result.IsError {
+			return renderPlainContent(v, v.result.Content)
+		}
+		return ""
+	})
+}
+
+// deleteRenderer handles deleted files
+type deleteRenderer struct {
+	baseRenderer
+}
+
+// Render displays the deleted path and how many lines were removed
+func (dr deleteRenderer) Render(v *toolCallCmp) string {
+	var params tools.DeleteParams
+	var args []string
+	if err := dr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fsext.PrettyPath(params.Path)).
+			addFlag("recursive", params.Recursive).
+			build()
+	}
+
+	return dr.renderWithParams(v, "Delete", args, func() string {
+		var meta tools.DeleteResponseMetadata
+		if err := dr.unmarshalParams(v.result.Metadata, &meta); err != nil {
+			return renderPlainContent(v, v.result.Content)
+		}
+		lines := []string{fmt.Sprintf("-%d line(s)", meta.Removals)}
+		if len(meta.Files) > 0 {
+			lines = append(lines, fmt.Sprintf("Updated references in %d file(s)", len(meta.Files)))
+			lines = append(lines, meta.Files...)
+		}
+		return renderDiagnosticsDelta(v, renderPlainContent(v, strings.Join(lines, "\n")), meta.Diagnostics)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Task renderer
 // -----------------------------------------------------------------------------
@@ -1045,6 +1297,9 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 	switch {
 	case v.result.IsError:
 		message = v.renderToolError()
+		if conflicts := renderMergeConflicts(v); conflicts != "" {
+			message = lipgloss.JoinVertical(lipgloss.Left, message, "", conflicts)
+		}
 	case v.cancelled:
 		message = t.S().Base.Foreground(t.FgSubtle).Render("Canceled.")
 	case v.result.ToolCallID == "":
@@ -1260,6 +1515,39 @@ func (v *toolCallCmp) renderToolError() string {
 	return err
 }
 
+// renderMergeConflicts renders the changes of the agent that conflict with
+// the ones made to the file on disk, when the tool couldn't merge them.
+func renderMergeConflicts(v *toolCallCmp) string {
+	var meta tools.MergeConflictMetadata
+	if err := json.Unmarshal([]byte(v.result.Metadata), &meta); err != nil || len(meta.Conflicts) == 0 {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	file := fsext.PrettyPath(meta.FilePath)
+	parts := []string{t.S().Muted.Render(fmt.Sprintf("%d conflicting change(s) in %s", len(meta.Conflicts), file))}
+	for _, conflict := range meta.Conflicts {
+		formatter := core.DiffFormatter().
+			Before(fmt.Sprintf("%s:%d (on disk)", file, conflict.Line), conflict.Theirs).
+			After(fmt.Sprintf("%s:%d (agent)", file, conflict.Line), conflict.Ours).
+			Width(v.textWidth() - 2) // -2 for padding
+		if v.textWidth() > 120 {
+			formatter = formatter.Split()
+		}
+		parts = append(parts, formatter.String())
+	}
+	formatted := strings.Join(parts, "\n")
+	if lipgloss.Height(formatted) > responseContextHeight {
+		contentLines := strings.Split(formatted, "\n")
+		truncateMessage := t.S().Muted.
+			Background(t.BgBaseLighter).
+			PaddingLeft(2).
+			Width(v.textWidth() - 2).
+			Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
+		formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+	}
+	return formatted
+}
+
 func truncateHeight(s string, h int) string {
 	lines := strings.Split(s, "\n")
 	if len(lines) > h {
@@ -1284,6 +1572,10 @@ func prettifyToolName(name string) string {
 		return "Edit"
 	case tools.MultiEditToolName:
 		return "Multi-Edit"
+	case tools.EditLinesToolName:
+		return "Edit Lines"
+	case tools.NotebookEditToolName:
+		return "Notebook Edit"
 	case tools.FetchToolName:
 		return "Fetch"
 	case tools.AgenticFetchToolName:
@@ -1302,6 +1594,18 @@ func prettifyToolName(name string) string {
 		return "Sourcegraph"
 	case tools.TodosToolName:
 		return "To-Do"
+	case tools.RenameToolName:
+		return "Rename"
+	case tools.ApplyPatchToolName:
+		return "Apply Patch"
+	case tools.MoveToolName:
+		return "Move"
+	case tools.CopyToolName:
+		return "Copy"
+	case tools.DeleteToolName:
+		return "Delete"
+	case tools.CodeActionToolName:
+		return "Code Action"
 	case tools.ViewToolName:
 		return "View"
 	case tools.WriteToolName:
@@ -264,6 +264,9 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 			if params.Offset > 0 {
 				parts = append(parts, fmt.Sprintf("**Offset:** %d", params.Offset))
 			}
+			if params.Pages != "" {
+				parts = append(parts, fmt.Sprintf("**Pages:** %s", params.Pages))
+			}
 			return strings.Join(parts, "\n")
 		}
 	case tools.EditToolName:
@@ -271,6 +274,26 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
 			return fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath))
 		}
+	case tools.EditLinesToolName:
+		var params tools.EditLinesParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath)))
+			parts = append(parts, fmt.Sprintf("**Lines:** %d-%d", params.StartLine, params.EndLine))
+			return strings.Join(parts, "\n")
+		}
+	case tools.NotebookEditToolName:
+		var params tools.NotebookEditParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			cell := params.CellID
+			if cell == "" {
+				cell = fmt.Sprintf("%d", params.CellIndex)
+			}
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath)))
+			parts = append(parts, fmt.Sprintf("**Cell:** %s", cell))
+			return strings.Join(parts, "\n")
+		}
 	case tools.MultiEditToolName:
 		var params tools.MultiEditParams
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
@@ -411,7 +434,7 @@ func (m *toolCallCmp) formatResultForCopy() string {
 		return m.formatBashResultForCopy()
 	case tools.ViewToolName:
 		return m.formatViewResultForCopy()
-	case tools.EditToolName:
+	case tools.EditToolName, tools.EditLinesToolName, tools.NotebookEditToolName:
 		return m.formatEditResultForCopy()
 	case tools.MultiEditToolName:
 		return m.formatMultiEditResultForCopy()
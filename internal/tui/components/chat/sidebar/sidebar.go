@@ -22,6 +22,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
 	"github.com/charmbracelet/crush/internal/tui/components/files"
+	"github.com/charmbracelet/crush/internal/tui/components/jobs"
 	"github.com/charmbracelet/crush/internal/tui/components/logo"
 	lspcomponent "github.com/charmbracelet/crush/internal/tui/components/lsp"
 	"github.com/charmbracelet/crush/internal/tui/components/mcp"
@@ -44,6 +45,7 @@ const (
 	DefaultMaxFilesShown = 10
 	DefaultMaxLSPsShown  = 8
 	DefaultMaxMCPsShown  = 8
+	DefaultMaxJobsShown  = 3
 	MinItemsPerSection   = 2 // Minimum items to show per section
 )
 
@@ -62,6 +64,9 @@ type Sidebar interface {
 	layout.Sizeable
 	SetSession(session session.Session) tea.Cmd
 	SetCompactMode(bool)
+	// LSPAt returns the name of the LSP shown at the position, relative to
+	// the sidebar.
+	LSPAt(x, y int) (string, bool)
 }
 
 type sidebarCmp struct {
@@ -112,9 +117,24 @@ func (m *sidebarCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 }
 
 func (m *sidebarCmp) View() string {
-	t := styles.CurrentTheme()
-	parts := []string{}
+	style := m.style()
+	parts := m.headerParts()
+	if !m.horizontal() {
+		parts = append(parts,
+			"",
+			m.lspBlock(),
+			"",
+			m.mcpBlock(),
+		)
+	}
+
+	return style.Render(
+		lipgloss.JoinVertical(lipgloss.Left, parts...),
+	)
+}
 
+func (m *sidebarCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
 	style := t.S().Base.
 		Width(m.width).
 		Height(m.height).
@@ -122,6 +142,20 @@ func (m *sidebarCmp) View() string {
 	if m.compactMode {
 		style = style.PaddingTop(0)
 	}
+	return style
+}
+
+// horizontal reports whether the sections are laid out side by side, which
+// is done in compact mode when the sidebar is wider than it's high.
+func (m *sidebarCmp) horizontal() bool {
+	return m.compactMode && m.width > m.height
+}
+
+// headerParts returns the parts of the sidebar shown above the LSP and MCP
+// blocks, or with the sections when they are laid out horizontally.
+func (m *sidebarCmp) headerParts() []string {
+	t := styles.CurrentTheme()
+	parts := []string{}
 
 	if !m.compactMode {
 		if m.height > LogoHeightBreakpoint {
@@ -129,7 +163,7 @@ func (m *sidebarCmp) View() string {
 		} else {
 			// Use a smaller logo for smaller screens
 			parts = append(parts,
-				logo.SmallRender(m.width-style.GetHorizontalFrameSize()),
+				logo.SmallRender(m.width-m.style().GetHorizontalFrameSize()),
 				"")
 		}
 	}
@@ -151,28 +185,20 @@ func (m *sidebarCmp) View() string {
 	)
 
 	// Check if we should use horizontal layout for sections
-	if m.compactMode && m.width > m.height {
+	if m.horizontal() {
 		// Horizontal layout for compact mode when width > height
 		sectionsContent := m.renderSectionsHorizontal()
 		if sectionsContent != "" {
 			parts = append(parts, "", sectionsContent)
 		}
-	} else {
+	} else if m.session.ID != "" {
 		// Vertical layout (default)
-		if m.session.ID != "" {
-			parts = append(parts, "", m.filesBlock())
+		parts = append(parts, "", m.filesBlock())
+		if jobs := m.jobsBlock(); jobs != "" {
+			parts = append(parts, "", jobs)
 		}
-		parts = append(parts,
-			"",
-			m.lspBlock(),
-			"",
-			m.mcpBlock(),
-		)
 	}
-
-	return style.Render(
-		lipgloss.JoinVertical(lipgloss.Left, parts...),
-	)
+	return parts
 }
 
 func (m *sidebarCmp) handleFileHistoryEvent(event pubsub.Event[history.File]) tea.Cmd {
@@ -477,20 +503,50 @@ func (m *sidebarCmp) filesBlock() string {
 	}, true)
 }
 
-func (m *sidebarCmp) lspBlock() string {
-	// Limit the number of LSPs shown
+// jobsBlock renders the background jobs of the session, if there are any.
+func (m *sidebarCmp) jobsBlock() string {
+	sessionJobs := jobs.SessionJobs(m.session.ID)
+	if len(sessionJobs) == 0 {
+		return ""
+	}
+	return jobs.RenderJobBlock(sessionJobs, jobs.RenderOptions{
+		MaxWidth:    m.getMaxWidth(),
+		MaxItems:    DefaultMaxJobsShown,
+		ShowSection: true,
+		SectionName: core.Section("Jobs", m.getMaxWidth()),
+	}, true)
+}
+
+// maxLSPsShown returns how many LSPs the LSP block lists.
+func (m *sidebarCmp) maxLSPsShown() int {
 	_, maxLSPs, _ := m.getDynamicLimits()
-	lspConfigs := config.Get().LSP.Sorted()
-	maxLSPs = min(len(lspConfigs), maxLSPs)
+	return min(len(config.Get().LSP.Sorted()), maxLSPs)
+}
 
+func (m *sidebarCmp) lspBlock() string {
 	return lspcomponent.RenderLSPBlock(m.lspClients, lspcomponent.RenderOptions{
 		MaxWidth:    m.getMaxWidth(),
-		MaxItems:    maxLSPs,
+		MaxItems:    m.maxLSPsShown(),
 		ShowSection: true,
 		SectionName: core.Section("LSPs", m.getMaxWidth()),
 	}, true)
 }
 
+func (m *sidebarCmp) LSPAt(x, y int) (string, bool) {
+	if x < 0 || x >= m.width || m.horizontal() {
+		return "", false
+	}
+	// The LSP rows come after the header, an empty line, the section title
+	// and another empty line.
+	top := m.style().GetPaddingTop() + lipgloss.Height(lipgloss.JoinVertical(lipgloss.Left, m.headerParts()...)) + 3
+	lspConfigs := config.Get().LSP.Sorted()
+	row := y - top
+	if row < 0 || row >= m.maxLSPsShown() {
+		return "", false
+	}
+	return lspConfigs[row].Name, true
+}
+
 func (m *sidebarCmp) mcpBlock() string {
 	// Limit the number of MCPs shown
 	_, _, maxMCPs := m.getDynamicLimits()
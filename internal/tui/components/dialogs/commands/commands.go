@@ -21,6 +21,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/lspactions"
 	"github.com/charmbracelet/crush/internal/tui/exp/list"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
@@ -84,6 +85,13 @@ type (
 	CompactMsg             struct {
 		SessionID string
 	}
+	OpenPermissionLogMsg struct {
+		SessionID string
+	}
+	ResetShellMsg struct {
+		SessionID string
+	}
+	OpenJobsMsg struct{}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -353,6 +361,36 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				})
 			},
 		})
+		commands = append(commands, Command{
+			ID:          "permission_log",
+			Title:       "View Permission Log",
+			Description: "Show the permission decisions made in the current session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenPermissionLogMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "jobs",
+			Title:       "Background Jobs",
+			Description: "Show the background jobs and their output",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(OpenJobsMsg{})
+			},
+		})
+		if config.Get().Tools.Bash.PersistentShell {
+			commands = append(commands, Command{
+				ID:          "reset_shell",
+				Title:       "Reset Shell",
+				Description: "Reset the working directory and environment of the session's shell",
+				Handler: func(cmd Command) tea.Cmd {
+					return util.CmdHandler(ResetShellMsg{
+						SessionID: c.sessionID,
+					})
+				},
+			})
+		}
 	}
 
 	// Add reasoning toggle for models that support it
@@ -432,6 +470,19 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		})
 	}
 
+	for _, l := range config.Get().LSP.Sorted() {
+		for _, action := range lspactions.Actions(l.Name) {
+			commands = append(commands, Command{
+				ID:          fmt.Sprintf("lsp_%s_%s", action, l.Name),
+				Title:       fmt.Sprintf("%s LSP: %s", action.Title(), l.Name),
+				Description: fmt.Sprintf("%s the %s language server", action.Title(), l.Name),
+				Handler: func(cmd Command) tea.Cmd {
+					return util.CmdHandler(lspactions.ActionMsg{Name: l.Name, Action: action})
+				},
+			})
+		}
+	}
+
 	return append(commands, []Command{
 		{
 			ID:          "toggle_yolo",
@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/jobs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const JobsDialogID dialogs.DialogID = "jobs"
+
+// maxListItems is the number of jobs listed before the list scrolls.
+const maxListItems = 8
+
+// refreshInterval is how often the runtimes and the output are refreshed.
+const refreshInterval = time.Second
+
+// JobsDialog lists the background jobs and shows the output of the selected
+// one.
+type JobsDialog interface {
+	dialogs.DialogModel
+}
+
+type refreshMsg struct{}
+
+type jobsDialogCmp struct {
+	wWidth   int
+	wHeight  int
+	width    int
+	jobs     []shell.BackgroundJob
+	selected int
+	attached bool
+	viewport viewport.Model
+	keyMap   KeyMap
+	help     help.Model
+}
+
+// NewJobsDialogCmp creates a dialog listing the background jobs.
+func NewJobsDialogCmp() JobsDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &jobsDialogCmp{
+		jobs:     shell.GetBackgroundShellManager().Jobs(),
+		viewport: viewport.New(),
+		keyMap:   DefaultKeyMap(),
+		help:     help,
+	}
+}
+
+func (j *jobsDialogCmp) Init() tea.Cmd {
+	return refresh()
+}
+
+func refresh() tea.Cmd {
+	return tea.Tick(refreshInterval, func(time.Time) tea.Msg {
+		return refreshMsg{}
+	})
+}
+
+func (j *jobsDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		j.wWidth = msg.Width
+		j.wHeight = msg.Height
+		j.width = min(120, j.wWidth-8)
+		j.viewport.SetWidth(j.width - 4)
+		j.updateOutput()
+	case refreshMsg:
+		j.reload()
+		return j, refresh()
+	case pubsub.Event[shell.BackgroundJob]:
+		j.reload()
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, j.keyMap.Close):
+			if j.attached {
+				j.attached = false
+				j.updateOutput()
+				return j, nil
+			}
+			return j, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, j.keyMap.Attach):
+			if len(j.jobs) > 0 && !j.attached {
+				j.attached = true
+				j.updateOutput()
+				j.viewport.GotoBottom()
+			}
+		case key.Matches(msg, j.keyMap.Kill):
+			if len(j.jobs) == 0 {
+				return j, nil
+			}
+			job := j.jobs[j.selected]
+			j.attached = false
+			return j, func() tea.Msg {
+				if err := shell.GetBackgroundShellManager().Kill(job.ID); err != nil {
+					return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+				}
+				return util.InfoMsg{Type: util.InfoTypeInfo, Msg: fmt.Sprintf("Killed background job %s", job.ID)}
+			}
+		case j.attached:
+			vp, cmd := j.viewport.Update(msg)
+			j.viewport = vp
+			return j, cmd
+		case key.Matches(msg, j.keyMap.Previous):
+			j.selected = max(0, j.selected-1)
+			j.updateOutput()
+		case key.Matches(msg, j.keyMap.Next):
+			j.selected = min(max(0, len(j.jobs)-1), j.selected+1)
+			j.updateOutput()
+		}
+	case tea.MouseWheelMsg:
+		if j.attached {
+			vp, cmd := j.viewport.Update(msg)
+			j.viewport = vp
+			return j, cmd
+		}
+	}
+	return j, nil
+}
+
+// reload refreshes the jobs, keeping the selected job selected.
+func (j *jobsDialogCmp) reload() {
+	var selectedID string
+	if j.selected < len(j.jobs) {
+		selectedID = j.jobs[j.selected].ID
+	}
+	j.jobs = shell.GetBackgroundShellManager().Jobs()
+	j.selected = min(j.selected, max(0, len(j.jobs)-1))
+	for i, job := range j.jobs {
+		if job.ID == selectedID {
+			j.selected = i
+			break
+		}
+	}
+	if j.attached && len(j.jobs) == 0 {
+		j.attached = false
+	}
+	j.updateOutput()
+}
+
+// updateOutput shows the output of the selected job: the last lines while
+// browsing the list, all of it, following new output, while attached.
+func (j *jobsDialogCmp) updateOutput() {
+	t := styles.CurrentTheme()
+	height := j.outputHeight()
+	j.viewport.SetHeight(height)
+
+	if len(j.jobs) == 0 {
+		j.viewport.SetContent(t.S().Muted.Render("No background jobs."))
+		return
+	}
+	bgShell, ok := shell.GetBackgroundShellManager().Get(j.jobs[j.selected].ID)
+	if !ok {
+		j.viewport.SetContent("")
+		return
+	}
+
+	stdout, stderr, _, _ := bgShell.GetOutput()
+	var lines []string
+	for line := range strings.SplitSeq(strings.TrimRight(stdout, "\n"), "\n") {
+		lines = append(lines, t.S().Text.Render(ansi.Strip(line)))
+	}
+	for line := range strings.SplitSeq(strings.TrimRight(stderr, "\n"), "\n") {
+		if line != "" {
+			lines = append(lines, t.S().Base.Foreground(t.Error).Render(ansi.Strip(line)))
+		}
+	}
+	if len(lines) == 1 && stdout == "" {
+		lines = []string{t.S().Muted.Render("No output yet.")}
+	}
+
+	if !j.attached {
+		lines = lines[max(0, len(lines)-height):]
+		j.viewport.SetContent(strings.Join(lines, "\n"))
+		return
+	}
+	follow := j.viewport.AtBottom()
+	j.viewport.SetContent(strings.Join(lines, "\n"))
+	if follow {
+		j.viewport.GotoBottom()
+	}
+}
+
+func (j *jobsDialogCmp) renderList() string {
+	t := styles.CurrentTheme()
+	if len(j.jobs) == 0 {
+		return ""
+	}
+
+	width := j.width - 4
+	start := max(0, min(j.selected-maxListItems/2, len(j.jobs)-maxListItems))
+	end := min(len(j.jobs), start+maxListItems)
+	rows := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		job := j.jobs[i]
+		icon, status := jobs.Status(job)
+		title := fmt.Sprintf("%s  %s", job.ID, ansi.Truncate(jobs.Title(job), width/2, "…"))
+		row := core.Status(core.StatusOpts{
+			Icon:        icon.String(),
+			Title:       title,
+			Description: status,
+		}, width)
+		if i == j.selected {
+			row = t.S().TextSelected.Width(width).Render(ansi.Strip(row))
+		}
+		rows = append(rows, row)
+	}
+	return strings.Join(rows, "\n")
+}
+
+func (j *jobsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	parts := []string{
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Background Jobs", j.width-4)),
+	}
+	if list := j.renderList(); list != "" && !j.attached {
+		parts = append(parts, t.S().Base.PaddingLeft(1).Render(list), "")
+	}
+	if j.attached && len(j.jobs) > 0 {
+		job := j.jobs[j.selected]
+		header := fmt.Sprintf("%s  %s", job.ID, job.Command)
+		parts = append(parts, t.S().Base.PaddingLeft(1).Render(t.S().Text.Bold(true).Render(ansi.Truncate(header, j.width-4, "…"))), "")
+	}
+	parts = append(parts,
+		t.S().Base.PaddingLeft(1).Render(j.viewport.View()),
+		"",
+		t.S().Base.Width(j.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(j.help.View(j.keyMap)),
+	)
+
+	return t.S().Base.
+		Width(j.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// outputHeight is the height left for the output below the job list.
+func (j *jobsDialogCmp) outputHeight() int {
+	height := j.wHeight/2 - 6 // 5 for the border, title and help
+	if !j.attached {
+		height -= min(len(j.jobs), maxListItems) + 1
+	} else {
+		height -= 2
+	}
+	return max(3, height)
+}
+
+func (j *jobsDialogCmp) Position() (int, int) {
+	row := j.wHeight/4 - 2 // just a bit above the center
+	col := j.wWidth / 2
+	col -= j.width / 2
+	return row, col
+}
+
+// ID implements JobsDialog.
+func (j *jobsDialogCmp) ID() dialogs.DialogID {
+	return JobsDialogID
+}
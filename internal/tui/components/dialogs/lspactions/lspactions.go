@@ -0,0 +1,259 @@
+// Package lspactions provides the dialog starting, stopping and restarting an
+// LSP server.
+package lspactions
+
+import (
+	"fmt"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+)
+
+const (
+	LSPActionsDialogID dialogs.DialogID = "lsp_actions"
+
+	defaultWidth int = 50
+)
+
+// Action is something that can be done to an LSP server.
+type Action string
+
+const (
+	ActionStart   Action = "start"
+	ActionStop    Action = "stop"
+	ActionRestart Action = "restart"
+)
+
+// Title returns the title of the action.
+func (a Action) Title() string {
+	switch a {
+	case ActionStart:
+		return "Start"
+	case ActionStop:
+		return "Stop"
+	default:
+		return "Restart"
+	}
+}
+
+// ActionMsg asks for an action on the LSP server with the given name.
+type ActionMsg struct {
+	Name   string
+	Action Action
+}
+
+// Actions returns the actions available for the LSP server in its current
+// state.
+func Actions(name string) []Action {
+	info, ok := app.GetLSPState(name)
+	if !ok {
+		return nil
+	}
+	switch info.State {
+	case lsp.StateDisabled:
+		return nil
+	case lsp.StateStopped, lsp.StateIdle:
+		return []Action{ActionStart}
+	}
+	return []Action{ActionRestart, ActionStop}
+}
+
+type listModel = list.FilterableList[list.CompletionItem[Action]]
+
+type LSPActionsDialog interface {
+	dialogs.DialogModel
+}
+
+type lspActionsDialogCmp struct {
+	name    string
+	width   int
+	wWidth  int // Width of the terminal window
+	wHeight int // Height of the terminal window
+
+	actionList listModel
+	keyMap     KeyMap
+	help       help.Model
+}
+
+type KeyMap struct {
+	Next     key.Binding
+	Previous key.Binding
+	Select   key.Binding
+	Close    key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Next: key.NewBinding(
+			key.WithKeys("down", "j", "ctrl+n"),
+			key.WithHelp("↓/j/ctrl+n", "next"),
+		),
+		Previous: key.NewBinding(
+			key.WithKeys("up", "k", "ctrl+p"),
+			key.WithHelp("↑/k/ctrl+p", "previous"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "select"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "ctrl+c"),
+			key.WithHelp("esc/ctrl+c", "close"),
+		),
+	}
+}
+
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Select, k.Close}
+}
+
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Next, k.Previous},
+		{k.Select, k.Close},
+	}
+}
+
+// NewLSPActionsDialog returns the dialog for the actions on the LSP server
+// with the given name.
+func NewLSPActionsDialog(name string) LSPActionsDialog {
+	keyMap := DefaultKeyMap()
+	listKeyMap := list.DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	t := styles.CurrentTheme()
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	actionList := list.NewFilterableList(
+		[]list.CompletionItem[Action]{},
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+			list.WithResizeByList(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+
+	return &lspActionsDialogCmp{
+		name:       name,
+		actionList: actionList,
+		width:      defaultWidth,
+		keyMap:     keyMap,
+		help:       help,
+	}
+}
+
+func (d *lspActionsDialogCmp) Init() tea.Cmd {
+	items := []list.CompletionItem[Action]{}
+	for _, action := range Actions(d.name) {
+		items = append(items, list.NewCompletionItem(
+			action.Title(),
+			action,
+			list.WithCompletionID(string(action)),
+		))
+	}
+	return d.actionList.SetItems(items)
+}
+
+func (d *lspActionsDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.wWidth = msg.Width
+		d.wHeight = msg.Height
+		return d, d.actionList.SetSize(d.listWidth(), d.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keyMap.Select):
+			selectedItem := d.actionList.SelectedItem()
+			if selectedItem == nil {
+				return d, nil
+			}
+			return d, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(ActionMsg{Name: d.name, Action: (*selectedItem).Value()}),
+			)
+		case key.Matches(msg, d.keyMap.Close):
+			return d, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := d.actionList.Update(msg)
+			d.actionList = u.(listModel)
+			return d, cmd
+		}
+	}
+	return d, nil
+}
+
+func (d *lspActionsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+
+	header := t.S().Base.Padding(0, 1, 1, 1).Render(core.Title(fmt.Sprintf("LSP: %s", d.name), d.width-4))
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		d.actionList.View(),
+		"",
+		t.S().Base.Width(d.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(d.help.View(d.keyMap)),
+	)
+	return d.style().Render(content)
+}
+
+func (d *lspActionsDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := d.actionList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = d.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (d *lspActionsDialogCmp) listWidth() int {
+	return d.width - 2
+}
+
+func (d *lspActionsDialogCmp) listHeight() int {
+	listHeight := len(d.actionList.Items()) + 2 + 4 // height based on items + 2 for the input + 4 for the sections
+	return min(listHeight, d.wHeight/2)
+}
+
+func (d *lspActionsDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := d.Position()
+	offset := row + 3
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+func (d *lspActionsDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(d.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (d *lspActionsDialogCmp) Position() (int, int) {
+	row := d.wHeight/4 - 2 // just a bit above the center
+	col := d.wWidth / 2
+	col -= d.width / 2
+	return row, col
+}
+
+func (d *lspActionsDialogCmp) ID() dialogs.DialogID {
+	return LSPActionsDialogID
+}
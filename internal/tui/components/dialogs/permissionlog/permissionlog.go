@@ -0,0 +1,146 @@
+package permissionlog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+)
+
+const PermissionLogDialogID dialogs.DialogID = "permission_log"
+
+// PermissionLogDialog shows the permission decisions made in a session.
+type PermissionLogDialog interface {
+	dialogs.DialogModel
+}
+
+type permissionLogDialogCmp struct {
+	wWidth   int
+	wHeight  int
+	width    int
+	entries  []permission.LogEntry
+	viewport viewport.Model
+	keyMap   KeyMap
+	help     help.Model
+}
+
+// NewPermissionLogDialogCmp creates a dialog listing the given log entries,
+// oldest first.
+func NewPermissionLogDialogCmp(entries []permission.LogEntry) PermissionLogDialog {
+	t := styles.CurrentTheme()
+	help := help.New()
+	help.Styles = t.S().Help
+	return &permissionLogDialogCmp{
+		entries:  entries,
+		viewport: viewport.New(),
+		keyMap:   DefaultKeyMap(),
+		help:     help,
+	}
+}
+
+func (p *permissionLogDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (p *permissionLogDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+		p.width = min(120, p.wWidth-8)
+		p.viewport.SetWidth(p.width - 4)
+		p.viewport.SetHeight(p.listHeight())
+		p.viewport.SetContent(p.renderEntries())
+		p.viewport.GotoBottom()
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, p.keyMap.Close):
+			return p, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, p.keyMap.Up):
+			p.viewport.ScrollUp(1)
+		case key.Matches(msg, p.keyMap.Down):
+			p.viewport.ScrollDown(1)
+		default:
+			vp, cmd := p.viewport.Update(msg)
+			p.viewport = vp
+			return p, cmd
+		}
+	case tea.MouseWheelMsg:
+		vp, cmd := p.viewport.Update(msg)
+		p.viewport = vp
+		return p, cmd
+	}
+	return p, nil
+}
+
+func (p *permissionLogDialogCmp) renderEntries() string {
+	t := styles.CurrentTheme()
+	if len(p.entries) == 0 {
+		return t.S().Muted.Render("No permission requests in this session yet.")
+	}
+
+	width := p.width - 4
+	lines := make([]string, 0, len(p.entries))
+	for _, e := range p.entries {
+		decision := t.S().Base.Foreground(t.Success).Render("✓ " + string(e.Decision))
+		if !e.Granted {
+			decision = t.S().Base.Foreground(t.Error).Render("✗ " + string(e.Decision))
+		}
+		header := fmt.Sprintf(
+			"%s %s %s",
+			t.S().Muted.Render(time.UnixMilli(e.RequestedAt).Local().Format("15:04:05")),
+			t.S().Text.Bold(true).Render(e.ToolName+":"+e.Action),
+			decision,
+		)
+		detail := e.Description
+		if detail == "" {
+			detail = e.Path
+		}
+		detail = strings.ReplaceAll(strings.TrimSpace(detail), "\n", " ")
+		lines = append(lines, header, t.S().Subtle.Width(width).PaddingLeft(2).Render(detail))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *permissionLogDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Permission Log", p.width-4)),
+		t.S().Base.PaddingLeft(1).Render(p.viewport.View()),
+		"",
+		t.S().Base.Width(p.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(p.help.View(p.keyMap)),
+	)
+
+	return t.S().Base.
+		Width(p.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (p *permissionLogDialogCmp) listHeight() int {
+	return p.wHeight/2 - 6 // 5 for the border, title and help
+}
+
+func (p *permissionLogDialogCmp) Position() (int, int) {
+	row := p.wHeight/4 - 2 // just a bit above the center
+	col := p.wWidth / 2
+	col -= p.width / 2
+	return row, col
+}
+
+// ID implements PermissionLogDialog.
+func (p *permissionLogDialogCmp) ID() dialogs.DialogID {
+	return PermissionLogDialogID
+}
@@ -26,6 +26,7 @@ type PermissionAction string
 const (
 	PermissionAllow           PermissionAction = "allow"
 	PermissionAllowForSession PermissionAction = "allow_session"
+	PermissionAllowPattern    PermissionAction = "allow_pattern"
 	PermissionDeny            PermissionAction = "deny"
 
 	PermissionsDialogID dialogs.DialogID = "permissions"
@@ -35,6 +36,8 @@ const (
 type PermissionResponseMsg struct {
 	Permission permission.PermissionRequest
 	Action     PermissionAction
+	// Rules to persist when Action is PermissionAllowPattern.
+	Rules []permission.Rule
 }
 
 // PermissionDialogCmp interface for permission dialog component
@@ -50,7 +53,8 @@ type permissionDialogCmp struct {
 	height          int
 	permission      permission.PermissionRequest
 	contentViewPort viewport.Model
-	selectedOption  int // 0: Allow, 1: Allow for session, 2: Deny
+	selectedOption  int // 0: Allow, 1: Allow for session, 2: Allow pattern, 3: Deny
+	rules           []permission.Rule
 
 	// Diff view state
 	defaultDiffSplitMode bool  // true for split, false for unified
@@ -70,7 +74,7 @@ type permissionDialogCmp struct {
 	keyMap KeyMap
 }
 
-func NewPermissionDialogCmp(permission permission.PermissionRequest, opts *Options) PermissionDialogCmp {
+func NewPermissionDialogCmp(req permission.PermissionRequest, opts *Options) PermissionDialogCmp {
 	if opts == nil {
 		opts = &Options{}
 	}
@@ -80,7 +84,8 @@ func NewPermissionDialogCmp(permission permission.PermissionRequest, opts *Optio
 	return &permissionDialogCmp{
 		contentViewPort: contentViewport,
 		selectedOption:  0, // Default to "Allow"
-		permission:      permission,
+		permission:      req,
+		rules:           permission.SuggestRules(req, opts.WorkingDir),
 		diffSplitMode:   opts.isSplitMode(),
 		keyMap:          DefaultKeyMap(),
 		contentDirty:    true, // Mark as dirty initially
@@ -92,7 +97,7 @@ func (p *permissionDialogCmp) Init() tea.Cmd {
 }
 
 func (p *permissionDialogCmp) supportsDiffView() bool {
-	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName
+	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.EditLinesToolName || p.permission.ToolName == tools.NotebookEditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName || p.permission.ToolName == tools.RenameToolName || p.permission.ToolName == tools.ApplyPatchToolName || p.permission.ToolName == tools.MoveToolName || p.permission.ToolName == tools.DeleteToolName || p.permission.ToolName == tools.CodeActionToolName
 }
 
 func (p *permissionDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
@@ -108,10 +113,16 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 	case tea.KeyPressMsg:
 		switch {
 		case key.Matches(msg, p.keyMap.Right) || key.Matches(msg, p.keyMap.Tab):
-			p.selectedOption = (p.selectedOption + 1) % 3
+			p.selectedOption = (p.selectedOption + 1) % 4
+			if p.selectedOption == 2 && len(p.rules) == 0 {
+				p.selectedOption++
+			}
 			return p, nil
 		case key.Matches(msg, p.keyMap.Left):
-			p.selectedOption = (p.selectedOption + 2) % 3
+			p.selectedOption = (p.selectedOption + 3) % 4
+			if p.selectedOption == 2 && len(p.rules) == 0 {
+				p.selectedOption--
+			}
 		case key.Matches(msg, p.keyMap.Select):
 			return p, p.selectCurrentOption()
 		case key.Matches(msg, p.keyMap.Allow):
@@ -124,6 +135,11 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
 				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForSession, Permission: p.permission}),
 			)
+		case key.Matches(msg, p.keyMap.AllowPattern) && len(p.rules) > 0:
+			return p, tea.Batch(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowPattern, Permission: p.permission, Rules: p.rules}),
+			)
 		case key.Matches(msg, p.keyMap.Deny):
 			return p, tea.Batch(
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
@@ -228,11 +244,13 @@ func (p *permissionDialogCmp) selectCurrentOption() tea.Cmd {
 	case 1:
 		action = PermissionAllowForSession
 	case 2:
+		action = PermissionAllowPattern
+	case 3:
 		action = PermissionDeny
 	}
 
 	return tea.Batch(
-		util.CmdHandler(PermissionResponseMsg{Action: action, Permission: p.permission}),
+		util.CmdHandler(PermissionResponseMsg{Action: action, Permission: p.permission, Rules: p.rules}),
 		util.CmdHandler(dialogs.CloseDialogMsg{}),
 	)
 }
@@ -252,12 +270,20 @@ func (p *permissionDialogCmp) renderButtons() string {
 			UnderlineIndex: 10, // "S" in "Session"
 			Selected:       p.selectedOption == 1,
 		},
-		{
-			Text:           "Deny",
-			UnderlineIndex: 0, // "D"
+	}
+	// Requests without a command, path or URL have no pattern to allow.
+	if len(p.rules) > 0 {
+		buttons = append(buttons, core.ButtonOpts{
+			Text:           "Always Allow Pattern",
+			UnderlineIndex: 13, // "P" in "Pattern"
 			Selected:       p.selectedOption == 2,
-		},
+		})
 	}
+	buttons = append(buttons, core.ButtonOpts{
+		Text:           "Deny",
+		UnderlineIndex: 0, // "D"
+		Selected:       p.selectedOption == 3,
+	})
 
 	content := core.SelectableButtons(buttons, "  ")
 	if lipgloss.Width(content) > p.width-4 {
@@ -298,6 +324,20 @@ func (p *permissionDialogCmp) renderHeader() string {
 		),
 	}
 
+	if patterns := p.rulePatterns(); patterns != "" {
+		ruleKey := t.S().Muted.Render("Rule")
+		ruleValue := t.S().Text.
+			Width(p.width - lipgloss.Width(ruleKey)).
+			Render(fmt.Sprintf(" %s", patterns))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				ruleKey,
+				ruleValue,
+			),
+		)
+	}
+
 	// Add tool-specific header information
 	switch p.permission.ToolName {
 	case tools.BashToolName:
@@ -312,6 +352,21 @@ func (p *permissionDialogCmp) renderHeader() string {
 				descKey,
 				descValue,
 			),
+		)
+		if params.Sandbox != "" {
+			sandboxKey := t.S().Muted.Render("Sandbox")
+			sandboxValue := t.S().Text.
+				Width(p.width - lipgloss.Width(sandboxKey)).
+				Render(fmt.Sprintf(" %s", params.Sandbox))
+			headerParts = append(headerParts,
+				lipgloss.JoinHorizontal(
+					lipgloss.Left,
+					sandboxKey,
+					sandboxValue,
+				),
+			)
+		}
+		headerParts = append(headerParts,
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 			t.S().Muted.Width(p.width).Render("Command"),
 		)
@@ -338,7 +393,7 @@ func (p *permissionDialogCmp) renderHeader() string {
 			),
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 		)
-	case tools.EditToolName:
+	case tools.EditToolName, tools.EditLinesToolName, tools.NotebookEditToolName:
 		params := p.permission.Params.(tools.EditPermissionsParams)
 		fileKey := t.S().Muted.Render("File")
 		filePath := t.S().Text.
@@ -381,6 +436,104 @@ func (p *permissionDialogCmp) renderHeader() string {
 			),
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 		)
+	case tools.RenameToolName:
+		params := p.permission.Params.(tools.RenamePermissionsParams)
+		symbolKey := t.S().Muted.Render("Symbol")
+		symbolValue := t.S().Text.
+			Width(p.width - lipgloss.Width(symbolKey)).
+			Render(fmt.Sprintf(" %s → %s", params.Symbol, params.NewName))
+		filesKey := t.S().Muted.Render("Files")
+		filesValue := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Files)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				symbolKey,
+				symbolValue,
+			),
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				filesValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	case tools.MoveToolName:
+		params := p.permission.Params.(tools.MovePermissionsParams)
+		headerParts = append(headerParts, p.fileOperationHeader(params.SourcePath, params.DestinationPath)...)
+	case tools.CopyToolName:
+		params := p.permission.Params.(tools.CopyPermissionsParams)
+		headerParts = append(headerParts, p.fileOperationHeader(params.SourcePath, params.DestinationPath)...)
+	case tools.DeleteToolName:
+		params := p.permission.Params.(tools.DeletePermissionsParams)
+		pathKey := t.S().Muted.Render("Path")
+		pathValue := t.S().Text.
+			Width(p.width - lipgloss.Width(pathKey)).
+			Render(fmt.Sprintf(" %s", fsext.PrettyPath(params.Path)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				pathKey,
+				pathValue,
+			),
+		)
+		if len(params.Unrestorable) > 0 {
+			// Binary and large files aren't kept in the history.
+			warningKey := t.S().Warning.Render("Warning")
+			warningValue := t.S().Text.
+				Width(p.width - lipgloss.Width(warningKey)).
+				Render(fmt.Sprintf(" %s can't be restored after the deletion", unrestorableSummary(params.Unrestorable)))
+			headerParts = append(headerParts,
+				lipgloss.JoinHorizontal(
+					lipgloss.Left,
+					warningKey,
+					warningValue,
+				),
+			)
+		}
+		headerParts = append(headerParts, baseStyle.Render(strings.Repeat(" ", p.width)))
+	case tools.ApplyPatchToolName:
+		params := p.permission.Params.(tools.ApplyPatchPermissionsParams)
+		filesKey := t.S().Muted.Render("Files")
+		filesValue := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Files)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				filesValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	case tools.CodeActionToolName:
+		params := p.permission.Params.(tools.CodeActionPermissionsParams)
+		actionKey := t.S().Muted.Render("Action")
+		actionValue := t.S().Text.
+			Width(p.width - lipgloss.Width(actionKey)).
+			Render(fmt.Sprintf(" %s", params.Title))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				actionKey,
+				actionValue,
+			),
+		)
+		if params.Command != "" {
+			commandKey := t.S().Muted.Render("Command")
+			commandValue := t.S().Text.
+				Width(p.width - lipgloss.Width(commandKey)).
+				Render(fmt.Sprintf(" %s", params.Command))
+			headerParts = append(headerParts,
+				lipgloss.JoinHorizontal(
+					lipgloss.Left,
+					commandKey,
+					commandValue,
+				),
+			)
+		}
+		headerParts = append(headerParts, baseStyle.Render(strings.Repeat(" ", p.width)))
 	case tools.FetchToolName:
 		headerParts = append(headerParts,
 			baseStyle.Render(strings.Repeat(" ", p.width)),
@@ -424,6 +577,15 @@ func (p *permissionDialogCmp) renderHeader() string {
 	return baseStyle.Render(lipgloss.JoinVertical(lipgloss.Left, headerParts...))
 }
 
+// rulePatterns describes the patterns "Always Allow Pattern" would save.
+func (p *permissionDialogCmp) rulePatterns() string {
+	patterns := make([]string, 0, len(p.rules))
+	for _, rule := range p.rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	return strings.Join(patterns, ", ")
+}
+
 func (p *permissionDialogCmp) getOrGenerateContent() string {
 	// Return cached content if available and not dirty
 	if !p.contentDirty && p.cachedContent != "" {
@@ -437,12 +599,24 @@ func (p *permissionDialogCmp) getOrGenerateContent() string {
 		content = p.generateBashContent()
 	case tools.DownloadToolName:
 		content = p.generateDownloadContent()
-	case tools.EditToolName:
+	case tools.EditToolName, tools.EditLinesToolName, tools.NotebookEditToolName:
 		content = p.generateEditContent()
 	case tools.WriteToolName:
 		content = p.generateWriteContent()
 	case tools.MultiEditToolName:
 		content = p.generateMultiEditContent()
+	case tools.RenameToolName:
+		if pr, ok := p.permission.Params.(tools.RenamePermissionsParams); ok {
+			content = p.generateWorkspaceEditContent(pr.Files)
+		}
+	case tools.ApplyPatchToolName:
+		if pr, ok := p.permission.Params.(tools.ApplyPatchPermissionsParams); ok {
+			content = p.generateWorkspaceEditContent(pr.Files)
+		}
+	case tools.MoveToolName, tools.CopyToolName, tools.DeleteToolName:
+		content = p.generateFileOperationContent()
+	case tools.CodeActionToolName:
+		content = p.generateCodeActionContent()
 	case tools.FetchToolName:
 		content = p.generateFetchContent()
 	case tools.AgenticFetchToolName:
@@ -588,6 +762,57 @@ func (p *permissionDialogCmp) generateMultiEditContent() string {
 	return ""
 }
 
+// generateWorkspaceEditContent stacks the diffs of all files changed by an
+// LSP workspace edit, scrolled as a whole.
+func (p *permissionDialogCmp) generateWorkspaceEditContent(files []tools.WorkspaceFileChange) string {
+	t := styles.CurrentTheme()
+	var parts []string
+	for _, file := range files {
+		before, after := fsext.PrettyPath(file.FilePath), fsext.PrettyPath(file.FilePath)
+		title := before
+		if file.NewPath != "" {
+			after = fsext.PrettyPath(file.NewPath)
+			title = fmt.Sprintf("%s → %s", before, after)
+		}
+		parts = append(parts, t.S().Text.Bold(true).Render(ansi.Truncate(title, p.contentViewPort.Width(), "…")))
+		if file.OldContent == file.NewContent {
+			continue
+		}
+		formatter := core.DiffFormatter().
+			Before(before, file.OldContent).
+			After(after, file.NewContent).
+			Width(p.contentViewPort.Width()).
+			XOffset(p.diffXOffset)
+		if p.useDiffSplitMode() {
+			formatter = formatter.Split()
+		} else {
+			formatter = formatter.Unified()
+		}
+		parts = append(parts, formatter.String(), "")
+	}
+
+	lines := strings.Split(strings.Join(parts, "\n"), "\n")
+	height := p.contentViewPort.Height()
+	offset := min(p.diffYOffset, max(0, len(lines)-height))
+	return strings.Join(lines[offset:min(len(lines), offset+height)], "\n")
+}
+
+func (p *permissionDialogCmp) generateCodeActionContent() string {
+	t := styles.CurrentTheme()
+	pr, ok := p.permission.Params.(tools.CodeActionPermissionsParams)
+	if !ok {
+		return ""
+	}
+	if len(pr.Files) > 0 {
+		return p.generateWorkspaceEditContent(pr.Files)
+	}
+	content := "The action runs a command on the language server, which may change files."
+	return t.S().Base.Background(t.BgSubtle).
+		Padding(1, 2).
+		Width(p.contentViewPort.Width()).
+		Render(t.S().Muted.Background(t.BgSubtle).Render(content))
+}
+
 func (p *permissionDialogCmp) generateFetchContent() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base.Background(t.BgSubtle)
@@ -659,6 +884,64 @@ func (p *permissionDialogCmp) generateLSContent() string {
 	return ""
 }
 
+// fileOperationHeader shows where a file is moved or copied.
+func (p *permissionDialogCmp) fileOperationHeader(source, destination string) []string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base.Background(t.BgSubtle)
+	fromKey := t.S().Muted.Render("From")
+	fromValue := t.S().Text.
+		Width(p.width - lipgloss.Width(fromKey)).
+		Render(fmt.Sprintf(" %s", fsext.PrettyPath(source)))
+	toKey := t.S().Muted.Render("To")
+	toValue := t.S().Text.
+		Width(p.width - lipgloss.Width(toKey)).
+		Render(fmt.Sprintf(" %s", fsext.PrettyPath(destination)))
+	return []string{
+		lipgloss.JoinHorizontal(lipgloss.Left, fromKey, fromValue),
+		lipgloss.JoinHorizontal(lipgloss.Left, toKey, toValue),
+		baseStyle.Render(strings.Repeat(" ", p.width)),
+	}
+}
+
+// unrestorableSummary names the first deleted files that can't be restored.
+func unrestorableSummary(paths []string) string {
+	const maxNames = 3
+	names := make([]string, 0, maxNames)
+	for _, path := range paths[:min(len(paths), maxNames)] {
+		names = append(names, fsext.PrettyPath(path))
+	}
+	summary := strings.Join(names, ", ")
+	if len(paths) > maxNames {
+		summary += fmt.Sprintf(" and %d more file(s)", len(paths)-maxNames)
+	}
+	return summary
+}
+
+// generateFileOperationContent shows the files the LSP servers change
+// because of a move or delete, or the operation itself when there are none.
+func (p *permissionDialogCmp) generateFileOperationContent() string {
+	var files []tools.WorkspaceFileChange
+	content := p.permission.Description
+	switch pr := p.permission.Params.(type) {
+	case tools.MovePermissionsParams:
+		files = pr.Files
+	case tools.DeletePermissionsParams:
+		files = pr.Files
+		if pr.IsDir {
+			content = fmt.Sprintf("Delete the directory %s and everything in it", fsext.PrettyPath(pr.Path))
+		}
+	}
+	if len(files) > 0 {
+		return p.generateWorkspaceEditContent(files)
+	}
+
+	t := styles.CurrentTheme()
+	return t.S().Base.Background(t.BgSubtle).
+		Padding(1, 2).
+		Width(p.contentViewPort.Width()).
+		Render(content)
+}
+
 func (p *permissionDialogCmp) generateDefaultContent() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base.Background(t.BgSubtle)
@@ -814,7 +1097,7 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.DownloadToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.4)
-	case tools.EditToolName:
+	case tools.EditToolName, tools.EditLinesToolName, tools.NotebookEditToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.8)
 	case tools.WriteToolName:
@@ -823,6 +1106,9 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.MultiEditToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.8)
+	case tools.RenameToolName, tools.CodeActionToolName, tools.ApplyPatchToolName, tools.MoveToolName, tools.DeleteToolName:
+		p.width = int(float64(p.wWidth) * 0.8)
+		p.height = int(float64(p.wHeight) * 0.8)
 	case tools.FetchToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.3)
@@ -879,7 +1165,8 @@ func (p *permissionDialogCmp) Position() (int, int) {
 
 // Options for create a new permission dialog
 type Options struct {
-	DiffMode string // split or unified, empty means use defaultDiffSplitMode
+	DiffMode   string // split or unified, empty means use defaultDiffSplitMode
+	WorkingDir string // used to make suggested rule patterns relative
 }
 
 // isSplitMode returns internal representation of diff mode switch
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+)
+
+// RenderOptions contains options for rendering background job lists.
+type RenderOptions struct {
+	MaxWidth    int
+	MaxItems    int
+	ShowSection bool
+	SectionName string
+}
+
+// SessionJobs returns the background jobs started in the given session.
+func SessionJobs(sessionID string) []shell.BackgroundJob {
+	var jobs []shell.BackgroundJob
+	for _, job := range shell.GetBackgroundShellManager().Jobs() {
+		if job.SessionID == sessionID {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// Runtime returns how long the job ran, or has been running so far.
+func Runtime(job shell.BackgroundJob) time.Duration {
+	end := job.CompletedAt
+	if !job.Done() {
+		end = time.Now()
+	}
+	return end.Sub(job.StartedAt).Round(time.Second)
+}
+
+// Status returns the icon and the description of the job's status.
+func Status(job shell.BackgroundJob) (lipgloss.Style, string) {
+	t := styles.CurrentTheme()
+	switch {
+	case !job.Done():
+		return t.ItemBusyIcon, fmt.Sprintf("running %s", Runtime(job))
+	case job.ExitCode != 0:
+		return t.ItemErrorIcon, fmt.Sprintf("exit %d after %s", job.ExitCode, Runtime(job))
+	default:
+		return t.ItemOnlineIcon, fmt.Sprintf("done in %s", Runtime(job))
+	}
+}
+
+// Title returns a one line title for the job.
+func Title(job shell.BackgroundJob) string {
+	title := job.Description
+	if title == "" {
+		title = job.Command
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// RenderJobList renders a list of background job status items with the
+// given options.
+func RenderJobList(jobs []shell.BackgroundJob, opts RenderOptions) []string {
+	t := styles.CurrentTheme()
+	jobList := []string{}
+
+	if opts.ShowSection {
+		sectionName := opts.SectionName
+		if sectionName == "" {
+			sectionName = "Jobs"
+		}
+		section := t.S().Subtle.Render(sectionName)
+		jobList = append(jobList, section, "")
+	}
+
+	if len(jobs) == 0 {
+		jobList = append(jobList, t.S().Base.Foreground(t.Border).Render("None"))
+		return jobList
+	}
+
+	// Determine how many items to show
+	maxItems := len(jobs)
+	if opts.MaxItems > 0 {
+		maxItems = min(opts.MaxItems, len(jobs))
+	}
+
+	for _, job := range jobs[:maxItems] {
+		icon, description := Status(job)
+		jobList = append(jobList,
+			core.Status(
+				core.StatusOpts{
+					Icon:        icon.String(),
+					Title:       Title(job),
+					Description: t.S().Subtle.Render(description),
+				},
+				opts.MaxWidth,
+			),
+		)
+	}
+
+	return jobList
+}
+
+// RenderJobBlock renders a complete background job block with optional
+// truncation indicator.
+func RenderJobBlock(jobs []shell.BackgroundJob, opts RenderOptions, showTruncationIndicator bool) string {
+	t := styles.CurrentTheme()
+	jobList := RenderJobList(jobs, opts)
+
+	// Add truncation indicator if needed
+	if showTruncationIndicator && opts.MaxItems > 0 && len(jobs) > opts.MaxItems {
+		remaining := len(jobs) - opts.MaxItems
+		if remaining == 1 {
+			jobList = append(jobList, t.S().Base.Foreground(t.FgMuted).Render("…"))
+		} else {
+			jobList = append(jobList,
+				t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("…and %d more", remaining)),
+			)
+		}
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Left, jobList...)
+	if opts.MaxWidth > 0 {
+		return lipgloss.NewStyle().Width(opts.MaxWidth).Render(content)
+	}
+	return content
+}
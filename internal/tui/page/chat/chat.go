@@ -34,6 +34,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/copilot"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/hyper"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/lspactions"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/reasoning"
 	"github.com/charmbracelet/crush/internal/tui/page"
@@ -217,6 +218,11 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		if p.compact {
 			msg.Y -= 1
 		}
+		if name, ok := p.lspAt(msg.X, msg.Y); ok {
+			return p, util.CmdHandler(dialogs.OpenDialogMsg{
+				Model: lspactions.NewLSPActionsDialog(name),
+			})
+		}
 		if p.isMouseOverChat(msg.X, msg.Y) {
 			p.focusedPane = PanelTypeChat
 			p.chat.Focus()
@@ -1411,6 +1417,19 @@ func (p *chatPage) IsChatFocused() bool {
 	return p.focusedPane == PanelTypeChat
 }
 
+// lspAt returns the LSP listed in the sidebar at the given coordinates, if
+// it has any actions available.
+func (p *chatPage) lspAt(x, y int) (string, bool) {
+	if p.compact || p.session.ID == "" {
+		return "", false
+	}
+	name, ok := p.sidebar.LSPAt(x-(p.width-SideBarWidth), y)
+	if !ok || len(lspactions.Actions(name)) == 0 {
+		return "", false
+	}
+	return name, true
+}
+
 // isMouseOverChat checks if the given mouse coordinates are within the chat area bounds.
 // Returns true if the mouse is over the chat area, false otherwise.
 func (p *chatPage) isMouseOverChat(x, y int) bool {
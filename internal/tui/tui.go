@@ -16,8 +16,11 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/event"
+	"github.com/charmbracelet/crush/internal/filetracker"
+	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/charmbracelet/crush/internal/stringext"
 	cmpChat "github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/splash"
@@ -28,7 +31,10 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
+	jobsdialog "github.com/charmbracelet/crush/internal/tui/components/dialogs/jobs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/lspactions"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissionlog"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissions"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
@@ -103,6 +109,11 @@ func (a appModel) Init() tea.Cmd {
 
 	cmd = a.status.Init()
 	cmds = append(cmds, cmd)
+	if sandbox := a.app.Config().Tools.Bash.ShellSandbox(a.app.Config().WorkingDir()); sandbox != nil {
+		if err := sandbox.Check(); err != nil {
+			cmds = append(cmds, util.ReportWarn("Bash commands won't run: "+err.Error()))
+		}
+	}
 	if a.QueryVersion {
 		cmds = append(cmds, tea.RequestTerminalVersion)
 	}
@@ -167,6 +178,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, handleMCPToolsEvent(context.Background(), msg.Payload.Name)
 		}
 
+	case pubsub.Event[filetracker.StaleFile]:
+		if msg.Payload.SessionID != a.selectedSessionID {
+			return a, nil
+		}
+		return a, util.ReportWarn(fmt.Sprintf("%s changed outside of Crush since the agent read it", fsext.PrettyPath(msg.Payload.Path)))
+
 	// Completions messages
 	case completions.OpenCompletionsMsg, completions.FilterCompletionsMsg,
 		completions.CloseCompletionsMsg, completions.RepositionCompletionsMsg:
@@ -248,6 +265,45 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case commands.OpenPermissionLogMsg:
+		return a, func() tea.Msg {
+			entries, err := a.app.PermissionLog.ListBySession(context.Background(), msg.SessionID)
+			if err != nil {
+				return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+			}
+			return dialogs.OpenDialogMsg{
+				Model: permissionlog.NewPermissionLogDialogCmp(entries),
+			}
+		}
+
+	case commands.OpenJobsMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: jobsdialog.NewJobsDialogCmp(),
+		})
+
+	case commands.ResetShellMsg:
+		shell.GetSessionShellManager().Reset(msg.SessionID)
+		return a, util.ReportInfo("Shell reset")
+
+	case lspactions.ActionMsg:
+		return a, func() tea.Msg {
+			ctx := context.Background()
+			var err error
+			var done string
+			switch msg.Action {
+			case lspactions.ActionStart:
+				err, done = a.app.StartLSP(ctx, msg.Name), "started"
+			case lspactions.ActionStop:
+				err, done = a.app.StopLSP(ctx, msg.Name), "stopped"
+			default:
+				err, done = a.app.RestartLSP(ctx, msg.Name), "restarted"
+			}
+			if err != nil {
+				return util.InfoMsg{Type: util.InfoTypeError, Msg: fmt.Sprintf("Failed to %s LSP %s: %s", msg.Action, msg.Name, err)}
+			}
+			return util.InfoMsg{Type: util.InfoTypeInfo, Msg: fmt.Sprintf("LSP %s %s", msg.Name, done)}
+		}
+
 	case commands.SwitchModelMsg:
 		return a, util.CmdHandler(
 			dialogs.OpenDialogMsg{
@@ -318,7 +374,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case pubsub.Event[permission.PermissionRequest]:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: permissions.NewPermissionDialogCmp(msg.Payload, &permissions.Options{
-				DiffMode: config.Get().Options.TUI.DiffMode,
+				DiffMode:   config.Get().Options.TUI.DiffMode,
+				WorkingDir: config.Get().WorkingDir(),
 			}),
 		})
 	case permissions.PermissionResponseMsg:
@@ -327,6 +384,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.app.Permissions.Grant(msg.Permission)
 		case permissions.PermissionAllowForSession:
 			a.app.Permissions.GrantPersistent(msg.Permission)
+		case permissions.PermissionAllowPattern:
+			a.app.Permissions.AddRules(msg.Rules...)
+			a.app.Permissions.Grant(msg.Permission)
+			if err := config.Get().AddPermissionRules(msg.Rules...); err != nil {
+				return a, util.ReportError(err)
+			}
 		case permissions.PermissionDeny:
 			a.app.Permissions.Deny(msg.Permission)
 		}
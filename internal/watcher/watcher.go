@@ -0,0 +1,272 @@
+// Package watcher watches the files of the workspace, honoring the ignore
+// files, and publishes their changes, so LSP servers and cached listings learn
+// about files changed outside of the tools, like by code generators.
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Op is the kind of a change.
+type Op int
+
+const (
+	Created Op = iota + 1
+	Changed
+	Deleted
+)
+
+// Change is a change to a file or directory of the workspace.
+type Change struct {
+	Path string
+	Op   Op
+}
+
+// Event holds the changes made to the workspace since the previous event.
+type Event struct {
+	Changes []Change
+}
+
+// batchDelay is how long the watcher waits for more changes before
+// publishing an event, as tools often change many files at once.
+const batchDelay = 100 * time.Millisecond
+
+type Service interface {
+	pubsub.Subscriber[Event]
+	// Watch watches the workspace until the context is done. Directory
+	// listings and glob results are cached meanwhile, see
+	// [fsext.CacheListings].
+	Watch(ctx context.Context) error
+}
+
+type service struct {
+	*pubsub.Broker[Event]
+	root string
+
+	mu      sync.Mutex
+	fs      *fsnotify.Watcher
+	walker  *fsext.FastGlobWalker
+	dirs    map[string]bool
+	pending map[string]Op
+	timer   *time.Timer
+}
+
+func NewService(root string) Service {
+	return &service{
+		Broker:  pubsub.NewBroker[Event](),
+		root:    root,
+		dirs:    make(map[string]bool),
+		pending: make(map[string]Op),
+	}
+}
+
+func (s *service) Watch(ctx context.Context) error {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	s.mu.Lock()
+	s.root = root
+	s.fs = watcher
+	s.walker = fsext.NewFastGlobWalker(root)
+	s.mu.Unlock()
+	s.addTree(root, false)
+	slog.Debug("Watching workspace", "root", root)
+
+	fsext.CacheListings(s.watched)
+	defer func() {
+		fsext.StopCachingListings()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fs = nil
+		clear(s.dirs)
+		if s.timer != nil {
+			s.timer.Stop()
+			s.timer = nil
+		}
+		clear(s.pending)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			s.handle(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Workspace watcher error", "error", err)
+		}
+	}
+}
+
+// watched reports whether the changes in the directory are watched.
+func (s *service) watched(dir string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirs[dir]
+}
+
+func (s *service) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return
+	}
+	path := event.Name
+	fsext.InvalidateListings(path)
+
+	s.mu.Lock()
+	if base := filepath.Base(path); base == ".gitignore" || base == ".crushignore" {
+		// The ignore rules are cached by the walker.
+		s.walker = fsext.NewFastGlobWalker(s.root)
+	}
+	if s.walker.ShouldSkip(path) {
+		s.mu.Unlock()
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Create):
+		s.record(path, Created)
+	case event.Has(fsnotify.Write):
+		s.record(path, Changed)
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		s.record(path, Deleted)
+		s.removeTree(path)
+	}
+	s.mu.Unlock()
+
+	if event.Has(fsnotify.Create) {
+		// Files can be created in a new directory before it's watched, so
+		// they are reported as it's added.
+		s.addTree(path, true)
+	}
+}
+
+// addTree watches the directory at the path and the directories under it
+// that aren't ignored. When report is set, the files and directories under
+// it are recorded as created. The tree is walked without holding the lock,
+// as it can be large.
+func (s *service) addTree(path string, report bool) {
+	s.mu.Lock()
+	walker := s.walker
+	s.mu.Unlock()
+
+	var dirs, created []string
+	_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != path && walker.ShouldSkip(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if report && p != path {
+			created = append(created, p)
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fs == nil {
+		// The watcher stopped meanwhile.
+		return
+	}
+	for _, p := range created {
+		s.record(p, Created)
+	}
+	for _, dir := range dirs {
+		if s.dirs[dir] {
+			continue
+		}
+		if err := s.fs.Add(dir); err != nil {
+			slog.Warn("Failed to watch directory", "dir", dir, "error", err)
+			continue
+		}
+		s.dirs[dir] = true
+	}
+}
+
+// removeTree stops watching the directory at the path and the directories
+// under it, if any.
+func (s *service) removeTree(path string) {
+	for dir := range s.dirs {
+		if dir != path && !strings.HasPrefix(dir, path+string(filepath.Separator)) {
+			continue
+		}
+		// Removed directories are no longer watched already, but renamed
+		// ones still are, under their old path.
+		_ = s.fs.Remove(dir)
+		delete(s.dirs, dir)
+	}
+}
+
+// record adds the change to the pending event, folding it with the previous
+// change of the same path.
+func (s *service) record(path string, op Op) {
+	switch prev, ok := s.pending[path]; {
+	case !ok:
+		s.pending[path] = op
+	case prev == Created && op == Deleted:
+		delete(s.pending, path)
+	case prev == Created:
+		// Still a new file.
+	case prev == Deleted && op == Created:
+		s.pending[path] = Changed
+	default:
+		s.pending[path] = op
+	}
+
+	if s.timer != nil {
+		s.timer.Reset(batchDelay)
+		return
+	}
+	s.timer = time.AfterFunc(batchDelay, s.flush)
+}
+
+// flush publishes the pending changes.
+func (s *service) flush() {
+	s.mu.Lock()
+	s.timer = nil
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	changes := make([]Change, 0, len(s.pending))
+	for path, op := range s.pending {
+		changes = append(changes, Change{Path: path, Op: op})
+	}
+	clear(s.pending)
+	s.mu.Unlock()
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	s.Publish(pubsub.UpdatedEvent, Event{Changes: changes})
+}
@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("ignored/\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "ignored"), 0o755))
+
+	w := NewService(root)
+	events := w.Subscribe(t.Context())
+	go w.Watch(t.Context())
+
+	// Changes before the watcher started are missed, so keep writing.
+	probe := filepath.Join(root, "probe.txt")
+	require.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(probe, []byte("probe"), 0o644))
+		select {
+		case <-events:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 2*batchDelay)
+	time.Sleep(2 * batchDelay)
+	for len(events) > 0 {
+		<-events
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "gen", "pkg"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "gen", "pkg", "a.go"), []byte("package pkg"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "ignored", "b.go"), []byte("package ignored"), 0o644))
+		require.NoError(t, os.Remove(probe))
+
+		changes := make(map[string]Op)
+		require.Eventually(t, func() bool {
+			select {
+			case event := <-events:
+				for _, change := range event.Payload.Changes {
+					rel, err := filepath.Rel(root, change.Path)
+					require.NoError(t, err)
+					changes[filepath.ToSlash(rel)] = change.Op
+				}
+			default:
+			}
+			return len(changes) >= 4
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Equal(t, map[string]Op{
+			"gen":          Created,
+			"gen/pkg":      Created,
+			"gen/pkg/a.go": Created,
+			"probe.txt":    Deleted,
+		}, changes)
+	})
+
+	t.Run("listings", func(t *testing.T) {
+		files, _, err := fsext.ListDirectory(root, nil, -1, -1)
+		require.NoError(t, err)
+		require.NotContains(t, files, filepath.Join(root, "c.go"))
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "c.go"), []byte("package c"), 0o644))
+		require.Eventually(t, func() bool {
+			files, _, err := fsext.ListDirectory(root, nil, -1, -1)
+			require.NoError(t, err)
+			return slices.Contains(files, filepath.Join(root, "c.go"))
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
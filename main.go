@@ -7,10 +7,19 @@ import (
 	"os"
 
 	"github.com/charmbracelet/crush/internal/cmd"
-	_ "github.com/joho/godotenv/autoload"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/joho/godotenv"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == shell.SandboxHelperCommand {
+		shell.RunSandboxHelper(os.Args[2:])
+	}
+
+	// Loaded after the sandbox helper has run, so sandboxed commands only
+	// get the environment of the shell that started them.
+	_ = godotenv.Load()
+
 	if os.Getenv("CRUSH_PROFILE") != "" {
 		go func() {
 			slog.Info("Serving pprof at localhost:6060")